@@ -0,0 +1,256 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package accounting
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/differential-privacy/go/checks"
+)
+
+// The ln of the probability mass of Gaussian noise that is truncated from each
+// side of the distribution when computing its privacy loss distribution. This
+// mass is accounted for pessimistically.
+const gaussianLogMassTruncationBound = -50
+
+// additiveNoise is the noise distribution μ of an additive noise mechanism,
+// which outputs the value of a function f plus noise drawn from μ. Its privacy
+// loss at x is ln(P(x) / P(x - sensitivity)), where P is the probability
+// density (or mass, for discrete noise) of μ; it must be non-increasing in x.
+type additiveNoise interface {
+	// privacyLossTail returns the range [lowerX, upperX] of x for which the
+	// privacy loss is computed by discretization, and the probability masses
+	// of the privacy losses outside of this range.
+	privacyLossTail() (lowerX, upperX float64, tailPMF map[float64]float64)
+	// privacyLoss returns the privacy loss at x.
+	privacyLoss(x float64) float64
+	// inversePrivacyLoss returns the largest x such that the privacy loss at x
+	// is at least privacyLoss.
+	inversePrivacyLoss(privacyLoss float64) float64
+	// cdf returns the probability that the noise is at most x.
+	cdf(x float64) float64
+}
+
+// newAdditiveNoisePLD returns the pessimistic privacy loss distribution of an
+// additive noise mechanism. If discrete is true, the noise only takes integer
+// values.
+func newAdditiveNoisePLD(noise additiveNoise, discrete bool, discretization float64) *PrivacyLossDistribution {
+	lowerX, upperX, tailPMF := noise.privacyLossTail()
+	roundedPMF := make(map[int]float64)
+	infinityMass := 0.0
+	for privacyLoss, mass := range tailPMF {
+		if math.IsInf(privacyLoss, 1) {
+			infinityMass += mass
+			continue
+		}
+		roundedPMF[int(math.Ceil(privacyLoss/discretization))] += mass
+	}
+
+	if discrete {
+		for x := math.Ceil(lowerX); x <= math.Floor(upperX); x++ {
+			roundedPMF[int(math.Ceil(noise.privacyLoss(x)/discretization))] += noise.cdf(x) - noise.cdf(x-1)
+		}
+		return newPLD(roundedPMF, discretization, infinityMass)
+	}
+
+	// Each x in [lower, upper] below has a privacy loss in
+	// [roundedDown*discretization, (roundedDown+1)*discretization], which is
+	// rounded up.
+	lower := lowerX
+	roundedDown := int(math.Floor(noise.privacyLoss(lower) / discretization))
+	for lower < upperX {
+		upper := math.Min(upperX, noise.inversePrivacyLoss(float64(roundedDown)*discretization))
+		if upper > lower {
+			roundedPMF[roundedDown+1] += noise.cdf(upper) - noise.cdf(lower)
+			lower = upper
+		}
+		roundedDown--
+	}
+	return newPLD(roundedPMF, discretization, infinityMass)
+}
+
+// laplaceNoise is Laplace noise with the given parameter (or scale) b, whose
+// probability density at x is exp(-|x|/b) / 2b.
+type laplaceNoise struct {
+	parameter, sensitivity float64
+}
+
+// NewLaplacePLD returns the privacy loss distribution of the Laplace
+// mechanism with the given parameter (or scale) b, for a function with the
+// given sensitivity. The Laplace mechanism is ε-differentially private for
+// b = sensitivity/ε.
+func NewLaplacePLD(parameter, sensitivity, discretization float64) (*PrivacyLossDistribution, error) {
+	if err := checkAdditiveNoiseParams("accounting.NewLaplacePLD", parameter, sensitivity, discretization); err != nil {
+		return nil, err
+	}
+	return newAdditiveNoisePLD(laplaceNoise{parameter: parameter, sensitivity: sensitivity}, false, discretization), nil
+}
+
+// When x <= 0, the privacy loss is sensitivity/b, and when x >= sensitivity,
+// it is -sensitivity/b.
+func (n laplaceNoise) privacyLossTail() (lowerX, upperX float64, tailPMF map[float64]float64) {
+	return 0, n.sensitivity, map[float64]float64{
+		n.sensitivity / n.parameter:  0.5,
+		-n.sensitivity / n.parameter: n.cdf(-n.sensitivity),
+	}
+}
+
+func (n laplaceNoise) privacyLoss(x float64) float64 {
+	return (math.Abs(x-n.sensitivity) - math.Abs(x)) / n.parameter
+}
+
+func (n laplaceNoise) inversePrivacyLoss(privacyLoss float64) float64 {
+	if privacyLoss > n.sensitivity/n.parameter {
+		return math.Inf(-1)
+	}
+	if privacyLoss <= -n.sensitivity/n.parameter {
+		return math.Inf(1)
+	}
+	return 0.5 * (n.sensitivity - privacyLoss*n.parameter)
+}
+
+func (n laplaceNoise) cdf(x float64) float64 {
+	if x < 0 {
+		return 0.5 * math.Exp(x/n.parameter)
+	}
+	return 1 - 0.5*math.Exp(-x/n.parameter)
+}
+
+// gaussianNoise is centered Gaussian noise with standard deviation sigma.
+type gaussianNoise struct {
+	sigma, sensitivity float64
+	// The ln of the probability mass that is truncated from each side of the
+	// distribution, usually gaussianLogMassTruncationBound.
+	logMassTruncationBound float64
+}
+
+// NewGaussianPLD returns the privacy loss distribution of the Gaussian
+// mechanism with standard deviation sigma, for a function with the given
+// (l2) sensitivity.
+func NewGaussianPLD(sigma, sensitivity, discretization float64) (*PrivacyLossDistribution, error) {
+	if err := checkAdditiveNoiseParams("accounting.NewGaussianPLD", sigma, sensitivity, discretization); err != nil {
+		return nil, err
+	}
+	return newAdditiveNoisePLD(gaussianNoise{
+		sigma:                  sigma,
+		sensitivity:            sensitivity,
+		logMassTruncationBound: gaussianLogMassTruncationBound,
+	}, false, discretization), nil
+}
+
+// The noise is truncated to [lowerX, upperX] = [-t, t], where the probability
+// of being below -t is exp(logMassTruncationBound)/2. The privacy
+// loss is rounded up to +∞ below -t, and to the privacy loss at t above t.
+func (n gaussianNoise) privacyLossTail() (lowerX, upperX float64, tailPMF map[float64]float64) {
+	tailMass := 0.5 * math.Exp(n.logMassTruncationBound)
+	lowerX = n.quantile(tailMass)
+	upperX = -lowerX
+	return lowerX, upperX, map[float64]float64{
+		math.Inf(1):           tailMass,
+		n.privacyLoss(upperX): tailMass,
+	}
+}
+
+func (n gaussianNoise) privacyLoss(x float64) float64 {
+	return 0.5 * n.sensitivity * (n.sensitivity - 2*x) / (n.sigma * n.sigma)
+}
+
+func (n gaussianNoise) inversePrivacyLoss(privacyLoss float64) float64 {
+	return 0.5*n.sensitivity - privacyLoss*n.sigma*n.sigma/n.sensitivity
+}
+
+func (n gaussianNoise) cdf(x float64) float64 {
+	return 0.5 * math.Erfc(-x/(n.sigma*math.Sqrt2))
+}
+
+// quantile returns the x such that cdf(x) = p, for p < 0.5. It uses a binary
+// search, since math.Erfinv is not precise enough for very small p.
+func (n gaussianNoise) quantile(p float64) float64 {
+	lower, upper := -40*n.sigma, 0.0
+	for i := 0; i < 200 && upper-lower > 1e-12*n.sigma; i++ {
+		mid := (lower + upper) / 2
+		if n.cdf(mid) < p {
+			lower = mid
+		} else {
+			upper = mid
+		}
+	}
+	return lower
+}
+
+// discreteLaplaceNoise is discrete Laplace noise with parameter a, whose
+// probability mass at an integer x is proportional to exp(-a|x|).
+type discreteLaplaceNoise struct {
+	parameter   float64
+	sensitivity int64
+}
+
+// NewDiscreteLaplacePLD returns the privacy loss distribution of the discrete
+// Laplace mechanism with parameter a, for an integer-valued function with the
+// given sensitivity. The discrete Laplace mechanism is ε-differentially
+// private for a = ε/sensitivity.
+func NewDiscreteLaplacePLD(parameter float64, sensitivity int64, discretization float64) (*PrivacyLossDistribution, error) {
+	if err := checkAdditiveNoiseParams("accounting.NewDiscreteLaplacePLD", parameter, float64(sensitivity), discretization); err != nil {
+		return nil, err
+	}
+	return newAdditiveNoisePLD(discreteLaplaceNoise{parameter: parameter, sensitivity: sensitivity}, true, discretization), nil
+}
+
+// When x <= 0, the privacy loss is sensitivity*a, and when x >= sensitivity,
+// it is -sensitivity*a.
+func (n discreteLaplaceNoise) privacyLossTail() (lowerX, upperX float64, tailPMF map[float64]float64) {
+	sensitivity := float64(n.sensitivity)
+	return 1, sensitivity - 1, map[float64]float64{
+		sensitivity * n.parameter:  n.cdf(0),
+		-sensitivity * n.parameter: n.cdf(-sensitivity),
+	}
+}
+
+func (n discreteLaplaceNoise) privacyLoss(x float64) float64 {
+	sensitivity := float64(n.sensitivity)
+	return (math.Abs(x-sensitivity) - math.Abs(x)) * n.parameter
+}
+
+func (n discreteLaplaceNoise) inversePrivacyLoss(privacyLoss float64) float64 {
+	sensitivity := float64(n.sensitivity)
+	if privacyLoss > sensitivity*n.parameter {
+		return math.Inf(-1)
+	}
+	if privacyLoss <= -sensitivity*n.parameter {
+		return math.Inf(1)
+	}
+	return math.Floor(0.5 * (sensitivity - privacyLoss/n.parameter))
+}
+
+func (n discreteLaplaceNoise) cdf(x float64) float64 {
+	x = math.Floor(x)
+	if x < 0 {
+		return math.Exp(n.parameter*(x+1)) / (math.Exp(n.parameter) + 1)
+	}
+	return 1 - math.Exp(-n.parameter*x)/(math.Exp(n.parameter)+1)
+}
+
+func checkAdditiveNoiseParams(label string, parameter, sensitivity, discretization float64) error {
+	if parameter <= 0 || math.IsInf(parameter, 0) || math.IsNaN(parameter) {
+		return fmt.Errorf("%s: parameter is %f, should be strictly positive (and cannot be infinity or NaN)", label, parameter)
+	}
+	if err := checks.CheckLInfSensitivity(label, sensitivity); err != nil {
+		return err
+	}
+	return checkDiscretization(label, discretization)
+}
@@ -0,0 +1,186 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package accounting
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestLaplacePLD(t *testing.T) {
+	for _, tc := range []struct {
+		parameter, sensitivity, discretization float64
+		want                                   map[int]float64
+	}{
+		{1, 1, 1, map[int]float64{1: 0.69673467, 0: 0.11932561, -1: 0.18393972}},
+		{3, 3, 1, map[int]float64{1: 0.69673467, 0: 0.11932561, -1: 0.18393972}},
+		{1, 2, 1, map[int]float64{2: 0.69673467, 1: 0.11932561, 0: 0.07237464, -1: 0.04389744, -2: 0.06766764}},
+		{2, 4, 1, map[int]float64{2: 0.69673467, 1: 0.11932561, 0: 0.07237464, -1: 0.04389744, -2: 0.06766764}},
+		{1, 1, 0.5, map[int]float64{2: 0.61059961, 1: 0.08613506, 0: 0.06708205, -1: 0.05224356, -2: 0.18393972}},
+		{1, 1, 0.3, map[int]float64{4: 0.52438529, 3: 0.06624934, 2: 0.05702133, 1: 0.04907872, 0: 0.04224244, -1: 0.03635841, -2: 0.03129397, -3: 0.19337051}},
+	} {
+		pld, err := NewLaplacePLD(tc.parameter, tc.sensitivity, tc.discretization)
+		if err != nil {
+			t.Fatalf("NewLaplacePLD(%f, %f, %f): got error %v", tc.parameter, tc.sensitivity, tc.discretization, err)
+		}
+		if diff := cmp.Diff(tc.want, roundedPMF(pld), cmpopts.EquateApprox(0, 1e-7)); diff != "" {
+			t.Errorf("NewLaplacePLD(%f, %f, %f): rounded PMF mismatch (-want +got):\n%s", tc.parameter, tc.sensitivity, tc.discretization, diff)
+		}
+		if pld.InfinityMass() != 0 {
+			t.Errorf("NewLaplacePLD(%f, %f, %f): got infinity mass %e, want 0", tc.parameter, tc.sensitivity, tc.discretization, pld.InfinityMass())
+		}
+	}
+}
+
+func TestLaplacePLDDelta(t *testing.T) {
+	for _, tc := range []struct {
+		parameter, sensitivity, epsilon, want float64
+	}{
+		{1, 1, 1, 0},
+		{2, 4, 2, 0},
+		{2, 4, 0.5, 0.52763345},
+		{1, 1, 0, 0.39346934},
+		{1, 1, -2, 0.86466472},
+	} {
+		pld, err := NewLaplacePLD(tc.parameter, tc.sensitivity, 1e-4)
+		if err != nil {
+			t.Fatalf("NewLaplacePLD(%f, %f): got error %v", tc.parameter, tc.sensitivity, err)
+		}
+		// The discretization only makes the estimate of δ pessimistic.
+		if got := pld.GetDeltaForEpsilon(tc.epsilon); got < tc.want-1e-7 || got > tc.want+1e-4 {
+			t.Errorf("NewLaplacePLD(%f, %f).GetDeltaForEpsilon(%f): got %f, want %f", tc.parameter, tc.sensitivity, tc.epsilon, got, tc.want)
+		}
+	}
+}
+
+func TestGaussianPLD(t *testing.T) {
+	// Truncates the Gaussian distribution outside of [-0.9σ, 0.9σ].
+	logMassTruncationBound := math.Log(2) + math.Log(0.5*math.Erfc(0.9/math.Sqrt2))
+	for _, tc := range []struct {
+		sigma, sensitivity, discretization float64
+		want                               map[int]float64
+	}{
+		{1, 1, 1, map[int]float64{2: 0.12447741, 1: 0.38292492, 0: 0.30853754}},
+		{5, 5, 1, map[int]float64{2: 0.12447741, 1: 0.38292492, 0: 0.30853754}},
+		{1, 2, 1, map[int]float64{1: 0.30853754, 2: 0.19146246, 3: 0.19146246, 4: 0.12447741}},
+		{1, 1, 0.5, map[int]float64{3: 0.12447741, 2: 0.19146246, 1: 0.19146246, 0: 0.30853754}},
+		{1, 1, 0.3, map[int]float64{5: 0.05790353, 4: 0.10261461, 3: 0.11559390, 2: 0.11908755, 1: 0.11220275, 0: 0.09668214, -1: 0.21185540}},
+	} {
+		noise := gaussianNoise{sigma: tc.sigma, sensitivity: tc.sensitivity, logMassTruncationBound: logMassTruncationBound}
+		pld := newAdditiveNoisePLD(noise, false, tc.discretization)
+		if diff := cmp.Diff(tc.want, roundedPMF(pld), cmpopts.EquateApprox(0, 1e-7)); diff != "" {
+			t.Errorf("newAdditiveNoisePLD(%+v, %f): rounded PMF mismatch (-want +got):\n%s", noise, tc.discretization, diff)
+		}
+		if want := 0.18406013; !approxEqual(pld.InfinityMass(), want) {
+			t.Errorf("newAdditiveNoisePLD(%+v, %f): got infinity mass %f, want %f", noise, tc.discretization, pld.InfinityMass(), want)
+		}
+	}
+}
+
+func TestGaussianPLDDelta(t *testing.T) {
+	for _, tc := range []struct {
+		sigma, sensitivity, epsilon, want float64
+	}{
+		{1, 1, 1, 0.12693674},
+		{2, 2, 1, 0.12693674},
+		{1, 3, 1, 0.78760074},
+		{1, 1, 2, 0.02092364},
+		{5, 5, 2, 0.02092364},
+	} {
+		pld, err := NewGaussianPLD(tc.sigma, tc.sensitivity, 1e-4)
+		if err != nil {
+			t.Fatalf("NewGaussianPLD(%f, %f): got error %v", tc.sigma, tc.sensitivity, err)
+		}
+		// The discretization only makes the estimate of δ pessimistic.
+		if got := pld.GetDeltaForEpsilon(tc.epsilon); got < tc.want-1e-7 || got > tc.want+1e-4 {
+			t.Errorf("NewGaussianPLD(%f, %f).GetDeltaForEpsilon(%f): got %f, want %f", tc.sigma, tc.sensitivity, tc.epsilon, got, tc.want)
+		}
+	}
+}
+
+func TestDiscreteLaplacePLD(t *testing.T) {
+	for _, tc := range []struct {
+		parameter      float64
+		sensitivity    int64
+		discretization float64
+		want           map[int]float64
+	}{
+		{1, 1, 1, map[int]float64{1: 0.73105858, -1: 0.26894142}},
+		{1, 2, 1, map[int]float64{2: 0.73105858, 0: 0.17000340, -2: 0.09893802}},
+		{0.8, 2, 1, map[int]float64{2: 0.68997448, 0: 0.17072207, -1: 0.13930345}},
+		{0.8, 3, 1, map[int]float64{3: 0.68997448, 1: 0.17072207, 0: 0.07671037, -2: 0.06259307}},
+		{1, 2, 0.7, map[int]float64{3: 0.73105858, 0: 0.17000340, -2: 0.09893802}},
+		{1, 2, 2.2, map[int]float64{1: 0.73105858, 0: 0.26894142}},
+	} {
+		pld, err := NewDiscreteLaplacePLD(tc.parameter, tc.sensitivity, tc.discretization)
+		if err != nil {
+			t.Fatalf("NewDiscreteLaplacePLD(%f, %d, %f): got error %v", tc.parameter, tc.sensitivity, tc.discretization, err)
+		}
+		if diff := cmp.Diff(tc.want, roundedPMF(pld), cmpopts.EquateApprox(0, 1e-7)); diff != "" {
+			t.Errorf("NewDiscreteLaplacePLD(%f, %d, %f): rounded PMF mismatch (-want +got):\n%s", tc.parameter, tc.sensitivity, tc.discretization, diff)
+		}
+	}
+}
+
+func TestDiscreteLaplacePLDDelta(t *testing.T) {
+	for _, tc := range []struct {
+		parameter   float64
+		sensitivity int64
+		epsilon     float64
+		want        float64
+	}{
+		{1, 1, 1, 0},
+		{0.5, 4, 2, 0},
+		{0.5, 4, 0.5, 0.54202002},
+		{0.5, 4, 1, 0.39346934},
+		{0.5, 4, -0.5, 0.72222110},
+	} {
+		pld, err := NewDiscreteLaplacePLD(tc.parameter, tc.sensitivity, 1)
+		if err != nil {
+			t.Fatalf("NewDiscreteLaplacePLD(%f, %d): got error %v", tc.parameter, tc.sensitivity, err)
+		}
+		if got := pld.GetDeltaForEpsilon(tc.epsilon); !approxEqual(got, tc.want) {
+			t.Errorf("NewDiscreteLaplacePLD(%f, %d).GetDeltaForEpsilon(%f): got %f, want %f", tc.parameter, tc.sensitivity, tc.epsilon, got, tc.want)
+		}
+	}
+}
+
+func TestAdditiveNoisePLDInvalidParameters(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		err  error
+	}{
+		{"Laplace with zero parameter", errOf(NewLaplacePLD(0, 1, 1e-4))},
+		{"Laplace with negative sensitivity", errOf(NewLaplacePLD(1, -1, 1e-4))},
+		{"Laplace with zero discretization", errOf(NewLaplacePLD(1, 1, 0))},
+		{"Gaussian with negative sigma", errOf(NewGaussianPLD(-1, 1, 1e-4))},
+		{"Gaussian with infinite sensitivity", errOf(NewGaussianPLD(1, math.Inf(1), 1e-4))},
+		{"discrete Laplace with NaN parameter", errOf(NewDiscreteLaplacePLD(math.NaN(), 1, 1e-4))},
+		{"discrete Laplace with zero sensitivity", errOf(NewDiscreteLaplacePLD(1, 0, 1e-4))},
+	} {
+		if tc.err == nil {
+			t.Errorf("%s: got no error", tc.desc)
+		}
+	}
+}
+
+func errOf(_ *PrivacyLossDistribution, err error) error {
+	return err
+}
@@ -0,0 +1,112 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package accounting
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// Above this number of multiplications, convolutions are computed with a fast
+// Fourier transform instead of directly.
+const directConvolutionMaxOperations = 1 << 16
+
+// convolve returns the convolution of a and b: the slice c of length
+// len(a)+len(b)-1 such that c[k] is the sum of a[i]*b[j] over all i, j with
+// i+j = k. Negative values in the result, which can only come from rounding
+// errors since a and b are probability masses, are set to 0.
+func convolve(a, b []float64) []float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+	n := len(a) + len(b) - 1
+	if len(a)*len(b) <= directConvolutionMaxOperations {
+		c := make([]float64, n)
+		for i, x := range a {
+			for j, y := range b {
+				c[i+j] += x * y
+			}
+		}
+		return c
+	}
+	size := 1 << bits.Len(uint(n-1))
+	fa := toComplex(a, size)
+	fb := toComplex(b, size)
+	fft(fa, false)
+	fft(fb, false)
+	for i := range fa {
+		fa[i] *= fb[i]
+	}
+	fft(fa, true)
+	c := make([]float64, n)
+	for i := range c {
+		c[i] = math.Max(0, real(fa[i]))
+	}
+	return c
+}
+
+func toComplex(x []float64, size int) []complex128 {
+	c := make([]complex128, size)
+	for i, v := range x {
+		c[i] = complex(v, 0)
+	}
+	return c
+}
+
+// fft computes the discrete Fourier transform of x in place, or its inverse
+// if inverse is true. The length of x must be a power of two.
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	// Bit-reversal permutation.
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	// The twiddle factors are computed directly rather than by repeated
+	// multiplication, to avoid accumulating rounding errors.
+	twiddles := make([]complex128, n/2)
+	for k := range twiddles {
+		twiddles[k] = cmplx.Rect(1, sign*2*math.Pi*float64(k)/float64(n))
+	}
+	for length := 2; length <= n; length <<= 1 {
+		step := n / length
+		for start := 0; start < n; start += length {
+			for k := 0; k < length/2; k++ {
+				u := x[start+k]
+				v := x[start+k+length/2] * twiddles[k*step]
+				x[start+k] = u + v
+				x[start+k+length/2] = u - v
+			}
+		}
+	}
+	if inverse {
+		for i := range x {
+			x[i] /= complex(float64(n), 0)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package accounting
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestConvolve(t *testing.T) {
+	for _, tc := range []struct {
+		a, b, want []float64
+	}{
+		{a: []float64{2, 0, 4}, b: []float64{3, 0, 6}, want: []float64{6, 0, 24, 0, 24}},
+		{a: []float64{1}, b: []float64{0.5, 0.5}, want: []float64{0.5, 0.5}},
+		{a: nil, b: []float64{1}, want: nil},
+	} {
+		if diff := cmp.Diff(tc.want, convolve(tc.a, tc.b), cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+			t.Errorf("convolve(%v, %v): mismatch (-want +got):\n%s", tc.a, tc.b, diff)
+		}
+	}
+}
+
+// Checks that the fast Fourier transform gives the same result as the direct
+// convolution for large inputs.
+func TestConvolveFFT(t *testing.T) {
+	a := make([]float64, 1000)
+	b := make([]float64, 300)
+	for i := range a {
+		a[i] = float64(i%7) / 3000
+	}
+	for i := range b {
+		b[i] = float64(i%5) / 600
+	}
+	if len(a)*len(b) <= directConvolutionMaxOperations {
+		t.Fatalf("inputs are too small to use the fast Fourier transform")
+	}
+	want := make([]float64, len(a)+len(b)-1)
+	for i, x := range a {
+		for j, y := range b {
+			want[i+j] += x * y
+		}
+	}
+	if diff := cmp.Diff(want, convolve(a, b), cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+		t.Errorf("convolve: mismatch with the direct convolution (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,247 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package accounting provides tools for tracking the privacy budget of
+// differentially private mechanisms under composition.
+//
+// It implements privacy loss distributions (PLDs), which give an accurate
+// estimate of the total (ε,δ) of the composition of several mechanisms, much
+// tighter than the sum of their ε and δ. See the Python implementation and its
+// supplementary material in the accounting directory of the repository for
+// more details.
+package accounting
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/differential-privacy/go/checks"
+)
+
+// DefaultDiscretizationInterval is the default interval to which the values
+// of privacy loss distributions are rounded.
+const DefaultDiscretizationInterval = 1e-4
+
+// After composition, probability masses adding up to less than this bound at
+// each end of a privacy loss distribution are merged into neighbouring values,
+// to keep the distribution small. This is done in a pessimistic way.
+const tailMassTruncationBound = 1e-15
+
+// PrivacyLossDistribution is the privacy loss distribution (PLD) of a
+// mechanism: the distribution of the privacy loss ln(P(o) / P'(o)), where o is
+// an outcome of the mechanism on some input, drawn with probability P(o), and
+// P'(o) is the probability of o for a neighbouring input.
+//
+// Values of the privacy loss are rounded up to an integer multiple of a
+// discretization interval, so that the (ε,δ) computed from the distribution
+// are pessimistic estimates: the mechanism is always (ε,δ)-differentially
+// private for the returned values.
+type PrivacyLossDistribution struct {
+	discretization float64
+	// pmf[i] is the probability mass of the privacy loss
+	// (offset+i)*discretization.
+	offset int
+	pmf    []float64
+	// Probability mass of the outcomes with an infinite privacy loss, i.e. that
+	// can only occur for one of the two neighbouring inputs.
+	infinityMass float64
+}
+
+// newPLD returns a PrivacyLossDistribution from a map of rounded privacy loss
+// values to probability masses.
+func newPLD(roundedPMF map[int]float64, discretization, infinityMass float64) *PrivacyLossDistribution {
+	pld := &PrivacyLossDistribution{discretization: discretization, infinityMass: infinityMass}
+	if len(roundedPMF) == 0 {
+		return pld
+	}
+	min, max := math.MaxInt64, math.MinInt64
+	for i := range roundedPMF {
+		if i < min {
+			min = i
+		}
+		if i > max {
+			max = i
+		}
+	}
+	pld.offset = min
+	pld.pmf = make([]float64, max-min+1)
+	for i, mass := range roundedPMF {
+		pld.pmf[i-min] += mass
+	}
+	return pld
+}
+
+func checkDiscretization(label string, discretization float64) error {
+	if discretization <= 0 || math.IsInf(discretization, 0) || math.IsNaN(discretization) {
+		return fmt.Errorf("%s: discretization interval is %f, should be strictly positive (and cannot be infinity or NaN)", label, discretization)
+	}
+	return nil
+}
+
+// NewPLDFromPrivacyParameters returns the privacy loss distribution of a
+// generic (ε,δ)-differentially private mechanism. It is the worst case PLD of
+// all such mechanisms, so composing it with other PLDs gives valid guarantees
+// for any (ε,δ)-differentially private mechanism.
+func NewPLDFromPrivacyParameters(epsilon, delta, discretization float64) (*PrivacyLossDistribution, error) {
+	if err := checks.CheckEpsilon("accounting.NewPLDFromPrivacyParameters", epsilon); err != nil {
+		return nil, err
+	}
+	if err := checks.CheckDelta("accounting.NewPLDFromPrivacyParameters", delta); err != nil {
+		return nil, err
+	}
+	if err := checkDiscretization("accounting.NewPLDFromPrivacyParameters", discretization); err != nil {
+		return nil, err
+	}
+	// The dominating pair of distributions of (ε,δ)-differential privacy: an
+	// outcome with infinite privacy loss with probability δ, and randomized
+	// response with privacy loss ±ε otherwise.
+	roundedPMF := make(map[int]float64)
+	roundedPMF[int(math.Ceil(epsilon/discretization))] += (1 - delta) * math.Exp(epsilon) / (1 + math.Exp(epsilon))
+	roundedPMF[int(math.Ceil(-epsilon/discretization))] += (1 - delta) / (1 + math.Exp(epsilon))
+	return newPLD(roundedPMF, discretization, delta), nil
+}
+
+// DiscretizationInterval returns the interval to which the values of the
+// privacy loss are rounded.
+func (pld *PrivacyLossDistribution) DiscretizationInterval() float64 {
+	return pld.discretization
+}
+
+// InfinityMass returns the probability mass of an infinite privacy loss.
+func (pld *PrivacyLossDistribution) InfinityMass() float64 {
+	return pld.infinityMass
+}
+
+// Compose returns the privacy loss distribution of the composition of the
+// mechanisms of pld and other. Both distributions must have the same
+// discretization interval.
+func (pld *PrivacyLossDistribution) Compose(other *PrivacyLossDistribution) (*PrivacyLossDistribution, error) {
+	if pld.discretization != other.discretization {
+		return nil, fmt.Errorf("accounting.Compose: discretization intervals are different: %e and %e", pld.discretization, other.discretization)
+	}
+	result := &PrivacyLossDistribution{
+		discretization: pld.discretization,
+		offset:         pld.offset + other.offset,
+		pmf:            convolve(pld.pmf, other.pmf),
+		infinityMass:   pld.infinityMass + other.infinityMass - pld.infinityMass*other.infinityMass,
+	}
+	result.truncateTails()
+	return result, nil
+}
+
+// SelfCompose returns the privacy loss distribution of the composition of
+// numTimes runs of the mechanism of pld.
+func (pld *PrivacyLossDistribution) SelfCompose(numTimes int) (*PrivacyLossDistribution, error) {
+	if numTimes <= 0 {
+		return nil, fmt.Errorf("accounting.SelfCompose: numTimes is %d, should be strictly positive", numTimes)
+	}
+	// Exponentiation by squaring.
+	var result *PrivacyLossDistribution
+	square := pld
+	for {
+		var err error
+		if numTimes%2 == 1 {
+			if result == nil {
+				result = square
+			} else if result, err = result.Compose(square); err != nil {
+				return nil, err
+			}
+		}
+		numTimes /= 2
+		if numTimes == 0 {
+			return result, nil
+		}
+		if square, err = square.Compose(square); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// truncateTails merges the probability mass at both ends of the distribution
+// that adds up to less than tailMassTruncationBound. The mass of the smallest
+// privacy losses is moved up to the smallest remaining privacy loss, and the
+// mass of the largest privacy losses is moved to the infinity mass. This can
+// only increase the privacy loss.
+func (pld *PrivacyLossDistribution) truncateTails() {
+	lower, lowerMass := 0, 0.0
+	for lower < len(pld.pmf) && lowerMass+pld.pmf[lower] < tailMassTruncationBound {
+		lowerMass += pld.pmf[lower]
+		lower++
+	}
+	upper, upperMass := len(pld.pmf), 0.0
+	for upper > lower && upperMass+pld.pmf[upper-1] < tailMassTruncationBound {
+		upperMass += pld.pmf[upper-1]
+		upper--
+	}
+	pld.infinityMass += upperMass
+	if lower == upper {
+		pld.infinityMass += lowerMass
+		pld.pmf = nil
+		return
+	}
+	pld.pmf[lower] += lowerMass
+	pld.pmf = pld.pmf[lower:upper]
+	pld.offset += lower
+}
+
+// GetDeltaForEpsilon returns the smallest δ such that the mechanism of pld is
+// (ε,δ)-differentially private. This is the ε-hockey stick divergence of the
+// two distributions of the mechanism.
+func (pld *PrivacyLossDistribution) GetDeltaForEpsilon(epsilon float64) float64 {
+	// The divergence is the sum, over all privacy loss values v > ε, of the
+	// probability mass of v times 1 - e^(ε-v), plus the infinity mass.
+	delta := pld.infinityMass
+	for i, mass := range pld.pmf {
+		v := float64(pld.offset+i) * pld.discretization
+		if v > epsilon && mass > 0 {
+			delta += (1 - math.Exp(epsilon-v)) * mass
+		}
+	}
+	return delta
+}
+
+// GetEpsilonForDelta returns the smallest ε such that the mechanism of pld is
+// (ε,δ)-differentially private. It returns +∞ if there is no such ε, i.e. if
+// δ is smaller than the infinity mass.
+func (pld *PrivacyLossDistribution) GetEpsilonForDelta(delta float64) float64 {
+	if pld.infinityMass > delta {
+		return math.Inf(1)
+	}
+	// For ε between two consecutive privacy loss values, the divergence is
+	// massUpper - e^ε * massLower, where massUpper is the mass of the larger
+	// privacy losses v, and massLower is the sum of their mass times e^(-v).
+	// Going through the values in decreasing order, we find the interval where
+	// it is equal to δ.
+	massUpper, massLower := pld.infinityMass, 0.0
+	for i := len(pld.pmf) - 1; i >= 0; i-- {
+		if pld.pmf[i] == 0 {
+			continue
+		}
+		v := float64(pld.offset+i) * pld.discretization
+		if massUpper > delta && massLower > 0 && math.Log((massUpper-delta)/massLower) >= v {
+			break
+		}
+		massUpper += pld.pmf[i]
+		massLower += pld.pmf[i] * math.Exp(-v)
+		if massUpper >= delta && massLower == 0 {
+			return math.Max(0, v)
+		}
+	}
+	if massUpper <= massLower+delta {
+		return 0
+	}
+	return math.Log((massUpper - delta) / massLower)
+}
@@ -0,0 +1,206 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package accounting
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// roundedPMF returns the probability masses of pld as a map from the rounded
+// privacy loss values to their non-zero mass.
+func roundedPMF(pld *PrivacyLossDistribution) map[int]float64 {
+	m := make(map[int]float64)
+	for i, mass := range pld.pmf {
+		if mass != 0 {
+			m[pld.offset+i] = mass
+		}
+	}
+	return m
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-7
+}
+
+func TestNewPLDFromPrivacyParameters(t *testing.T) {
+	pld, err := NewPLDFromPrivacyParameters(math.Log(3), 0.1, 0.5)
+	if err != nil {
+		t.Fatalf("NewPLDFromPrivacyParameters: got error %v", err)
+	}
+	// ln(3) ≈ 1.0986 is rounded up to 1.5, and -ln(3) to -1.
+	want := map[int]float64{3: 0.675, -2: 0.225}
+	if diff := cmp.Diff(want, roundedPMF(pld), cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("NewPLDFromPrivacyParameters: rounded PMF mismatch (-want +got):\n%s", diff)
+	}
+	if pld.InfinityMass() != 0.1 {
+		t.Errorf("NewPLDFromPrivacyParameters: got infinity mass %f, want 0.1", pld.InfinityMass())
+	}
+	if got := pld.DiscretizationInterval(); got != 0.5 {
+		t.Errorf("NewPLDFromPrivacyParameters: got discretization interval %f, want 0.5", got)
+	}
+
+	for _, tc := range []struct {
+		epsilon, delta, discretization float64
+	}{
+		{-1, 0, 1e-4},
+		{math.Inf(1), 0, 1e-4},
+		{1, -0.1, 1e-4},
+		{1, 1, 1e-4},
+		{1, 0, 0},
+		{1, 0, math.NaN()},
+	} {
+		if _, err := NewPLDFromPrivacyParameters(tc.epsilon, tc.delta, tc.discretization); err == nil {
+			t.Errorf("NewPLDFromPrivacyParameters(%f, %e, %e): got no error", tc.epsilon, tc.delta, tc.discretization)
+		}
+	}
+}
+
+// Checks that the PLD of an (ε,δ)-differentially private mechanism gives back
+// ε and δ.
+func TestPLDFromPrivacyParametersRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		epsilon, delta float64
+	}{
+		{1, 0},
+		{0.5, 1e-5},
+		{2, 0.01},
+	} {
+		pld, err := NewPLDFromPrivacyParameters(tc.epsilon, tc.delta, DefaultDiscretizationInterval)
+		if err != nil {
+			t.Fatalf("NewPLDFromPrivacyParameters(%f, %e): got error %v", tc.epsilon, tc.delta, err)
+		}
+		if got := pld.GetDeltaForEpsilon(tc.epsilon); !approxEqual(got, tc.delta) {
+			t.Errorf("GetDeltaForEpsilon(%f): got %e, want %e", tc.epsilon, got, tc.delta)
+		}
+		if got := pld.GetEpsilonForDelta(tc.delta); math.Abs(got-tc.epsilon) > DefaultDiscretizationInterval {
+			t.Errorf("GetEpsilonForDelta(%e): got %f, want %f", tc.delta, got, tc.epsilon)
+		}
+	}
+}
+
+func TestCompose(t *testing.T) {
+	pld1 := newPLD(map[int]float64{1: 0.5, -1: 0.4}, 1, 0.1)
+	pld2 := newPLD(map[int]float64{2: 0.3, 0: 0.7}, 1, 0)
+	got, err := pld1.Compose(pld2)
+	if err != nil {
+		t.Fatalf("Compose: got error %v", err)
+	}
+	want := map[int]float64{3: 0.15, 1: 0.47, -1: 0.28}
+	if diff := cmp.Diff(want, roundedPMF(got), cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("Compose: rounded PMF mismatch (-want +got):\n%s", diff)
+	}
+	if !approxEqual(got.InfinityMass(), 0.1) {
+		t.Errorf("Compose: got infinity mass %f, want 0.1", got.InfinityMass())
+	}
+
+	if _, err := pld1.Compose(newPLD(map[int]float64{0: 1}, 0.5, 0)); err == nil {
+		t.Errorf("Compose: got no error for different discretization intervals")
+	}
+}
+
+func TestSelfCompose(t *testing.T) {
+	pld := newPLD(map[int]float64{1: 0.5, -1: 0.5}, 1, 0)
+	got, err := pld.SelfCompose(3)
+	if err != nil {
+		t.Fatalf("SelfCompose: got error %v", err)
+	}
+	want := map[int]float64{3: 0.125, 1: 0.375, -1: 0.375, -3: 0.125}
+	if diff := cmp.Diff(want, roundedPMF(got), cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("SelfCompose: rounded PMF mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := pld.SelfCompose(0); err == nil {
+		t.Errorf("SelfCompose(0): got no error")
+	}
+}
+
+// Checks that the truncated tails are merged pessimistically.
+func TestTruncateTails(t *testing.T) {
+	pld := newPLD(map[int]float64{-2: 1e-16, 0: 0.5, 1: 0.5 - 2e-16, 5: 1e-16}, 1, 0)
+	pld.truncateTails()
+	want := map[int]float64{0: 0.5 + 1e-16, 1: 0.5 - 2e-16}
+	if diff := cmp.Diff(want, roundedPMF(pld)); diff != "" {
+		t.Errorf("truncateTails: rounded PMF mismatch (-want +got):\n%s", diff)
+	}
+	if pld.InfinityMass() != 1e-16 {
+		t.Errorf("truncateTails: got infinity mass %e, want 1e-16", pld.InfinityMass())
+	}
+}
+
+func TestGetDeltaForEpsilon(t *testing.T) {
+	pld := newPLD(map[int]float64{2: 0.5, 1: 0.2, -1: 0.2}, 1, 0.1)
+	for _, tc := range []struct {
+		epsilon, want float64
+	}{
+		{epsilon: 3, want: 0.1},
+		{epsilon: 1.5, want: 0.1 + 0.5*(1-math.Exp(-0.5))},
+		{epsilon: 0, want: 0.1 + 0.5*(1-math.Exp(-2)) + 0.2*(1-math.Exp(-1))},
+	} {
+		if got := pld.GetDeltaForEpsilon(tc.epsilon); !approxEqual(got, tc.want) {
+			t.Errorf("GetDeltaForEpsilon(%f): got %f, want %f", tc.epsilon, got, tc.want)
+		}
+	}
+}
+
+// Checks that GetEpsilonForDelta is the inverse of GetDeltaForEpsilon.
+func TestGetEpsilonForDelta(t *testing.T) {
+	pld, err := NewLaplacePLD(1, 1, 1e-3)
+	if err != nil {
+		t.Fatalf("NewLaplacePLD: got error %v", err)
+	}
+	if pld, err = pld.SelfCompose(10); err != nil {
+		t.Fatalf("SelfCompose: got error %v", err)
+	}
+	for _, delta := range []float64{1e-1, 1e-3, 1e-6, 1e-10} {
+		epsilon := pld.GetEpsilonForDelta(delta)
+		if got := pld.GetDeltaForEpsilon(epsilon); !approxEqual(got, delta) {
+			t.Errorf("GetDeltaForEpsilon(GetEpsilonForDelta(%e)): got %e, want %e", delta, got, delta)
+		}
+		if epsilon < 0 || epsilon > 10 {
+			t.Errorf("GetEpsilonForDelta(%e): got %f, want a value in [0, 10]", delta, epsilon)
+		}
+	}
+	if got := pld.GetEpsilonForDelta(1); got != 0 {
+		t.Errorf("GetEpsilonForDelta(1): got %f, want 0", got)
+	}
+	if got := newPLD(map[int]float64{1: 0.9}, 1, 0.1).GetEpsilonForDelta(0.05); !math.IsInf(got, 1) {
+		t.Errorf("GetEpsilonForDelta with a delta smaller than the infinity mass: got %f, want +Inf", got)
+	}
+}
+
+// Checks that composing Laplace mechanisms with PLDs gives a smaller ε than
+// adding their ε, and a larger ε than advanced composition would allow to
+// ignore.
+func TestComposedLaplaceEpsilon(t *testing.T) {
+	const numTimes = 100
+	pld, err := NewLaplacePLD(1, 0.1, DefaultDiscretizationInterval)
+	if err != nil {
+		t.Fatalf("NewLaplacePLD: got error %v", err)
+	}
+	if pld, err = pld.SelfCompose(numTimes); err != nil {
+		t.Fatalf("SelfCompose: got error %v", err)
+	}
+	const delta = 1e-5
+	// Advanced composition: ε' = √(2k ln(1/δ)) ε + k ε (e^ε - 1).
+	advanced := math.Sqrt(2*numTimes*math.Log(1/delta))*0.1 + numTimes*0.1*(math.Exp(0.1)-1)
+	if got := pld.GetEpsilonForDelta(delta); got >= advanced || got <= 0 {
+		t.Errorf("GetEpsilonForDelta(%e): got %f, want a value in (0, %f)", delta, got, advanced)
+	}
+}
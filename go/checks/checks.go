@@ -84,6 +84,15 @@ func CheckNoDelta(label string, delta float64) error {
 	return nil
 }
 
+// CheckAlpha returns an error if α (the probability that a confidence interval
+// doesn't contain the raw value) is not strictly between 0 and 1.
+func CheckAlpha(label string, alpha float64) error {
+	if math.IsNaN(alpha) || alpha <= 0 || alpha >= 1 {
+		return fmt.Errorf("%s: Alpha is %f, should be strictly between 0 and 1", label, alpha)
+	}
+	return nil
+}
+
 // CheckL0Sensitivity returns an error if l0Sensitivity is nonpositive.
 func CheckL0Sensitivity(label string, l0Sensitivity int64) error {
 	if l0Sensitivity <= 0 {
@@ -0,0 +1,760 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/go/rand"
+)
+
+const (
+	// smallestNormalFloat64 is the smallest positive normal float64, and the
+	// default scale of ApproxBounds.
+	smallestNormalFloat64   = 0x1p-1022
+	defaultApproxBoundsBase = 2.0
+	// defaultSuccessProbability is the default probability that ApproxBounds
+	// does not select a bin that was empty before noise addition.
+	defaultSuccessProbability = 1 - 1e-9
+)
+
+// ApproxBounds finds approximate bounds of a collection of float64 values in a
+// differentially private way, using logarithmic histogram bins. It can be used
+// to determine clamping bounds for other aggregations when no sensible bounds
+// are known in advance. It is a port of cc/algorithms/approx-bounds.h.
+//
+// Two histograms of numBins bins each are built: one for positive and one for
+// negative entries. Positive bin i contains the entries in
+// (scale * base^(i-1), scale * base^i], except bin 0, which contains the
+// entries in [0, scale]. Negative bins are symmetrical, and negative bin 0 does
+// not contain 0. Entries larger in magnitude than the largest boundary are put
+// in the last bins.
+//
+// To compute the result, noise is added to each bin count, and a threshold is
+// derived from the success probability, i.e. the probability that no bin that
+// was empty before noise addition is above the threshold. The approximate
+// minimum is the smaller boundary of the leftmost bin whose noisy count is at
+// least the threshold, and the approximate maximum is the larger boundary of
+// the rightmost such bin. If no bin reaches the threshold, Result returns an
+// error.
+//
+// For example, with scale = 1, base = 2, numBins = 4 and entries
+// {0, 0, 0, 0, 1, 3, 7, 8, 8, 8}, the bin counts are:
+//
+//	[0, 1]: 5
+//	(1, 2]: 0
+//	(2, 4]: 1
+//	(4, 8]: 4
+//
+// With a threshold of 3.5, the result is [0, 8].
+//
+// ApproxBounds always uses Laplace noise. It supports scaling the noise in the
+// case where users can contribute to multiple partitions (via the
+// MaxPartitionsContributed parameter) and can contribute to a single partition
+// multiple times (via the MaxContributionsPerPartition parameter).
+//
+// Not thread-safe.
+type ApproxBounds struct {
+	// Parameters
+	epsilon         float64
+	l0Sensitivity   int64
+	lInfSensitivity int64
+	scale           float64
+	base            float64
+	threshold       float64
+	// binBoundaries[i] is the larger boundary of positive bin i.
+	binBoundaries []float64
+	noise         noise.Noise
+
+	// State variables
+	posBins        []int64
+	negBins        []int64
+	resultReturned bool // whether the result has already been returned
+}
+
+func abEquallyInitialized(ab1, ab2 *ApproxBounds) bool {
+	if ab1 == nil || ab2 == nil {
+		return ab1 == ab2
+	}
+	return ab1.epsilon == ab2.epsilon &&
+		ab1.l0Sensitivity == ab2.l0Sensitivity &&
+		ab1.lInfSensitivity == ab2.lInfSensitivity &&
+		ab1.scale == ab2.scale &&
+		ab1.base == ab2.base &&
+		ab1.threshold == ab2.threshold &&
+		len(ab1.binBoundaries) == len(ab2.binBoundaries)
+}
+
+// ApproxBoundsOptions contains the options necessary to initialize an ApproxBounds.
+type ApproxBoundsOptions struct {
+	Epsilon                      float64 // Privacy parameter ε. Required.
+	MaxPartitionsContributed     int64   // How many distinct partitions may a single user contribute to? Defaults to 1.
+	MaxContributionsPerPartition int64   // How many times may a single user contribute to a single partition? Defaults to 1.
+	// Smallest bin boundary. Must be positive. Defaults to the smallest positive
+	// normal float64.
+	Scale float64
+	// Ratio between consecutive bin boundaries. Must be greater than 1. Defaults to 2.
+	Base float64
+	// Number of bins of each of the positive and negative histograms. Defaults
+	// to the number of bins needed to cover all float64 values.
+	NumBins int64
+	// Probability that no bin that was empty before noise addition is above the
+	// threshold. Must be in (0, 1). Defaults to 1-10⁻⁹. Increasing it increases
+	// the threshold, and ApproxBounds might then fail to find bounds for small
+	// collections of entries. Ignored if Threshold is set.
+	SuccessProbability float64
+	// Minimum noisy count of a bin for it to be used as a bound. Must be
+	// non-negative. If 0, the threshold is derived from SuccessProbability.
+	Threshold float64
+	// Source of the randomness of the Laplace noise added to the bins. Optional,
+	// defaults to a cryptographically secure source. Only set it in tests that
+	// need reproducible results: the noise is only differentially private if
+	// Source is cryptographically secure.
+	Source rand.Source
+	// Largest bin boundary. Defaults to math.MaxFloat64. This is only needed for
+	// aggregations of int64 values, which is why the option is not exported.
+	maxBoundary float64
+}
+
+// NewApproxBounds returns a new ApproxBounds. It exits the program if the
+// options are invalid; use NewApproxBoundsE to handle this case instead.
+func NewApproxBounds(opt *ApproxBoundsOptions) *ApproxBounds {
+	ab, err := NewApproxBoundsE(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewApproxBounds: %v", err)
+	}
+	return ab
+}
+
+// NewApproxBoundsE is the same as NewApproxBounds, but returns an error instead
+// of exiting the program if the options are invalid.
+func NewApproxBoundsE(opt *ApproxBoundsOptions) (*ApproxBounds, error) {
+	if opt == nil {
+		opt = &ApproxBoundsOptions{}
+	}
+	// Set defaults.
+	l0 := opt.MaxPartitionsContributed
+	if l0 == 0 {
+		l0 = 1
+	}
+	lInf := opt.MaxContributionsPerPartition
+	if lInf == 0 {
+		lInf = 1
+	}
+	scale := opt.Scale
+	if scale == 0 {
+		scale = smallestNormalFloat64
+	}
+	base := opt.Base
+	if base == 0 {
+		base = defaultApproxBoundsBase
+	}
+	maxBoundary := opt.maxBoundary
+	if maxBoundary == 0 {
+		maxBoundary = math.MaxFloat64
+	}
+	numBins := opt.NumBins
+	if numBins == 0 {
+		// Take the difference of two logarithms to prevent overflow.
+		numBins = int64(math.Ceil((math.Log(maxBoundary)-math.Log(scale))/math.Log(base))) + 1
+	}
+	successProbability := opt.SuccessProbability
+	if successProbability == 0 {
+		successProbability = defaultSuccessProbability
+	}
+
+	if err := checkApproxBoundsParameters(opt.Epsilon, l0, lInf, numBins, scale, base, successProbability, opt.Threshold); err != nil {
+		return nil, err
+	}
+
+	threshold := opt.Threshold
+	if threshold == 0 {
+		// Find the noisy count that a bin needs to reach to be chosen, given the
+		// success probability, assuming that Laplace noise is added to each of the
+		// 2*numBins bins.
+		threshold = -math.Log(2-2*math.Pow(successProbability, 1/float64(2*numBins-1))) *
+			float64(l0) * float64(lInf) / opt.Epsilon
+	}
+
+	// Cache the bin boundaries.
+	binBoundaries := make([]float64, numBins)
+	boundary := scale
+	for i := range binBoundaries {
+		// boundary overflows to +Inf past math.MaxFloat64.
+		binBoundaries[i] = math.Min(boundary, maxBoundary)
+		boundary *= base
+	}
+
+	return &ApproxBounds{
+		epsilon:         opt.Epsilon,
+		l0Sensitivity:   l0,
+		lInfSensitivity: lInf,
+		scale:           scale,
+		base:            base,
+		threshold:       threshold,
+		binBoundaries:   binBoundaries,
+		noise:           noise.WithSource(noise.Laplace(), opt.Source),
+		posBins:         make([]int64, numBins),
+		negBins:         make([]int64, numBins),
+		resultReturned:  false,
+	}, nil
+}
+
+func checkApproxBoundsParameters(epsilon float64, l0, lInf, numBins int64, scale, base, successProbability, threshold float64) error {
+	if err := checks.CheckEpsilonStrict("checkApproxBoundsParameters", epsilon); err != nil {
+		return err
+	}
+	if err := checks.CheckL0Sensitivity("checkApproxBoundsParameters", l0); err != nil {
+		return err
+	}
+	if err := checks.CheckLInfSensitivity("checkApproxBoundsParameters", float64(lInf)); err != nil {
+		return err
+	}
+	if numBins < 1 {
+		return fmt.Errorf("NumBins must be at least 1, got %d", numBins)
+	}
+	if !(scale > 0) || math.IsInf(scale, 0) {
+		return fmt.Errorf("Scale must be positive and finite, got %f", scale)
+	}
+	if !(base > 1) || math.IsInf(base, 0) {
+		return fmt.Errorf("Base must be greater than 1 and finite, got %f", base)
+	}
+	if !(successProbability > 0 && successProbability < 1) {
+		return fmt.Errorf("SuccessProbability must be in (0, 1), got %f", successProbability)
+	}
+	if !(threshold >= 0) || math.IsInf(threshold, 0) {
+		return fmt.Errorf("Threshold must be non-negative and finite, got %f", threshold)
+	}
+	return nil
+}
+
+// Add adds an entry to the histogram of ApproxBounds. It ignores NaN entries.
+func (ab *ApproxBounds) Add(e float64) {
+	if err := ab.AddE(e); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+}
+
+// AddE is the same as Add, but returns an error instead of exiting the program
+// if the result has already been returned.
+func (ab *ApproxBounds) AddE(e float64) error {
+	if ab.resultReturned {
+		return fmt.Errorf("The bounds have already been calculated and returned. They cannot be amended.")
+	}
+	if math.IsNaN(e) {
+		return nil
+	}
+	i := ab.mostSignificantBit(e)
+	if e >= 0 {
+		ab.posBins[i]++
+	} else {
+		ab.negBins[i]++
+	}
+	return nil
+}
+
+// Result returns differentially private approximate lower and upper bounds of
+// the entries added so far. Both bounds are bin boundaries. It returns an
+// error if no bin has a noisy count reaching the threshold, in which case a
+// larger collection of entries or a smaller SuccessProbability is needed.
+// It can be called only once, after which no further operation can be done on
+// the ApproxBounds; subsequent calls return an error.
+func (ab *ApproxBounds) Result() (lower, upper float64, err error) {
+	if ab.resultReturned {
+		return 0, 0, fmt.Errorf("The bounds have already been calculated and returned. They can only be returned once.")
+	}
+	ab.resultReturned = true
+	noisyPosBins := ab.addNoise(ab.posBins)
+	noisyNegBins := ab.addNoise(ab.negBins)
+	numBins := len(ab.binBoundaries)
+
+	// Find the leftmost bin above the threshold for the lower bound.
+	lowerFound := false
+	for i := numBins - 1; i >= 0 && !lowerFound; i-- {
+		if noisyNegBins[i] >= ab.threshold {
+			lower, lowerFound = ab.negRightBinBoundary(i), true
+		}
+	}
+	for i := 0; i < numBins && !lowerFound; i++ {
+		if noisyPosBins[i] >= ab.threshold {
+			lower, lowerFound = ab.posLeftBinBoundary(i), true
+		}
+	}
+
+	// Find the rightmost bin above the threshold for the upper bound.
+	upperFound := false
+	for i := numBins - 1; i >= 0 && !upperFound; i-- {
+		if noisyPosBins[i] >= ab.threshold {
+			upper, upperFound = ab.posRightBinBoundary(i), true
+		}
+	}
+	for i := 0; i < numBins && !upperFound; i++ {
+		if noisyNegBins[i] >= ab.threshold {
+			upper, upperFound = ab.negLeftBinBoundary(i), true
+		}
+	}
+
+	if !lowerFound || !upperFound {
+		return 0, 0, fmt.Errorf("ApproxBounds: bin count threshold %f was too large to find approximate bounds, "+
+			"either run over a larger dataset or decrease the success probability", ab.threshold)
+	}
+	return lower, upper, nil
+}
+
+func (ab *ApproxBounds) addNoise(bins []int64) []float64 {
+	noisyBins := make([]float64, len(bins))
+	for i, count := range bins {
+		noisyBins[i] = ab.noise.AddNoiseFloat64(float64(count), ab.l0Sensitivity, float64(ab.lInfSensitivity), ab.epsilon, 0)
+	}
+	return noisyBins
+}
+
+// mostSignificantBit returns the index of the bin that e belongs to.
+func (ab *ApproxBounds) mostSignificantBit(e float64) int {
+	// log(0) is undefined.
+	if e == 0 {
+		return 0
+	}
+	abs := math.Min(math.Abs(e), math.MaxFloat64)
+	msb := math.Ceil((math.Log(abs) - math.Log(ab.scale)) / math.Log(ab.base))
+	binIndex := int(math.Max(0, math.Min(msb, float64(len(ab.binBoundaries)-1))))
+
+	// Floating-point precision errors mean that for some bin boundaries, we end
+	// up calculating the larger-magnitude bin rather than the smaller one.
+	if (e > 0 && e <= ab.posLeftBinBoundary(binIndex)) || (e < 0 && e >= ab.negLeftBinBoundary(binIndex)) {
+		if binIndex > 0 {
+			return binIndex - 1
+		}
+	}
+	return binIndex
+}
+
+// posRightBinBoundary returns the larger-magnitude boundary of positive bin i.
+func (ab *ApproxBounds) posRightBinBoundary(i int) float64 {
+	return ab.binBoundaries[i]
+}
+
+// posLeftBinBoundary returns the smaller-magnitude boundary of positive bin i.
+func (ab *ApproxBounds) posLeftBinBoundary(i int) float64 {
+	if i == 0 {
+		return 0
+	}
+	return ab.binBoundaries[i-1]
+}
+
+// negRightBinBoundary returns the larger-magnitude boundary of negative bin i.
+func (ab *ApproxBounds) negRightBinBoundary(i int) float64 {
+	return -ab.posRightBinBoundary(i)
+}
+
+// negLeftBinBoundary returns the smaller-magnitude boundary of negative bin i.
+func (ab *ApproxBounds) negLeftBinBoundary(i int) float64 {
+	return -ab.posLeftBinBoundary(i)
+}
+
+// addToPartialSums splits e into partial sums, one for each bin between 0 and
+// the bin of e, and adds them to posSums if e is non-negative, and to negSums
+// otherwise. The partial sum of a bin is the part of e that lies within the
+// bin. For example, with bins [0, 1], (1, 2], (2, 4] and (4, 8], the partial
+// sums of 7 are 1, 1, 2 and 3.
+//
+// Once the bounds are known, the clamped sum of the entries can be computed
+// from the partial sums with computeSumFromPartialSums, which allows
+// aggregations to store their entries before the bounds are determined.
+func (ab *ApproxBounds) addToPartialSums(posSums, negSums []float64, e float64) {
+	msb := ab.mostSignificantBit(e)
+	for i := 0; i <= msb; i++ {
+		var partial, remainder float64
+		if e >= 0 {
+			partial = ab.posRightBinBoundary(i) - ab.posLeftBinBoundary(i)
+			remainder = e - ab.posLeftBinBoundary(i)
+		} else {
+			partial = ab.negRightBinBoundary(i) - ab.negLeftBinBoundary(i)
+			remainder = e - ab.negLeftBinBoundary(i)
+		}
+		// For the bin of e, only add the remaining part of e, but not more than
+		// the size of the bin. The latter can happen if e is larger in magnitude
+		// than the largest bin boundary.
+		if i == msb && math.Abs(remainder) < math.Abs(partial) {
+			partial = remainder
+		}
+		if e >= 0 {
+			posSums[i] += partial
+		} else {
+			negSums[i] += partial
+		}
+	}
+}
+
+// computeSumFromPartialSums returns the sum of count entries clamped between
+// lower and upper, given their partial sums. lower and upper must be bin
+// boundaries, e.g. the result of ApproxBounds.
+func (ab *ApproxBounds) computeSumFromPartialSums(posSums, negSums []float64, lower, upper float64, count int64) float64 {
+	lowerMSB := ab.mostSignificantBit(lower)
+	upperMSB := ab.mostSignificantBit(upper)
+	sum := 0.0
+	switch {
+	case lower <= 0 && 0 <= upper:
+		// Add the partial sums of the bins between 0 and upper, and between lower and 0.
+		if lower < 0 {
+			for i := 0; i <= lowerMSB; i++ {
+				sum += negSums[i]
+			}
+		}
+		if upper > 0 {
+			for i := 0; i <= upperMSB; i++ {
+				sum += posSums[i]
+			}
+		}
+	case upper < 0:
+		// Each entry contributes at most upper, and the partial sums of the bins
+		// between upper and lower contain the remainder.
+		sum += float64(count) * upper
+		for i := upperMSB + 1; i <= lowerMSB; i++ {
+			sum += negSums[i]
+		}
+	default: // 0 < lower <= upper
+		// Each entry contributes at least lower, and the partial sums of the bins
+		// between lower and upper contain the remainder.
+		sum += float64(count) * lower
+		for i := lowerMSB + 1; i <= upperMSB; i++ {
+			sum += posSums[i]
+		}
+	}
+	return sum
+}
+
+// Merge merges ab2 into ab (i.e., adds to ab all entries that were added to
+// ab2). ab2 is consumed by this operation: ab2 may not be used after it is
+// merged into ab.
+func (ab *ApproxBounds) Merge(ab2 *ApproxBounds) {
+	if err := ab.MergeE(ab2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+}
+
+// MergeE is the same as Merge, but returns an error instead of exiting the
+// program if ab and ab2 cannot be merged.
+func (ab *ApproxBounds) MergeE(ab2 *ApproxBounds) error {
+	if err := checkMergeApproxBounds(ab, ab2); err != nil {
+		return err
+	}
+	for i := range ab.posBins {
+		ab.posBins[i] += ab2.posBins[i]
+		ab.negBins[i] += ab2.negBins[i]
+	}
+	ab2.resultReturned = true
+	return nil
+}
+
+func checkMergeApproxBounds(ab1, ab2 *ApproxBounds) error {
+	if ab1.resultReturned {
+		return fmt.Errorf("checkMergeApproxBounds: ab1 already returned the result, cannot be merged with another ApproxBounds instance")
+	}
+	if ab2.resultReturned {
+		return fmt.Errorf("checkMergeApproxBounds: ab2 already returned the result, cannot be merged with another ApproxBounds instance")
+	}
+	if !abEquallyInitialized(ab1, ab2) {
+		return fmt.Errorf("checkMergeApproxBounds: ab1 and ab2 are not compatible")
+	}
+	return nil
+}
+
+// encodableApproxBounds can be encoded by the gob package.
+type encodableApproxBounds struct {
+	Epsilon         float64
+	L0Sensitivity   int64
+	LInfSensitivity int64
+	Scale           float64
+	Base            float64
+	Threshold       float64
+	BinBoundaries   []float64
+	PosBins         []int64
+	NegBins         []int64
+	ResultReturned  bool
+	// Seed of the deterministic Source of the noise, if any.
+	Seed encodableSeed
+}
+
+// GobEncode encodes ApproxBounds.
+func (ab *ApproxBounds) GobEncode() ([]byte, error) {
+	enc := encodableApproxBounds{
+		Epsilon:         ab.epsilon,
+		L0Sensitivity:   ab.l0Sensitivity,
+		LInfSensitivity: ab.lInfSensitivity,
+		Scale:           ab.scale,
+		Base:            ab.base,
+		Threshold:       ab.threshold,
+		BinBoundaries:   ab.binBoundaries,
+		PosBins:         ab.posBins,
+		NegBins:         ab.negBins,
+		ResultReturned:  ab.resultReturned,
+		Seed:            seedOf(noise.SourceOf(ab.noise)),
+	}
+	ab.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes ApproxBounds.
+func (ab *ApproxBounds) GobDecode(data []byte) error {
+	var enc encodableApproxBounds
+	err := decode(&enc, data)
+	if err != nil {
+		return err
+	}
+	*ab = ApproxBounds{
+		epsilon:         enc.Epsilon,
+		l0Sensitivity:   enc.L0Sensitivity,
+		lInfSensitivity: enc.LInfSensitivity,
+		scale:           enc.Scale,
+		base:            enc.Base,
+		threshold:       enc.Threshold,
+		binBoundaries:   enc.BinBoundaries,
+		noise:           noise.WithSource(noise.Laplace(), enc.Seed.source()),
+		posBins:         enc.PosBins,
+		negBins:         enc.NegBins,
+		resultReturned:  enc.ResultReturned,
+	}
+	// gob does not distinguish between empty and nil slices.
+	numBins := len(ab.binBoundaries)
+	if len(ab.posBins) != numBins {
+		ab.posBins = make([]int64, numBins)
+	}
+	if len(ab.negBins) != numBins {
+		ab.negBins = make([]int64, numBins)
+	}
+	return nil
+}
+
+// setSource makes ab draw its noise from src, or from the cryptographically
+// secure source if src is nil.
+func (ab *ApproxBounds) setSource(src rand.Source) {
+	ab.noise = noise.WithSource(noise.Laplace(), src)
+}
+
+// addToPartialSumsInt64 is the same as addToPartialSums, for int64 entries and
+// partial sums.
+func (ab *ApproxBounds) addToPartialSumsInt64(posSums, negSums []int64, e int64) {
+	msb := ab.mostSignificantBit(float64(e))
+	for i := 0; i <= msb; i++ {
+		var partial, remainder int64
+		if e >= 0 {
+			partial = int64Boundary(ab.posRightBinBoundary(i)) - int64Boundary(ab.posLeftBinBoundary(i))
+			remainder = e - int64Boundary(ab.posLeftBinBoundary(i))
+		} else {
+			partial = int64Boundary(ab.negRightBinBoundary(i)) - int64Boundary(ab.negLeftBinBoundary(i))
+			remainder = e - int64Boundary(ab.negLeftBinBoundary(i))
+		}
+		if i == msb && absInt64(remainder) < absInt64(partial) {
+			partial = remainder
+		}
+		if e >= 0 {
+			posSums[i] += partial
+		} else {
+			negSums[i] += partial
+		}
+	}
+}
+
+// computeSumFromPartialSumsInt64 is the same as computeSumFromPartialSums, for
+// int64 partial sums.
+func (ab *ApproxBounds) computeSumFromPartialSumsInt64(posSums, negSums []int64, lower, upper int64, count int64) int64 {
+	lowerMSB := ab.mostSignificantBit(float64(lower))
+	upperMSB := ab.mostSignificantBit(float64(upper))
+	var sum int64
+	switch {
+	case lower <= 0 && 0 <= upper:
+		if lower < 0 {
+			for i := 0; i <= lowerMSB; i++ {
+				sum += negSums[i]
+			}
+		}
+		if upper > 0 {
+			for i := 0; i <= upperMSB; i++ {
+				sum += posSums[i]
+			}
+		}
+	case upper < 0:
+		sum += count * upper
+		for i := upperMSB + 1; i <= lowerMSB; i++ {
+			sum += negSums[i]
+		}
+	default: // 0 < lower <= upper
+		sum += count * lower
+		for i := lowerMSB + 1; i <= upperMSB; i++ {
+			sum += posSums[i]
+		}
+	}
+	return sum
+}
+
+// int64Boundary converts a bin boundary to int64. Boundaries whose magnitude
+// does not fit in an int64 are clamped to ±math.MaxInt64.
+func int64Boundary(b float64) int64 {
+	if b >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	if b <= -math.MaxInt64 {
+		return -math.MaxInt64
+	}
+	return int64(b)
+}
+
+func absInt64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// ErrBoundsNotFound is returned, possibly wrapped, by the aggregations that
+// determine their bounds automatically when ApproxBounds fails to find them,
+// e.g. because too few entries were added. Use errors.Is to check for it.
+var ErrBoundsNotFound = errors.New("couldn't determine the bounds automatically")
+
+// autoBoundsFloat64 is used by aggregations of float64 values that determine
+// their clamping bounds from the data. Since the bounds are only known once the
+// result is computed, the entries are stored as partial sums, see
+// ApproxBounds.addToPartialSums.
+type autoBoundsFloat64 struct {
+	ApproxBounds   *ApproxBounds
+	PosPartialSums []float64
+	NegPartialSums []float64
+	Count          int64 // number of non-NaN entries
+}
+
+func newAutoBoundsFloat64(epsilon float64, maxPartitionsContributed, maxContributionsPerPartition int64, src rand.Source) (*autoBoundsFloat64, error) {
+	ab, err := NewApproxBoundsE(&ApproxBoundsOptions{
+		Epsilon:                      epsilon,
+		MaxPartitionsContributed:     maxPartitionsContributed,
+		MaxContributionsPerPartition: maxContributionsPerPartition,
+		Source:                       src,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &autoBoundsFloat64{
+		ApproxBounds:   ab,
+		PosPartialSums: make([]float64, len(ab.binBoundaries)),
+		NegPartialSums: make([]float64, len(ab.binBoundaries)),
+	}, nil
+}
+
+func (a *autoBoundsFloat64) add(e float64) {
+	if math.IsNaN(e) {
+		return
+	}
+	a.ApproxBounds.Add(e)
+	a.ApproxBounds.addToPartialSums(a.PosPartialSums, a.NegPartialSums, e)
+	a.Count++
+}
+
+func (a *autoBoundsFloat64) merge(a2 *autoBoundsFloat64) {
+	a.ApproxBounds.Merge(a2.ApproxBounds)
+	for i := range a.PosPartialSums {
+		a.PosPartialSums[i] += a2.PosPartialSums[i]
+		a.NegPartialSums[i] += a2.NegPartialSums[i]
+	}
+	a.Count += a2.Count
+}
+
+// clampedSum determines the bounds with ApproxBounds, and returns them along
+// with the sum of the entries clamped between them.
+func (a *autoBoundsFloat64) clampedSum() (lower, upper, sum float64, err error) {
+	lower, upper, err = a.ApproxBounds.Result()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	sum = a.ApproxBounds.computeSumFromPartialSums(a.PosPartialSums, a.NegPartialSums, lower, upper, a.Count)
+	return lower, upper, sum, nil
+}
+
+func autoBoundsEquallyInitializedFloat64(a1, a2 *autoBoundsFloat64) bool {
+	if a1 == nil || a2 == nil {
+		return a1 == a2
+	}
+	return abEquallyInitialized(a1.ApproxBounds, a2.ApproxBounds)
+}
+
+// autoBoundsInt64 is the same as autoBoundsFloat64, for int64 values.
+type autoBoundsInt64 struct {
+	ApproxBounds   *ApproxBounds
+	PosPartialSums []int64
+	NegPartialSums []int64
+	Count          int64
+}
+
+func newAutoBoundsInt64(epsilon float64, maxPartitionsContributed, maxContributionsPerPartition int64, src rand.Source) (*autoBoundsInt64, error) {
+	ab, err := NewApproxBoundsE(&ApproxBoundsOptions{
+		Epsilon:                      epsilon,
+		MaxPartitionsContributed:     maxPartitionsContributed,
+		MaxContributionsPerPartition: maxContributionsPerPartition,
+		Scale:                        1,
+		Source:                       src,
+		maxBoundary:                  math.MaxInt64,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &autoBoundsInt64{
+		ApproxBounds:   ab,
+		PosPartialSums: make([]int64, len(ab.binBoundaries)),
+		NegPartialSums: make([]int64, len(ab.binBoundaries)),
+	}, nil
+}
+
+func (a *autoBoundsInt64) add(e int64) {
+	a.ApproxBounds.Add(float64(e))
+	a.ApproxBounds.addToPartialSumsInt64(a.PosPartialSums, a.NegPartialSums, e)
+	a.Count++
+}
+
+func (a *autoBoundsInt64) merge(a2 *autoBoundsInt64) {
+	a.ApproxBounds.Merge(a2.ApproxBounds)
+	for i := range a.PosPartialSums {
+		a.PosPartialSums[i] += a2.PosPartialSums[i]
+		a.NegPartialSums[i] += a2.NegPartialSums[i]
+	}
+	a.Count += a2.Count
+}
+
+// clampedSum determines the bounds with ApproxBounds, and returns them along
+// with the sum of the entries clamped between them.
+func (a *autoBoundsInt64) clampedSum() (lower, upper, sum int64, err error) {
+	lowerFloat, upperFloat, err := a.ApproxBounds.Result()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	lower, upper = int64Boundary(lowerFloat), int64Boundary(upperFloat)
+	sum = a.ApproxBounds.computeSumFromPartialSumsInt64(a.PosPartialSums, a.NegPartialSums, lower, upper, a.Count)
+	return lower, upper, sum, nil
+}
+
+func autoBoundsEquallyInitializedInt64(a1, a2 *autoBoundsInt64) bool {
+	if a1 == nil || a2 == nil {
+		return a1 == a2
+	}
+	return abEquallyInitialized(a1.ApproxBounds, a2.ApproxBounds)
+}
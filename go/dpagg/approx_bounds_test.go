@@ -0,0 +1,400 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/rand"
+	"github.com/google/go-cmp/cmp"
+)
+
+// getNoiselessAB returns an ApproxBounds with bins [0, 1], (1, 2], (2, 4] and
+// (4, 8] (and the symmetrical negative bins), and a threshold of 3.5.
+func getNoiselessAB() *ApproxBounds {
+	ab := NewApproxBounds(&ApproxBoundsOptions{
+		Epsilon:   ln3,
+		Scale:     1,
+		Base:      2,
+		NumBins:   4,
+		Threshold: 3.5,
+	})
+	ab.noise = noNoise{}
+	return ab
+}
+
+func TestNewApproxBounds(t *testing.T) {
+	ab := NewApproxBounds(&ApproxBoundsOptions{
+		Epsilon:                      ln3,
+		MaxPartitionsContributed:     2,
+		MaxContributionsPerPartition: 3,
+		Scale:                        0.5,
+		Base:                         10,
+		NumBins:                      3,
+		SuccessProbability:           0.9,
+	})
+	if got, want := ab.binBoundaries, []float64{0.5, 5, 50}; !cmp.Equal(got, want) {
+		t.Errorf("NewApproxBounds: for binBoundaries got %v, want %v", got, want)
+	}
+	// threshold = -log(2 - 2 * 0.9^(1/5)) * l0 * lInf / ε
+	wantThreshold := -math.Log(2-2*math.Pow(0.9, 0.2)) * 6 / ln3
+	if !ApproxEqual(ab.threshold, wantThreshold) {
+		t.Errorf("NewApproxBounds: for threshold got %f, want %f", ab.threshold, wantThreshold)
+	}
+	if ab.l0Sensitivity != 2 || ab.lInfSensitivity != 3 {
+		t.Errorf("NewApproxBounds: for sensitivities got l0 %d, lInf %d, want l0 2, lInf 3", ab.l0Sensitivity, ab.lInfSensitivity)
+	}
+}
+
+func TestNewApproxBoundsDefaultBinsCoverAllValues(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		opt         *ApproxBoundsOptions
+		maxBoundary float64
+	}{
+		{"float64", &ApproxBoundsOptions{Epsilon: ln3}, math.MaxFloat64},
+		{"int64", &ApproxBoundsOptions{Epsilon: ln3, Scale: 1, maxBoundary: math.MaxInt64}, math.MaxInt64},
+	} {
+		ab := NewApproxBounds(tc.opt)
+		n := len(ab.binBoundaries)
+		if got := ab.binBoundaries[n-1]; got != tc.maxBoundary {
+			t.Errorf("NewApproxBounds: for %s got largest bin boundary %g, want %g", tc.desc, got, tc.maxBoundary)
+		}
+		if got := ab.binBoundaries[n-2]; got >= tc.maxBoundary {
+			t.Errorf("NewApproxBounds: for %s got second largest bin boundary %g, want less than %g", tc.desc, got, tc.maxBoundary)
+		}
+	}
+}
+
+func TestApproxBoundsResult(t *testing.T) {
+	for _, tc := range []struct {
+		desc      string
+		entries   []float64
+		wantLower float64
+		wantUpper float64
+	}{
+		{"positive entries", []float64{0, 0, 0, 0, 1, 3, 7, 8, 8, 8}, 0, 8},
+		{"negative entries", []float64{-0.5, -0.5, -0.5, -0.5, -3, -3, -3, -3}, -4, 0},
+		{"positive and negative entries", []float64{-2, -2, -2, -2, 5, 5, 5, 5}, -2, 8},
+		{"entries not containing 0", []float64{1.5, 1.5, 1.5, 1.5, 3, 3, 3, 3}, 1, 4},
+		{"entries out of the largest bin", []float64{100, 100, 100, 100, -1e9, -1e9, -1e9, -1e9}, -8, 8},
+		{"entries below the threshold are ignored", []float64{-8, -8, -8, 0.5, 0.5, 0.5, 0.5, 6, 6, 6}, 0, 1},
+	} {
+		ab := getNoiselessAB()
+		for _, e := range tc.entries {
+			ab.Add(e)
+		}
+		lower, upper, err := ab.Result()
+		if err != nil {
+			t.Fatalf("Result: for %s got error %v", tc.desc, err)
+		}
+		if lower != tc.wantLower || upper != tc.wantUpper {
+			t.Errorf("Result: for %s got [%f, %f], want [%f, %f]", tc.desc, lower, upper, tc.wantLower, tc.wantUpper)
+		}
+	}
+}
+
+func TestApproxBoundsResultReturnsErrorForSmallInput(t *testing.T) {
+	ab := getNoiselessAB()
+	ab.Add(1)
+	ab.Add(1)
+	ab.Add(2)
+	if _, _, err := ab.Result(); err == nil {
+		t.Errorf("Result: when no bin reaches the threshold got nil error, want error")
+	}
+}
+
+func TestApproxBoundsAddIgnoresNaN(t *testing.T) {
+	ab := getNoiselessAB()
+	for i := 0; i < 4; i++ {
+		ab.Add(math.NaN())
+	}
+	if _, _, err := ab.Result(); err == nil {
+		t.Errorf("Result: when only NaN entries were added got nil error, want error")
+	}
+}
+
+func TestComputeSumFromPartialSums(t *testing.T) {
+	ab := getNoiselessAB()
+	entries := []float64{-20, -7, -3.5, -1, -0.25, 0, 0.5, 1, 1.5, 3, 6, 8, 13}
+	posSums, negSums := make([]float64, 4), make([]float64, 4)
+	for _, e := range entries {
+		ab.addToPartialSums(posSums, negSums, e)
+	}
+	boundaries := []float64{-8, -4, -2, -1, 0, 1, 2, 4, 8}
+	for i, lower := range boundaries {
+		for _, upper := range boundaries[i+1:] {
+			want := 0.0
+			for _, e := range entries {
+				want += math.Min(math.Max(e, lower), upper)
+			}
+			got := ab.computeSumFromPartialSums(posSums, negSums, lower, upper, int64(len(entries)))
+			if !ApproxEqual(got, want) {
+				t.Errorf("computeSumFromPartialSums: for bounds [%f, %f] got %f, want %f", lower, upper, got, want)
+			}
+		}
+	}
+}
+
+func TestComputeSumFromPartialSumsInt64(t *testing.T) {
+	ab := NewApproxBounds(&ApproxBoundsOptions{Epsilon: ln3, Scale: 1, maxBoundary: math.MaxInt64})
+	n := len(ab.binBoundaries)
+	entries := []int64{math.MinInt64, -20, -7, -1, 0, 1, 3, 6, 13, math.MaxInt64}
+	posSums, negSums := make([]int64, n), make([]int64, n)
+	for _, e := range entries {
+		ab.addToPartialSumsInt64(posSums, negSums, e)
+	}
+	boundaries := []int64{-8, -4, -1, 0, 1, 2, 8}
+	for i, lower := range boundaries {
+		for _, upper := range boundaries[i+1:] {
+			var want int64
+			for _, e := range entries {
+				if e < lower {
+					e = lower
+				}
+				if e > upper {
+					e = upper
+				}
+				want += e
+			}
+			got := ab.computeSumFromPartialSumsInt64(posSums, negSums, lower, upper, int64(len(entries)))
+			if got != want {
+				t.Errorf("computeSumFromPartialSumsInt64: for bounds [%d, %d] got %d, want %d", lower, upper, got, want)
+			}
+		}
+	}
+}
+
+func TestMergeApproxBounds(t *testing.T) {
+	ab1, ab2 := getNoiselessAB(), getNoiselessAB()
+	for i := 0; i < 2; i++ {
+		ab1.Add(3)
+		ab2.Add(3)
+	}
+	ab2.Add(-1)
+	ab1.Merge(ab2)
+	lower, upper, err := ab1.Result()
+	if err != nil {
+		t.Fatalf("Merge: after merging 2 instances of ApproxBounds got error %v", err)
+	}
+	if lower != 2 || upper != 4 {
+		t.Errorf("Merge: after merging 2 instances of ApproxBounds got [%f, %f], want [2, 4]", lower, upper)
+	}
+	if !ab2.resultReturned {
+		t.Errorf("Merge: when merging 2 instances of ApproxBounds for resultReturned got false, want true")
+	}
+}
+
+func TestCheckMergeApproxBounds(t *testing.T) {
+	ab1 := getNoiselessAB()
+	ab2 := NewApproxBounds(&ApproxBoundsOptions{
+		Epsilon:   ln3,
+		Scale:     1,
+		Base:      2,
+		NumBins:   5,
+		Threshold: 3.5,
+	})
+	if err := checkMergeApproxBounds(ab1, ab2); err == nil {
+		t.Errorf("CheckMerge: when numbers of bins are different got nil error, want error")
+	}
+	if err := checkMergeApproxBounds(ab1, getNoiselessAB()); err != nil {
+		t.Errorf("CheckMerge: when options are the same got error %v, want nil", err)
+	}
+	ab3 := getNoiselessAB()
+	ab3.Result()
+	if err := checkMergeApproxBounds(ab1, ab3); err == nil {
+		t.Errorf("CheckMerge: when ab2 already returned the result got nil error, want error")
+	}
+}
+
+func compareApproxBounds(ab1, ab2 *ApproxBounds) bool {
+	return abEquallyInitialized(ab1, ab2) &&
+		cmp.Equal(ab1.binBoundaries, ab2.binBoundaries) &&
+		ab1.noise == ab2.noise &&
+		cmp.Equal(ab1.posBins, ab2.posBins) &&
+		cmp.Equal(ab1.negBins, ab2.negBins) &&
+		ab1.resultReturned == ab2.resultReturned
+}
+
+// Tests that serialization for ApproxBounds works as expected.
+func TestApproxBoundsSerialization(t *testing.T) {
+	opts := &ApproxBoundsOptions{
+		Epsilon:                      ln3,
+		MaxPartitionsContributed:     2,
+		MaxContributionsPerPartition: 3,
+		Scale:                        1,
+		Base:                         2,
+		NumBins:                      10,
+	}
+	ab, abUnchanged := NewApproxBounds(opts), NewApproxBounds(opts)
+	ab.Add(3)
+	abUnchanged.Add(3)
+	bytes, err := encode(ab)
+	if err != nil {
+		t.Fatalf("encode(ApproxBounds) error: %v", err)
+	}
+	abUnmarshalled := new(ApproxBounds)
+	if err := decode(abUnmarshalled, bytes); err != nil {
+		t.Fatalf("decode(ApproxBounds) error: %v", err)
+	}
+	// Check that encoding -> decoding is the identity function.
+	if !cmp.Equal(abUnchanged, abUnmarshalled, cmp.Comparer(compareApproxBounds)) {
+		t.Errorf("decode(encode(_)): got %+v, want %+v", abUnmarshalled, abUnchanged)
+	}
+	// Check that the original ApproxBounds has its resultReturned set to true after serialization.
+	if !ab.resultReturned {
+		t.Errorf("ApproxBounds %v should have its resultReturned set to true after being serialized", ab)
+	}
+}
+
+// Checks that ApproxBounds decoded from the same encoding, using a
+// deterministic source, return the same result. Decoding used to replace the
+// source with the cryptographically secure one.
+func TestApproxBoundsWithDeterministicSourceSerialization(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		newApproxBounds := func() *ApproxBounds {
+			ab := NewApproxBounds(&ApproxBoundsOptions{
+				Epsilon:                      ln3,
+				MaxPartitionsContributed:     1,
+				MaxContributionsPerPartition: 1,
+				Source:                       rand.NewDeterministicSource(seed),
+			})
+			// Few enough entries that whether the bounds are found depends on
+			// the noise.
+			for i := 0; i < 50; i++ {
+				ab.Add(float64(i%10) * 1.7)
+			}
+			return ab
+		}
+		bytes, err := encode(newApproxBounds())
+		if err != nil {
+			t.Fatalf("encode(ApproxBounds) error: %v", err)
+		}
+		decoded1, decoded2 := new(ApproxBounds), new(ApproxBounds)
+		for _, decoded := range []*ApproxBounds{decoded1, decoded2} {
+			if err := decode(decoded, bytes); err != nil {
+				t.Fatalf("decode(ApproxBounds) error: %v", err)
+			}
+		}
+		lower1, upper1, err1 := decoded1.Result()
+		lower2, upper2, err2 := decoded2.Result()
+		if lower1 != lower2 || upper1 != upper2 || (err1 == nil) != (err2 == nil) {
+			t.Errorf("Result: for ApproxBounds decoded from the same encoding with seed %d got (%f, %f, %v) and (%f, %f, %v), want identical results", seed, lower1, upper1, err1, lower2, upper2, err2)
+		}
+	}
+}
+
+func TestBoundedSumWithAutoBounds(t *testing.T) {
+	// With the default options, the threshold is ~52, so 60 entries of a bin
+	// are enough for it to be chosen, and a single entry is not.
+	bsi := NewBoundedSumInt64(&BoundedSumInt64Options{Epsilon: ln3, Noise: noNoise{}, AutoBounds: true})
+	bsi.autoBounds.ApproxBounds.noise = noNoise{}
+	bsf := NewBoundedSumFloat64(&BoundedSumFloat64Options{Epsilon: ln3, Noise: noNoise{}, AutoBounds: true})
+	bsf.autoBounds.ApproxBounds.noise = noNoise{}
+	for i := 0; i < 60; i++ {
+		bsi.Add(3)
+		bsi.Add(-2)
+		bsf.Add(3)
+		bsf.Add(-2)
+	}
+	bsi.Add(1000) // clamped to 4
+	bsf.Add(1000) // clamped to 4
+	if got, want := bsi.Result(), int64(64); got != want {
+		t.Errorf("Result: for BoundedSumInt64 with AutoBounds got %d, want %d", got, want)
+	}
+	if got, want := bsf.Result(), 64.0; !ApproxEqual(got, want) {
+		t.Errorf("Result: for BoundedSumFloat64 with AutoBounds got %f, want %f", got, want)
+	}
+	if bsf.lower != -2 || bsf.upper != 4 || bsf.lInfSensitivity != 4 {
+		t.Errorf("Result: for BoundedSumFloat64 with AutoBounds got bounds [%f, %f] and lInfSensitivity %f, want [-2, 4] and 4",
+			bsf.lower, bsf.upper, bsf.lInfSensitivity)
+	}
+}
+
+func TestBoundedSumWithAutoBoundsFailure(t *testing.T) {
+	bs := NewBoundedSumFloat64(&BoundedSumFloat64Options{Epsilon: ln3, Noise: noNoise{}, AutoBounds: true})
+	bs.autoBounds.ApproxBounds.noise = noNoise{}
+	bs.Add(100)
+	if got := bs.ThresholdedResult(5); got != nil {
+		t.Errorf("ThresholdedResult: when the bounds cannot be determined got %f, want nil", *got)
+	}
+}
+
+func TestAutoBoundsFailureReturnsErrBoundsNotFound(t *testing.T) {
+	bsi := NewBoundedSumInt64(&BoundedSumInt64Options{Epsilon: ln3, Noise: noNoise{}, AutoBounds: true})
+	bsi.autoBounds.ApproxBounds.noise = noNoise{}
+	bsi.Add(100)
+	if _, err := bsi.ResultE(); !errors.Is(err, ErrBoundsNotFound) {
+		t.Errorf("ResultE: for BoundedSumInt64 when the bounds cannot be determined got error %v, want ErrBoundsNotFound", err)
+	}
+	bsf := NewBoundedSumFloat64(&BoundedSumFloat64Options{Epsilon: ln3, Noise: noNoise{}, AutoBounds: true})
+	bsf.autoBounds.ApproxBounds.noise = noNoise{}
+	bsf.Add(100)
+	if _, err := bsf.ThresholdedResultE(5); !errors.Is(err, ErrBoundsNotFound) {
+		t.Errorf("ThresholdedResultE: for BoundedSumFloat64 when the bounds cannot be determined got error %v, want ErrBoundsNotFound", err)
+	}
+	bm := NewBoundedMeanFloat64(&BoundedMeanFloat64Options{
+		Epsilon:                      ln3,
+		MaxContributionsPerPartition: 1,
+		Noise:                        noNoise{},
+		AutoBounds:                   true,
+	})
+	bm.autoBounds.ApproxBounds.noise = noNoise{}
+	bm.Add(100)
+	if _, err := bm.ResultE(); !errors.Is(err, ErrBoundsNotFound) {
+		t.Errorf("ResultE: for BoundedMeanFloat64 when the bounds cannot be determined got error %v, want ErrBoundsNotFound", err)
+	}
+}
+
+func TestMergeBoundedSumWithAutoBounds(t *testing.T) {
+	bs1 := NewBoundedSumFloat64(&BoundedSumFloat64Options{Epsilon: ln3, Noise: noNoise{}, AutoBounds: true})
+	bs1.autoBounds.ApproxBounds.noise = noNoise{}
+	bs2 := NewBoundedSumFloat64(&BoundedSumFloat64Options{Epsilon: ln3, Noise: noNoise{}, AutoBounds: true})
+	for i := 0; i < 30; i++ {
+		bs1.Add(3)
+		bs2.Add(3)
+	}
+	bs1.Merge(bs2)
+	if got, want := bs1.Result(), 180.0; !ApproxEqual(got, want) {
+		t.Errorf("Merge: for BoundedSumFloat64 with AutoBounds got %f, want %f", got, want)
+	}
+}
+
+func TestBoundedMeanWithAutoBounds(t *testing.T) {
+	bm := NewBoundedMeanFloat64(&BoundedMeanFloat64Options{
+		Epsilon:                      ln3,
+		MaxContributionsPerPartition: 1,
+		Noise:                        noNoise{},
+		AutoBounds:                   true,
+	})
+	bm.autoBounds.ApproxBounds.noise = noNoise{}
+	for i := 0; i < 60; i++ {
+		bm.Add(3)
+	}
+	bm.Add(1000) // clamped to 4
+	bm.Add(math.NaN())
+	got := bm.Result()
+	want := (60*3.0 + 4) / 61
+	if !ApproxEqual(got, want) {
+		t.Errorf("Result: for BoundedMeanFloat64 with AutoBounds got %f, want %f", got, want)
+	}
+	if bm.lower != 2 || bm.upper != 4 {
+		t.Errorf("Result: for BoundedMeanFloat64 with AutoBounds got bounds [%f, %f], want [2, 4]", bm.lower, bm.upper)
+	}
+}
@@ -19,6 +19,8 @@ package dpagg
 import (
 	"bytes"
 	"encoding/gob"
+
+	"github.com/google/differential-privacy/go/rand"
 )
 
 // Helpers for serializing DP aggregations.
@@ -33,3 +35,28 @@ func encode(v interface{}) ([]byte, error) {
 func decode(v interface{}, data []byte) error {
 	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
 }
+
+// encodableSeed is the seed of the deterministic Source used by an aggregation,
+// if any, so that an equivalent Source can be recreated when decoding it. gob
+// doesn't transmit zero values, so whether the seed is set is stored explicitly:
+// a *int64 pointing to 0 would be decoded as nil.
+type encodableSeed struct {
+	Set  bool
+	Seed int64
+}
+
+// seedOf returns the encodableSeed of src, which is not set unless src was
+// returned by rand.NewDeterministicSource.
+func seedOf(src rand.Source) encodableSeed {
+	seed, ok := rand.DeterministicSeed(src)
+	return encodableSeed{Set: ok, Seed: seed}
+}
+
+// source returns a new deterministic Source created from the seed, or nil to
+// use the cryptographically secure source if the seed is not set.
+func (s encodableSeed) source() rand.Source {
+	if !s.Set {
+		return nil
+	}
+	return rand.NewDeterministicSource(s.Seed)
+}
@@ -0,0 +1,466 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// paddedMutex is a mutex padded to the size of a cache line, so that the locks
+// of different shards don't share a cache line.
+type paddedMutex struct {
+	sync.Mutex
+	_ [56]byte
+}
+
+// shards guards the aggregations of the shards of a concurrent aggregation.
+// Contributions are spread across shards in a round-robin fashion, so that
+// concurrent callers rarely wait for each other. The shards are merged into
+// shard 0 the first time the result (or its confidence interval) is requested;
+// from then on, all operations are done on shard 0.
+type shards struct {
+	next   uint32
+	locks  []paddedMutex
+	merged bool // only written while holding all the locks
+}
+
+func newShards() shards {
+	return shards{locks: make([]paddedMutex, runtime.GOMAXPROCS(0))}
+}
+
+// lock locks the shard that the next contribution should be added to, and
+// returns its index.
+func (s *shards) lock() int {
+	i := int(atomic.AddUint32(&s.next, 1) % uint32(len(s.locks)))
+	s.locks[i].Lock()
+	if s.merged && i != 0 {
+		s.locks[i].Unlock()
+		i = 0
+		s.locks[0].Lock()
+	}
+	return i
+}
+
+func (s *shards) unlock(i int) {
+	s.locks[i].Unlock()
+}
+
+// lockAndMerge locks all the shards and, if they haven't been merged yet,
+// calls merge with the index of each shard other than shard 0. If it returns
+// an error, the shards are unlocked; otherwise, unlockAll must be called once
+// done with shard 0.
+func (s *shards) lockAndMerge(merge func(i int) error) error {
+	for i := range s.locks {
+		s.locks[i].Lock()
+	}
+	if s.merged {
+		return nil
+	}
+	for i := 1; i < len(s.locks); i++ {
+		if err := merge(i); err != nil {
+			s.unlockAll()
+			return err
+		}
+	}
+	s.merged = true
+	return nil
+}
+
+func (s *shards) unlockAll() {
+	for i := range s.locks {
+		s.locks[i].Unlock()
+	}
+}
+
+// ConcurrentCount is a Count that can be incremented from many goroutines at
+// the same time. It spreads increments across several Counts, one per CPU,
+// which are merged when the result is returned. As with Count, the result can
+// be returned only once, after which the count cannot be amended.
+//
+// Thread-safe.
+type ConcurrentCount struct {
+	s      shards
+	counts []*Count
+}
+
+// NewConcurrentCount returns a new ConcurrentCount, initialized at 0. It exits
+// the program if the options are invalid; use NewConcurrentCountE to handle
+// this case instead.
+func NewConcurrentCount(opt *CountOptions) *ConcurrentCount {
+	cc, err := NewConcurrentCountE(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewConcurrentCount: %v", err)
+	}
+	return cc
+}
+
+// NewConcurrentCountE is the same as NewConcurrentCount, but returns an error
+// instead of exiting the program if the options are invalid.
+func NewConcurrentCountE(opt *CountOptions) (*ConcurrentCount, error) {
+	cc := &ConcurrentCount{s: newShards()}
+	for range cc.s.locks {
+		c, err := NewCountE(opt)
+		if err != nil {
+			return nil, err
+		}
+		cc.counts = append(cc.counts, c)
+	}
+	return cc, nil
+}
+
+// Increment increments the count by one.
+func (cc *ConcurrentCount) Increment() {
+	cc.IncrementBy(1)
+}
+
+// IncrementE is the same as Increment, but returns an error instead of exiting
+// the program if the result has already been returned.
+func (cc *ConcurrentCount) IncrementE() error {
+	return cc.IncrementByE(1)
+}
+
+// IncrementBy increments the count by the given value.
+// Note that this shouldn't be used to count multiple contributions to a
+// single partition from the same user.
+func (cc *ConcurrentCount) IncrementBy(count int64) {
+	if err := cc.IncrementByE(count); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+}
+
+// IncrementByE is the same as IncrementBy, but returns an error instead of
+// exiting the program if the result has already been returned.
+func (cc *ConcurrentCount) IncrementByE(count int64) error {
+	i := cc.s.lock()
+	defer cc.s.unlock(i)
+	return cc.counts[i].IncrementByE(count)
+}
+
+func (cc *ConcurrentCount) lockAndMerge() error {
+	return cc.s.lockAndMerge(func(i int) error { return cc.counts[0].MergeE(cc.counts[i]) })
+}
+
+// Result is the same as Count.Result.
+func (cc *ConcurrentCount) Result() int64 {
+	result, err := cc.ResultE()
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the result has already been returned.
+func (cc *ConcurrentCount) ResultE() (int64, error) {
+	if err := cc.lockAndMerge(); err != nil {
+		return 0, err
+	}
+	defer cc.s.unlockAll()
+	return cc.counts[0].ResultE()
+}
+
+// ThresholdedResult is the same as Count.ThresholdedResult.
+func (cc *ConcurrentCount) ThresholdedResult(deltaThreshold float64) *int64 {
+	result, err := cc.ThresholdedResultE(deltaThreshold)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ThresholdedResultE is the same as ThresholdedResult, but returns an error
+// instead of exiting the program if the result has already been returned or
+// deltaThreshold is invalid.
+func (cc *ConcurrentCount) ThresholdedResultE(deltaThreshold float64) (*int64, error) {
+	if err := cc.lockAndMerge(); err != nil {
+		return nil, err
+	}
+	defer cc.s.unlockAll()
+	return cc.counts[0].ThresholdedResultE(deltaThreshold)
+}
+
+// ConfidenceInterval is the same as Count.ConfidenceInterval.
+func (cc *ConcurrentCount) ConfidenceInterval(alpha float64) noise.ConfidenceInterval {
+	confInt, err := cc.ConfidenceIntervalE(alpha)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return confInt
+}
+
+// ConfidenceIntervalE is the same as ConfidenceInterval, but returns an error
+// instead of exiting the program if no result was returned or alpha is
+// invalid.
+func (cc *ConcurrentCount) ConfidenceIntervalE(alpha float64) (noise.ConfidenceInterval, error) {
+	if err := cc.lockAndMerge(); err != nil {
+		return noise.ConfidenceInterval{}, err
+	}
+	defer cc.s.unlockAll()
+	return cc.counts[0].ConfidenceIntervalE(alpha)
+}
+
+// ConcurrentBoundedSumInt64 is a BoundedSumInt64 to which elements can be
+// added from many goroutines at the same time. It spreads elements across
+// several BoundedSumInt64s, one per CPU, which are merged when the result is
+// returned. As with BoundedSumInt64, the result can be returned only once,
+// after which the sum cannot be amended.
+//
+// Thread-safe.
+type ConcurrentBoundedSumInt64 struct {
+	s    shards
+	sums []*BoundedSumInt64
+}
+
+// NewConcurrentBoundedSumInt64 returns a new ConcurrentBoundedSumInt64, whose
+// sum is initialized at 0. It exits the program if the options are invalid;
+// use NewConcurrentBoundedSumInt64E to handle this case instead.
+func NewConcurrentBoundedSumInt64(opt *BoundedSumInt64Options) *ConcurrentBoundedSumInt64 {
+	cbs, err := NewConcurrentBoundedSumInt64E(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewConcurrentBoundedSumInt64: %v", err)
+	}
+	return cbs
+}
+
+// NewConcurrentBoundedSumInt64E is the same as NewConcurrentBoundedSumInt64,
+// but returns an error instead of exiting the program if the options are
+// invalid.
+func NewConcurrentBoundedSumInt64E(opt *BoundedSumInt64Options) (*ConcurrentBoundedSumInt64, error) {
+	cbs := &ConcurrentBoundedSumInt64{s: newShards()}
+	for range cbs.s.locks {
+		bs, err := NewBoundedSumInt64E(opt)
+		if err != nil {
+			return nil, err
+		}
+		cbs.sums = append(cbs.sums, bs)
+	}
+	return cbs, nil
+}
+
+// Add adds a new summand to the ConcurrentBoundedSumInt64.
+func (cbs *ConcurrentBoundedSumInt64) Add(e int64) {
+	if err := cbs.AddE(e); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+}
+
+// AddE is the same as Add, but returns an error instead of exiting the program
+// if the result has already been returned.
+func (cbs *ConcurrentBoundedSumInt64) AddE(e int64) error {
+	i := cbs.s.lock()
+	defer cbs.s.unlock(i)
+	return cbs.sums[i].AddE(e)
+}
+
+func (cbs *ConcurrentBoundedSumInt64) lockAndMerge() error {
+	return cbs.s.lockAndMerge(func(i int) error { return cbs.sums[0].MergeE(cbs.sums[i]) })
+}
+
+// Result is the same as BoundedSumInt64.Result.
+func (cbs *ConcurrentBoundedSumInt64) Result() int64 {
+	result, err := cbs.ResultE()
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the result has already been returned.
+func (cbs *ConcurrentBoundedSumInt64) ResultE() (int64, error) {
+	if err := cbs.lockAndMerge(); err != nil {
+		return 0, err
+	}
+	defer cbs.s.unlockAll()
+	return cbs.sums[0].ResultE()
+}
+
+// ThresholdedResult is the same as BoundedSumInt64.ThresholdedResult.
+func (cbs *ConcurrentBoundedSumInt64) ThresholdedResult(deltaThreshold float64) *int64 {
+	result, err := cbs.ThresholdedResultE(deltaThreshold)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ThresholdedResultE is the same as ThresholdedResult, but returns an error
+// instead of exiting the program if the result has already been returned or
+// deltaThreshold is invalid.
+func (cbs *ConcurrentBoundedSumInt64) ThresholdedResultE(deltaThreshold float64) (*int64, error) {
+	if err := cbs.lockAndMerge(); err != nil {
+		return nil, err
+	}
+	defer cbs.s.unlockAll()
+	return cbs.sums[0].ThresholdedResultE(deltaThreshold)
+}
+
+// ConfidenceInterval is the same as BoundedSumInt64.ConfidenceInterval.
+func (cbs *ConcurrentBoundedSumInt64) ConfidenceInterval(alpha float64) noise.ConfidenceInterval {
+	confInt, err := cbs.ConfidenceIntervalE(alpha)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return confInt
+}
+
+// ConfidenceIntervalE is the same as ConfidenceInterval, but returns an error
+// instead of exiting the program if no result was returned or alpha is
+// invalid.
+func (cbs *ConcurrentBoundedSumInt64) ConfidenceIntervalE(alpha float64) (noise.ConfidenceInterval, error) {
+	if err := cbs.lockAndMerge(); err != nil {
+		return noise.ConfidenceInterval{}, err
+	}
+	defer cbs.s.unlockAll()
+	return cbs.sums[0].ConfidenceIntervalE(alpha)
+}
+
+// ConcurrentBoundedSumFloat64 is a BoundedSumFloat64 to which elements can be
+// added from many goroutines at the same time. It spreads elements across
+// several BoundedSumFloat64s, one per CPU, which are merged when the result is
+// returned. As with BoundedSumFloat64, the result can be returned only once,
+// after which the sum cannot be amended.
+//
+// Thread-safe.
+type ConcurrentBoundedSumFloat64 struct {
+	s    shards
+	sums []*BoundedSumFloat64
+}
+
+// NewConcurrentBoundedSumFloat64 returns a new ConcurrentBoundedSumFloat64,
+// whose sum is initialized at 0. It exits the program if the options are
+// invalid; use NewConcurrentBoundedSumFloat64E to handle this case instead.
+func NewConcurrentBoundedSumFloat64(opt *BoundedSumFloat64Options) *ConcurrentBoundedSumFloat64 {
+	cbs, err := NewConcurrentBoundedSumFloat64E(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewConcurrentBoundedSumFloat64: %v", err)
+	}
+	return cbs
+}
+
+// NewConcurrentBoundedSumFloat64E is the same as
+// NewConcurrentBoundedSumFloat64, but returns an error instead of exiting the
+// program if the options are invalid.
+func NewConcurrentBoundedSumFloat64E(opt *BoundedSumFloat64Options) (*ConcurrentBoundedSumFloat64, error) {
+	cbs := &ConcurrentBoundedSumFloat64{s: newShards()}
+	for range cbs.s.locks {
+		bs, err := NewBoundedSumFloat64E(opt)
+		if err != nil {
+			return nil, err
+		}
+		cbs.sums = append(cbs.sums, bs)
+	}
+	return cbs, nil
+}
+
+// Add adds a new summand to the ConcurrentBoundedSumFloat64.
+func (cbs *ConcurrentBoundedSumFloat64) Add(e float64) {
+	if err := cbs.AddE(e); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+}
+
+// AddE is the same as Add, but returns an error instead of exiting the program
+// if the result has already been returned.
+func (cbs *ConcurrentBoundedSumFloat64) AddE(e float64) error {
+	i := cbs.s.lock()
+	defer cbs.s.unlock(i)
+	return cbs.sums[i].AddE(e)
+}
+
+func (cbs *ConcurrentBoundedSumFloat64) lockAndMerge() error {
+	return cbs.s.lockAndMerge(func(i int) error { return cbs.sums[0].MergeE(cbs.sums[i]) })
+}
+
+// Result is the same as BoundedSumFloat64.Result.
+func (cbs *ConcurrentBoundedSumFloat64) Result() float64 {
+	result, err := cbs.ResultE()
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the result has already been returned.
+func (cbs *ConcurrentBoundedSumFloat64) ResultE() (float64, error) {
+	if err := cbs.lockAndMerge(); err != nil {
+		return 0, err
+	}
+	defer cbs.s.unlockAll()
+	return cbs.sums[0].ResultE()
+}
+
+// ThresholdedResult is the same as BoundedSumFloat64.ThresholdedResult.
+func (cbs *ConcurrentBoundedSumFloat64) ThresholdedResult(deltaThreshold float64) *float64 {
+	result, err := cbs.ThresholdedResultE(deltaThreshold)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ThresholdedResultE is the same as ThresholdedResult, but returns an error
+// instead of exiting the program if the result has already been returned or
+// deltaThreshold is invalid.
+func (cbs *ConcurrentBoundedSumFloat64) ThresholdedResultE(deltaThreshold float64) (*float64, error) {
+	if err := cbs.lockAndMerge(); err != nil {
+		return nil, err
+	}
+	defer cbs.s.unlockAll()
+	return cbs.sums[0].ThresholdedResultE(deltaThreshold)
+}
+
+// ConfidenceInterval is the same as BoundedSumFloat64.ConfidenceInterval.
+func (cbs *ConcurrentBoundedSumFloat64) ConfidenceInterval(alpha float64) noise.ConfidenceInterval {
+	confInt, err := cbs.ConfidenceIntervalE(alpha)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return confInt
+}
+
+// ConfidenceIntervalE is the same as ConfidenceInterval, but returns an error
+// instead of exiting the program if no result was returned or alpha is
+// invalid.
+func (cbs *ConcurrentBoundedSumFloat64) ConfidenceIntervalE(alpha float64) (noise.ConfidenceInterval, error) {
+	if err := cbs.lockAndMerge(); err != nil {
+		return noise.ConfidenceInterval{}, err
+	}
+	defer cbs.s.unlockAll()
+	return cbs.sums[0].ConfidenceIntervalE(alpha)
+}
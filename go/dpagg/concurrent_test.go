@@ -0,0 +1,112 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"sync"
+	"testing"
+)
+
+// runConcurrently calls f from goroutines goroutines, times times each.
+func runConcurrently(goroutines, times int, f func()) {
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < times; i++ {
+				f()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentCountIncrement(t *testing.T) {
+	cc := NewConcurrentCount(&CountOptions{Epsilon: ln3, Delta: tenten, Noise: noNoise{}})
+	runConcurrently(8, 1000, func() {
+		cc.Increment()
+		cc.IncrementBy(2)
+	})
+	if got, want := cc.Result(), int64(24000); got != want {
+		t.Errorf("Increment: after concurrent increments got count %d, want %d", got, want)
+	}
+}
+
+func TestConcurrentCountReturnsResultOnlyOnce(t *testing.T) {
+	cc := NewConcurrentCount(&CountOptions{Epsilon: ln3, Delta: tenten, Noise: noNoise{}})
+	cc.Increment()
+	cc.Result()
+	if _, err := cc.ResultE(); err == nil {
+		t.Errorf("ResultE: when the result was already returned got no error, want error")
+	}
+	runConcurrently(4, 10, func() {
+		if err := cc.IncrementE(); err == nil {
+			t.Errorf("IncrementE: when the result was already returned got no error, want error")
+		}
+	})
+}
+
+func TestConcurrentCountConfidenceInterval(t *testing.T) {
+	cc := NewConcurrentCount(&CountOptions{Epsilon: ln3, Delta: tenten, Noise: noNoise{}})
+	if _, err := cc.ConfidenceIntervalE(0.05); err == nil {
+		t.Errorf("ConfidenceIntervalE: before the result was returned got no error, want error")
+	}
+	// Computing the confidence interval merges the shards, which must not
+	// prevent further increments.
+	runConcurrently(4, 10, cc.Increment)
+	if got, want := cc.Result(), int64(40); got != want {
+		t.Errorf("Increment: after concurrent increments got count %d, want %d", got, want)
+	}
+	if _, err := cc.ConfidenceIntervalE(0.05); err != nil {
+		t.Errorf("ConfidenceIntervalE: after the result was returned got error %v", err)
+	}
+}
+
+func TestConcurrentBoundedSumInt64Add(t *testing.T) {
+	cbs := NewConcurrentBoundedSumInt64(&BoundedSumInt64Options{Epsilon: ln3, Delta: tenten, Lower: -1, Upper: 5, Noise: noNoise{}})
+	runConcurrently(8, 1000, func() {
+		cbs.Add(2)
+		cbs.Add(10) // clamped to 5
+	})
+	if got, want := cbs.Result(), int64(56000); got != want {
+		t.Errorf("Add: after concurrent additions got sum %d, want %d", got, want)
+	}
+	if err := cbs.AddE(1); err == nil {
+		t.Errorf("AddE: when the result was already returned got no error, want error")
+	}
+}
+
+func TestConcurrentBoundedSumFloat64Add(t *testing.T) {
+	cbs := NewConcurrentBoundedSumFloat64(&BoundedSumFloat64Options{Epsilon: ln3, Delta: tenten, Lower: -1, Upper: 5, Noise: noNoise{}})
+	runConcurrently(8, 1000, func() {
+		cbs.Add(1.5)
+		cbs.Add(-3) // clamped to -1
+	})
+	if got, want := cbs.Result(), 4000.0; !ApproxEqual(got, want) {
+		t.Errorf("Add: after concurrent additions got sum %f, want %f", got, want)
+	}
+	if _, err := cbs.ResultE(); err == nil {
+		t.Errorf("ResultE: when the result was already returned got no error, want error")
+	}
+}
+
+func TestConcurrentCountInvalidOptionsReturnsError(t *testing.T) {
+	if _, err := NewConcurrentCountE(&CountOptions{Epsilon: -1}); err == nil {
+		t.Errorf("NewConcurrentCountE: with negative epsilon got no error, want error")
+	}
+}
@@ -18,6 +18,7 @@ package dpagg
 
 import (
 	"fmt"
+	"math"
 
 	log "github.com/golang/glog"
 	"github.com/google/differential-privacy/go/noise"
@@ -33,7 +34,8 @@ import (
 // Note: Do not use when your results may cause overflows for int64 values.
 // This aggregation is not hardened for such applications yet.
 //
-// Not thread-safe.
+// Not thread-safe; use ConcurrentCount to increment a count from several
+// goroutines.
 type Count struct {
 	// Parameters
 	epsilon         float64
@@ -46,6 +48,9 @@ type Count struct {
 	// State variables
 	count          int64
 	resultReturned bool // whether the result has already been returned
+	// The returned result, used to compute confidence intervals. nil if no
+	// result was returned, e.g. because it was thresholded.
+	noisedCount *int64
 }
 
 func countEquallyInitialized(c1, c2 *Count) bool {
@@ -68,8 +73,20 @@ type CountOptions struct {
 	maxContributionsPerPartition int64
 }
 
-// NewCount returns a new Count, initialized at 0.
+// NewCount returns a new Count, initialized at 0. It exits the program if the
+// options are invalid; use NewCountE to handle this case instead.
 func NewCount(opt *CountOptions) *Count {
+	c, err := NewCountE(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewCount: %v", err)
+	}
+	return c
+}
+
+// NewCountE is the same as NewCount, but returns an error instead of exiting
+// the program if the options are invalid.
+func NewCountE(opt *CountOptions) (*Count, error) {
 	if opt == nil {
 		opt = &CountOptions{}
 	}
@@ -91,7 +108,9 @@ func NewCount(opt *CountOptions) *Count {
 	// Check that the parameters are compatible with the noise chosen by calling
 	// the noise on some dummy value.
 	eps, del := opt.Epsilon, opt.Delta
-	n.AddNoiseInt64(0, l0, lInf, eps, del)
+	if _, err := noise.AddNoiseInt64E(n, 0, l0, lInf, eps, del); err != nil {
+		return nil, err
+	}
 
 	return &Count{
 		epsilon:         eps,
@@ -102,7 +121,7 @@ func NewCount(opt *CountOptions) *Count {
 		noiseKind:       noise.ToKind(n),
 		count:           0,
 		resultReturned:  false,
-	}
+	}, nil
 }
 
 // Increment increments the count by one.
@@ -110,25 +129,51 @@ func (c *Count) Increment() {
 	c.IncrementBy(1)
 }
 
+// IncrementE is the same as Increment, but returns an error instead of exiting
+// the program if the result has already been returned.
+func (c *Count) IncrementE() error {
+	return c.IncrementByE(1)
+}
+
 // IncrementBy increments the count by the given value.
 // Note that this shouldn't be used to count multiple contributions to a
 // single partition from the same user.
 func (c *Count) IncrementBy(count int64) {
+	if err := c.IncrementByE(count); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+}
+
+// IncrementByE is the same as IncrementBy, but returns an error instead of
+// exiting the program if the result has already been returned.
+func (c *Count) IncrementByE(count int64) error {
 	if c.resultReturned {
-		log.Fatalf("The count has already been calculated and returned. It cannot be amended.")
+		return fmt.Errorf("The count has already been calculated and returned. It cannot be amended.")
 	}
 	c.count += count
+	return nil
 }
 
 // Merge merges c2 into c (i.e., adds to c all entries that were added to c2).
 // c2 is consumed by this operation: it may not be used after it is merged
 // into c.
 func (c *Count) Merge(c2 *Count) {
-	if e := checkMergeCount(c, c2); e != nil {
-		log.Exit(e)
+	if err := c.MergeE(c2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+}
+
+// MergeE is the same as Merge, but returns an error instead of exiting the
+// program if c and c2 cannot be merged.
+func (c *Count) MergeE(c2 *Count) error {
+	if err := checkMergeCount(c, c2); err != nil {
+		return err
 	}
 	c.count += c2.count
 	c2.resultReturned = true
+	return nil
 }
 
 func checkMergeCount(c1, c2 *Count) error {
@@ -150,23 +195,89 @@ func checkMergeCount(c1, c2 *Count) error {
 // be called only once, after which no further operation can be done on the
 // Count.
 func (c *Count) Result() int64 {
+	result, err := c.ResultE()
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the result has already been returned.
+func (c *Count) ResultE() (int64, error) {
 	if c.resultReturned {
-		log.Fatalf("The count has already been calculated and returned. It can only be returned once.")
+		return 0, fmt.Errorf("The count has already been calculated and returned. It can only be returned once.")
 	}
 	c.resultReturned = true
-	return c.noise.AddNoiseInt64(c.count, c.l0Sensitivity, c.lInfSensitivity, c.epsilon, c.delta)
+	result, err := noise.AddNoiseInt64E(c.noise, c.count, c.l0Sensitivity, c.lInfSensitivity, c.epsilon, c.delta)
+	if err != nil {
+		return 0, err
+	}
+	c.noisedCount = &result
+	return result, nil
 }
 
 // ThresholdedResult is similar to Result() but applies thresholding to the
 // result. So, if the result is less than the threshold specified by the noise
 // mechanism, it returns nil. Otherwise, it returns the result.
 func (c *Count) ThresholdedResult(deltaThreshold float64) *int64 {
-	threshold := c.noise.Threshold(c.l0Sensitivity, float64(c.lInfSensitivity), c.epsilon, c.delta, deltaThreshold)
-	result := c.Result()
+	result, err := c.ThresholdedResultE(deltaThreshold)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ThresholdedResultE is the same as ThresholdedResult, but returns an error
+// instead of exiting the program if the result has already been returned or
+// deltaThreshold is invalid.
+func (c *Count) ThresholdedResultE(deltaThreshold float64) (*int64, error) {
+	threshold, err := noise.ThresholdE(c.noise, c.l0Sensitivity, float64(c.lInfSensitivity), c.epsilon, c.delta, deltaThreshold)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.ResultE()
+	if err != nil {
+		return nil, err
+	}
 	if result < int64(threshold) {
-		return nil
+		c.noisedCount = nil
+		return nil, nil
+	}
+	return &result, nil
+}
+
+// ConfidenceInterval returns a confidence interval that contains the raw count
+// with probability at least 1-alpha. It can only be called after the result
+// was returned by Result or ThresholdedResult. Computing it doesn't consume
+// any privacy budget.
+func (c *Count) ConfidenceInterval(alpha float64) noise.ConfidenceInterval {
+	confInt, err := c.ConfidenceIntervalE(alpha)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return confInt
+}
+
+// ConfidenceIntervalE is the same as ConfidenceInterval, but returns an error
+// instead of exiting the program if no result was returned or alpha is
+// invalid.
+func (c *Count) ConfidenceIntervalE(alpha float64) (noise.ConfidenceInterval, error) {
+	if c.noisedCount == nil {
+		return noise.ConfidenceInterval{}, fmt.Errorf("The confidence interval of the count can only be computed after its result is returned.")
+	}
+	confInt, err := noise.ComputeConfidenceIntervalFloat64E(c.noise, float64(*c.noisedCount), c.l0Sensitivity, float64(c.lInfSensitivity), c.epsilon, c.delta, alpha)
+	if err != nil {
+		return noise.ConfidenceInterval{}, err
 	}
-	return &result
+	// The raw count is a non-negative integer.
+	confInt = roundConfidenceInterval(confInt)
+	confInt.LowerBound = math.Max(0, confInt.LowerBound)
+	confInt.UpperBound = math.Max(0, confInt.UpperBound)
+	return confInt, nil
 }
 
 // encodableCount can be encoded by the gob package.
@@ -178,6 +289,8 @@ type encodableCount struct {
 	NoiseKind       noise.Kind
 	Count           int64
 	ResultReturned  bool
+	// Seed of the deterministic Source of the noise, if any.
+	Seed encodableSeed
 }
 
 // GobEncode encodes Count.
@@ -190,6 +303,7 @@ func (c *Count) GobEncode() ([]byte, error) {
 		NoiseKind:       noise.ToKind(c.noise),
 		Count:           c.count,
 		ResultReturned:  c.resultReturned,
+		Seed:            seedOf(noise.SourceOf(c.noise)),
 	}
 	c.resultReturned = true
 	return encode(enc)
@@ -200,7 +314,6 @@ func (c *Count) GobDecode(data []byte) error {
 	var enc encodableCount
 	err := decode(&enc, data)
 	if err != nil {
-		log.Fatalf("GobDecode: couldn't decode Count from bytes")
 		return err
 	}
 	*c = Count{
@@ -209,7 +322,7 @@ func (c *Count) GobDecode(data []byte) error {
 		l0Sensitivity:   enc.L0Sensitivity,
 		lInfSensitivity: enc.LInfSensitivity,
 		noiseKind:       enc.NoiseKind,
-		noise:           noise.ToNoise(enc.NoiseKind),
+		noise:           noise.WithSource(noise.ToNoise(enc.NoiseKind), enc.Seed.source()),
 		count:           enc.Count,
 		resultReturned:  enc.ResultReturned,
 	}
@@ -17,10 +17,12 @@
 package dpagg
 
 import (
+	"math"
 	"reflect"
 	"testing"
 
 	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/go/rand"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -136,6 +138,32 @@ func TestCountSerialization(t *testing.T) {
 	}
 }
 
+// Checks that Counts decoded from the same encoding, using noise with a
+// deterministic source, return the same result. Decoding used to replace the
+// source with the cryptographically secure one.
+func TestCountWithDeterministicSourceSerialization(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		newCount := func() *Count {
+			c := NewCount(&CountOptions{Epsilon: ln3, Noise: noise.WithSource(noise.Laplace(), rand.NewDeterministicSource(seed))})
+			c.IncrementBy(10)
+			return c
+		}
+		bytes, err := encode(newCount())
+		if err != nil {
+			t.Fatalf("encode(Count) error: %v", err)
+		}
+		decoded1, decoded2 := new(Count), new(Count)
+		for _, decoded := range []*Count{decoded1, decoded2} {
+			if err := decode(decoded, bytes); err != nil {
+				t.Fatalf("decode(Count) error: %v", err)
+			}
+		}
+		if got1, got2 := decoded1.Result(), decoded2.Result(); got1 != got2 {
+			t.Errorf("Result: for Counts decoded from the same encoding with seed %d got %d and %d, want identical results", seed, got1, got2)
+		}
+	}
+}
+
 func getNoiselessCount() *Count {
 	return NewCount(&CountOptions{
 		Epsilon:                  ln3,
@@ -534,3 +562,83 @@ func TestCountEquallyInitialized(t *testing.T) {
 		}
 	}
 }
+
+func TestNewCountEReturnsErrorForInvalidOptions(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opt  *CountOptions
+	}{
+		{"negative epsilon", &CountOptions{Epsilon: -1, Noise: noise.Laplace()}},
+		{"non-zero delta with Laplace noise", &CountOptions{Epsilon: ln3, Delta: 0.5, Noise: noise.Laplace()}},
+		{"zero delta with Gaussian noise", &CountOptions{Epsilon: ln3, Noise: noise.Gaussian()}},
+	} {
+		if _, err := NewCountE(tc.opt); err == nil {
+			t.Errorf("NewCountE: for %s got no error, want error", tc.desc)
+		}
+	}
+}
+
+func TestCountEReturnsErrorAfterResult(t *testing.T) {
+	c := getNoiselessCount()
+	if _, err := c.ResultE(); err != nil {
+		t.Fatalf("ResultE: got error %v", err)
+	}
+	if err := c.IncrementE(); err == nil {
+		t.Errorf("IncrementE: after ResultE got no error, want error")
+	}
+	if err := c.IncrementByE(2); err == nil {
+		t.Errorf("IncrementByE: after ResultE got no error, want error")
+	}
+	if _, err := c.ResultE(); err == nil {
+		t.Errorf("ResultE: when called twice got no error, want error")
+	}
+	if err := getNoiselessCount().MergeE(c); err == nil {
+		t.Errorf("MergeE: with a Count that returned its result got no error, want error")
+	}
+}
+
+func TestCountConfidenceInterval(t *testing.T) {
+	c := NewCount(&CountOptions{Epsilon: ln3, MaxPartitionsContributed: 2})
+	c.IncrementBy(100)
+	result := c.Result()
+	got := c.ConfidenceInterval(0.05)
+	confInt := noise.Laplace().ComputeConfidenceIntervalFloat64(float64(result), 2, 1, ln3, 0, 0.05)
+	want := noise.ConfidenceInterval{LowerBound: math.Max(0, math.Floor(confInt.LowerBound)), UpperBound: math.Ceil(confInt.UpperBound)}
+	if got != want {
+		t.Errorf("ConfidenceInterval(0.05): got %+v, want %+v", got, want)
+	}
+	if got.LowerBound > float64(result) || got.UpperBound < float64(result) {
+		t.Errorf("ConfidenceInterval(0.05): got %+v, want an interval containing the result %d", got, result)
+	}
+}
+
+func TestCountConfidenceIntervalIsNonNegative(t *testing.T) {
+	c := getNoiselessCount()
+	c.Result()
+	if got, want := c.ConfidenceInterval(0.05), (noise.ConfidenceInterval{}); got != want {
+		t.Errorf("ConfidenceInterval(0.05) for an empty count: got %+v, want %+v", got, want)
+	}
+}
+
+func TestCountConfidenceIntervalEReturnsErrorWithoutResult(t *testing.T) {
+	c := getNoiselessCount()
+	if _, err := c.ConfidenceIntervalE(0.05); err == nil {
+		t.Errorf("ConfidenceIntervalE before Result: got no error, want error")
+	}
+	// The result is below the threshold of noNoise, so it isn't returned.
+	c.Increment()
+	if result := c.ThresholdedResult(0.1); result != nil {
+		t.Fatalf("ThresholdedResult: got %d, want nil", *result)
+	}
+	if _, err := c.ConfidenceIntervalE(0.05); err == nil {
+		t.Errorf("ConfidenceIntervalE after a thresholded result: got no error, want error")
+	}
+}
+
+func TestCountConfidenceIntervalEReturnsErrorForInvalidAlpha(t *testing.T) {
+	c := NewCount(&CountOptions{Epsilon: ln3})
+	c.Result()
+	if _, err := c.ConfidenceIntervalE(1.5); err == nil {
+		t.Errorf("ConfidenceIntervalE(1.5): got no error, want error")
+	}
+}
@@ -33,7 +33,7 @@ var (
 )
 
 // noNoise is a Noise instance that doesn't add noise to the data, and has a
-// threshold of 5.
+// threshold of 5. Its confidence intervals only contain the noised value.
 type noNoise struct {
 	noise.Noise
 }
@@ -53,3 +53,7 @@ func ApproxEqual(x, y float64) bool {
 func (noNoise) Threshold(_ int64, _, _, _, _ float64) float64 {
 	return 5
 }
+
+func (noNoise) ComputeConfidenceIntervalFloat64(noisedX float64, _ int64, _, _, _, _ float64) noise.ConfidenceInterval {
+	return noise.ConfidenceInterval{LowerBound: noisedX, UpperBound: noisedX}
+}
@@ -16,7 +16,12 @@
 
 package dpagg
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/differential-privacy/go/noise"
+)
 
 // ClampFloat64 clamps e within lower and upper, such that lower is returned
 // if e < lower, and upper is returned if e > upper. Otherwise, e is returned.
@@ -49,3 +54,12 @@ func ClampInt64(e, lower, upper int64) (int64, error) {
 	}
 	return e, nil
 }
+
+// roundConfidenceInterval rounds the bounds of confInt outwards to integers.
+// It is used for int64 aggregations, whose noisy result AddNoiseInt64 may round
+// to the nearest integer: since the raw value is an integer, the rounded
+// interval around the rounded result still contains it whenever the interval
+// around the unrounded result does.
+func roundConfidenceInterval(confInt noise.ConfidenceInterval) noise.ConfidenceInterval {
+	return noise.ConfidenceInterval{LowerBound: math.Floor(confInt.LowerBound), UpperBound: math.Ceil(confInt.UpperBound)}
+}
@@ -61,13 +61,16 @@ type BoundedMeanFloat64 struct {
 	// it will be calculated based on the lower and upper values.
 	midPoint       float64
 	resultReturned bool // whether the result has already been returned
+	// Set if the bounds are determined automatically, nil otherwise.
+	autoBounds *autoBoundsFloat64
 }
 
 func bmEquallyInitializedFloat64(bm1, bm2 *BoundedMeanFloat64) bool {
 	return bm1.lower == bm2.lower &&
 		bm1.upper == bm2.upper &&
 		countEquallyInitialized(&bm1.count, &bm2.count) &&
-		bsEquallyInitializedFloat64(&bm1.normalizedSum, &bm2.normalizedSum)
+		bsEquallyInitializedFloat64(&bm1.normalizedSum, &bm2.normalizedSum) &&
+		autoBoundsEquallyInitializedFloat64(bm1.autoBounds, bm2.autoBounds)
 }
 
 // BoundedMeanFloat64Options contains the options necessary to initialize a BoundedMeanFloat64.
@@ -79,18 +82,34 @@ type BoundedMeanFloat64Options struct {
 	// Lower and Upper bounds for clamping. Default to 0; must be such that Lower < Upper.
 	Lower, Upper                 float64
 	Noise                        noise.Noise // Type of noise used in BoundedMean. Defaults to Laplace noise.
+	// If true, Lower and Upper must be left 0, and the bounds are determined
+	// from the data with ApproxBounds. Half of Epsilon is then used for
+	// determining the bounds, and half for the count and the sum.
+	AutoBounds bool
 }
 
 // NewBoundedMeanFloat64 returns a new BoundedMeanFloat64.
+// It exits the program if the options are invalid; use NewBoundedMeanFloat64E
+// to handle this case instead.
 func NewBoundedMeanFloat64(opt *BoundedMeanFloat64Options) *BoundedMeanFloat64 {
+	bm, err := NewBoundedMeanFloat64E(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewBoundedMeanFloat64: %v", err)
+	}
+	return bm
+}
+
+// NewBoundedMeanFloat64E is the same as NewBoundedMeanFloat64, but returns an
+// error instead of exiting the program if the options are invalid.
+func NewBoundedMeanFloat64E(opt *BoundedMeanFloat64Options) (*BoundedMeanFloat64, error) {
 	if opt == nil {
 		opt = &BoundedMeanFloat64Options{}
 	}
 
 	maxContributionsPerPartition := opt.MaxContributionsPerPartition
 	if maxContributionsPerPartition == 0 {
-		// TODO: do not exit the program from within library code
-		log.Fatalf("NewBoundedMeanFloat64 requires a value for MaxContributionsPerPartition")
+		return nil, fmt.Errorf("NewBoundedMeanFloat64 requires a value for MaxContributionsPerPartition")
 	}
 
 	// Set defaults.
@@ -103,21 +122,36 @@ func NewBoundedMeanFloat64(opt *BoundedMeanFloat64Options) *BoundedMeanFloat64 {
 	if n == nil {
 		n = noise.Laplace()
 	}
+	eps, del := opt.Epsilon, opt.Delta
 	// Check bounds & use them to compute L_∞ sensitivity.
 	lower, upper := opt.Lower, opt.Upper
+	var autoBounds *autoBoundsFloat64
+	if opt.AutoBounds {
+		if lower != 0 || upper != 0 {
+			return nil, fmt.Errorf("NewBoundedMeanFloat64 requires Lower and Upper to be 0 when AutoBounds is set, got lower %f, upper %f", lower, upper)
+		}
+		// ApproxBounds always uses Laplace noise, so the entire δ is left for the
+		// count and the sum.
+		eps = eps / 2
+		var err error
+		autoBounds, err = newAutoBoundsFloat64(eps, maxPartitionsContributed, maxContributionsPerPartition, noise.SourceOf(n))
+		if err != nil {
+			return nil, err
+		}
+		// The bounds are not known until the result is computed, use placeholder
+		// bounds in the meantime.
+		lower, upper = -1, 1
+	}
 	if lower == 0 && upper == 0 {
-		// TODO: do not exit the program from within library code
-		log.Fatalf("NewBoundedMeanFloat64 requires a non-default value for Lower or Upper (automatic bounds determination is not implemented yet)")
+		return nil, fmt.Errorf("NewBoundedMeanFloat64 requires a non-default value for Lower or Upper, or AutoBounds to be set")
 	}
 	if err := checks.CheckBoundsFloat64("NewBoundedMeanFloat64", lower, upper); err != nil {
-		// TODO: do not exit the program from within library code
-		log.Fatalf("CheckBoundsFloat64(lower %f, upper %f) failed with %v", lower, upper, err)
+		return nil, err
 	}
 	// (lower + upper) / 2 may cause an overflow if lower and upper are large values.
 	midPoint := lower + (upper-lower)/2.0
 	maxDistFromMidpoint := math.Abs(upper - midPoint)
 
-	eps, del := opt.Epsilon, opt.Delta
 	// We split the budget in half to calculate the count and the noised normalized sum
 	// TODO: this can be optimized for the Gaussian noise
 	halfEpsilon := eps / 2
@@ -125,16 +159,21 @@ func NewBoundedMeanFloat64(opt *BoundedMeanFloat64Options) *BoundedMeanFloat64 {
 
 	// Check that the parameters are compatible with the noise chosen by calling
 	// the noise on some dummy value.
-	n.AddNoiseFloat64(0, 1, 1, halfEpsilon, halfDelta)
+	if _, err := noise.AddNoiseFloat64E(n, 0, 1, 1, halfEpsilon, halfDelta); err != nil {
+		return nil, err
+	}
 
 	// Noised count of the entities.
-	count := NewCount(&CountOptions{
+	count, err := NewCountE(&CountOptions{
 		Epsilon:                      halfEpsilon,
 		Delta:                        halfDelta,
 		MaxPartitionsContributed:     maxPartitionsContributed,
 		Noise:                        n,
 		maxContributionsPerPartition: maxContributionsPerPartition,
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	// normalizedSum stores a noised sum of distances of the input entities from the middle of the
 	// range (i.e., "normalized noised sum").
@@ -157,7 +196,7 @@ func NewBoundedMeanFloat64(opt *BoundedMeanFloat64Options) *BoundedMeanFloat64 {
 	// delta = halfDelta. It will sum up (e - midpoint) for each entry e.
 	//
 	// 2. Count with epsilon = halfEpsilon, delta = halfDelta. It will count entities.
-	normalizedSum := NewBoundedSumFloat64(&BoundedSumFloat64Options{
+	normalizedSum, err := NewBoundedSumFloat64E(&BoundedSumFloat64Options{
 		Epsilon:                      halfEpsilon,
 		Delta:                        halfDelta,
 		MaxPartitionsContributed:     maxPartitionsContributed,
@@ -166,6 +205,9 @@ func NewBoundedMeanFloat64(opt *BoundedMeanFloat64Options) *BoundedMeanFloat64 {
 		Noise:                        n,
 		maxContributionsPerPartition: maxContributionsPerPartition,
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	return &BoundedMeanFloat64{
 		lower:          lower,
@@ -174,7 +216,8 @@ func NewBoundedMeanFloat64(opt *BoundedMeanFloat64Options) *BoundedMeanFloat64 {
 		count:          *count,
 		normalizedSum:  *normalizedSum,
 		resultReturned: false,
-	}
+		autoBounds:     autoBounds,
+	}, nil
 }
 
 // Add an entry to a BoundedMeanFloat64. It skips NaN entries and doesn't count them in the final result
@@ -182,52 +225,179 @@ func NewBoundedMeanFloat64(opt *BoundedMeanFloat64Options) *BoundedMeanFloat64 {
 // regardless of other entries, which would break the indistinguishability
 // property required for differential privacy.
 func (bm *BoundedMeanFloat64) Add(e float64) {
-	if bm.resultReturned {
+	if err := bm.AddE(e); err != nil {
 		// TODO: do not exit the program from within library code
-		log.Fatalf("The mean has already been calculated and returned. It cannot be amended.")
+		log.Fatal(err)
+	}
+}
+
+// AddE is the same as Add, but returns an error instead of exiting the program
+// if the result has already been returned.
+func (bm *BoundedMeanFloat64) AddE(e float64) error {
+	if bm.resultReturned {
+		return fmt.Errorf("The mean has already been calculated and returned. It cannot be amended.")
+	}
+	if math.IsNaN(e) {
+		return nil
+	}
+	if bm.autoBounds != nil {
+		bm.autoBounds.add(e)
+		return bm.count.IncrementE()
+	}
+	clamped, err := ClampFloat64(e, bm.lower, bm.upper)
+	if err != nil {
+		return fmt.Errorf("Couldn't clamp input value %v, err %v", e, err)
 	}
-	if !math.IsNaN(e) {
-		clamped, err := ClampFloat64(e, bm.lower, bm.upper)
-		if err != nil {
-			// TODO: do not exit the program from within library code
-			log.Fatalf("Couldn't clamp input value %v, err %v", e, err)
-		}
 
-		x := clamped - bm.midPoint
-		bm.normalizedSum.Add(x)
-		bm.count.Increment()
+	x := clamped - bm.midPoint
+	if err := bm.normalizedSum.AddE(x); err != nil {
+		return err
 	}
+	return bm.count.IncrementE()
 }
 
 // Result returns a differentially private average of elements added so far.
 // It can be called only once, after which no further operation can be done on the BoundedMeanFloat64.
 func (bm *BoundedMeanFloat64) Result() float64 {
-	if bm.resultReturned {
+	result, err := bm.ResultE()
+	if err != nil {
 		// TODO: do not exit the program from within library code
-		log.Fatalf("The mean has already been calculated and returned. It can only be returned once.")
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the result has already been returned, or an error wrapping
+// ErrBoundsNotFound if the bounds are determined automatically and ApproxBounds
+// fails to find them.
+func (bm *BoundedMeanFloat64) ResultE() (float64, error) {
+	if bm.resultReturned {
+		return 0, fmt.Errorf("The mean has already been calculated and returned. It can only be returned once.")
 	}
 	bm.resultReturned = true
-	noisedCount := math.Max(1.0, float64(bm.count.Result()))
-	noisedSum := bm.normalizedSum.Result()
+	if bm.autoBounds != nil {
+		if err := bm.setAutoBounds(); err != nil {
+			return 0, fmt.Errorf("BoundedMeanFloat64: %w: %v", ErrBoundsNotFound, err)
+		}
+	}
+	rawCount, err := bm.count.ResultE()
+	if err != nil {
+		return 0, err
+	}
+	noisedCount := math.Max(1.0, float64(rawCount))
+	noisedSum, err := bm.normalizedSum.ResultE()
+	if err != nil {
+		return 0, err
+	}
 	clamped, err := ClampFloat64(noisedSum/noisedCount+bm.midPoint, bm.lower, bm.upper)
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't clamp the result, err %v", err)
+	}
+	return clamped, nil
+}
+
+// ConfidenceInterval returns a confidence interval that contains the raw mean
+// of the clamped entries with probability at least 1-alpha. It can only be
+// called after the result was returned by Result. Computing it doesn't consume
+// any privacy budget.
+func (bm *BoundedMeanFloat64) ConfidenceInterval(alpha float64) noise.ConfidenceInterval {
+	confInt, err := bm.ConfidenceIntervalE(alpha)
 	if err != nil {
 		// TODO: do not exit the program from within library code
-		log.Fatalf("Couldn't clamp the result, err %v", err)
+		log.Fatal(err)
 	}
-	return clamped
+	return confInt
+}
+
+// ConfidenceIntervalE is the same as ConfidenceInterval, but returns an error
+// instead of exiting the program if no result was returned or alpha is
+// invalid.
+func (bm *BoundedMeanFloat64) ConfidenceIntervalE(alpha float64) (noise.ConfidenceInterval, error) {
+	if !bm.resultReturned {
+		return noise.ConfidenceInterval{}, fmt.Errorf("The confidence interval of the mean can only be computed after its result is returned.")
+	}
+	if err := checks.CheckAlpha("ConfidenceInterval (BoundedMeanFloat64)", alpha); err != nil {
+		return noise.ConfidenceInterval{}, err
+	}
+	// The noise of the count and of the normalized sum are independent, so
+	// both of their confidence intervals for alpha' = 1-√(1-alpha) contain
+	// their raw value with probability at least (1-alpha')² = 1-alpha.
+	splitAlpha := -math.Expm1(0.5 * math.Log1p(-alpha))
+	countConfInt, err := bm.count.ConfidenceIntervalE(splitAlpha)
+	if err != nil {
+		return noise.ConfidenceInterval{}, err
+	}
+	sumConfInt, err := bm.normalizedSum.ConfidenceIntervalE(splitAlpha)
+	if err != nil {
+		return noise.ConfidenceInterval{}, err
+	}
+	// As in Result, the count is at least 1. The ratio of the normalized sum
+	// and of the count is then smallest for the smallest sum, divided by the
+	// largest count if that sum is positive and by the smallest count
+	// otherwise, and conversely for the largest ratio.
+	minCount := math.Max(1, countConfInt.LowerBound)
+	maxCount := math.Max(1, countConfInt.UpperBound)
+	lower := sumConfInt.LowerBound / minCount
+	if sumConfInt.LowerBound >= 0 {
+		lower = sumConfInt.LowerBound / maxCount
+	}
+	upper := sumConfInt.UpperBound / maxCount
+	if sumConfInt.UpperBound >= 0 {
+		upper = sumConfInt.UpperBound / minCount
+	}
+	lower, err = ClampFloat64(lower+bm.midPoint, bm.lower, bm.upper)
+	if err != nil {
+		return noise.ConfidenceInterval{}, err
+	}
+	upper, err = ClampFloat64(upper+bm.midPoint, bm.lower, bm.upper)
+	if err != nil {
+		return noise.ConfidenceInterval{}, err
+	}
+	return noise.ConfidenceInterval{LowerBound: lower, UpperBound: upper}, nil
+}
+
+// setAutoBounds determines the bounds of bm with ApproxBounds, and sets the
+// normalized sum to the sum of the distances of the clamped entries from the
+// new midpoint.
+func (bm *BoundedMeanFloat64) setAutoBounds() error {
+	lower, upper, sum, err := bm.autoBounds.clampedSum()
+	if err != nil {
+		return err
+	}
+	bm.lower, bm.upper = lower, upper
+	bm.midPoint = lower + (upper-lower)/2.0
+	maxDistFromMidpoint := math.Abs(upper - bm.midPoint)
+	if err := bm.normalizedSum.setBounds(-maxDistFromMidpoint, maxDistFromMidpoint, bm.autoBounds.ApproxBounds.lInfSensitivity); err != nil {
+		return err
+	}
+	bm.normalizedSum.sum = sum - float64(bm.autoBounds.Count)*bm.midPoint
+	return nil
 }
 
 // Merge merges bm2 into bm (i.e., adds to bm all entries that were added to
 // bm2). bm2 is consumed by this operation: bm2 may not be used after it is
 // merged into bm.
 func (bm *BoundedMeanFloat64) Merge(bm2 *BoundedMeanFloat64) {
-	if err := checkMergeBoundedMeanFloat64(bm, bm2); err != nil {
+	if err := bm.MergeE(bm2); err != nil {
 		// TODO: do not exit the program from within library code
 		log.Exit(err)
 	}
+}
+
+// MergeE is the same as Merge, but returns an error instead of exiting the
+// program if bm and bm2 cannot be merged.
+func (bm *BoundedMeanFloat64) MergeE(bm2 *BoundedMeanFloat64) error {
+	if err := checkMergeBoundedMeanFloat64(bm, bm2); err != nil {
+		return err
+	}
+	if bm.autoBounds != nil {
+		bm.autoBounds.merge(bm2.autoBounds)
+	}
 	bm.normalizedSum.sum += bm2.normalizedSum.sum
 	bm.count.count += bm2.count.count
 	bm2.resultReturned = true
+	return nil
 }
 
 func checkMergeBoundedMeanFloat64(bm1, bm2 *BoundedMeanFloat64) error {
@@ -254,6 +424,7 @@ func (bm *BoundedMeanFloat64) GobEncode() ([]byte, error) {
 		EncodableNormalizedSum: &bm.normalizedSum,
 		MidPoint:               bm.midPoint,
 		ResultReturned:         bm.resultReturned,
+		AutoBounds:             bm.autoBounds,
 	}
 	bm.resultReturned = true
 	return encode(enc)
@@ -264,7 +435,6 @@ func (bm *BoundedMeanFloat64) GobDecode(data []byte) error {
 	var enc encodableBoundedMeanFloat64
 	err := decode(&enc, data)
 	if err != nil {
-		log.Fatalf("GobDecode: couldn't decode BoundedMeanFloat64 from bytes")
 		return err
 	}
 	*bm = BoundedMeanFloat64{
@@ -274,6 +444,13 @@ func (bm *BoundedMeanFloat64) GobDecode(data []byte) error {
 		normalizedSum:  *enc.EncodableNormalizedSum,
 		midPoint:       enc.MidPoint,
 		resultReturned: enc.ResultReturned,
+		autoBounds:     enc.AutoBounds,
+	}
+	// As when bm was created, the count, the sum and ApproxBounds draw from the
+	// same Source, which the count and the sum share through their noise.
+	bm.normalizedSum.noise = bm.count.noise
+	if bm.autoBounds != nil {
+		bm.autoBounds.ApproxBounds.setSource(noise.SourceOf(bm.count.noise))
 	}
 	return nil
 }
@@ -286,4 +463,5 @@ type encodableBoundedMeanFloat64 struct {
 	EncodableNormalizedSum *BoundedSumFloat64
 	MidPoint               float64
 	ResultReturned         bool
+	AutoBounds             *autoBoundsFloat64
 }
@@ -738,3 +738,136 @@ func TestBMFloat64Serialization(t *testing.T) {
 		}
 	}
 }
+
+// Checks that BoundedMeanFloat64s decoded from the same encoding, using noise
+// with a deterministic source, return the same result, including when the
+// bounds are determined automatically. Decoding used to replace the source with
+// the cryptographically secure one.
+func TestBMFloat64WithDeterministicSourceSerialization(t *testing.T) {
+	for _, autoBounds := range []bool{false, true} {
+		for seed := int64(0); seed < 10; seed++ {
+			newMean := func() *BoundedMeanFloat64 {
+				opt := &BoundedMeanFloat64Options{
+					Epsilon:                      ln3,
+					MaxContributionsPerPartition: 1,
+					Lower:                        -1,
+					Upper:                        5,
+					AutoBounds:                   autoBounds,
+					Noise:                        noise.WithSource(noise.Laplace(), rand.NewDeterministicSource(seed)),
+				}
+				if autoBounds {
+					opt.Lower, opt.Upper = 0, 0
+				}
+				bm := NewBoundedMeanFloat64(opt)
+				for i := 0; i < 1000; i++ {
+					bm.Add(2.5)
+				}
+				return bm
+			}
+			bytes, err := encode(newMean())
+			if err != nil {
+				t.Fatalf("encode(BoundedMeanFloat64) error: %v", err)
+			}
+			decoded1, decoded2 := new(BoundedMeanFloat64), new(BoundedMeanFloat64)
+			for _, decoded := range []*BoundedMeanFloat64{decoded1, decoded2} {
+				if err := decode(decoded, bytes); err != nil {
+					t.Fatalf("decode(BoundedMeanFloat64) error: %v", err)
+				}
+			}
+			if got1, got2 := decoded1.Result(), decoded2.Result(); got1 != got2 {
+				t.Errorf("Result: for BoundedMeanFloat64s decoded from the same encoding with AutoBounds=%t and seed %d got %f and %f, want identical results", autoBounds, seed, got1, got2)
+			}
+		}
+	}
+}
+
+func TestNewBoundedMeanFloat64EReturnsErrorForInvalidOptions(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opt  *BoundedMeanFloat64Options
+	}{
+		{"no MaxContributionsPerPartition", &BoundedMeanFloat64Options{Epsilon: ln3, Lower: -1, Upper: 5}},
+		{"default bounds", &BoundedMeanFloat64Options{Epsilon: ln3, MaxContributionsPerPartition: 1}},
+		{"lower greater than upper", &BoundedMeanFloat64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 5, Upper: -1}},
+		{"negative epsilon", &BoundedMeanFloat64Options{Epsilon: -1, MaxContributionsPerPartition: 1, Lower: -1, Upper: 5}},
+	} {
+		if _, err := NewBoundedMeanFloat64E(tc.opt); err == nil {
+			t.Errorf("NewBoundedMeanFloat64E: for %s got no error, want error", tc.desc)
+		}
+	}
+}
+
+func TestBMEReturnsErrorAfterResultFloat64(t *testing.T) {
+	bm := getNoiselessBMF()
+	if err := bm.AddE(1); err != nil {
+		t.Fatalf("AddE: got error %v", err)
+	}
+	if _, err := bm.ResultE(); err != nil {
+		t.Fatalf("ResultE: got error %v", err)
+	}
+	if err := bm.AddE(2); err == nil {
+		t.Errorf("AddE: after ResultE got no error, want error")
+	}
+	if _, err := bm.ResultE(); err == nil {
+		t.Errorf("ResultE: when called twice got no error, want error")
+	}
+	if err := getNoiselessBMF().MergeE(bm); err == nil {
+		t.Errorf("MergeE: with a BoundedMean that returned its result got no error, want error")
+	}
+}
+
+func TestBMConfidenceIntervalFloat64(t *testing.T) {
+	bm := getNoiselessBMF()
+	for _, e := range []float64{1, 2, 3.5} {
+		bm.Add(e)
+	}
+	bm.Result()
+	// Without noise, the interval only contains the mean.
+	want := noise.ConfidenceInterval{LowerBound: 6.5 / 3, UpperBound: 6.5 / 3}
+	if got := bm.ConfidenceInterval(0.05); !ApproxEqual(got.LowerBound, want.LowerBound) || !ApproxEqual(got.UpperBound, want.UpperBound) {
+		t.Errorf("ConfidenceInterval(0.05): got %+v, want %+v", got, want)
+	}
+}
+
+// Checks that the confidence interval of BoundedMeanFloat64 contains the raw
+// mean with probability at least 1-alpha.
+func TestBMConfidenceIntervalFloat64Coverage(t *testing.T) {
+	const numberOfRuns = 2000
+	const alpha = 0.2
+	covered := 0
+	for i := 0; i < numberOfRuns; i++ {
+		bm := NewBoundedMeanFloat64(&BoundedMeanFloat64Options{
+			Epsilon:                      ln3,
+			MaxPartitionsContributed:     1,
+			MaxContributionsPerPartition: 1,
+			Lower:                        0,
+			Upper:                        10,
+		})
+		for j := 0; j < 20; j++ {
+			bm.Add(float64(j % 5))
+		}
+		bm.Result()
+		confInt := bm.ConfidenceInterval(alpha)
+		if confInt.LowerBound <= 2 && 2 <= confInt.UpperBound {
+			covered++
+		}
+		if confInt.LowerBound < 0 || confInt.UpperBound > 10 {
+			t.Errorf("ConfidenceInterval(%f): got %+v, want an interval within the bounds [0, 10]", alpha, confInt)
+		}
+	}
+	// The coverage is approximately Gaussian distributed with a standard
+	// deviation of at most sqrt(alpha(1-alpha)/numberOfRuns). The tolerance is
+	// set to its 99.9995% quantile, so the test falsely rejects with a
+	// probability of at most 10⁻⁵.
+	coverage := float64(covered) / numberOfRuns
+	if tolerance := 4.41717 * math.Sqrt(alpha*(1-alpha)/numberOfRuns); coverage < 1-alpha-tolerance {
+		t.Errorf("ConfidenceInterval(%f): got coverage %f, want at least %f", alpha, coverage, 1-alpha)
+	}
+}
+
+func TestBMConfidenceIntervalEReturnsErrorWithoutResultFloat64(t *testing.T) {
+	bm := getNoiselessBMF()
+	if _, err := bm.ConfidenceIntervalE(0.05); err == nil {
+		t.Errorf("ConfidenceIntervalE before Result: got no error, want error")
+	}
+}
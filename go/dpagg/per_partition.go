@@ -0,0 +1,434 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"reflect"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/go/rand"
+)
+
+// PerPartitionAggregation is the aggregation computed by a PerPartition for
+// each partition.
+type PerPartitionAggregation int
+
+const (
+	// PerPartitionCount counts the contributions to each partition. The values
+	// of the contributions are ignored.
+	PerPartitionCount PerPartitionAggregation = iota
+	// PerPartitionSum sums the values of the contributions to each partition,
+	// after clamping them between Lower and Upper.
+	PerPartitionSum
+	// PerPartitionMean averages the values of the contributions to each
+	// partition, after clamping them between Lower and Upper.
+	PerPartitionMean
+)
+
+func (a PerPartitionAggregation) String() string {
+	switch a {
+	case PerPartitionCount:
+		return "PerPartitionCount"
+	case PerPartitionSum:
+		return "PerPartitionSum"
+	case PerPartitionMean:
+		return "PerPartitionMean"
+	}
+	return fmt.Sprintf("PerPartitionAggregation(%d)", int(a))
+}
+
+// PerPartition computes a differentially private aggregation per partition
+// of a collection of (privacy ID, partition key, value) records, i.e. a DP
+// equivalent of a GROUP BY query, in memory.
+//
+// It bounds contributions for each privacy ID: it keeps at most
+// MaxContributionsPerPartition contributions per partition, picked uniformly
+// at random with reservoir sampling, and at most MaxPartitionsContributed
+// partitions, also picked uniformly at random. It then aggregates the kept
+// contributions of each partition, and uses PreAggSelectPartition to decide
+// which partitions to return.
+//
+// The privacy budget is split between partition selection and aggregation:
+// half of Epsilon is used for each. With Gaussian and discrete Gaussian noise,
+// half of Delta is used for each; otherwise, all of Delta is used for
+// partition selection.
+//
+// All records are kept in memory until the result is returned, since which
+// contributions are kept can only be decided once all records are added.
+//
+// Not thread-safe.
+type PerPartition struct {
+	// Parameters
+	aggregation                  PerPartitionAggregation
+	epsilonAggregation           float64
+	deltaAggregation             float64
+	epsilonPartitionSelection    float64
+	deltaPartitionSelection      float64
+	maxPartitionsContributed     int64
+	maxContributionsPerPartition int64
+	lower, upper                 float64
+	noise                        noise.Noise
+	source                       rand.Source
+	random                       *rand.Rand
+
+	// State variables
+	// Privacy IDs and partition keys are kept in the order they were first
+	// added, so that the result only depends on the randomness source.
+	ids            []interface{}
+	contributions  map[interface{}]*idContributions
+	partitionKeys  []interface{}
+	knownPartition map[interface{}]bool
+	resultReturned bool // whether the result has already been returned
+}
+
+// idContributions are the contributions of a single privacy ID.
+type idContributions struct {
+	partitionKeys []interface{} // in the order they were first added
+	values        map[interface{}]*reservoir
+}
+
+// reservoir holds up to k values, sampled uniformly at random among all the
+// values added to it.
+type reservoir struct {
+	values []float64
+	seen   int64
+}
+
+func (r *reservoir) add(v float64, k int64, random *rand.Rand) {
+	r.seen++
+	if int64(len(r.values)) < k {
+		r.values = append(r.values, v)
+		return
+	}
+	if i := random.I63n(r.seen); i < k {
+		r.values[i] = v
+	}
+}
+
+// PerPartitionOptions contains the options necessary to initialize a
+// PerPartition.
+type PerPartitionOptions struct {
+	Aggregation PerPartitionAggregation // Aggregation computed for each partition. Defaults to PerPartitionCount.
+	Epsilon     float64                 // Privacy parameter ε, for both partition selection and aggregation. Required.
+	Delta       float64                 // Privacy parameter δ, for both partition selection and aggregation. Required.
+	// How many distinct partitions may a single user contribute to? Required.
+	MaxPartitionsContributed int64
+	// How many times may a single user contribute to a single partition?
+	// Defaults to 1.
+	MaxContributionsPerPartition int64
+	// Lower and Upper bounds for clamping values. Required with PerPartitionSum
+	// and PerPartitionMean, must be such that Lower < Upper.
+	Lower, Upper float64
+	Noise        noise.Noise // Type of noise used. Defaults to Laplace noise.
+	// Source is the source of randomness used to bound contributions, to select
+	// partitions and to add noise. Optional, defaults to a cryptographically
+	// secure source. Only set it in tests that need reproducible results, e.g.
+	// with rand.NewDeterministicSource: the result is only differentially
+	// private if Source is cryptographically secure.
+	Source rand.Source
+}
+
+// NewPerPartition returns a new PerPartition without any records. It exits the
+// program if the options are invalid; use NewPerPartitionE to handle this case
+// instead.
+func NewPerPartition(opt *PerPartitionOptions) *PerPartition {
+	pp, err := NewPerPartitionE(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewPerPartition: %v", err)
+	}
+	return pp
+}
+
+// NewPerPartitionE is the same as NewPerPartition, but returns an error
+// instead of exiting the program if the options are invalid.
+func NewPerPartitionE(opt *PerPartitionOptions) (*PerPartition, error) {
+	if opt == nil {
+		opt = &PerPartitionOptions{}
+	}
+	if opt.Aggregation < PerPartitionCount || opt.Aggregation > PerPartitionMean {
+		return nil, fmt.Errorf("NewPerPartition: unknown aggregation %v", opt.Aggregation)
+	}
+	if opt.MaxPartitionsContributed <= 0 {
+		return nil, fmt.Errorf("NewPerPartition: MaxPartitionsContributed must be set to a positive value, got %d", opt.MaxPartitionsContributed)
+	}
+	// Set defaults.
+	maxContributionsPerPartition := opt.MaxContributionsPerPartition
+	if maxContributionsPerPartition == 0 {
+		maxContributionsPerPartition = 1
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	random := rand.Secure()
+	if opt.Source != nil {
+		n = noise.WithSource(n, opt.Source)
+		random = rand.New(opt.Source)
+	}
+
+	epsilonPartitionSelection := opt.Epsilon / 2
+	epsilonAggregation := opt.Epsilon - epsilonPartitionSelection
+	// Only Gaussian noise uses δ; otherwise, all of δ is used for partition
+	// selection.
+	deltaPartitionSelection, deltaAggregation := opt.Delta, 0.0
+	if k := noise.ToKind(n); k == noise.GaussianNoise || k == noise.DiscreteGaussianNoise {
+		deltaPartitionSelection = opt.Delta / 2
+		deltaAggregation = opt.Delta - deltaPartitionSelection
+	}
+
+	pp := &PerPartition{
+		aggregation:                  opt.Aggregation,
+		epsilonAggregation:           epsilonAggregation,
+		deltaAggregation:             deltaAggregation,
+		epsilonPartitionSelection:    epsilonPartitionSelection,
+		deltaPartitionSelection:      deltaPartitionSelection,
+		maxPartitionsContributed:     opt.MaxPartitionsContributed,
+		maxContributionsPerPartition: maxContributionsPerPartition,
+		lower:                        opt.Lower,
+		upper:                        opt.Upper,
+		noise:                        n,
+		source:                       opt.Source,
+		random:                       random,
+		contributions:                make(map[interface{}]*idContributions),
+		knownPartition:               make(map[interface{}]bool),
+	}
+	// Check the remaining options by initializing the aggregation of a partition.
+	if _, err := pp.newPartitionAggregation(); err != nil {
+		return nil, fmt.Errorf("NewPerPartition: %v", err)
+	}
+	return pp, nil
+}
+
+// Add adds a record contributed by privacyID to the partition partitionKey.
+// privacyID and partitionKey must be comparable, i.e. usable as map keys.
+// With PerPartitionCount, value is ignored.
+func (pp *PerPartition) Add(privacyID, partitionKey interface{}, value float64) {
+	if err := pp.AddE(privacyID, partitionKey, value); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+}
+
+// AddE is the same as Add, but returns an error instead of exiting the program
+// if the result has already been returned, or privacyID or partitionKey isn't
+// comparable.
+func (pp *PerPartition) AddE(privacyID, partitionKey interface{}, value float64) error {
+	if pp.resultReturned {
+		return fmt.Errorf("The per-partition aggregation has already been calculated and returned. It cannot be amended.")
+	}
+	if err := checkComparable("privacy ID", privacyID); err != nil {
+		return err
+	}
+	if err := checkComparable("partition key", partitionKey); err != nil {
+		return err
+	}
+	c, ok := pp.contributions[privacyID]
+	if !ok {
+		c = &idContributions{values: make(map[interface{}]*reservoir)}
+		pp.contributions[privacyID] = c
+		pp.ids = append(pp.ids, privacyID)
+	}
+	r, ok := c.values[partitionKey]
+	if !ok {
+		r = &reservoir{}
+		c.values[partitionKey] = r
+		c.partitionKeys = append(c.partitionKeys, partitionKey)
+	}
+	if !pp.knownPartition[partitionKey] {
+		pp.knownPartition[partitionKey] = true
+		pp.partitionKeys = append(pp.partitionKeys, partitionKey)
+	}
+	r.add(value, pp.maxContributionsPerPartition, pp.random)
+	return nil
+}
+
+func checkComparable(name string, key interface{}) error {
+	if key == nil {
+		return fmt.Errorf("The %s must not be nil.", name)
+	}
+	if t := reflect.TypeOf(key); !t.Comparable() {
+		return fmt.Errorf("The %s must be comparable, got a %v.", name, t)
+	}
+	return nil
+}
+
+// partitionAggregation holds the partition selection and the aggregation of a
+// single partition. Only one of count, sum and mean is set, depending on the
+// aggregation.
+type partitionAggregation struct {
+	selection *PreAggSelectPartition
+	count     *Count
+	sum       *BoundedSumFloat64
+	mean      *BoundedMeanFloat64
+}
+
+func (pp *PerPartition) newPartitionAggregation() (*partitionAggregation, error) {
+	selection, err := NewPreAggSelectPartitionE(&PreAggSelectPartitionOptions{
+		Epsilon:                  pp.epsilonPartitionSelection,
+		Delta:                    pp.deltaPartitionSelection,
+		MaxPartitionsContributed: pp.maxPartitionsContributed,
+		Source:                   pp.source,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pa := &partitionAggregation{selection: selection}
+	switch pp.aggregation {
+	case PerPartitionCount:
+		pa.count, err = NewCountE(&CountOptions{
+			Epsilon:                      pp.epsilonAggregation,
+			Delta:                        pp.deltaAggregation,
+			MaxPartitionsContributed:     pp.maxPartitionsContributed,
+			Noise:                        pp.noise,
+			maxContributionsPerPartition: pp.maxContributionsPerPartition,
+		})
+	case PerPartitionSum:
+		pa.sum, err = NewBoundedSumFloat64E(&BoundedSumFloat64Options{
+			Epsilon:                      pp.epsilonAggregation,
+			Delta:                        pp.deltaAggregation,
+			MaxPartitionsContributed:     pp.maxPartitionsContributed,
+			Lower:                        pp.lower,
+			Upper:                        pp.upper,
+			Noise:                        pp.noise,
+			maxContributionsPerPartition: pp.maxContributionsPerPartition,
+		})
+	case PerPartitionMean:
+		pa.mean, err = NewBoundedMeanFloat64E(&BoundedMeanFloat64Options{
+			Epsilon:                      pp.epsilonAggregation,
+			Delta:                        pp.deltaAggregation,
+			MaxPartitionsContributed:     pp.maxPartitionsContributed,
+			MaxContributionsPerPartition: pp.maxContributionsPerPartition,
+			Lower:                        pp.lower,
+			Upper:                        pp.upper,
+			Noise:                        pp.noise,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pa, nil
+}
+
+func (pa *partitionAggregation) add(v float64) error {
+	switch {
+	case pa.count != nil:
+		return pa.count.IncrementE()
+	case pa.sum != nil:
+		return pa.sum.AddE(v)
+	default:
+		return pa.mean.AddE(v)
+	}
+}
+
+func (pa *partitionAggregation) result() (float64, error) {
+	switch {
+	case pa.count != nil:
+		result, err := pa.count.ResultE()
+		return float64(result), err
+	case pa.sum != nil:
+		return pa.sum.ResultE()
+	default:
+		return pa.mean.ResultE()
+	}
+}
+
+// samplePartitions returns up to maxPartitionsContributed keys of c, picked
+// uniformly at random.
+func (pp *PerPartition) samplePartitions(c *idContributions) []interface{} {
+	keys := c.partitionKeys
+	if int64(len(keys)) <= pp.maxPartitionsContributed {
+		return keys
+	}
+	// Partial Fisher-Yates shuffle on a copy, so that the order in which the
+	// keys were added is preserved.
+	keys = append([]interface{}(nil), keys...)
+	for i := int64(0); i < pp.maxPartitionsContributed; i++ {
+		j := i + pp.random.I63n(int64(len(keys))-i)
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+	return keys[:pp.maxPartitionsContributed]
+}
+
+// Result bounds contributions, selects partitions and returns a map from the
+// key of each selected partition to its differentially private aggregate. It
+// can be called only once, after which no further operation can be done on
+// the PerPartition.
+func (pp *PerPartition) Result() map[interface{}]float64 {
+	result, err := pp.ResultE()
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the result has already been returned.
+func (pp *PerPartition) ResultE() (map[interface{}]float64, error) {
+	if pp.resultReturned {
+		return nil, fmt.Errorf("The per-partition aggregation has already been calculated and returned. It can only be returned once.")
+	}
+	pp.resultReturned = true
+
+	aggregations := make(map[interface{}]*partitionAggregation)
+	for _, id := range pp.ids {
+		c := pp.contributions[id]
+		for _, key := range pp.samplePartitions(c) {
+			pa, ok := aggregations[key]
+			if !ok {
+				var err error
+				if pa, err = pp.newPartitionAggregation(); err != nil {
+					return nil, err
+				}
+				aggregations[key] = pa
+			}
+			if err := pa.selection.AddE(); err != nil {
+				return nil, err
+			}
+			for _, v := range c.values[key].values {
+				if err := pa.add(v); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	// The records aren't needed anymore.
+	pp.ids, pp.contributions = nil, nil
+
+	results := make(map[interface{}]float64)
+	for _, key := range pp.partitionKeys {
+		pa, ok := aggregations[key]
+		if !ok {
+			// No privacy ID kept its contributions to this partition.
+			continue
+		}
+		keep, err := pa.selection.ResultE()
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			continue
+		}
+		if results[key], err = pa.result(); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,231 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/go/rand"
+	"github.com/google/go-cmp/cmp"
+)
+
+func getNoiselessPerPartition(aggregation PerPartitionAggregation, maxPartitionsContributed, maxContributionsPerPartition int64) *PerPartition {
+	return NewPerPartition(&PerPartitionOptions{
+		Aggregation:                  aggregation,
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxPartitionsContributed:     maxPartitionsContributed,
+		MaxContributionsPerPartition: maxContributionsPerPartition,
+		Lower:                        -1,
+		Upper:                        5,
+		Noise:                        noNoise{},
+	})
+}
+
+func TestPerPartitionCountBoundsContributionsPerPartition(t *testing.T) {
+	pp := getNoiselessPerPartition(PerPartitionCount, 2, 3)
+	for id := 0; id < 1000; id++ {
+		for i := 0; i < 5; i++ {
+			pp.Add(id, "a", 0)
+		}
+		pp.Add(id, "b", 0)
+	}
+	want := map[interface{}]float64{"a": 3000, "b": 1000}
+	if got := pp.Result(); !cmp.Equal(got, want) {
+		t.Errorf("Result: got %v, want %v", got, want)
+	}
+}
+
+func TestPerPartitionCountBoundsPartitionsContributed(t *testing.T) {
+	pp := getNoiselessPerPartition(PerPartitionCount, 2, 1)
+	for id := 0; id < 1000; id++ {
+		for p := 0; p < 4; p++ {
+			pp.Add(id, p, 0)
+		}
+	}
+	got := pp.Result()
+	// Each privacy ID contributes to 2 of the 4 partitions, picked at random.
+	total := 0.0
+	for p, count := range got {
+		if count < 300 || count > 700 {
+			t.Errorf("Result: got count %f for partition %v, want about 500", count, p)
+		}
+		total += count
+	}
+	if len(got) != 4 || total != 2000 {
+		t.Errorf("Result: got %v, want 4 partitions with a total count of 2000", got)
+	}
+}
+
+func TestPerPartitionSumAndMeanClampValues(t *testing.T) {
+	for _, tc := range []struct {
+		aggregation PerPartitionAggregation
+		want        map[interface{}]float64
+	}{
+		// Each privacy ID contributes 10, clamped to 5, and -3, clamped to -1.
+		{PerPartitionSum, map[interface{}]float64{int64(1): 4000}},
+		{PerPartitionMean, map[interface{}]float64{int64(1): 2}},
+	} {
+		pp := getNoiselessPerPartition(tc.aggregation, 1, 2)
+		for id := 0; id < 1000; id++ {
+			pp.Add(id, int64(1), 10)
+			pp.Add(id, int64(1), -3)
+		}
+		if got := pp.Result(); !cmp.Equal(got, tc.want, cmp.Comparer(ApproxEqual)) {
+			t.Errorf("Result with %v: got %v, want %v", tc.aggregation, got, tc.want)
+		}
+	}
+}
+
+func TestPerPartitionDropsPartitionsWithFewPrivacyIDs(t *testing.T) {
+	pp := getNoiselessPerPartition(PerPartitionCount, 1, 1)
+	for id := 0; id < 1000; id++ {
+		pp.Add(id, "many", 0)
+	}
+	// Keeping a partition with a single privacy ID happens with probability at
+	// most δ.
+	pp.Add(1000, "few", 0)
+	got := pp.Result()
+	if _, ok := got["few"]; ok {
+		t.Errorf("Result: got %v, want partition \"few\" to be dropped", got)
+	}
+	if _, ok := got["many"]; !ok {
+		t.Errorf("Result: got %v, want partition \"many\" to be kept", got)
+	}
+}
+
+func TestPerPartitionWithDeterministicSourceIsReproducible(t *testing.T) {
+	var got [2]map[interface{}]float64
+	for i := range got {
+		pp := NewPerPartition(&PerPartitionOptions{
+			Aggregation:                  PerPartitionSum,
+			Epsilon:                      ln3,
+			Delta:                        tenfive,
+			MaxPartitionsContributed:     2,
+			MaxContributionsPerPartition: 2,
+			Lower:                        0,
+			Upper:                        10,
+			Noise:                        noise.Laplace(),
+			Source:                       rand.NewDeterministicSource(42),
+		})
+		for id := 0; id < 200; id++ {
+			for p := 0; p < 5; p++ {
+				pp.Add(id, p, float64(id%10))
+				pp.Add(id, p, float64(p))
+				pp.Add(id, p, 1)
+			}
+		}
+		got[i] = pp.Result()
+	}
+	if !cmp.Equal(got[0], got[1]) {
+		t.Errorf("Result: with the same deterministic source got %v and %v, want identical results", got[0], got[1])
+	}
+}
+
+// Checks that with noise that doesn't use δ, all of δ is used for partition
+// selection.
+func TestPerPartitionWithDiscreteLaplaceNoise(t *testing.T) {
+	pp, err := NewPerPartitionE(&PerPartitionOptions{
+		Aggregation:              PerPartitionCount,
+		Epsilon:                  ln3,
+		Delta:                    tenfive,
+		MaxPartitionsContributed: 1,
+		Noise:                    noise.DiscreteLaplace(),
+	})
+	if err != nil {
+		t.Fatalf("NewPerPartitionE: with discrete Laplace noise and δ>0 got error %v", err)
+	}
+	if pp.deltaAggregation != 0 || pp.deltaPartitionSelection != tenfive {
+		t.Errorf("NewPerPartitionE: with discrete Laplace noise got δ=%e for the aggregation and δ=%e for partition selection, want 0 and %e", pp.deltaAggregation, pp.deltaPartitionSelection, tenfive)
+	}
+	for id := 0; id < 1000; id++ {
+		pp.Add(id, "a", 0)
+	}
+	if got := pp.Result(); len(got) != 1 || got["a"] < 900 || got["a"] > 1100 {
+		t.Errorf("Result: got %v, want a count of about 1000 for partition \"a\"", got)
+	}
+}
+
+func TestReservoirKeepsAtMostKValues(t *testing.T) {
+	r := &reservoir{}
+	for v := 0; v < 100; v++ {
+		r.add(float64(v), 3, rand.Secure())
+	}
+	if len(r.values) != 3 || r.seen != 100 {
+		t.Fatalf("add: got %d values out of %d seen, want 3 out of 100", len(r.values), r.seen)
+	}
+	seen := make(map[float64]bool)
+	for _, v := range r.values {
+		if v < 0 || v >= 100 || seen[v] {
+			t.Errorf("add: got values %v, want 3 distinct values added to the reservoir", r.values)
+		}
+		seen[v] = true
+	}
+}
+
+func TestPerPartitionReturnsResultOnlyOnce(t *testing.T) {
+	pp := getNoiselessPerPartition(PerPartitionCount, 1, 1)
+	pp.Add(1, 1, 0)
+	pp.Result()
+	if _, err := pp.ResultE(); err == nil {
+		t.Errorf("ResultE: when the result was already returned got no error, want error")
+	}
+	if err := pp.AddE(1, 1, 0); err == nil {
+		t.Errorf("AddE: when the result was already returned got no error, want error")
+	}
+}
+
+func TestPerPartitionAddInvalidKeysReturnsError(t *testing.T) {
+	pp := getNoiselessPerPartition(PerPartitionCount, 1, 1)
+	for _, tc := range []struct {
+		desc                   string
+		privacyID, partitionID interface{}
+	}{
+		{"nil privacy ID", nil, 1},
+		{"nil partition key", 1, nil},
+		{"non-comparable privacy ID", []int{1}, 1},
+		{"non-comparable partition key", 1, map[int]int{}},
+	} {
+		if err := pp.AddE(tc.privacyID, tc.partitionID, 0); err == nil {
+			t.Errorf("AddE: with %s got no error, want error", tc.desc)
+		}
+	}
+}
+
+func TestNewPerPartitionInvalidOptionsReturnsError(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opt  *PerPartitionOptions
+	}{
+		{"no options", nil},
+		{"MaxPartitionsContributed unset",
+			&PerPartitionOptions{Epsilon: ln3, Delta: tenfive}},
+		{"unknown aggregation",
+			&PerPartitionOptions{Aggregation: PerPartitionAggregation(42), Epsilon: ln3, Delta: tenfive, MaxPartitionsContributed: 1}},
+		{"no delta",
+			&PerPartitionOptions{Epsilon: ln3, MaxPartitionsContributed: 1}},
+		{"sum without bounds",
+			&PerPartitionOptions{Aggregation: PerPartitionSum, Epsilon: ln3, Delta: tenfive, MaxPartitionsContributed: 1}},
+		{"mean with lower > upper",
+			&PerPartitionOptions{Aggregation: PerPartitionMean, Epsilon: ln3, Delta: tenfive, MaxPartitionsContributed: 1, Lower: 5, Upper: 1}},
+	} {
+		if _, err := NewPerPartitionE(tc.opt); err == nil {
+			t.Errorf("NewPerPartitionE: with %s got no error, want error", tc.desc)
+		}
+	}
+}
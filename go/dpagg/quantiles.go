@@ -0,0 +1,483 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// Constants used for the quantile tree.
+const (
+	defaultTreeHeight      = 4
+	defaultBranchingFactor = 16
+	rootIndex              = 0
+)
+
+// BoundedQuantiles calculates differentially private quantiles of a collection
+// of float64 values using a quantile tree mechanism.
+//
+// The range [lower, upper] is split into branchingFactor^treeHeight buckets of
+// equal width, which are the leaves of a complete tree of height treeHeight.
+// Every inner node of the tree counts the number of values that fall in the
+// buckets of its subtree. When computing a quantile, noise is added to the
+// count of each node that is visited, and the tree is traversed from the root
+// to a leaf by choosing, at each level, the child containing the requested
+// rank. The result is then obtained by linear interpolation within this leaf.
+//
+// Since each value contributes to exactly one node per level of the tree, the
+// L_0 sensitivity of the node counts is treeHeight times the number of
+// partitions a user may contribute to.
+//
+// BoundedQuantiles supports scaling the noise in the case where users can
+// contribute to multiple partitions (via the MaxPartitionsContributed parameter)
+// and can contribute to a single partition multiple times
+// (via the MaxContributionsPerPartition parameter).
+//
+// Not thread-safe.
+type BoundedQuantiles struct {
+	// Parameters
+	epsilon           float64
+	delta             float64
+	l0Sensitivity     int64
+	lInfSensitivity   float64
+	lower             float64
+	upper             float64
+	treeHeight        int
+	branchingFactor   int
+	numLeaves         int
+	leftmostLeafIndex int
+	noise             noise.Noise
+	noiseKind         noise.Kind // necessary for serializing noise.Noise information
+
+	// State variables
+	tree map[int]int64
+	// noisedTree caches the noised counts of the nodes that have been visited
+	// by Result, so that the noise of a given node is only drawn once.
+	noisedTree     map[int]float64
+	resultReturned bool // whether a result has already been returned
+}
+
+func bqEquallyInitialized(bq1, bq2 *BoundedQuantiles) bool {
+	return bq1.epsilon == bq2.epsilon &&
+		bq1.delta == bq2.delta &&
+		bq1.l0Sensitivity == bq2.l0Sensitivity &&
+		bq1.lInfSensitivity == bq2.lInfSensitivity &&
+		bq1.lower == bq2.lower &&
+		bq1.upper == bq2.upper &&
+		bq1.treeHeight == bq2.treeHeight &&
+		bq1.branchingFactor == bq2.branchingFactor &&
+		bq1.noiseKind == bq2.noiseKind
+}
+
+// BoundedQuantilesOptions contains the options necessary to initialize a BoundedQuantiles.
+type BoundedQuantilesOptions struct {
+	Epsilon                      float64 // Privacy parameter ε. Required.
+	Delta                        float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed     int64   // How many distinct partitions may a single user contribute to? Defaults to 1.
+	MaxContributionsPerPartition int64   // How many times may a single user contribute to a single partition? Required.
+	// Lower and Upper bounds for clamping. Default to 0; must be such that Lower < Upper.
+	Lower, Upper float64
+	Noise        noise.Noise // Type of noise used in BoundedQuantiles. Defaults to Laplace noise.
+	// It is very likely that the default values of TreeHeight and
+	// BranchingFactor are good enough for most use cases. Larger values result
+	// in more fine-grained buckets, but also in more noise.
+	TreeHeight      int // Height of the quantile tree. Defaults to 4.
+	BranchingFactor int // Number of children of every non-leaf node. Defaults to 16.
+}
+
+// NewBoundedQuantiles returns a new BoundedQuantiles.
+// It exits the program if the options are invalid; use NewBoundedQuantilesE
+// to handle this case instead.
+func NewBoundedQuantiles(opt *BoundedQuantilesOptions) *BoundedQuantiles {
+	bq, err := NewBoundedQuantilesE(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewBoundedQuantiles: %v", err)
+	}
+	return bq
+}
+
+// NewBoundedQuantilesE is the same as NewBoundedQuantiles, but returns an
+// error instead of exiting the program if the options are invalid.
+func NewBoundedQuantilesE(opt *BoundedQuantilesOptions) (*BoundedQuantiles, error) {
+	if opt == nil {
+		opt = &BoundedQuantilesOptions{}
+	}
+
+	maxContributionsPerPartition := opt.MaxContributionsPerPartition
+	if maxContributionsPerPartition == 0 {
+		return nil, fmt.Errorf("NewBoundedQuantiles requires a value for MaxContributionsPerPartition")
+	}
+
+	// Set defaults.
+	maxPartitionsContributed := opt.MaxPartitionsContributed
+	if maxPartitionsContributed == 0 {
+		maxPartitionsContributed = 1
+	}
+	treeHeight := opt.TreeHeight
+	if treeHeight == 0 {
+		treeHeight = defaultTreeHeight
+	}
+	branchingFactor := opt.BranchingFactor
+	if branchingFactor == 0 {
+		branchingFactor = defaultBranchingFactor
+	}
+	if err := checkTreeParameters(treeHeight, branchingFactor); err != nil {
+		return nil, err
+	}
+
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	// Check bounds.
+	lower, upper := opt.Lower, opt.Upper
+	if lower == 0 && upper == 0 {
+		return nil, fmt.Errorf("NewBoundedQuantiles requires a non-default value for Lower or Upper (automatic bounds determination is not implemented yet)")
+	}
+	if err := checks.CheckBoundsFloat64("NewBoundedQuantiles", lower, upper); err != nil {
+		return nil, err
+	}
+
+	// Each value added to the tree increments the count of exactly one node per
+	// level (the root excluded), so a single user can influence at most
+	// treeHeight nodes per partition.
+	l0 := maxPartitionsContributed * int64(treeHeight)
+	lInf := float64(maxContributionsPerPartition)
+	// Check that the parameters are compatible with the noise chosen by calling
+	// the noise on some dummy value.
+	eps, del := opt.Epsilon, opt.Delta
+	if _, err := noise.AddNoiseFloat64E(n, 0, l0, lInf, eps, del); err != nil {
+		return nil, err
+	}
+
+	numLeaves := intPow(branchingFactor, treeHeight)
+	return &BoundedQuantiles{
+		epsilon:           eps,
+		delta:             del,
+		l0Sensitivity:     l0,
+		lInfSensitivity:   lInf,
+		lower:             lower,
+		upper:             upper,
+		treeHeight:        treeHeight,
+		branchingFactor:   branchingFactor,
+		numLeaves:         numLeaves,
+		leftmostLeafIndex: (numLeaves - 1) / (branchingFactor - 1),
+		noise:             n,
+		noiseKind:         noise.ToKind(n),
+		tree:              make(map[int]int64),
+		noisedTree:        make(map[int]float64),
+		resultReturned:    false,
+	}, nil
+}
+
+// checkTreeParameters returns an error if the tree height or the branching
+// factor are invalid, or if the resulting tree is too large to be indexed.
+func checkTreeParameters(treeHeight, branchingFactor int) error {
+	if treeHeight < 1 {
+		return fmt.Errorf("TreeHeight is %d, should be at least 1", treeHeight)
+	}
+	if branchingFactor < 2 {
+		return fmt.Errorf("BranchingFactor is %d, should be at least 2", branchingFactor)
+	}
+	if float64(treeHeight)*math.Log2(float64(branchingFactor)) >= 62 {
+		return fmt.Errorf("TreeHeight (%d) and BranchingFactor (%d) are too high - the number of nodes of the tree would overflow", treeHeight, branchingFactor)
+	}
+	return nil
+}
+
+// intPow returns base^exp for non-negative exp.
+func intPow(base, exp int) int {
+	result := 1
+	for i := 0; i < exp; i++ {
+		result *= base
+	}
+	return result
+}
+
+// Add an entry to a BoundedQuantiles. It skips NaN entries and doesn't count
+// them in the final result because introducing even a single NaN entry would
+// break the indistinguishability property required for differential privacy.
+func (bq *BoundedQuantiles) Add(e float64) {
+	if err := bq.AddE(e); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+}
+
+// AddE is the same as Add, but returns an error instead of exiting the program
+// if the result has already been returned.
+func (bq *BoundedQuantiles) AddE(e float64) error {
+	if bq.resultReturned {
+		return fmt.Errorf("The quantiles have already been calculated and returned. They cannot be amended.")
+	}
+	if math.IsNaN(e) {
+		return nil
+	}
+	clamped, err := ClampFloat64(e, bq.lower, bq.upper)
+	if err != nil {
+		return fmt.Errorf("Couldn't clamp input value %v, err %v", e, err)
+	}
+	// Increment the count of the leaf containing the value and of all its
+	// ancestors, except the root.
+	for index := bq.getLeafIndex(clamped); index != rootIndex; index = bq.getParentIndex(index) {
+		bq.tree[index]++
+	}
+	return nil
+}
+
+// getLeafIndex returns the index of the leaf whose bucket contains e. e must
+// be within the [lower, upper] bounds.
+func (bq *BoundedQuantiles) getLeafIndex(e float64) int {
+	if bq.lower == bq.upper {
+		return bq.leftmostLeafIndex
+	}
+	offset := int(math.Floor((e - bq.lower) / (bq.upper - bq.lower) * float64(bq.numLeaves)))
+	// The upper bound belongs to the rightmost leaf.
+	if offset >= bq.numLeaves {
+		offset = bq.numLeaves - 1
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return bq.leftmostLeafIndex + offset
+}
+
+func (bq *BoundedQuantiles) getParentIndex(index int) int {
+	return (index - 1) / bq.branchingFactor
+}
+
+func (bq *BoundedQuantiles) getLeftmostChildIndex(index int) int {
+	return index*bq.branchingFactor + 1
+}
+
+// getNoisedCount returns the noised count of the node with the given index.
+// The noise is only drawn the first time a given node is visited; subsequent
+// calls return the same noised count.
+func (bq *BoundedQuantiles) getNoisedCount(index int) (float64, error) {
+	if noisedCount, ok := bq.noisedTree[index]; ok {
+		return noisedCount, nil
+	}
+	noisedCount, err := noise.AddNoiseFloat64E(bq.noise, float64(bq.tree[index]), bq.l0Sensitivity, bq.lInfSensitivity, bq.epsilon, bq.delta)
+	if err != nil {
+		return 0, err
+	}
+	bq.noisedTree[index] = noisedCount
+	return noisedCount, nil
+}
+
+// Result returns a differentially private estimate of the value at the given
+// rank, which must be between 0 and 1 (e.g., 0.5 for the median, 0.9 for the
+// 90th percentile). Unlike other aggregations, Result can be called multiple
+// times with different ranks: the noise is drawn only once for each node of
+// the tree, so calling Result again doesn't consume additional privacy budget.
+// The results are monotonic: for rank1 ≤ rank2, Result(rank1) ≤ Result(rank2).
+//
+// After the first call to Result, no further entries can be added to the
+// BoundedQuantiles and it cannot be merged with another BoundedQuantiles.
+func (bq *BoundedQuantiles) Result(rank float64) float64 {
+	result, err := bq.ResultE(rank)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the rank is invalid.
+func (bq *BoundedQuantiles) ResultE(rank float64) (float64, error) {
+	if rank < 0 || rank > 1 || math.IsNaN(rank) {
+		return 0, fmt.Errorf("BoundedQuantiles: rank is %f, should be between 0 and 1", rank)
+	}
+	bq.resultReturned = true
+
+	// [lo, hi] is the range of values covered by the current node.
+	lo, hi := bq.lower, bq.upper
+	index := rootIndex
+	for index < bq.leftmostLeafIndex {
+		leftmostChildIndex := bq.getLeftmostChildIndex(index)
+		// Negative noised counts are meaningless, so they are set to 0.
+		totalCount := 0.0
+		childCounts := make([]float64, bq.branchingFactor)
+		for i := range childCounts {
+			noisedCount, err := bq.getNoisedCount(leftmostChildIndex + i)
+			if err != nil {
+				return 0, err
+			}
+			childCounts[i] = math.Max(0, noisedCount)
+			totalCount += childCounts[i]
+		}
+		if totalCount == 0 {
+			// None of the children has a positive noised count: we assume that the
+			// values are uniformly distributed within the range of the current node.
+			break
+		}
+
+		// Find the child containing the requested rank, and compute the rank
+		// relative to the values of this child.
+		correctedRank := rank * totalCount
+		cumulativeCount := 0.0
+		chosenChild, lastPositiveChild := -1, 0
+		for i, childCount := range childCounts {
+			if childCount == 0 {
+				continue
+			}
+			lastPositiveChild = i
+			if cumulativeCount+childCount >= correctedRank {
+				chosenChild = i
+				rank = (correctedRank - cumulativeCount) / childCount
+				break
+			}
+			cumulativeCount += childCount
+		}
+		if chosenChild == -1 {
+			// This can only happen due to floating-point rounding errors, when the
+			// requested rank is (close to) 1.
+			chosenChild = lastPositiveChild
+			rank = 1
+		}
+
+		width := (hi - lo) / float64(bq.branchingFactor)
+		lo = lo + float64(chosenChild)*width
+		hi = lo + width
+		index = leftmostChildIndex + chosenChild
+	}
+
+	// Interpolate linearly within the range of the current node.
+	result, err := ClampFloat64(lo+rank*(hi-lo), bq.lower, bq.upper)
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't clamp the result, err %v", err)
+	}
+	return result, nil
+}
+
+// Merge merges bq2 into bq (i.e., adds to bq all entries that were added to
+// bq2). bq2 is consumed by this operation: bq2 may not be used after it is
+// merged into bq.
+func (bq *BoundedQuantiles) Merge(bq2 *BoundedQuantiles) {
+	if err := bq.MergeE(bq2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+}
+
+// MergeE is the same as Merge, but returns an error instead of exiting the
+// program if bq and bq2 cannot be merged.
+func (bq *BoundedQuantiles) MergeE(bq2 *BoundedQuantiles) error {
+	if err := checkMergeBoundedQuantiles(bq, bq2); err != nil {
+		return err
+	}
+	for index, count := range bq2.tree {
+		bq.tree[index] += count
+	}
+	bq2.resultReturned = true
+	return nil
+}
+
+func checkMergeBoundedQuantiles(bq1, bq2 *BoundedQuantiles) error {
+	if bq1.resultReturned {
+		return fmt.Errorf("checkMergeBoundedQuantiles: bq1 already returned the result, cannot be merged with another BoundedQuantiles instance")
+	}
+	if bq2.resultReturned {
+		return fmt.Errorf("checkMergeBoundedQuantiles: bq2 already returned the result, cannot be merged with another BoundedQuantiles instance")
+	}
+
+	if !bqEquallyInitialized(bq1, bq2) {
+		return fmt.Errorf("checkMergeBoundedQuantiles: bq1 and bq2 are not compatible")
+	}
+
+	return nil
+}
+
+// encodableBoundedQuantiles can be encoded by the gob package.
+type encodableBoundedQuantiles struct {
+	Epsilon           float64
+	Delta             float64
+	L0Sensitivity     int64
+	LInfSensitivity   float64
+	Lower             float64
+	Upper             float64
+	TreeHeight        int
+	BranchingFactor   int
+	NumLeaves         int
+	LeftmostLeafIndex int
+	NoiseKind         noise.Kind
+	QuantileTree      map[int]int64
+	ResultReturned    bool
+	// Seed of the deterministic Source of the noise, if any.
+	Seed encodableSeed
+}
+
+// GobEncode encodes BoundedQuantiles.
+func (bq *BoundedQuantiles) GobEncode() ([]byte, error) {
+	enc := encodableBoundedQuantiles{
+		Epsilon:           bq.epsilon,
+		Delta:             bq.delta,
+		L0Sensitivity:     bq.l0Sensitivity,
+		LInfSensitivity:   bq.lInfSensitivity,
+		Lower:             bq.lower,
+		Upper:             bq.upper,
+		TreeHeight:        bq.treeHeight,
+		BranchingFactor:   bq.branchingFactor,
+		NumLeaves:         bq.numLeaves,
+		LeftmostLeafIndex: bq.leftmostLeafIndex,
+		NoiseKind:         noise.ToKind(bq.noise),
+		QuantileTree:      bq.tree,
+		ResultReturned:    bq.resultReturned,
+		Seed:              seedOf(noise.SourceOf(bq.noise)),
+	}
+	bq.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes BoundedQuantiles.
+func (bq *BoundedQuantiles) GobDecode(data []byte) error {
+	var enc encodableBoundedQuantiles
+	err := decode(&enc, data)
+	if err != nil {
+		return err
+	}
+	tree := enc.QuantileTree
+	if tree == nil {
+		// gob doesn't transmit empty maps.
+		tree = make(map[int]int64)
+	}
+	*bq = BoundedQuantiles{
+		epsilon:           enc.Epsilon,
+		delta:             enc.Delta,
+		l0Sensitivity:     enc.L0Sensitivity,
+		lInfSensitivity:   enc.LInfSensitivity,
+		lower:             enc.Lower,
+		upper:             enc.Upper,
+		treeHeight:        enc.TreeHeight,
+		branchingFactor:   enc.BranchingFactor,
+		numLeaves:         enc.NumLeaves,
+		leftmostLeafIndex: enc.LeftmostLeafIndex,
+		noiseKind:         enc.NoiseKind,
+		noise:             noise.WithSource(noise.ToNoise(enc.NoiseKind), enc.Seed.source()),
+		tree:              tree,
+		noisedTree:        make(map[int]float64),
+		resultReturned:    enc.ResultReturned,
+	}
+	return nil
+}
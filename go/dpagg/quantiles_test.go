@@ -0,0 +1,373 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/go/rand"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewBoundedQuantiles(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opt  *BoundedQuantilesOptions
+		want *BoundedQuantiles
+	}{
+		{"MaxPartitionsContributed is not set",
+			&BoundedQuantilesOptions{
+				Epsilon:                      ln3,
+				Delta:                        tenten,
+				Lower:                        -1,
+				Upper:                        5,
+				Noise:                        noNoise{},
+				MaxContributionsPerPartition: 2,
+				TreeHeight:                   3,
+				BranchingFactor:              4,
+			},
+			&BoundedQuantiles{
+				epsilon:           ln3,
+				delta:             tenten,
+				l0Sensitivity:     3,
+				lInfSensitivity:   2,
+				lower:             -1,
+				upper:             5,
+				treeHeight:        3,
+				branchingFactor:   4,
+				numLeaves:         64,
+				leftmostLeafIndex: 21,
+				noise:             noNoise{},
+				tree:              make(map[int]int64),
+				noisedTree:        make(map[int]float64),
+				resultReturned:    false,
+			}},
+		{"Noise, TreeHeight and BranchingFactor are not set",
+			&BoundedQuantilesOptions{
+				Epsilon:                      ln3,
+				Delta:                        0,
+				Lower:                        -1,
+				Upper:                        5,
+				MaxPartitionsContributed:     2,
+				MaxContributionsPerPartition: 1,
+			},
+			&BoundedQuantiles{
+				epsilon:           ln3,
+				delta:             0,
+				l0Sensitivity:     8,
+				lInfSensitivity:   1,
+				lower:             -1,
+				upper:             5,
+				treeHeight:        4,
+				branchingFactor:   16,
+				numLeaves:         65536,
+				leftmostLeafIndex: 4369,
+				noise:             noise.Laplace(),
+				noiseKind:         noise.LaplaceNoise,
+				tree:              make(map[int]int64),
+				noisedTree:        make(map[int]float64),
+				resultReturned:    false,
+			}},
+	} {
+		got := NewBoundedQuantiles(tc.opt)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("NewBoundedQuantiles: when %s got %+v, want %+v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func getNoiselessBQ() *BoundedQuantiles {
+	return NewBoundedQuantiles(&BoundedQuantilesOptions{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		Lower:                        0,
+		Upper:                        100,
+		Noise:                        noNoise{},
+		TreeHeight:                   2,
+		BranchingFactor:              10,
+	})
+}
+
+func TestBQResult(t *testing.T) {
+	bq := getNoiselessBQ()
+	// Leaves have a width of 1, so each of these values falls in a distinct leaf.
+	for i := 0; i < 100; i++ {
+		bq.Add(float64(i) + 0.5)
+	}
+	for _, tc := range []struct {
+		rank float64
+		want float64
+	}{
+		{0, 0},
+		{0.1, 10},
+		{0.255, 25.5},
+		{0.5, 50},
+		{0.99, 99},
+		{1, 100},
+	} {
+		got := bq.Result(tc.rank)
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("Result(%f): got %f, want %f", tc.rank, got, tc.want)
+		}
+	}
+}
+
+func TestBQResultIsMonotonic(t *testing.T) {
+	bq := NewBoundedQuantiles(&BoundedQuantilesOptions{
+		Epsilon:                      ln3,
+		MaxContributionsPerPartition: 1,
+		Lower:                        -10,
+		Upper:                        10,
+		Noise:                        noise.Laplace(),
+	})
+	for i := 0; i < 1000; i++ {
+		bq.Add(float64(i%20) - 10)
+	}
+	previous := math.Inf(-1)
+	for rank := 0.0; rank <= 1; rank += 0.01 {
+		got := bq.Result(rank)
+		if got < previous {
+			t.Errorf("Result(%f) = %f is smaller than the result for a lower rank (%f)", rank, got, previous)
+		}
+		if got < -10 || got > 10 {
+			t.Errorf("Result(%f) = %f is outside of the bounds [-10, 10]", rank, got)
+		}
+		previous = got
+	}
+}
+
+func TestBQClamp(t *testing.T) {
+	bq := getNoiselessBQ()
+	bq.Add(-50) // Clamped to 0.
+	bq.Add(150) // Clamped to 100.
+	if got := bq.Result(0); got != 0 {
+		t.Errorf("Result(0): when values are clamped got %f, want 0", got)
+	}
+	if got := bq.Result(1); got != 100 {
+		t.Errorf("Result(1): when values are clamped got %f, want 100", got)
+	}
+}
+
+func TestBQAddIgnoresNaN(t *testing.T) {
+	bq := getNoiselessBQ()
+	bq.Add(math.NaN())
+	bq.Add(20.5)
+	if got, want := bq.Result(0.5), 20.5; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Result(0.5): when NaN was added got %f, want %f", got, want)
+	}
+}
+
+func TestBQNoInput(t *testing.T) {
+	bq := getNoiselessBQ()
+	// Without any input, values are assumed to be uniformly distributed.
+	if got, want := bq.Result(0.3), 30.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Result(0.3): when there is no input data got %f, want %f", got, want)
+	}
+}
+
+func TestMergeBoundedQuantiles(t *testing.T) {
+	bq1 := getNoiselessBQ()
+	bq2 := getNoiselessBQ()
+	for i := 0; i < 50; i++ {
+		bq1.Add(float64(i) + 0.5)
+		bq2.Add(float64(i) + 50.5)
+	}
+	bq1.Merge(bq2)
+	if got, want := bq1.Result(0.75), 75.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Merge: when merging 2 instances of BoundedQuantiles got %f, want %f", got, want)
+	}
+	if !bq2.resultReturned {
+		t.Errorf("Merge: when merging 2 instances of BoundedQuantiles for bq2.resultReturned got false, want true")
+	}
+}
+
+func TestCheckMergeBoundedQuantiles(t *testing.T) {
+	for _, tc := range []struct {
+		desc          string
+		opt1          *BoundedQuantilesOptions
+		opt2          *BoundedQuantilesOptions
+		returnResult1 bool
+		returnResult2 bool
+		wantErr       bool
+	}{
+		{"same options",
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10},
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10},
+			false,
+			false,
+			false},
+		{"different epsilon",
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10},
+			&BoundedQuantilesOptions{Epsilon: 2, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10},
+			false,
+			false,
+			true},
+		{"different bounds",
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10},
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 20},
+			false,
+			false,
+			true},
+		{"different tree height",
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10, TreeHeight: 3},
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10, TreeHeight: 4},
+			false,
+			false,
+			true},
+		{"different branching factor",
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10, BranchingFactor: 8},
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10, BranchingFactor: 16},
+			false,
+			false,
+			true},
+		{"bq1 already returned result",
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10},
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10},
+			true,
+			false,
+			true},
+		{"bq2 already returned result",
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10},
+			&BoundedQuantilesOptions{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 10},
+			false,
+			true,
+			true},
+	} {
+		bq1 := NewBoundedQuantiles(tc.opt1)
+		bq2 := NewBoundedQuantiles(tc.opt2)
+		if tc.returnResult1 {
+			bq1.Result(0.5)
+		}
+		if tc.returnResult2 {
+			bq2.Result(0.5)
+		}
+		if err := checkMergeBoundedQuantiles(bq1, bq2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func compareBoundedQuantiles(bq1, bq2 *BoundedQuantiles) bool {
+	return bq1.epsilon == bq2.epsilon &&
+		bq1.delta == bq2.delta &&
+		bq1.l0Sensitivity == bq2.l0Sensitivity &&
+		bq1.lInfSensitivity == bq2.lInfSensitivity &&
+		bq1.lower == bq2.lower &&
+		bq1.upper == bq2.upper &&
+		bq1.treeHeight == bq2.treeHeight &&
+		bq1.branchingFactor == bq2.branchingFactor &&
+		bq1.numLeaves == bq2.numLeaves &&
+		bq1.leftmostLeafIndex == bq2.leftmostLeafIndex &&
+		bq1.noise == bq2.noise &&
+		bq1.noiseKind == bq2.noiseKind &&
+		reflect.DeepEqual(bq1.tree, bq2.tree) &&
+		bq1.resultReturned == bq2.resultReturned
+}
+
+// Tests that serialization for BoundedQuantiles works as expected.
+func TestBoundedQuantilesSerialization(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opts *BoundedQuantilesOptions
+	}{
+		{"default options", &BoundedQuantilesOptions{
+			Epsilon:                      ln3,
+			Lower:                        0,
+			Upper:                        1,
+			MaxContributionsPerPartition: 1,
+		}},
+		{"non-default options", &BoundedQuantilesOptions{
+			Lower:                        -100,
+			Upper:                        555,
+			Epsilon:                      ln3,
+			Delta:                        1e-5,
+			MaxPartitionsContributed:     5,
+			MaxContributionsPerPartition: 6,
+			Noise:                        noise.Gaussian(),
+			TreeHeight:                   3,
+			BranchingFactor:              12,
+		}},
+	} {
+		bq, bqUnchanged := NewBoundedQuantiles(tc.opts), NewBoundedQuantiles(tc.opts)
+		bq.Add(0.5)
+		bqUnchanged.Add(0.5)
+		bytes, err := encode(bq)
+		if err != nil {
+			t.Fatalf("encode(BoundedQuantiles) error: %v", err)
+		}
+		bqUnmarshalled := new(BoundedQuantiles)
+		if err := decode(bqUnmarshalled, bytes); err != nil {
+			t.Fatalf("decode(BoundedQuantiles) error: %v", err)
+		}
+		// Check that encoding -> decoding is the identity function.
+		if !cmp.Equal(bqUnchanged, bqUnmarshalled, cmp.Comparer(compareBoundedQuantiles)) {
+			t.Errorf("decode(encode(_)): when %s got %+v, want %+v", tc.desc, bqUnmarshalled, bqUnchanged)
+		}
+		// Check that the original BoundedQuantiles has its resultReturned set to true after serialization.
+		if !bq.resultReturned {
+			t.Errorf("BoundedQuantiles %v should have its resultReturned set to true after being serialized", bq)
+		}
+	}
+}
+
+// Checks that BoundedQuantiles decoded from the same encoding, using noise with
+// a deterministic source, return the same result. Decoding used to replace the
+// source with the cryptographically secure one.
+func TestBoundedQuantilesWithDeterministicSourceSerialization(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		newQuantiles := func() *BoundedQuantiles {
+			bq := NewBoundedQuantiles(&BoundedQuantilesOptions{
+				Epsilon:                      ln3,
+				MaxContributionsPerPartition: 1,
+				Lower:                        -1,
+				Upper:                        5,
+				Noise:                        noise.WithSource(noise.Laplace(), rand.NewDeterministicSource(seed)),
+			})
+			for i := 0; i < 1000; i++ {
+				bq.Add(float64(i%6) - 0.5)
+			}
+			return bq
+		}
+		bytes, err := encode(newQuantiles())
+		if err != nil {
+			t.Fatalf("encode(BoundedQuantiles) error: %v", err)
+		}
+		decoded1, decoded2 := new(BoundedQuantiles), new(BoundedQuantiles)
+		for _, decoded := range []*BoundedQuantiles{decoded1, decoded2} {
+			if err := decode(decoded, bytes); err != nil {
+				t.Fatalf("decode(BoundedQuantiles) error: %v", err)
+			}
+		}
+		if got1, got2 := decoded1.Result(0.5), decoded2.Result(0.5); got1 != got2 {
+			t.Errorf("Result(0.5): for BoundedQuantiles decoded from the same encoding with seed %d got %f and %f, want identical results", seed, got1, got2)
+		}
+	}
+}
+
+func TestBQResultEReturnsErrorForInvalidRank(t *testing.T) {
+	bq := getNoiselessBQ()
+	for _, rank := range []float64{-0.1, 1.1, math.NaN()} {
+		if _, err := bq.ResultE(rank); err == nil {
+			t.Errorf("ResultE: for rank %f got no error, want error", rank)
+		}
+	}
+}
@@ -19,7 +19,6 @@ package dpagg
 import (
 	"fmt"
 	"math"
-	"reflect"
 
 	log "github.com/golang/glog"
 	"github.com/google/differential-privacy/go/checks"
@@ -73,6 +72,12 @@ type PreAggSelectPartition struct {
 	epsilon       float64
 	delta         float64
 	l0Sensitivity int64
+	// random is the Rand used to decide whether to keep the partition, or nil
+	// to use the cryptographically secure source of the rand package.
+	random *rand.Rand
+	// seed is the seed of the Source of random if it is deterministic, so that
+	// random can be recreated when decoding.
+	seed encodableSeed
 
 	// State variables
 	// idCount is the count of unique privacy IDs in the partition.
@@ -97,15 +102,37 @@ type PreAggSelectPartitionOptions struct {
 	// contribute to.
 	// Defaults to 1.
 	MaxPartitionsContributed int64
+	// Source is the source of randomness used to decide whether to keep the
+	// partition. Optional, defaults to a cryptographically secure source.
+	// Only set it in tests that need reproducible results, e.g. with
+	// rand.NewDeterministicSource: the decision is only differentially private
+	// if Source is cryptographically secure.
+	Source rand.Source
 }
 
 // NewPreAggSelectPartition constructs a new PreAggSelectPartition from opt.
+// It exits the program if the options are invalid; use
+// NewPreAggSelectPartitionE to handle this case instead.
 func NewPreAggSelectPartition(opt *PreAggSelectPartitionOptions) *PreAggSelectPartition {
+	s, err := NewPreAggSelectPartitionE(opt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s
+}
+
+// NewPreAggSelectPartitionE is the same as NewPreAggSelectPartition, but
+// returns an error instead of exiting the program if the options are invalid.
+func NewPreAggSelectPartitionE(opt *PreAggSelectPartitionOptions) (*PreAggSelectPartition, error) {
 	s := PreAggSelectPartition{
 		epsilon:       opt.Epsilon,
 		delta:         opt.Delta,
 		l0Sensitivity: opt.MaxPartitionsContributed,
 	}
+	if opt.Source != nil {
+		s.random = rand.New(opt.Source)
+		s.seed = seedOf(opt.Source)
+	}
 	// Override the 0-default, but do not override any explicitly set (i.e., negative) values
 	// for l0Sensitivity.
 	if s.l0Sensitivity == 0 {
@@ -113,23 +140,32 @@ func NewPreAggSelectPartition(opt *PreAggSelectPartitionOptions) *PreAggSelectPa
 	}
 
 	if err := checks.CheckDeltaStrict("dpagg.NewPreAggSelectPartition", s.delta); err != nil {
-		log.Fatalf("%s: CheckDeltaStrict failed with %v", &s, err)
+		return nil, fmt.Errorf("%s: CheckDeltaStrict failed with %v", &s, err)
 	}
 	if err := checks.CheckEpsilon("dpagg.NewPreAggSelectPartition", s.epsilon); err != nil {
-		log.Fatalf("%s: CheckEpsilon failed with %v", &s, err)
+		return nil, fmt.Errorf("%s: CheckEpsilon failed with %v", &s, err)
 	}
 	if err := checks.CheckL0Sensitivity("dpagg.NewPreAggSelectPartition", s.l0Sensitivity); err != nil {
-		log.Fatalf("%s: CheckL0Sensitivity failed with %v", &s, err)
+		return nil, fmt.Errorf("%s: CheckL0Sensitivity failed with %v", &s, err)
 	}
-	return &s
+	return &s, nil
 }
 
 // Add increments the count of privacy IDs.
 func (s *PreAggSelectPartition) Add() {
+	if err := s.AddE(); err != nil {
+		log.Exit(err)
+	}
+}
+
+// AddE is the same as Add, but returns an error instead of exiting the program
+// if the result has already been returned.
+func (s *PreAggSelectPartition) AddE() error {
 	if s.resultReturned {
-		log.Exitf("This PreAggSelectPartition has already returned a Result. It can only be used once.")
+		return fmt.Errorf("This PreAggSelectPartition has already returned a Result. It can only be used once.")
 	}
 	s.idCount++
+	return nil
 }
 
 // Merge merges s2 into s (i.e., add the idCount of s2 to s). This implicitly
@@ -139,12 +175,21 @@ func (s *PreAggSelectPartition) Add() {
 // Preconditions: s and s2 must have the same privacy parameters. In addition,
 // Result() may not be called yet for either s or s2.
 func (s *PreAggSelectPartition) Merge(s2 *PreAggSelectPartition) {
-	if err := checkMergePreAggSelectPartition(*s, *s2); err != nil {
+	if err := s.MergeE(s2); err != nil {
 		log.Exit(err)
 	}
+}
+
+// MergeE is the same as Merge, but returns an error instead of exiting the
+// program if s and s2 cannot be merged.
+func (s *PreAggSelectPartition) MergeE(s2 *PreAggSelectPartition) error {
+	if err := checkMergePreAggSelectPartition(*s, *s2); err != nil {
+		return err
+	}
 
 	s.idCount += s2.idCount
 	s2.resultReturned = true
+	return nil
 }
 
 func checkMergePreAggSelectPartition(s PreAggSelectPartition, s2 PreAggSelectPartition) error {
@@ -156,20 +201,41 @@ func checkMergePreAggSelectPartition(s PreAggSelectPartition, s2 PreAggSelectPar
 		return fmt.Errorf(resultReturnedMsg, "s2")
 	}
 
-	s.idCount, s2.idCount = 0, 0
-	if !reflect.DeepEqual(s, s2) {
+	if !preAggSelectPartitionEquallyInitialized(&s, &s2) {
 		return fmt.Errorf("s and s2 are not compatible")
 	}
 	return nil
 }
 
+// preAggSelectPartitionEquallyInitialized returns whether s1 and s2 have the
+// same privacy parameters. Their sources of randomness may differ.
+func preAggSelectPartitionEquallyInitialized(s1, s2 *PreAggSelectPartition) bool {
+	return s1.epsilon == s2.epsilon &&
+		s1.delta == s2.delta &&
+		s1.l0Sensitivity == s2.l0Sensitivity
+}
+
 // Result returns whether the partition should be materialized.
 func (s *PreAggSelectPartition) Result() bool {
+	result, err := s.ResultE()
+	if err != nil {
+		log.Exit(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the result has already been returned.
+func (s *PreAggSelectPartition) ResultE() (bool, error) {
 	if s.resultReturned {
-		log.Exitf("This PreAggSelectPartition has already returned a Result. It can only be used once.")
+		return false, fmt.Errorf("This PreAggSelectPartition has already returned a Result. It can only be used once.")
 	}
 	s.resultReturned = true
-	return rand.Uniform() < selectPartitionPr(s.idCount, s.l0Sensitivity, s.epsilon, s.delta)
+	r := s.random
+	if r == nil {
+		r = rand.Secure()
+	}
+	return r.Uniform() < selectPartitionPr(s.idCount, s.l0Sensitivity, s.epsilon, s.delta), nil
 }
 
 // sumExpPowers returns the evaluation of
@@ -287,6 +353,8 @@ type encodablePreAggSelectPartition struct {
 	L0Sensitivity  int64
 	IDCount        int64
 	ResultReturned bool
+	// Seed of the deterministic Source of the PreAggSelectPartition, if any.
+	Seed encodableSeed
 }
 
 // GobEncode encodes PreAggSelectPartition.
@@ -297,6 +365,7 @@ func (s *PreAggSelectPartition) GobEncode() ([]byte, error) {
 		L0Sensitivity:  s.l0Sensitivity,
 		IDCount:        s.idCount,
 		ResultReturned: s.resultReturned,
+		Seed:           s.seed,
 	}
 	s.resultReturned = true
 	return encode(enc)
@@ -312,6 +381,10 @@ func (s *PreAggSelectPartition) GobDecode(data []byte) error {
 		l0Sensitivity:  enc.L0Sensitivity,
 		idCount:        enc.IDCount,
 		resultReturned: enc.ResultReturned,
+		seed:           enc.Seed,
+	}
+	if src := enc.Seed.source(); src != nil {
+		s.random = rand.New(src)
 	}
 	return err
 }
@@ -23,6 +23,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/differential-privacy/go/rand"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -505,3 +506,80 @@ func TestCheckMergePreAggSelectPartition(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPreAggSelectPartitionEReturnsErrorForInvalidOptions(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opt  *PreAggSelectPartitionOptions
+	}{
+		{"zero delta", &PreAggSelectPartitionOptions{Epsilon: 0.1}},
+		{"negative epsilon", &PreAggSelectPartitionOptions{Epsilon: -1, Delta: 0.2}},
+		{"negative MaxPartitionsContributed", &PreAggSelectPartitionOptions{Epsilon: 0.1, Delta: 0.2, MaxPartitionsContributed: -1}},
+	} {
+		if _, err := NewPreAggSelectPartitionE(tc.opt); err == nil {
+			t.Errorf("NewPreAggSelectPartitionE: for %s got no error, want error", tc.desc)
+		}
+	}
+}
+
+func TestPreAggSelectPartitionEReturnsErrorAfterResult(t *testing.T) {
+	s := NewPreAggSelectPartition(&PreAggSelectPartitionOptions{Epsilon: 0.1, Delta: 0.2})
+	if _, err := s.ResultE(); err != nil {
+		t.Fatalf("ResultE: got error %v", err)
+	}
+	if err := s.AddE(); err == nil {
+		t.Errorf("AddE: after ResultE got no error, want error")
+	}
+	if _, err := s.ResultE(); err == nil {
+		t.Errorf("ResultE: when called twice got no error, want error")
+	}
+}
+
+func TestPreAggSelectPartitionWithDeterministicSourceIsReproducible(t *testing.T) {
+	newSelectPartitions := func() []*PreAggSelectPartition {
+		var s []*PreAggSelectPartition
+		src := rand.NewDeterministicSource(42)
+		for i := 0; i < 100; i++ {
+			sp := NewPreAggSelectPartition(&PreAggSelectPartitionOptions{Epsilon: ln3, Delta: 0.1, Source: src})
+			sp.Add()
+			s = append(s, sp)
+		}
+		return s
+	}
+	s1, s2 := newSelectPartitions(), newSelectPartitions()
+	for i := range s1 {
+		if got1, got2 := s1[i].Result(), s2[i].Result(); got1 != got2 {
+			t.Errorf("Result: got %t and %t for the %d-th partition with the same seed, want identical results", got1, got2, i)
+		}
+	}
+}
+
+// Checks that a PreAggSelectPartition with a deterministic Source can be
+// merged after being encoded and decoded, and that it still draws from a
+// Source with the same seed.
+func TestPreAggSelectPartitionWithDeterministicSourceSerialization(t *testing.T) {
+	for seed := int64(0); seed < 100; seed++ {
+		newSelectPartition := func() *PreAggSelectPartition {
+			return NewPreAggSelectPartition(&PreAggSelectPartitionOptions{Epsilon: ln3, Delta: 0.1, Source: rand.NewDeterministicSource(seed)})
+		}
+		s1, s2 := newSelectPartition(), newSelectPartition()
+		s2.Add()
+		bytes, err := encode(s1)
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		decoded := new(PreAggSelectPartition)
+		if err := decode(decoded, bytes); err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		if err := decoded.MergeE(s2); err != nil {
+			t.Fatalf("MergeE: for a decoded PreAggSelectPartition with seed %d got error %v", seed, err)
+		}
+
+		want := newSelectPartition()
+		want.Add()
+		if got, want := decoded.Result(), want.Result(); got != want {
+			t.Errorf("Result: for a decoded PreAggSelectPartition with seed %d got %t, want %t", seed, got, want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// BoundedStdDevFloat64 calculates a differentially private standard deviation
+// of a collection of float64 values.
+//
+// The standard deviation is the square root of a differentially private
+// variance computed by BoundedVarianceFloat64. Since taking the square root is
+// a post-processing step, the DP guarantees are the same as those of
+// BoundedVarianceFloat64.
+//
+// BoundedStdDevFloat64 supports scaling the noise in the case where users can
+// contribute to multiple partitions (via the MaxPartitionsContributed parameter)
+// and can contribute to a single partition multiple times
+// (via the MaxContributionsPerPartition parameter).
+//
+// Note: Do not use when your results may cause overflows for int64 or float64
+// values. This aggregation is not hardened for such applications yet.
+//
+// Not thread-safe.
+type BoundedStdDevFloat64 struct {
+	// State variables
+	variance BoundedVarianceFloat64
+}
+
+// BoundedStdDevFloat64Options contains the options necessary to initialize a BoundedStdDevFloat64.
+type BoundedStdDevFloat64Options struct {
+	Epsilon                      float64 // Privacy parameter ε. Required.
+	Delta                        float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed     int64   // How many distinct partitions may a single user contribute to? Defaults to 1.
+	MaxContributionsPerPartition int64   // How many times may a single user contribute to a single partition? Required.
+	// Lower and Upper bounds for clamping. Default to 0; must be such that Lower < Upper.
+	Lower, Upper float64
+	Noise        noise.Noise // Type of noise used in BoundedStdDev. Defaults to Laplace noise.
+}
+
+// NewBoundedStdDevFloat64 returns a new BoundedStdDevFloat64.
+// It exits the program if the options are invalid; use
+// NewBoundedStdDevFloat64E to handle this case instead.
+func NewBoundedStdDevFloat64(opt *BoundedStdDevFloat64Options) *BoundedStdDevFloat64 {
+	bstd, err := NewBoundedStdDevFloat64E(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewBoundedStdDevFloat64: %v", err)
+	}
+	return bstd
+}
+
+// NewBoundedStdDevFloat64E is the same as NewBoundedStdDevFloat64, but returns
+// an error instead of exiting the program if the options are invalid.
+func NewBoundedStdDevFloat64E(opt *BoundedStdDevFloat64Options) (*BoundedStdDevFloat64, error) {
+	if opt == nil {
+		opt = &BoundedStdDevFloat64Options{}
+	}
+	variance, err := NewBoundedVarianceFloat64E(&BoundedVarianceFloat64Options{
+		Epsilon:                      opt.Epsilon,
+		Delta:                        opt.Delta,
+		MaxPartitionsContributed:     opt.MaxPartitionsContributed,
+		MaxContributionsPerPartition: opt.MaxContributionsPerPartition,
+		Lower:                        opt.Lower,
+		Upper:                        opt.Upper,
+		Noise:                        opt.Noise,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BoundedStdDevFloat64{variance: *variance}, nil
+}
+
+// Add an entry to a BoundedStdDevFloat64. It skips NaN entries and doesn't count
+// them in the final result.
+func (bstd *BoundedStdDevFloat64) Add(e float64) {
+	if err := bstd.AddE(e); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+}
+
+// AddE is the same as Add, but returns an error instead of exiting the program
+// if the result has already been returned.
+func (bstd *BoundedStdDevFloat64) AddE(e float64) error {
+	if bstd.variance.resultReturned {
+		return fmt.Errorf("The standard deviation has already been calculated and returned. It cannot be amended.")
+	}
+	return bstd.variance.AddE(e)
+}
+
+// Result returns a differentially private standard deviation of elements added so far.
+// It can be called only once, after which no further operation can be done on the BoundedStdDevFloat64.
+func (bstd *BoundedStdDevFloat64) Result() float64 {
+	result, err := bstd.ResultE()
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the result has already been returned.
+func (bstd *BoundedStdDevFloat64) ResultE() (float64, error) {
+	if bstd.variance.resultReturned {
+		return 0, fmt.Errorf("The standard deviation has already been calculated and returned. It can only be returned once.")
+	}
+	variance, err := bstd.variance.ResultE()
+	if err != nil {
+		return 0, err
+	}
+	return math.Sqrt(variance), nil
+}
+
+// Merge merges bstd2 into bstd (i.e., adds to bstd all entries that were added to
+// bstd2). bstd2 is consumed by this operation: bstd2 may not be used after it is
+// merged into bstd.
+func (bstd *BoundedStdDevFloat64) Merge(bstd2 *BoundedStdDevFloat64) {
+	if err := bstd.MergeE(bstd2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+}
+
+// MergeE is the same as Merge, but returns an error instead of exiting the
+// program if bstd and bstd2 cannot be merged.
+func (bstd *BoundedStdDevFloat64) MergeE(bstd2 *BoundedStdDevFloat64) error {
+	if err := checkMergeBoundedStdDevFloat64(bstd, bstd2); err != nil {
+		return err
+	}
+	return bstd.variance.MergeE(&bstd2.variance)
+}
+
+func checkMergeBoundedStdDevFloat64(bstd1, bstd2 *BoundedStdDevFloat64) error {
+	if err := checkMergeBoundedVarianceFloat64(&bstd1.variance, &bstd2.variance); err != nil {
+		return fmt.Errorf("checkMergeBoundedStdDevFloat64: %v", err)
+	}
+	return nil
+}
+
+// GobEncode encodes BoundedStdDevFloat64.
+func (bstd *BoundedStdDevFloat64) GobEncode() ([]byte, error) {
+	enc := encodableBoundedStdDevFloat64{
+		EncodableVariance: &bstd.variance,
+	}
+	return encode(enc)
+}
+
+// GobDecode decodes BoundedStdDevFloat64.
+func (bstd *BoundedStdDevFloat64) GobDecode(data []byte) error {
+	var enc encodableBoundedStdDevFloat64
+	err := decode(&enc, data)
+	if err != nil {
+		return err
+	}
+	*bstd = BoundedStdDevFloat64{
+		variance: *enc.EncodableVariance,
+	}
+	return nil
+}
+
+// encodableBoundedStdDevFloat64 can be encoded by the gob package.
+type encodableBoundedStdDevFloat64 struct {
+	EncodableVariance *BoundedVarianceFloat64
+}
@@ -0,0 +1,130 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+)
+
+func getNoiselessBSTDF() *BoundedStdDevFloat64 {
+	return NewBoundedStdDevFloat64(&BoundedStdDevFloat64Options{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		Lower:                        -1,
+		Upper:                        5,
+		Noise:                        noNoise{},
+	})
+}
+
+func TestBSTDAddFloat64(t *testing.T) {
+	bstd := getNoiselessBSTDF()
+	bstd.Add(1)
+	bstd.Add(1)
+	bstd.Add(2)
+	bstd.Add(4)
+	got := bstd.Result()
+	// variance = 1.5
+	want := math.Sqrt(1.5)
+	if !ApproxEqual(got, want) {
+		t.Errorf("Add: when dataset with elements inside boundaries got %f, want %f", got, want)
+	}
+}
+
+func TestBSTDClampFloat64(t *testing.T) {
+	bstd := getNoiselessBSTDF()
+	// lower = -1, upper = 5
+	bstd.Add(8.3)  // clamped to 5
+	bstd.Add(-7.5) // clamped to -1
+	got := bstd.Result()
+	want := 3.0
+	if !ApproxEqual(got, want) {
+		t.Errorf("Add: when dataset with elements outside boundaries got %f, want %f", got, want)
+	}
+}
+
+func TestMergeBoundedStdDevFloat64(t *testing.T) {
+	bstd1 := getNoiselessBSTDF()
+	bstd2 := getNoiselessBSTDF()
+	bstd1.Add(1)
+	bstd1.Add(1)
+	bstd2.Add(2)
+	bstd2.Add(4)
+	bstd1.Merge(bstd2)
+	got := bstd1.Result()
+	want := math.Sqrt(1.5)
+	if !ApproxEqual(got, want) {
+		t.Errorf("Merge: when merging 2 instances of BoundedStdDev got %f, want %f", got, want)
+	}
+	if !bstd2.variance.resultReturned {
+		t.Errorf("Merge: when merging 2 instances of BoundedStdDev for resultReturned got false, want true")
+	}
+}
+
+func TestCheckMergeBoundedStdDevFloat64(t *testing.T) {
+	bstd1 := getNoiselessBSTDF()
+	bstd2 := NewBoundedStdDevFloat64(&BoundedStdDevFloat64Options{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		Lower:                        -1,
+		Upper:                        6,
+		Noise:                        noNoise{},
+	})
+	if err := checkMergeBoundedStdDevFloat64(bstd1, bstd2); err == nil {
+		t.Errorf("CheckMerge: when bounds are different got nil error, want error")
+	}
+	if err := checkMergeBoundedStdDevFloat64(bstd1, getNoiselessBSTDF()); err != nil {
+		t.Errorf("CheckMerge: when options are the same got error %v, want nil", err)
+	}
+}
+
+// Tests that serialization for BoundedStdDevFloat64 works as expected.
+func TestBSTDFloat64Serialization(t *testing.T) {
+	opts := &BoundedStdDevFloat64Options{
+		Lower:                        -100,
+		Upper:                        555,
+		Epsilon:                      ln3,
+		Delta:                        1e-5,
+		MaxPartitionsContributed:     5,
+		MaxContributionsPerPartition: 6,
+		Noise:                        noise.Gaussian(),
+	}
+	bstd, bstdUnchanged := NewBoundedStdDevFloat64(opts), NewBoundedStdDevFloat64(opts)
+	bytes, err := encode(bstd)
+	if err != nil {
+		t.Fatalf("encode(BoundedStdDevFloat64) error: %v", err)
+	}
+	bstdUnmarshalled := new(BoundedStdDevFloat64)
+	if err := decode(bstdUnmarshalled, bytes); err != nil {
+		t.Fatalf("decode(BoundedStdDevFloat64) error: %v", err)
+	}
+	// Check that encoding -> decoding is the identity function.
+	if !cmp.Equal(&bstdUnchanged.variance, &bstdUnmarshalled.variance, cmp.Comparer(compareBoundedVarianceFloat64)) {
+		t.Errorf("decode(encode(_)): got %v, want %v", bstdUnmarshalled, bstd)
+	}
+	// Check that the original BoundedStdDev has its resultReturned set to true after serialization.
+	if !bstd.variance.resultReturned {
+		t.Errorf("BoundedStdDev %v should have its resultReturned set to true after being serialized", bstd)
+	}
+}
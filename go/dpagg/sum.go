@@ -18,6 +18,7 @@
 package dpagg
 
 import (
+	"errors"
 	"fmt"
 	"math"
 
@@ -35,7 +36,8 @@ import (
 // Note: Do not use when your results may cause overflows for int64
 // values. This aggregation is not hardened for such applications yet.
 //
-// Not thread-safe.
+// Not thread-safe; use ConcurrentBoundedSumInt64 to add elements from several
+// goroutines.
 type BoundedSumInt64 struct {
 	// Parameters
 	epsilon         float64
@@ -50,6 +52,11 @@ type BoundedSumInt64 struct {
 	// State variables
 	sum            int64
 	resultReturned bool // whether the result has already been returned
+	// The returned result, used to compute confidence intervals. nil if no
+	// result was returned, e.g. because it was thresholded.
+	noisedSum *int64
+	// Set if the bounds are determined automatically, nil otherwise.
+	autoBounds *autoBoundsInt64
 }
 
 func bsEquallyInitializedint64(s1, s2 *BoundedSumInt64) bool {
@@ -59,7 +66,8 @@ func bsEquallyInitializedint64(s1, s2 *BoundedSumInt64) bool {
 		s1.lInfSensitivity == s2.lInfSensitivity &&
 		s1.lower == s2.lower &&
 		s1.upper == s2.upper &&
-		s1.noiseKind == s2.noiseKind
+		s1.noiseKind == s2.noiseKind &&
+		autoBoundsEquallyInitializedInt64(s1.autoBounds, s2.autoBounds)
 }
 
 // BoundedSumInt64Options contains the options necessary to initialize a BoundedSumInt64.
@@ -70,6 +78,10 @@ type BoundedSumInt64Options struct {
 	// Lower and Upper bounds for clamping. Default to 0; must be such that Lower < Upper.
 	Lower, Upper						 int64
 	Noise                    noise.Noise // Type of noise used in BoundedSum. Defaults to Laplace noise.
+	// If true, Lower and Upper must be left 0, and the bounds are determined
+	// from the data with ApproxBounds. Half of Epsilon is then used for
+	// determining the bounds, and half for the sum.
+	AutoBounds bool
 	// How many times may a single user contribute to a single partition?
 	// Defaults to 1. This is only needed for other aggregation functions using BoundedSum;
 	// which is why the option is not exported.
@@ -77,7 +89,20 @@ type BoundedSumInt64Options struct {
 }
 
 // NewBoundedSumInt64 returns a new BoundedSumInt64, whose sum is initialized at 0.
+// It exits the program if the options are invalid; use NewBoundedSumInt64E to
+// handle this case instead.
 func NewBoundedSumInt64(opt *BoundedSumInt64Options) *BoundedSumInt64 {
+	bs, err := NewBoundedSumInt64E(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewBoundedSumInt64: %v", err)
+	}
+	return bs
+}
+
+// NewBoundedSumInt64E is the same as NewBoundedSumInt64, but returns an error
+// instead of exiting the program if the options are invalid.
+func NewBoundedSumInt64E(opt *BoundedSumInt64Options) (*BoundedSumInt64, error) {
 	if opt == nil {
 		opt = &BoundedSumInt64Options{}
 	}
@@ -96,25 +121,27 @@ func NewBoundedSumInt64(opt *BoundedSumInt64Options) *BoundedSumInt64 {
 	if n == nil {
 		n = noise.Laplace()
 	}
+	eps, del := opt.Epsilon, opt.Delta
+	if opt.AutoBounds {
+		return newBoundedSumInt64WithAutoBounds(opt, l0, maxContributionsPerPartition, n)
+	}
 	// Check bounds & use them to compute L_∞ sensitivity
 	lower, upper := opt.Lower, opt.Upper
 	if lower == 0 && upper == 0 {
-		// TODO: do not exit the program from within library code
-		log.Fatalf("NewBoundedSumInt64 requires a non-default value for Lower or Upper (automatic bounds determination is not implemented yet)")
+		return nil, fmt.Errorf("NewBoundedSumInt64 requires a non-default value for Lower or Upper, or AutoBounds to be set")
 	}
 	if err := checks.CheckBoundsInt64("NewBoundedSumInt64", lower, upper); err != nil {
-		// TODO: do not exit the program from within library code
-		log.Fatalf("CheckBoundsInt64(lower %d, upper %d) failed with %v", lower, upper, err)
+		return nil, err
 	}
 	lInf, err := getLInfInt(lower, upper, maxContributionsPerPartition)
 	if err != nil {
-		// TODO: do not exit the program from within library code
-		log.Fatalf("getLInfInt(lower %d, upper %d, maxContributionsPerPartition %d) failed with %v", lower, upper, maxContributionsPerPartition, err)
+		return nil, err
 	}
 	// Check that the parameters are compatible with the noise chosen by calling
 	// the noise on some dummy value.
-	eps, del := opt.Epsilon, opt.Delta
-	n.AddNoiseInt64(0, l0, lInf, eps, del)
+	if _, err := noise.AddNoiseInt64E(n, 0, l0, lInf, eps, del); err != nil {
+		return nil, err
+	}
 
 	return &BoundedSumInt64{
 		epsilon:         eps,
@@ -127,7 +154,39 @@ func NewBoundedSumInt64(opt *BoundedSumInt64Options) *BoundedSumInt64 {
 		noiseKind:       noise.ToKind(n),
 		sum:             0,
 		resultReturned:  false,
+	}, nil
+}
+
+// newBoundedSumInt64WithAutoBounds returns a new BoundedSumInt64 whose bounds
+// are determined automatically with ApproxBounds, which uses half of the
+// privacy budget.
+func newBoundedSumInt64WithAutoBounds(opt *BoundedSumInt64Options, l0, maxContributionsPerPartition int64, n noise.Noise) (*BoundedSumInt64, error) {
+	if opt.Lower != 0 || opt.Upper != 0 {
+		return nil, fmt.Errorf("NewBoundedSumInt64 requires Lower and Upper to be 0 when AutoBounds is set, got lower %d, upper %d", opt.Lower, opt.Upper)
+	}
+	// ApproxBounds always uses Laplace noise, so the entire δ is left for the sum.
+	halfEpsilon := opt.Epsilon / 2
+	// Check that the parameters are compatible with the noise chosen by calling
+	// the noise on some dummy value. The L_∞ sensitivity is not known until the
+	// bounds are determined.
+	if _, err := noise.AddNoiseInt64E(n, 0, l0, maxContributionsPerPartition, halfEpsilon, opt.Delta); err != nil {
+		return nil, err
 	}
+	autoBounds, err := newAutoBoundsInt64(halfEpsilon, l0, maxContributionsPerPartition, noise.SourceOf(n))
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoundedSumInt64{
+		epsilon:        halfEpsilon,
+		delta:          opt.Delta,
+		l0Sensitivity:  l0,
+		noise:          n,
+		noiseKind:      noise.ToKind(n),
+		sum:            0,
+		resultReturned: false,
+		autoBounds:     autoBounds,
+	}, nil
 }
 
 // lInfIntOverflows checks if multiplication of the given number overflows int64.
@@ -170,27 +229,52 @@ func getLInfInt(lower, upper, maxContributionsPerPartition int64) (int64, error)
 
 // Add adds a new summand to the BoundedSumInt64.
 func (bs *BoundedSumInt64) Add(e int64) {
-	if bs.resultReturned {
+	if err := bs.AddE(e); err != nil {
 		// TODO: do not exit the program from within library code
-		log.Fatalf("The sum has already been calculated and returned. It cannot be amended.")
+		log.Fatal(err)
+	}
+}
+
+// AddE is the same as Add, but returns an error instead of exiting the program
+// if the result has already been returned.
+func (bs *BoundedSumInt64) AddE(e int64) error {
+	if bs.resultReturned {
+		return fmt.Errorf("The sum has already been calculated and returned. It cannot be amended.")
+	}
+	if bs.autoBounds != nil {
+		bs.autoBounds.add(e)
+		return nil
 	}
 	clamped, err := ClampInt64(e, bs.lower, bs.upper)
 	if err != nil {
-		// TODO: do not exit the program from within library code
-		log.Fatalf("Couldn't clamp input value %v, err %v", e, err)
+		return fmt.Errorf("Couldn't clamp input value %v, err %v", e, err)
 	}
 	bs.sum += clamped
+	return nil
 }
 
 // Merge merges bs2 into bs (i.e., adds to bs all entries that were added to
 // bs2). bs2 is consumed by this operation: bs2 may not be used after it is
 // merged into bs.
 func (bs *BoundedSumInt64) Merge(bs2 *BoundedSumInt64) {
-	if e := checkMergeBoundedSumInt64(bs, bs2); e != nil {
-		log.Exit(e)
+	if err := bs.MergeE(bs2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+}
+
+// MergeE is the same as Merge, but returns an error instead of exiting the
+// program if bs and bs2 cannot be merged.
+func (bs *BoundedSumInt64) MergeE(bs2 *BoundedSumInt64) error {
+	if err := checkMergeBoundedSumInt64(bs, bs2); err != nil {
+		return err
+	}
+	if bs.autoBounds != nil {
+		bs.autoBounds.merge(bs2.autoBounds)
 	}
 	bs.sum += bs2.sum
 	bs2.resultReturned = true
+	return nil
 }
 
 func checkMergeBoundedSumInt64(bs1, bs2 *BoundedSumInt64) error {
@@ -211,26 +295,129 @@ func checkMergeBoundedSumInt64(bs1, bs2 *BoundedSumInt64) error {
 // elements added so far. It can be called only once, after which no further
 // operation can be done on the BoundedSumInt64.
 func (bs *BoundedSumInt64) Result() int64 {
-	if bs.resultReturned {
+	result, err := bs.ResultE()
+	if err != nil {
 		// TODO: do not exit the program from within library code
-		log.Fatalf("The sum has already been calculated and returned. It can only be returned once.")
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the result has already been returned, or an error wrapping
+// ErrBoundsNotFound if the bounds are determined automatically and ApproxBounds
+// fails to find them.
+func (bs *BoundedSumInt64) ResultE() (int64, error) {
+	if err := bs.prepareResult(); err != nil {
+		return 0, err
+	}
+	result, err := noise.AddNoiseInt64E(bs.noise, bs.sum, bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta)
+	if err != nil {
+		return 0, err
+	}
+	bs.noisedSum = &result
+	return result, nil
+}
+
+// prepareResult marks the result of bs as returned, and determines the bounds
+// if they are determined automatically. It returns an error wrapping
+// ErrBoundsNotFound if the bounds cannot be determined.
+func (bs *BoundedSumInt64) prepareResult() error {
+	if bs.resultReturned {
+		return fmt.Errorf("The sum has already been calculated and returned. It can only be returned once.")
 	}
 	bs.resultReturned = true
-	return bs.noise.AddNoiseInt64(bs.sum, bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta)
+	if bs.autoBounds == nil {
+		return nil
+	}
+	lower, upper, sum, err := bs.autoBounds.clampedSum()
+	if err == nil {
+		err = bs.setBounds(lower, upper, bs.autoBounds.ApproxBounds.lInfSensitivity)
+	}
+	if err != nil {
+		return fmt.Errorf("BoundedSumInt64: %w: %v", ErrBoundsNotFound, err)
+	}
+	bs.sum = sum
+	return nil
+}
+
+// setBounds sets the bounds of bs and the L_∞ sensitivity derived from them.
+// It is used when the bounds are only known after the entries are added.
+func (bs *BoundedSumInt64) setBounds(lower, upper int64, maxContributionsPerPartition int64) error {
+	lInf, err := getLInfInt(lower, upper, maxContributionsPerPartition)
+	if err != nil {
+		return err
+	}
+	bs.lower, bs.upper, bs.lInfSensitivity = lower, upper, lInf
+	return nil
 }
 
 // ThresholdedResult is similar to Result() but applies thresholding to the
 // result. So, if the result is less than the threshold specified by the noise
-// mechanism, it returns nil. Otherwise, it returns the result.
+// mechanism, or if the bounds cannot be determined automatically, it returns
+// nil. Otherwise, it returns the result.
 func (bs *BoundedSumInt64) ThresholdedResult(deltaThreshold float64) *int64 {
-	threshold := bs.noise.Threshold(bs.l0Sensitivity, float64(bs.lInfSensitivity), bs.epsilon, bs.delta, deltaThreshold)
-	result := bs.Result()
+	result, err := bs.ThresholdedResultE(deltaThreshold)
+	if errors.Is(err, ErrBoundsNotFound) {
+		return nil
+	}
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ThresholdedResultE is the same as ThresholdedResult, but returns an error
+// instead of exiting the program if the result has already been returned or
+// deltaThreshold is invalid, or an error wrapping ErrBoundsNotFound if the
+// bounds cannot be determined automatically.
+func (bs *BoundedSumInt64) ThresholdedResultE(deltaThreshold float64) (*int64, error) {
+	if err := bs.prepareResult(); err != nil {
+		return nil, err
+	}
+	result, err := noise.AddNoiseInt64E(bs.noise, bs.sum, bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta)
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := noise.ThresholdE(bs.noise, bs.l0Sensitivity, float64(bs.lInfSensitivity), bs.epsilon, bs.delta, deltaThreshold)
+	if err != nil {
+		return nil, err
+	}
 	// To make sure floating-point rounding doesn't break DP guarantees, we err on
 	// the side of dropping the result if it is exactly equal to the threshold.
 	if float64(result) <= threshold {
-		return nil
+		return nil, nil
+	}
+	bs.noisedSum = &result
+	return &result, nil
+}
+
+// ConfidenceInterval returns a confidence interval that contains the raw
+// clamped sum with probability at least 1-alpha. It can only be called after
+// the result was returned by Result or ThresholdedResult. Computing it doesn't
+// consume any privacy budget.
+func (bs *BoundedSumInt64) ConfidenceInterval(alpha float64) noise.ConfidenceInterval {
+	confInt, err := bs.ConfidenceIntervalE(alpha)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return confInt
+}
+
+// ConfidenceIntervalE is the same as ConfidenceInterval, but returns an error
+// instead of exiting the program if no result was returned or alpha is
+// invalid.
+func (bs *BoundedSumInt64) ConfidenceIntervalE(alpha float64) (noise.ConfidenceInterval, error) {
+	if bs.noisedSum == nil {
+		return noise.ConfidenceInterval{}, fmt.Errorf("The confidence interval of the sum can only be computed after its result is returned.")
+	}
+	confInt, err := noise.ComputeConfidenceIntervalFloat64E(bs.noise, float64(*bs.noisedSum), bs.l0Sensitivity, float64(bs.lInfSensitivity), bs.epsilon, bs.delta, alpha)
+	if err != nil {
+		return noise.ConfidenceInterval{}, err
 	}
-	return &result
+	return roundConfidenceInterval(confInt), nil
 }
 
 // encodableBoundedSumFloat64 can be encoded by the gob package.
@@ -244,6 +431,9 @@ type encodableBoundedSumInt64 struct {
 	NoiseKind       noise.Kind
 	Sum             int64
 	ResultReturned  bool
+	AutoBounds      *autoBoundsInt64
+	// Seed of the deterministic Source of the noise, if any.
+	Seed encodableSeed
 }
 
 // GobEncode encodes BoundedSumInt64.
@@ -258,6 +448,8 @@ func (bs *BoundedSumInt64) GobEncode() ([]byte, error) {
 		NoiseKind:       noise.ToKind(bs.noise),
 		Sum:             bs.sum,
 		ResultReturned:  bs.resultReturned,
+		AutoBounds:      bs.autoBounds,
+		Seed:            seedOf(noise.SourceOf(bs.noise)),
 	}
 	bs.resultReturned = true
 	return encode(enc)
@@ -268,9 +460,9 @@ func (bs *BoundedSumInt64) GobDecode(data []byte) error {
 	var enc encodableBoundedSumInt64
 	err := decode(&enc, data)
 	if err != nil {
-		log.Fatalf("GobDecode: couldn't decode BoundedSumInt64 from bytes")
 		return err
 	}
+	src := enc.Seed.source()
 	*bs = BoundedSumInt64{
 		epsilon:         enc.Epsilon,
 		delta:           enc.Delta,
@@ -279,9 +471,14 @@ func (bs *BoundedSumInt64) GobDecode(data []byte) error {
 		lower:           enc.Lower,
 		upper:           enc.Upper,
 		noiseKind:       enc.NoiseKind,
-		noise:           noise.ToNoise(enc.NoiseKind),
+		noise:           noise.WithSource(noise.ToNoise(enc.NoiseKind), src),
 		sum:             enc.Sum,
 		resultReturned:  enc.ResultReturned,
+		autoBounds:      enc.AutoBounds,
+	}
+	if bs.autoBounds != nil {
+		// As when bs was created, ApproxBounds draws from the same Source.
+		bs.autoBounds.ApproxBounds.setSource(src)
 	}
 	return nil
 }
@@ -295,7 +492,8 @@ func (bs *BoundedSumInt64) GobDecode(data []byte) error {
 // Note: Do not use when your results may cause overflows for float64
 // values. This aggregation is not hardened for such applications yet.
 //
-// Not thread-safe.
+// Not thread-safe; use ConcurrentBoundedSumFloat64 to add elements from
+// several goroutines.
 type BoundedSumFloat64 struct {
 	// Parameters
 	epsilon         float64
@@ -310,6 +508,11 @@ type BoundedSumFloat64 struct {
 	// State variables
 	sum            float64
 	resultReturned bool // whether the result has already been returned
+	// The returned result, used to compute confidence intervals. nil if no
+	// result was returned, e.g. because it was thresholded.
+	noisedSum *float64
+	// Set if the bounds are determined automatically, nil otherwise.
+	autoBounds *autoBoundsFloat64
 }
 
 func bsEquallyInitializedFloat64(s1, s2 *BoundedSumFloat64) bool {
@@ -319,7 +522,8 @@ func bsEquallyInitializedFloat64(s1, s2 *BoundedSumFloat64) bool {
 		s1.lInfSensitivity == s2.lInfSensitivity &&
 		s1.lower == s2.lower &&
 		s1.upper == s2.upper &&
-		s1.noiseKind == s2.noiseKind
+		s1.noiseKind == s2.noiseKind &&
+		autoBoundsEquallyInitializedFloat64(s1.autoBounds, s2.autoBounds)
 }
 
 // BoundedSumFloat64Options contains the options necessary to initialize a BoundedSumFloat64.
@@ -330,6 +534,10 @@ type BoundedSumFloat64Options struct {
 	// Lower and Upper bounds for clamping. Default to 0; must be such that Lower < Upper.
 	Lower, Upper             float64
 	Noise                    noise.Noise // Type of noise used in BoundedSum. Defaults to Laplace noise.
+	// If true, Lower and Upper must be left 0, and the bounds are determined
+	// from the data with ApproxBounds. Half of Epsilon is then used for
+	// determining the bounds, and half for the sum.
+	AutoBounds bool
 	// How many times may a single user contribute to a single partition?
 	// Defaults to 1. This is only needed for other aggregation functions using BoundedSum;
 	// which is why the option is not exported.
@@ -337,7 +545,20 @@ type BoundedSumFloat64Options struct {
 }
 
 // NewBoundedSumFloat64 returns a new BoundedSumFloat64, whose sum is initialized at 0.
+// It exits the program if the options are invalid; use NewBoundedSumFloat64E to
+// handle this case instead.
 func NewBoundedSumFloat64(opt *BoundedSumFloat64Options) *BoundedSumFloat64 {
+	bs, err := NewBoundedSumFloat64E(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewBoundedSumFloat64: %v", err)
+	}
+	return bs
+}
+
+// NewBoundedSumFloat64E is the same as NewBoundedSumFloat64, but returns an error
+// instead of exiting the program if the options are invalid.
+func NewBoundedSumFloat64E(opt *BoundedSumFloat64Options) (*BoundedSumFloat64, error) {
 	if opt == nil {
 		opt = &BoundedSumFloat64Options{}
 	}
@@ -356,25 +577,27 @@ func NewBoundedSumFloat64(opt *BoundedSumFloat64Options) *BoundedSumFloat64 {
 	if n == nil {
 		n = noise.Laplace()
 	}
+	eps, del := opt.Epsilon, opt.Delta
+	if opt.AutoBounds {
+		return newBoundedSumFloat64WithAutoBounds(opt, l0, maxContributionsPerPartition, n)
+	}
 	// Check bounds & use them to compute L_∞ sensitivity
 	lower, upper := opt.Lower, opt.Upper
 	if lower == 0 && upper == 0 {
-		// TODO: do not exit the program from within library code
-		log.Fatalf("NewBoundedSumFloat64 requires a non-default value for Lower or Upper (automatic bounds determination is not implemented yet)")
+		return nil, fmt.Errorf("NewBoundedSumFloat64 requires a non-default value for Lower or Upper, or AutoBounds to be set")
 	}
 	if err := checks.CheckBoundsFloat64("NewBoundedSumFloat64", lower, upper); err != nil {
-		// TODO: do not exit the program from within library code
-		log.Fatalf("CheckBoundsFloat64(lower %f, upper %f) failed with %v", lower, upper, err)
+		return nil, err
 	}
 	lInf, err := getLInfFloat(lower, upper, maxContributionsPerPartition)
 	if err != nil {
-		// TODO: do not exit the program from within library code
-		log.Fatalf("getLInfFloat(lower %f, upper %f, maxContributionsPerPartition %d) failed with %v", lower, upper, maxContributionsPerPartition, err)
+		return nil, err
 	}
 	// Check that the parameters are compatible with the noise chosen by calling
 	// the noise on some dummy value.
-	eps, del := opt.Epsilon, opt.Delta
-	n.AddNoiseFloat64(0, l0, lInf, eps, del)
+	if _, err := noise.AddNoiseFloat64E(n, 0, l0, lInf, eps, del); err != nil {
+		return nil, err
+	}
 
 	return &BoundedSumFloat64{
 		epsilon:         eps,
@@ -387,7 +610,39 @@ func NewBoundedSumFloat64(opt *BoundedSumFloat64Options) *BoundedSumFloat64 {
 		noiseKind:       noise.ToKind(n),
 		sum:             0,
 		resultReturned:  false,
+	}, nil
+}
+
+// newBoundedSumFloat64WithAutoBounds returns a new BoundedSumFloat64 whose bounds
+// are determined automatically with ApproxBounds, which uses half of the
+// privacy budget.
+func newBoundedSumFloat64WithAutoBounds(opt *BoundedSumFloat64Options, l0, maxContributionsPerPartition int64, n noise.Noise) (*BoundedSumFloat64, error) {
+	if opt.Lower != 0 || opt.Upper != 0 {
+		return nil, fmt.Errorf("NewBoundedSumFloat64 requires Lower and Upper to be 0 when AutoBounds is set, got lower %f, upper %f", opt.Lower, opt.Upper)
+	}
+	// ApproxBounds always uses Laplace noise, so the entire δ is left for the sum.
+	halfEpsilon := opt.Epsilon / 2
+	// Check that the parameters are compatible with the noise chosen by calling
+	// the noise on some dummy value. The L_∞ sensitivity is not known until the
+	// bounds are determined.
+	if _, err := noise.AddNoiseFloat64E(n, 0, l0, float64(maxContributionsPerPartition), halfEpsilon, opt.Delta); err != nil {
+		return nil, err
+	}
+	autoBounds, err := newAutoBoundsFloat64(halfEpsilon, l0, maxContributionsPerPartition, noise.SourceOf(n))
+	if err != nil {
+		return nil, err
 	}
+
+	return &BoundedSumFloat64{
+		epsilon:        halfEpsilon,
+		delta:          opt.Delta,
+		l0Sensitivity:  l0,
+		noise:          n,
+		noiseKind:      noise.ToKind(n),
+		sum:            0,
+		resultReturned: false,
+		autoBounds:     autoBounds,
+	}, nil
 }
 
 func lInfFloatOverflows(bound float64, maxContributionsPerPartition int64) bool {
@@ -425,29 +680,54 @@ func getLInfFloat(lower, upper float64, maxContributionsPerPartition int64) (flo
 // regardless of other summands, which would break the indistinguishability
 // property required for differential privacy.
 func (bs *BoundedSumFloat64) Add(e float64) {
-	if bs.resultReturned {
+	if err := bs.AddE(e); err != nil {
 		// TODO: do not exit the program from within library code
-		log.Fatalf("The sum has already been calculated and returned. It cannot be amended.")
+		log.Fatal(err)
+	}
+}
+
+// AddE is the same as Add, but returns an error instead of exiting the program
+// if the result has already been returned.
+func (bs *BoundedSumFloat64) AddE(e float64) error {
+	if bs.resultReturned {
+		return fmt.Errorf("The sum has already been calculated and returned. It cannot be amended.")
+	}
+	if bs.autoBounds != nil {
+		bs.autoBounds.add(e)
+		return nil
 	}
 	if !math.IsNaN(e) {
 		clamped, err := ClampFloat64(e, bs.lower, bs.upper)
 		if err != nil {
-			// TODO: do not exit the program from within library code
-			log.Fatalf("Couldn't clamp input value %v, err %v", e, err)
+			return fmt.Errorf("Couldn't clamp input value %v, err %v", e, err)
 		}
 		bs.sum += clamped
 	}
+	return nil
 }
 
 // Merge merges bs2 into bs (i.e., adds to bs all entries that were added to
 // bs2). bs2 is consumed by this operation: bs2 may not be used after it is
 // merged into bs.
 func (bs *BoundedSumFloat64) Merge(bs2 *BoundedSumFloat64) {
-	if e := checkMergeBoundedSumFloat64(bs, bs2); e != nil {
-		log.Exit(e)
+	if err := bs.MergeE(bs2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+}
+
+// MergeE is the same as Merge, but returns an error instead of exiting the
+// program if bs and bs2 cannot be merged.
+func (bs *BoundedSumFloat64) MergeE(bs2 *BoundedSumFloat64) error {
+	if err := checkMergeBoundedSumFloat64(bs, bs2); err != nil {
+		return err
+	}
+	if bs.autoBounds != nil {
+		bs.autoBounds.merge(bs2.autoBounds)
 	}
 	bs.sum += bs2.sum
 	bs2.resultReturned = true
+	return nil
 }
 
 func checkMergeBoundedSumFloat64(bs1, bs2 *BoundedSumFloat64) error {
@@ -468,24 +748,123 @@ func checkMergeBoundedSumFloat64(bs1, bs2 *BoundedSumFloat64) error {
 // elements added so far. It can be called only once, after which no further
 // operation can be done on the BoundedSumFloat64.
 func (bs *BoundedSumFloat64) Result() float64 {
-	if bs.resultReturned {
+	result, err := bs.ResultE()
+	if err != nil {
 		// TODO: do not exit the program from within library code
-		log.Fatalf("The sum has already been calculated and returned. It can only be returned once.")
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the result has already been returned, or an error wrapping
+// ErrBoundsNotFound if the bounds are determined automatically and ApproxBounds
+// fails to find them.
+func (bs *BoundedSumFloat64) ResultE() (float64, error) {
+	if err := bs.prepareResult(); err != nil {
+		return 0, err
+	}
+	result, err := noise.AddNoiseFloat64E(bs.noise, bs.sum, bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta)
+	if err != nil {
+		return 0, err
+	}
+	bs.noisedSum = &result
+	return result, nil
+}
+
+// prepareResult marks the result of bs as returned, and determines the bounds
+// if they are determined automatically. It returns an error wrapping
+// ErrBoundsNotFound if the bounds cannot be determined.
+func (bs *BoundedSumFloat64) prepareResult() error {
+	if bs.resultReturned {
+		return fmt.Errorf("The sum has already been calculated and returned. It can only be returned once.")
 	}
 	bs.resultReturned = true
-	return bs.noise.AddNoiseFloat64(bs.sum, bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta)
+	if bs.autoBounds == nil {
+		return nil
+	}
+	lower, upper, sum, err := bs.autoBounds.clampedSum()
+	if err == nil {
+		err = bs.setBounds(lower, upper, bs.autoBounds.ApproxBounds.lInfSensitivity)
+	}
+	if err != nil {
+		return fmt.Errorf("BoundedSumFloat64: %w: %v", ErrBoundsNotFound, err)
+	}
+	bs.sum = sum
+	return nil
+}
+
+// setBounds sets the bounds of bs and the L_∞ sensitivity derived from them.
+// It is used when the bounds are only known after the entries are added.
+func (bs *BoundedSumFloat64) setBounds(lower, upper float64, maxContributionsPerPartition int64) error {
+	lInf, err := getLInfFloat(lower, upper, maxContributionsPerPartition)
+	if err != nil {
+		return err
+	}
+	bs.lower, bs.upper, bs.lInfSensitivity = lower, upper, lInf
+	return nil
 }
 
 // ThresholdedResult is similar to Result() but applies thresholding to the
 // result. So, if the result is less than the threshold specified by the noise,
-// mechanism, it returns nil. Otherwise, it returns the result.
+// mechanism, or if the bounds cannot be determined automatically, it returns
+// nil. Otherwise, it returns the result.
 func (bs *BoundedSumFloat64) ThresholdedResult(deltaThreshold float64) *float64 {
-	threshold := bs.noise.Threshold(bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta, deltaThreshold)
-	result := bs.Result()
-	if result < threshold {
+	result, err := bs.ThresholdedResultE(deltaThreshold)
+	if errors.Is(err, ErrBoundsNotFound) {
 		return nil
 	}
-	return &result
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ThresholdedResultE is the same as ThresholdedResult, but returns an error
+// instead of exiting the program if the result has already been returned or
+// deltaThreshold is invalid, or an error wrapping ErrBoundsNotFound if the
+// bounds cannot be determined automatically.
+func (bs *BoundedSumFloat64) ThresholdedResultE(deltaThreshold float64) (*float64, error) {
+	if err := bs.prepareResult(); err != nil {
+		return nil, err
+	}
+	result, err := noise.AddNoiseFloat64E(bs.noise, bs.sum, bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta)
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := noise.ThresholdE(bs.noise, bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta, deltaThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if result < threshold {
+		return nil, nil
+	}
+	bs.noisedSum = &result
+	return &result, nil
+}
+
+// ConfidenceInterval returns a confidence interval that contains the raw
+// clamped sum with probability at least 1-alpha. It can only be called after
+// the result was returned by Result or ThresholdedResult. Computing it doesn't
+// consume any privacy budget.
+func (bs *BoundedSumFloat64) ConfidenceInterval(alpha float64) noise.ConfidenceInterval {
+	confInt, err := bs.ConfidenceIntervalE(alpha)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return confInt
+}
+
+// ConfidenceIntervalE is the same as ConfidenceInterval, but returns an error
+// instead of exiting the program if no result was returned or alpha is
+// invalid.
+func (bs *BoundedSumFloat64) ConfidenceIntervalE(alpha float64) (noise.ConfidenceInterval, error) {
+	if bs.noisedSum == nil {
+		return noise.ConfidenceInterval{}, fmt.Errorf("The confidence interval of the sum can only be computed after its result is returned.")
+	}
+	return noise.ComputeConfidenceIntervalFloat64E(bs.noise, *bs.noisedSum, bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta, alpha)
 }
 
 // encodableBoundedSumFloat64 can be encoded by the gob package.
@@ -499,6 +878,9 @@ type encodableBoundedSumFloat64 struct {
 	NoiseKind       noise.Kind
 	Sum             float64
 	ResultReturned  bool
+	AutoBounds      *autoBoundsFloat64
+	// Seed of the deterministic Source of the noise, if any.
+	Seed encodableSeed
 }
 
 // GobEncode encodes BoundedSumInt64.
@@ -513,6 +895,8 @@ func (bs *BoundedSumFloat64) GobEncode() ([]byte, error) {
 		NoiseKind:       noise.ToKind(bs.noise),
 		Sum:             bs.sum,
 		ResultReturned:  bs.resultReturned,
+		AutoBounds:      bs.autoBounds,
+		Seed:            seedOf(noise.SourceOf(bs.noise)),
 	}
 	bs.resultReturned = true
 	return encode(enc)
@@ -523,9 +907,9 @@ func (bs *BoundedSumFloat64) GobDecode(data []byte) error {
 	var enc encodableBoundedSumFloat64
 	err := decode(&enc, data)
 	if err != nil {
-		log.Fatalf("GobDecode: couldn't decode BoundedSumFloat64 from bytes")
 		return err
 	}
+	src := enc.Seed.source()
 	*bs = BoundedSumFloat64{
 		epsilon:         enc.Epsilon,
 		delta:           enc.Delta,
@@ -534,9 +918,14 @@ func (bs *BoundedSumFloat64) GobDecode(data []byte) error {
 		lower:           enc.Lower,
 		upper:           enc.Upper,
 		noiseKind:       enc.NoiseKind,
-		noise:           noise.ToNoise(enc.NoiseKind),
+		noise:           noise.WithSource(noise.ToNoise(enc.NoiseKind), src),
 		sum:             enc.Sum,
 		resultReturned:  enc.ResultReturned,
+		autoBounds:      enc.AutoBounds,
+	}
+	if bs.autoBounds != nil {
+		// As when bs was created, ApproxBounds draws from the same Source.
+		bs.autoBounds.ApproxBounds.setSource(src)
 	}
 	return nil
 }
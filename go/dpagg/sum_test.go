@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/go/rand"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -101,6 +102,47 @@ func TestBoundedSumInt64Serialization(t *testing.T) {
 	}
 }
 
+// Checks that BoundedSumInt64s decoded from the same encoding, using noise with
+// a deterministic source, return the same result, including when the bounds are
+// determined automatically. Decoding used to replace the source with the
+// cryptographically secure one.
+func TestBoundedSumInt64WithDeterministicSourceSerialization(t *testing.T) {
+	for _, autoBounds := range []bool{false, true} {
+		for seed := int64(0); seed < 10; seed++ {
+			newSum := func() *BoundedSumInt64 {
+				opt := &BoundedSumInt64Options{
+					Epsilon:    ln3,
+					Lower:      -1,
+					Upper:      5,
+					AutoBounds: autoBounds,
+					Noise:      noise.WithSource(noise.Laplace(), rand.NewDeterministicSource(seed)),
+				}
+				if autoBounds {
+					opt.Lower, opt.Upper = 0, 0
+				}
+				bs := NewBoundedSumInt64(opt)
+				for i := 0; i < 1000; i++ {
+					bs.Add(3)
+				}
+				return bs
+			}
+			bytes, err := encode(newSum())
+			if err != nil {
+				t.Fatalf("encode(BoundedSumInt64) error: %v", err)
+			}
+			decoded1, decoded2 := new(BoundedSumInt64), new(BoundedSumInt64)
+			for _, decoded := range []*BoundedSumInt64{decoded1, decoded2} {
+				if err := decode(decoded, bytes); err != nil {
+					t.Fatalf("decode(BoundedSumInt64) error: %v", err)
+				}
+			}
+			if got1, got2 := decoded1.Result(), decoded2.Result(); got1 != got2 {
+				t.Errorf("Result: for BoundedSumInt64s decoded from the same encoding with AutoBounds=%t and seed %d got %d and %d, want identical results", autoBounds, seed, got1, got2)
+			}
+		}
+	}
+}
+
 func compareBoundedSumFloat64(bs1, bs2 *BoundedSumFloat64) bool {
 	return bs1.epsilon == bs2.epsilon &&
 		bs1.delta == bs2.delta &&
@@ -155,6 +197,47 @@ func TestBoundedSumFloat64Serialization(t *testing.T) {
 	}
 }
 
+// Checks that BoundedSumFloat64s decoded from the same encoding, using noise
+// with a deterministic source, return the same result, including when the
+// bounds are determined automatically. Decoding used to replace the source with
+// the cryptographically secure one.
+func TestBoundedSumFloat64WithDeterministicSourceSerialization(t *testing.T) {
+	for _, autoBounds := range []bool{false, true} {
+		for seed := int64(0); seed < 10; seed++ {
+			newSum := func() *BoundedSumFloat64 {
+				opt := &BoundedSumFloat64Options{
+					Epsilon:    ln3,
+					Lower:      -1,
+					Upper:      5,
+					AutoBounds: autoBounds,
+					Noise:      noise.WithSource(noise.Laplace(), rand.NewDeterministicSource(seed)),
+				}
+				if autoBounds {
+					opt.Lower, opt.Upper = 0, 0
+				}
+				bs := NewBoundedSumFloat64(opt)
+				for i := 0; i < 1000; i++ {
+					bs.Add(2.5)
+				}
+				return bs
+			}
+			bytes, err := encode(newSum())
+			if err != nil {
+				t.Fatalf("encode(BoundedSumFloat64) error: %v", err)
+			}
+			decoded1, decoded2 := new(BoundedSumFloat64), new(BoundedSumFloat64)
+			for _, decoded := range []*BoundedSumFloat64{decoded1, decoded2} {
+				if err := decode(decoded, bytes); err != nil {
+					t.Fatalf("decode(BoundedSumFloat64) error: %v", err)
+				}
+			}
+			if got1, got2 := decoded1.Result(), decoded2.Result(); got1 != got2 {
+				t.Errorf("Result: for BoundedSumFloat64s decoded from the same encoding with AutoBounds=%t and seed %d got %f and %f, want identical results", autoBounds, seed, got1, got2)
+			}
+		}
+	}
+}
+
 func TestGetLInfInt(t *testing.T) {
 	for _, tc := range []struct {
 		desc                         string
@@ -1389,3 +1472,76 @@ func TestBSEquallyInitializedFloat64(t *testing.T) {
 		}
 	}
 }
+
+func TestBoundedSumInt64ConfidenceInterval(t *testing.T) {
+	bs := NewBoundedSumInt64(&BoundedSumInt64Options{Epsilon: ln3, Delta: tenfive, Lower: -1, Upper: 5, Noise: noise.Gaussian()})
+	bs.Add(3)
+	result := bs.Result()
+	got := bs.ConfidenceInterval(0.05)
+	confInt := noise.Gaussian().ComputeConfidenceIntervalFloat64(float64(result), 1, 5, ln3, tenfive, 0.05)
+	want := noise.ConfidenceInterval{LowerBound: math.Floor(confInt.LowerBound), UpperBound: math.Ceil(confInt.UpperBound)}
+	if got != want {
+		t.Errorf("ConfidenceInterval(0.05): got %+v, want %+v", got, want)
+	}
+}
+
+func TestBoundedSumFloat64ConfidenceInterval(t *testing.T) {
+	bs := NewBoundedSumFloat64(&BoundedSumFloat64Options{Epsilon: ln3, Lower: -1, Upper: 5})
+	bs.Add(3.5)
+	result := bs.Result()
+	got := bs.ConfidenceInterval(0.05)
+	want := noise.Laplace().ComputeConfidenceIntervalFloat64(result, 1, 5, ln3, 0, 0.05)
+	if got != want {
+		t.Errorf("ConfidenceInterval(0.05): got %+v, want %+v", got, want)
+	}
+}
+
+func TestBoundedSumConfidenceIntervalEReturnsErrorWithoutResult(t *testing.T) {
+	bsi := getNoiselessBSI()
+	if _, err := bsi.ConfidenceIntervalE(0.05); err == nil {
+		t.Errorf("BoundedSumInt64.ConfidenceIntervalE before Result: got no error, want error")
+	}
+	bsf := getNoiselessBSF()
+	if _, err := bsf.ConfidenceIntervalE(0.05); err == nil {
+		t.Errorf("BoundedSumFloat64.ConfidenceIntervalE before Result: got no error, want error")
+	}
+	// The results are below the threshold of noNoise, so they aren't returned.
+	bsi.Add(1)
+	bsi.ThresholdedResult(0.1)
+	if _, err := bsi.ConfidenceIntervalE(0.05); err == nil {
+		t.Errorf("BoundedSumInt64.ConfidenceIntervalE after a thresholded result: got no error, want error")
+	}
+	bsf.Add(1)
+	bsf.ThresholdedResult(0.1)
+	if _, err := bsf.ConfidenceIntervalE(0.05); err == nil {
+		t.Errorf("BoundedSumFloat64.ConfidenceIntervalE after a thresholded result: got no error, want error")
+	}
+}
+
+// Checks that sums using noise with the same deterministic source return the
+// same results, including when the bounds are determined automatically.
+func TestBoundedSumFloat64WithDeterministicSourceIsReproducible(t *testing.T) {
+	for _, autoBounds := range []bool{false, true} {
+		var results [2]float64
+		for i := range results {
+			opt := &BoundedSumFloat64Options{
+				Epsilon:    ln3,
+				Lower:      -1,
+				Upper:      5,
+				AutoBounds: autoBounds,
+				Noise:      noise.WithSource(noise.Laplace(), rand.NewDeterministicSource(42)),
+			}
+			if autoBounds {
+				opt.Lower, opt.Upper = 0, 0
+			}
+			bs := NewBoundedSumFloat64(opt)
+			for j := 0; j < 1000; j++ {
+				bs.Add(2.5)
+			}
+			results[i] = bs.Result()
+		}
+		if results[0] != results[1] {
+			t.Errorf("BoundedSumFloat64 with AutoBounds=%t: got results %f and %f for the same seed, want identical results", autoBounds, results[0], results[1])
+		}
+	}
+}
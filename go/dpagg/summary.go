@@ -0,0 +1,404 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/google/differential-privacy/go/noise"
+	pb "github.com/google/differential-privacy/go/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// This file contains the serialization of aggregations to the Summary protocol
+// buffer shared with the C++ and Java libraries, so that partial aggregations
+// can be merged across libraries. Unlike gob encoding, a Summary only contains
+// the raw state of the aggregation and the parameters that the other libraries
+// know about, so it cannot be decoded into a new aggregation: it can only be
+// merged into an equally initialized one.
+
+const anyTypeURLPrefix = "type.googleapis.com/"
+
+// packSummary returns a Summary whose data is m.
+func packSummary(m proto.Message) (*pb.Summary, error) {
+	value, err := proto.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't marshal %s: %v", m.ProtoReflect().Descriptor().FullName(), err)
+	}
+	return &pb.Summary{
+		Data: &anypb.Any{
+			TypeUrl: anyTypeURLPrefix + string(m.ProtoReflect().Descriptor().FullName()),
+			Value:   value,
+		},
+	}, nil
+}
+
+// unpackSummary unmarshals the data of summary into m, and returns an error if
+// summary doesn't contain a message of the same type as m.
+func unpackSummary(summary *pb.Summary, m proto.Message) error {
+	data := summary.GetData()
+	if data == nil {
+		return fmt.Errorf("the summary has no data")
+	}
+	name := string(m.ProtoReflect().Descriptor().FullName())
+	if i := strings.LastIndex(data.GetTypeUrl(), "/"); data.GetTypeUrl()[i+1:] != name {
+		return fmt.Errorf("the summary contains a %s, want a %s", data.GetTypeUrl(), name)
+	}
+	if err := proto.Unmarshal(data.GetValue(), m); err != nil {
+		return fmt.Errorf("couldn't unmarshal %s: %v", name, err)
+	}
+	return nil
+}
+
+// toMechanismType returns the MechanismType of the Summary messages matching k.
+// Only Laplace and Gaussian noise are known to the other libraries.
+func toMechanismType(k noise.Kind) (pb.MechanismType, error) {
+	switch k {
+	case noise.LaplaceNoise:
+		return pb.MechanismType_LAPLACE, nil
+	case noise.GaussianNoise:
+		return pb.MechanismType_GAUSSIAN, nil
+	default:
+		return pb.MechanismType_EMPTY, fmt.Errorf("noise kind %v cannot be serialized to a Summary", k)
+	}
+}
+
+// toInt32 converts a contribution bound to the int32 fields used in Summary
+// messages.
+func toInt32(label string, v int64) (int32, error) {
+	if v > math.MaxInt32 {
+		return 0, fmt.Errorf("%s is %d, cannot be larger than %d in a Summary", label, v, math.MaxInt32)
+	}
+	return int32(v), nil
+}
+
+// contributionBoundFromSummary returns the value of a contribution bound of a
+// Summary message. Unset bounds default to 1, as in the other libraries.
+func contributionBoundFromSummary(bound *int32) int64 {
+	if bound == nil || *bound == 0 {
+		return 1
+	}
+	return int64(*bound)
+}
+
+// partialSumFromSummary returns the raw sum of a BoundedSumSummary with
+// manually set bounds. The Java library stores it in partial_sum, and the C++
+// library as the only element of pos_sum.
+func partialSumFromSummary(bss *pb.BoundedSumSummary) (*pb.ValueType, error) {
+	if bss.GetBoundsSummary() != nil || len(bss.GetNegSum()) != 0 {
+		return nil, fmt.Errorf("summaries of sums with automatically determined bounds are not supported")
+	}
+	if bss.GetPartialSum() != nil {
+		return bss.GetPartialSum(), nil
+	}
+	if len(bss.GetPosSum()) != 1 {
+		return nil, fmt.Errorf("the summary has %d partial sums, want 1", len(bss.GetPosSum()))
+	}
+	return bss.GetPosSum()[0], nil
+}
+
+// Serialize returns a Summary containing a CountSummary with the raw count and
+// the parameters of c. It can be merged into an equally initialized Count with
+// MergeSummary, or by the C++ and Java libraries. c is consumed by this
+// operation: it may not be used after it is serialized.
+func (c *Count) Serialize() (*pb.Summary, error) {
+	if c.resultReturned {
+		return nil, fmt.Errorf("Count: the result has already been returned, cannot serialize")
+	}
+	mechanism, err := toMechanismType(c.noiseKind)
+	if err != nil {
+		return nil, fmt.Errorf("Count: %v", err)
+	}
+	l0, err := toInt32("MaxPartitionsContributed", c.l0Sensitivity)
+	if err != nil {
+		return nil, fmt.Errorf("Count: %v", err)
+	}
+	lInf, err := toInt32("MaxContributionsPerPartition", c.lInfSensitivity)
+	if err != nil {
+		return nil, fmt.Errorf("Count: %v", err)
+	}
+	summary, err := packSummary(&pb.CountSummary{
+		Count:                        proto.Int64(c.count),
+		Epsilon:                      proto.Float64(c.epsilon),
+		Delta:                        proto.Float64(c.delta),
+		MechanismType:                mechanism.Enum(),
+		MaxPartitionsContributed:     proto.Int32(l0),
+		MaxContributionsPerPartition: proto.Int32(lInf),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Count: %v", err)
+	}
+	c.resultReturned = true
+	return summary, nil
+}
+
+// MergeSummary merges into c the raw count of a Summary returned by Serialize,
+// or by the C++ and Java libraries. It returns an error if the result of c has
+// already been returned, or if the summary isn't a CountSummary with the same
+// parameters as c.
+func (c *Count) MergeSummary(summary *pb.Summary) error {
+	if c.resultReturned {
+		return fmt.Errorf("Count: the result has already been returned, cannot merge a summary")
+	}
+	var cs pb.CountSummary
+	if err := unpackSummary(summary, &cs); err != nil {
+		return fmt.Errorf("Count: %v", err)
+	}
+	mechanism, err := toMechanismType(c.noiseKind)
+	if err != nil {
+		return fmt.Errorf("Count: %v", err)
+	}
+	if cs.GetEpsilon() != c.epsilon ||
+		cs.GetDelta() != c.delta ||
+		cs.GetMechanismType() != mechanism ||
+		contributionBoundFromSummary(cs.MaxPartitionsContributed) != c.l0Sensitivity ||
+		contributionBoundFromSummary(cs.MaxContributionsPerPartition) != c.lInfSensitivity {
+		return fmt.Errorf("Count: the summary {%v} is not compatible with the Count", &cs)
+	}
+	c.count += cs.GetCount()
+	return nil
+}
+
+// Serialize returns a Summary containing a BoundedSumSummary with the raw
+// clamped sum and the parameters of bs. It can be merged into an equally
+// initialized BoundedSumInt64 with MergeSummary, or by the C++ and Java
+// libraries. Sums whose bounds are determined automatically cannot be
+// serialized. bs is consumed by this operation: it may not be used after it is
+// serialized.
+func (bs *BoundedSumInt64) Serialize() (*pb.Summary, error) {
+	if bs.resultReturned {
+		return nil, fmt.Errorf("BoundedSumInt64: the result has already been returned, cannot serialize")
+	}
+	if bs.autoBounds != nil {
+		return nil, fmt.Errorf("BoundedSumInt64: sums with AutoBounds cannot be serialized to a Summary")
+	}
+	mechanism, err := toMechanismType(bs.noiseKind)
+	if err != nil {
+		return nil, fmt.Errorf("BoundedSumInt64: %v", err)
+	}
+	l0, err := toInt32("MaxPartitionsContributed", bs.l0Sensitivity)
+	if err != nil {
+		return nil, fmt.Errorf("BoundedSumInt64: %v", err)
+	}
+	sum := &pb.ValueType{Value: &pb.ValueType_IntValue{IntValue: bs.sum}}
+	summary, err := packSummary(&pb.BoundedSumSummary{
+		PosSum:                       []*pb.ValueType{sum},
+		PartialSum:                   sum,
+		Epsilon:                      proto.Float64(bs.epsilon),
+		Delta:                        proto.Float64(bs.delta),
+		MechanismType:                mechanism.Enum(),
+		Lower:                        proto.Float64(float64(bs.lower)),
+		Upper:                        proto.Float64(float64(bs.upper)),
+		MaxPartitionsContributed:     proto.Int32(l0),
+		MaxContributionsPerPartition: proto.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("BoundedSumInt64: %v", err)
+	}
+	bs.resultReturned = true
+	return summary, nil
+}
+
+// MergeSummary merges into bs the raw sum of a Summary returned by Serialize,
+// or by the C++ and Java libraries. It returns an error if the result of bs
+// has already been returned, if its bounds are determined automatically, or if
+// the summary isn't a BoundedSumSummary of integers with the same parameters
+// as bs.
+func (bs *BoundedSumInt64) MergeSummary(summary *pb.Summary) error {
+	if bs.resultReturned {
+		return fmt.Errorf("BoundedSumInt64: the result has already been returned, cannot merge a summary")
+	}
+	if bs.autoBounds != nil {
+		return fmt.Errorf("BoundedSumInt64: summaries cannot be merged into sums with AutoBounds")
+	}
+	var bss pb.BoundedSumSummary
+	if err := unpackSummary(summary, &bss); err != nil {
+		return fmt.Errorf("BoundedSumInt64: %v", err)
+	}
+	mechanism, err := toMechanismType(bs.noiseKind)
+	if err != nil {
+		return fmt.Errorf("BoundedSumInt64: %v", err)
+	}
+	if bss.GetEpsilon() != bs.epsilon ||
+		bss.GetDelta() != bs.delta ||
+		bss.GetMechanismType() != mechanism ||
+		bss.GetLower() != float64(bs.lower) ||
+		bss.GetUpper() != float64(bs.upper) ||
+		contributionBoundFromSummary(bss.MaxPartitionsContributed) != bs.l0Sensitivity ||
+		contributionBoundFromSummary(bss.MaxContributionsPerPartition) != 1 {
+		return fmt.Errorf("BoundedSumInt64: the summary {%v} is not compatible with the sum", &bss)
+	}
+	sum, err := partialSumFromSummary(&bss)
+	if err != nil {
+		return fmt.Errorf("BoundedSumInt64: %v", err)
+	}
+	intSum, ok := sum.GetValue().(*pb.ValueType_IntValue)
+	if !ok {
+		return fmt.Errorf("BoundedSumInt64: the summary contains a sum of type %T, want an integer", sum.GetValue())
+	}
+	bs.sum += intSum.IntValue
+	return nil
+}
+
+// Serialize returns a Summary containing a BoundedSumSummary with the raw
+// clamped sum and the parameters of bs. It can be merged into an equally
+// initialized BoundedSumFloat64 with MergeSummary, or by the C++ and Java
+// libraries. Sums whose bounds are determined automatically cannot be
+// serialized. bs is consumed by this operation: it may not be used after it is
+// serialized.
+func (bs *BoundedSumFloat64) Serialize() (*pb.Summary, error) {
+	if bs.resultReturned {
+		return nil, fmt.Errorf("BoundedSumFloat64: the result has already been returned, cannot serialize")
+	}
+	if bs.autoBounds != nil {
+		return nil, fmt.Errorf("BoundedSumFloat64: sums with AutoBounds cannot be serialized to a Summary")
+	}
+	mechanism, err := toMechanismType(bs.noiseKind)
+	if err != nil {
+		return nil, fmt.Errorf("BoundedSumFloat64: %v", err)
+	}
+	l0, err := toInt32("MaxPartitionsContributed", bs.l0Sensitivity)
+	if err != nil {
+		return nil, fmt.Errorf("BoundedSumFloat64: %v", err)
+	}
+	sum := &pb.ValueType{Value: &pb.ValueType_FloatValue{FloatValue: bs.sum}}
+	summary, err := packSummary(&pb.BoundedSumSummary{
+		PosSum:                       []*pb.ValueType{sum},
+		PartialSum:                   sum,
+		Epsilon:                      proto.Float64(bs.epsilon),
+		Delta:                        proto.Float64(bs.delta),
+		MechanismType:                mechanism.Enum(),
+		Lower:                        proto.Float64(bs.lower),
+		Upper:                        proto.Float64(bs.upper),
+		MaxPartitionsContributed:     proto.Int32(l0),
+		MaxContributionsPerPartition: proto.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("BoundedSumFloat64: %v", err)
+	}
+	bs.resultReturned = true
+	return summary, nil
+}
+
+// MergeSummary merges into bs the raw sum of a Summary returned by Serialize,
+// or by the C++ and Java libraries. It returns an error if the result of bs
+// has already been returned, if its bounds are determined automatically, or if
+// the summary isn't a BoundedSumSummary with the same parameters as bs. Sums of
+// integers are converted to float64.
+func (bs *BoundedSumFloat64) MergeSummary(summary *pb.Summary) error {
+	if bs.resultReturned {
+		return fmt.Errorf("BoundedSumFloat64: the result has already been returned, cannot merge a summary")
+	}
+	if bs.autoBounds != nil {
+		return fmt.Errorf("BoundedSumFloat64: summaries cannot be merged into sums with AutoBounds")
+	}
+	var bss pb.BoundedSumSummary
+	if err := unpackSummary(summary, &bss); err != nil {
+		return fmt.Errorf("BoundedSumFloat64: %v", err)
+	}
+	mechanism, err := toMechanismType(bs.noiseKind)
+	if err != nil {
+		return fmt.Errorf("BoundedSumFloat64: %v", err)
+	}
+	if bss.GetEpsilon() != bs.epsilon ||
+		bss.GetDelta() != bs.delta ||
+		bss.GetMechanismType() != mechanism ||
+		bss.GetLower() != bs.lower ||
+		bss.GetUpper() != bs.upper ||
+		contributionBoundFromSummary(bss.MaxPartitionsContributed) != bs.l0Sensitivity ||
+		contributionBoundFromSummary(bss.MaxContributionsPerPartition) != 1 {
+		return fmt.Errorf("BoundedSumFloat64: the summary {%v} is not compatible with the sum", &bss)
+	}
+	sum, err := partialSumFromSummary(&bss)
+	if err != nil {
+		return fmt.Errorf("BoundedSumFloat64: %v", err)
+	}
+	switch v := sum.GetValue().(type) {
+	case *pb.ValueType_FloatValue:
+		bs.sum += v.FloatValue
+	case *pb.ValueType_IntValue:
+		bs.sum += float64(v.IntValue)
+	default:
+		return fmt.Errorf("BoundedSumFloat64: the summary contains a sum of type %T, want a number", v)
+	}
+	return nil
+}
+
+// Serialize returns a Summary containing a BoundedMeanSummary with the raw
+// count and the raw clamped sum of the entries added to bm. It can be merged
+// into an equally initialized BoundedMeanFloat64 with MergeSummary, or by the
+// C++ library. BoundedMeanSummary doesn't contain the parameters of the mean,
+// so they cannot be checked when the summary is merged: it is the caller's
+// responsibility to only merge summaries of equally initialized means. Means
+// whose bounds are determined automatically cannot be serialized. bm is
+// consumed by this operation: it may not be used after it is serialized.
+func (bm *BoundedMeanFloat64) Serialize() (*pb.Summary, error) {
+	if bm.resultReturned {
+		return nil, fmt.Errorf("BoundedMeanFloat64: the result has already been returned, cannot serialize")
+	}
+	if bm.autoBounds != nil {
+		return nil, fmt.Errorf("BoundedMeanFloat64: means with AutoBounds cannot be serialized to a Summary")
+	}
+	// The summary contains the sum of the clamped entries, not the sum of their
+	// distances from the midpoint.
+	count := bm.count.count
+	sum := bm.normalizedSum.sum + float64(count)*bm.midPoint
+	summary, err := packSummary(&pb.BoundedMeanSummary{
+		Count:  proto.Int64(count),
+		PosSum: []*pb.ValueType{{Value: &pb.ValueType_FloatValue{FloatValue: sum}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("BoundedMeanFloat64: %v", err)
+	}
+	bm.resultReturned = true
+	return summary, nil
+}
+
+// MergeSummary merges into bm the raw count and sum of a Summary returned by
+// Serialize, or by the C++ library. It returns an error if the result of bm
+// has already been returned, if its bounds are determined automatically, or if
+// the summary isn't a BoundedMeanSummary with a single partial sum.
+func (bm *BoundedMeanFloat64) MergeSummary(summary *pb.Summary) error {
+	if bm.resultReturned {
+		return fmt.Errorf("BoundedMeanFloat64: the result has already been returned, cannot merge a summary")
+	}
+	if bm.autoBounds != nil {
+		return fmt.Errorf("BoundedMeanFloat64: summaries cannot be merged into means with AutoBounds")
+	}
+	var bms pb.BoundedMeanSummary
+	if err := unpackSummary(summary, &bms); err != nil {
+		return fmt.Errorf("BoundedMeanFloat64: %v", err)
+	}
+	if bms.GetBoundsSummary() != nil || len(bms.GetNegSum()) != 0 || len(bms.GetPosSum()) != 1 {
+		return fmt.Errorf("BoundedMeanFloat64: the summary {%v} is not compatible with the mean", &bms)
+	}
+	var sum float64
+	switch v := bms.GetPosSum()[0].GetValue().(type) {
+	case *pb.ValueType_FloatValue:
+		sum = v.FloatValue
+	case *pb.ValueType_IntValue:
+		sum = float64(v.IntValue)
+	default:
+		return fmt.Errorf("BoundedMeanFloat64: the summary contains a sum of type %T, want a number", v)
+	}
+	bm.count.count += bms.GetCount()
+	bm.normalizedSum.sum += sum - float64(bms.GetCount())*bm.midPoint
+	return nil
+}
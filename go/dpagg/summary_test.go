@@ -0,0 +1,259 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	pb "github.com/google/differential-privacy/go/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCountSerializeAndMergeSummary(t *testing.T) {
+	c1, c2 := getNoiselessCount(), getNoiselessCount()
+	c1.IncrementBy(3)
+	c2.IncrementBy(4)
+	summary, err := c2.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: got error %v", err)
+	}
+	if !c2.resultReturned {
+		t.Errorf("Serialize: Count %v should have its resultReturned set to true after being serialized", c2)
+	}
+	if err := c1.MergeSummary(summary); err != nil {
+		t.Fatalf("MergeSummary: got error %v", err)
+	}
+	if got, want := c1.Result(), int64(7); got != want {
+		t.Errorf("MergeSummary: after merging the summary got count %d, want %d", got, want)
+	}
+}
+
+func TestCountSerializeWritesParameters(t *testing.T) {
+	c := NewCount(&CountOptions{Epsilon: ln3, Delta: tenfive, MaxPartitionsContributed: 5, Noise: noise.Gaussian()})
+	c.IncrementBy(42)
+	summary, err := c.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: got error %v", err)
+	}
+	var got pb.CountSummary
+	if err := unpackSummary(summary, &got); err != nil {
+		t.Fatalf("unpackSummary: got error %v", err)
+	}
+	want := &pb.CountSummary{
+		Count:                        proto.Int64(42),
+		Epsilon:                      proto.Float64(ln3),
+		Delta:                        proto.Float64(tenfive),
+		MechanismType:                pb.MechanismType_GAUSSIAN.Enum(),
+		MaxPartitionsContributed:     proto.Int32(5),
+		MaxContributionsPerPartition: proto.Int32(1),
+	}
+	if !proto.Equal(&got, want) {
+		t.Errorf("Serialize: got summary {%v}, want {%v}", &got, want)
+	}
+	if got, want := summary.GetData().GetTypeUrl(), "type.googleapis.com/differential_privacy.CountSummary"; got != want {
+		t.Errorf("Serialize: got type URL %s, want %s", got, want)
+	}
+}
+
+func TestCountSerializeReturnsError(t *testing.T) {
+	returned := getNoiselessCount()
+	returned.Result()
+	discrete := NewCount(&CountOptions{Epsilon: ln3, Noise: noise.DiscreteLaplace()})
+	for _, tc := range []struct {
+		desc string
+		c    *Count
+	}{
+		{"result already returned", returned},
+		{"discrete Laplace noise", discrete},
+	} {
+		if _, err := tc.c.Serialize(); err == nil {
+			t.Errorf("Serialize: with %s got no error, want error", tc.desc)
+		}
+	}
+}
+
+func TestCountMergeSummaryReturnsError(t *testing.T) {
+	otherEpsilon := NewCount(&CountOptions{Epsilon: 1, Delta: tenten, Noise: noNoise{}})
+	otherEpsilonSummary, err := otherEpsilon.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: got error %v", err)
+	}
+	sum := getNoiselessBSI()
+	sumSummary, err := sum.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: got error %v", err)
+	}
+	for _, tc := range []struct {
+		desc    string
+		summary *pb.Summary
+	}{
+		{"different epsilon", otherEpsilonSummary},
+		{"summary of a sum", sumSummary},
+		{"empty summary", &pb.Summary{}},
+	} {
+		c := getNoiselessCount()
+		if err := c.MergeSummary(tc.summary); err == nil {
+			t.Errorf("MergeSummary: with %s got no error, want error", tc.desc)
+		}
+	}
+}
+
+func TestCountMergeSummaryDefaultsContributionBounds(t *testing.T) {
+	// The other libraries may leave the contribution bounds unset.
+	summary, err := packSummary(&pb.CountSummary{
+		Count:         proto.Int64(10),
+		Epsilon:       proto.Float64(ln3),
+		Delta:         proto.Float64(tenten),
+		MechanismType: pb.MechanismType_GAUSSIAN.Enum(),
+	})
+	if err != nil {
+		t.Fatalf("packSummary: got error %v", err)
+	}
+	c := getNoiselessCount()
+	if err := c.MergeSummary(summary); err != nil {
+		t.Fatalf("MergeSummary: got error %v", err)
+	}
+	if got, want := c.Result(), int64(10); got != want {
+		t.Errorf("MergeSummary: after merging the summary got count %d, want %d", got, want)
+	}
+}
+
+func TestBoundedSumInt64SerializeAndMergeSummary(t *testing.T) {
+	bs1, bs2 := getNoiselessBSI(), getNoiselessBSI()
+	bs1.Add(3)
+	bs2.Add(4)
+	bs2.Add(10) // clamped to 5
+	summary, err := bs2.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: got error %v", err)
+	}
+	if !bs2.resultReturned {
+		t.Errorf("Serialize: BoundedSumInt64 %v should have its resultReturned set to true after being serialized", bs2)
+	}
+	if err := bs1.MergeSummary(summary); err != nil {
+		t.Fatalf("MergeSummary: got error %v", err)
+	}
+	if got, want := bs1.Result(), int64(12); got != want {
+		t.Errorf("MergeSummary: after merging the summary got sum %d, want %d", got, want)
+	}
+}
+
+func TestBoundedSumFloat64SerializeAndMergeSummary(t *testing.T) {
+	bsf1, bsf2 := getNoiselessBSF(), getNoiselessBSF()
+	bsf1.Add(1.5)
+	bsf2.Add(2.5)
+	bsf2.Add(-3) // clamped to -1
+	summary, err := bsf2.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: got error %v", err)
+	}
+	if err := bsf1.MergeSummary(summary); err != nil {
+		t.Fatalf("MergeSummary: got error %v", err)
+	}
+	if got, want := bsf1.Result(), 3.0; !ApproxEqual(got, want) {
+		t.Errorf("MergeSummary: after merging the summary got sum %f, want %f", got, want)
+	}
+}
+
+// Checks that sums serialized by the C++ library, which only sets pos_sum, can
+// be merged.
+func TestBoundedSumFloat64MergeSummaryFromPosSum(t *testing.T) {
+	summary, err := packSummary(&pb.BoundedSumSummary{
+		PosSum:                   []*pb.ValueType{{Value: &pb.ValueType_FloatValue{FloatValue: 4.5}}},
+		Epsilon:                  proto.Float64(ln3),
+		Delta:                    proto.Float64(tenten),
+		MechanismType:            pb.MechanismType_GAUSSIAN.Enum(),
+		Lower:                    proto.Float64(-1),
+		Upper:                    proto.Float64(5),
+		MaxPartitionsContributed: proto.Int32(1),
+	})
+	if err != nil {
+		t.Fatalf("packSummary: got error %v", err)
+	}
+	bsf := getNoiselessBSF()
+	if err := bsf.MergeSummary(summary); err != nil {
+		t.Fatalf("MergeSummary: got error %v", err)
+	}
+	if got, want := bsf.Result(), 4.5; !ApproxEqual(got, want) {
+		t.Errorf("MergeSummary: after merging the summary got sum %f, want %f", got, want)
+	}
+}
+
+func TestBoundedSumMergeSummaryReturnsError(t *testing.T) {
+	otherBounds := NewBoundedSumInt64(&BoundedSumInt64Options{Epsilon: ln3, Delta: tenten, Lower: 0, Upper: 5, Noise: noNoise{}})
+	otherBoundsSummary, err := otherBounds.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: got error %v", err)
+	}
+	floatSum := getNoiselessBSF()
+	floatSumSummary, err := floatSum.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: got error %v", err)
+	}
+	for _, tc := range []struct {
+		desc    string
+		summary *pb.Summary
+	}{
+		{"different bounds", otherBoundsSummary},
+		{"sum of floats", floatSumSummary},
+	} {
+		bs := getNoiselessBSI()
+		if err := bs.MergeSummary(tc.summary); err == nil {
+			t.Errorf("MergeSummary: with %s got no error, want error", tc.desc)
+		}
+	}
+}
+
+func TestBoundedSumSerializeWithAutoBoundsReturnsError(t *testing.T) {
+	bs := NewBoundedSumInt64(&BoundedSumInt64Options{Epsilon: ln3, AutoBounds: true})
+	if _, err := bs.Serialize(); err == nil {
+		t.Errorf("Serialize: with AutoBounds got no error, want error")
+	}
+	bsf := NewBoundedSumFloat64(&BoundedSumFloat64Options{Epsilon: ln3, AutoBounds: true})
+	if _, err := bsf.Serialize(); err == nil {
+		t.Errorf("Serialize: with AutoBounds got no error, want error")
+	}
+}
+
+func TestBoundedMeanFloat64SerializeAndMergeSummary(t *testing.T) {
+	bm1, bm2 := getNoiselessBMF(), getNoiselessBMF()
+	bm1.Add(1)
+	bm2.Add(4)
+	bm2.Add(10) // clamped to 5
+	summary, err := bm2.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: got error %v", err)
+	}
+	if !bm2.resultReturned {
+		t.Errorf("Serialize: BoundedMeanFloat64 %v should have its resultReturned set to true after being serialized", bm2)
+	}
+	// The summary contains the raw sum, not the normalized sum.
+	var bms pb.BoundedMeanSummary
+	if err := unpackSummary(summary, &bms); err != nil {
+		t.Fatalf("unpackSummary: got error %v", err)
+	}
+	if bms.GetCount() != 2 || len(bms.GetPosSum()) != 1 || !ApproxEqual(bms.GetPosSum()[0].GetFloatValue(), 9) {
+		t.Errorf("Serialize: got summary {%v}, want count 2 and sum 9", &bms)
+	}
+	if err := bm1.MergeSummary(summary); err != nil {
+		t.Fatalf("MergeSummary: got error %v", err)
+	}
+	if got, want := bm1.Result(), 10.0/3.0; !ApproxEqual(got, want) {
+		t.Errorf("MergeSummary: after merging the summary got mean %f, want %f", got, want)
+	}
+}
@@ -0,0 +1,383 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// BoundedVarianceFloat64 calculates a differentially private variance of a
+// collection of float64 values.
+//
+// The variance is computed as the difference between the mean of the squares and
+// the square of the mean: Var(X) = E[X²] - E[X]². Both means are computed the same
+// way as in BoundedMeanFloat64: a noisy sum of normalized entries is divided by a
+// noisy count of the entries. Entries are normalized by subtracting the middle of
+// the input range, and squares of normalized entries are in turn normalized by
+// subtracting the middle of their own range. Since the variance is invariant under
+// translation, the normalization does not need to be undone. The privacy budget is
+// split equally between the count, the normalized sum and the normalized sum of
+// squares.
+//
+// BoundedVarianceFloat64 supports scaling the noise in the case where users can
+// contribute to multiple partitions (via the MaxPartitionsContributed parameter)
+// and can contribute to a single partition multiple times
+// (via the MaxContributionsPerPartition parameter).
+//
+// Note: Do not use when your results may cause overflows for int64 or float64
+// values. This aggregation is not hardened for such applications yet.
+//
+// Not thread-safe.
+type BoundedVarianceFloat64 struct {
+	// Parameters
+	lower float64
+	upper float64
+
+	// State variables
+	count                  Count
+	normalizedSum          BoundedSumFloat64
+	normalizedSumOfSquares BoundedSumFloat64
+	// The midpoint between lower and upper bounds. It cannot be set by the user;
+	// it will be calculated based on the lower and upper values.
+	midPoint float64
+	// The midpoint of the range of the squares of normalized entries, i.e.
+	// between 0 and ((upper-lower)/2)². It cannot be set by the user either.
+	midPointOfSquares float64
+	resultReturned    bool // whether the result has already been returned
+}
+
+func bvEquallyInitializedFloat64(bv1, bv2 *BoundedVarianceFloat64) bool {
+	return bv1.lower == bv2.lower &&
+		bv1.upper == bv2.upper &&
+		countEquallyInitialized(&bv1.count, &bv2.count) &&
+		bsEquallyInitializedFloat64(&bv1.normalizedSum, &bv2.normalizedSum) &&
+		bsEquallyInitializedFloat64(&bv1.normalizedSumOfSquares, &bv2.normalizedSumOfSquares)
+}
+
+// BoundedVarianceFloat64Options contains the options necessary to initialize a BoundedVarianceFloat64.
+type BoundedVarianceFloat64Options struct {
+	Epsilon                      float64 // Privacy parameter ε. Required.
+	Delta                        float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed     int64   // How many distinct partitions may a single user contribute to? Defaults to 1.
+	MaxContributionsPerPartition int64   // How many times may a single user contribute to a single partition? Required.
+	// Lower and Upper bounds for clamping. Default to 0; must be such that Lower < Upper.
+	Lower, Upper float64
+	Noise        noise.Noise // Type of noise used in BoundedVariance. Defaults to Laplace noise.
+}
+
+// NewBoundedVarianceFloat64 returns a new BoundedVarianceFloat64.
+// It exits the program if the options are invalid; use
+// NewBoundedVarianceFloat64E to handle this case instead.
+func NewBoundedVarianceFloat64(opt *BoundedVarianceFloat64Options) *BoundedVarianceFloat64 {
+	bv, err := NewBoundedVarianceFloat64E(opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewBoundedVarianceFloat64: %v", err)
+	}
+	return bv
+}
+
+// NewBoundedVarianceFloat64E is the same as NewBoundedVarianceFloat64, but
+// returns an error instead of exiting the program if the options are invalid.
+func NewBoundedVarianceFloat64E(opt *BoundedVarianceFloat64Options) (*BoundedVarianceFloat64, error) {
+	if opt == nil {
+		opt = &BoundedVarianceFloat64Options{}
+	}
+
+	maxContributionsPerPartition := opt.MaxContributionsPerPartition
+	if maxContributionsPerPartition == 0 {
+		return nil, fmt.Errorf("NewBoundedVarianceFloat64 requires a value for MaxContributionsPerPartition")
+	}
+
+	// Set defaults.
+	maxPartitionsContributed := opt.MaxPartitionsContributed
+	if maxPartitionsContributed == 0 {
+		maxPartitionsContributed = 1
+	}
+
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	// Check bounds & use them to compute L_∞ sensitivity.
+	lower, upper := opt.Lower, opt.Upper
+	if lower == 0 && upper == 0 {
+		return nil, fmt.Errorf("NewBoundedVarianceFloat64 requires a non-default value for Lower or Upper (automatic bounds determination is not implemented yet)")
+	}
+	if err := checks.CheckBoundsFloat64("NewBoundedVarianceFloat64", lower, upper); err != nil {
+		return nil, err
+	}
+	// (lower + upper) / 2 may cause an overflow if lower and upper are large values.
+	midPoint := lower + (upper-lower)/2.0
+	maxDistFromMidpoint := math.Abs(upper - midPoint)
+	// Squares of normalized entries are between 0 and maxDistFromMidpoint².
+	midPointOfSquares := maxDistFromMidpoint * maxDistFromMidpoint / 2.0
+
+	eps, del := opt.Epsilon, opt.Delta
+	// We split the budget in three to calculate the count, the noised normalized sum
+	// and the noised normalized sum of squares.
+	// TODO: this can be optimized for the Gaussian noise
+	thirdEpsilon := eps / 3
+	thirdDelta := del / 3
+
+	// Check that the parameters are compatible with the noise chosen by calling
+	// the noise on some dummy value.
+	if _, err := noise.AddNoiseFloat64E(n, 0, 1, 1, thirdEpsilon, thirdDelta); err != nil {
+		return nil, err
+	}
+
+	// Noised count of the entities.
+	count, err := NewCountE(&CountOptions{
+		Epsilon:                      thirdEpsilon,
+		Delta:                        thirdDelta,
+		MaxPartitionsContributed:     maxPartitionsContributed,
+		Noise:                        n,
+		maxContributionsPerPartition: maxContributionsPerPartition,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// normalizedSum stores a noised sum of distances of the input entities from
+	// the middle of the range, as in BoundedMeanFloat64.
+	normalizedSum, err := NewBoundedSumFloat64E(&BoundedSumFloat64Options{
+		Epsilon:                      thirdEpsilon,
+		Delta:                        thirdDelta,
+		MaxPartitionsContributed:     maxPartitionsContributed,
+		Lower:                        -maxDistFromMidpoint,
+		Upper:                        maxDistFromMidpoint,
+		Noise:                        n,
+		maxContributionsPerPartition: maxContributionsPerPartition,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// normalizedSumOfSquares stores a noised sum of the squared distances of the
+	// input entities from the middle of the range, each of them shifted by
+	// midPointOfSquares so that they are symmetric around 0.
+	normalizedSumOfSquares, err := NewBoundedSumFloat64E(&BoundedSumFloat64Options{
+		Epsilon:                      thirdEpsilon,
+		Delta:                        thirdDelta,
+		MaxPartitionsContributed:     maxPartitionsContributed,
+		Lower:                        -midPointOfSquares,
+		Upper:                        midPointOfSquares,
+		Noise:                        n,
+		maxContributionsPerPartition: maxContributionsPerPartition,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoundedVarianceFloat64{
+		lower:                  lower,
+		upper:                  upper,
+		midPoint:               midPoint,
+		midPointOfSquares:      midPointOfSquares,
+		count:                  *count,
+		normalizedSum:          *normalizedSum,
+		normalizedSumOfSquares: *normalizedSumOfSquares,
+		resultReturned:         false,
+	}, nil
+}
+
+// Add an entry to a BoundedVarianceFloat64. It skips NaN entries and doesn't count
+// them in the final result because introducing even a single NaN entry will result
+// in a NaN variance regardless of other entries, which would break the
+// indistinguishability property required for differential privacy.
+func (bv *BoundedVarianceFloat64) Add(e float64) {
+	if err := bv.AddE(e); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+}
+
+// AddE is the same as Add, but returns an error instead of exiting the program
+// if the result has already been returned.
+func (bv *BoundedVarianceFloat64) AddE(e float64) error {
+	if bv.resultReturned {
+		return fmt.Errorf("The variance has already been calculated and returned. It cannot be amended.")
+	}
+	if math.IsNaN(e) {
+		return nil
+	}
+	clamped, err := ClampFloat64(e, bv.lower, bv.upper)
+	if err != nil {
+		return fmt.Errorf("Couldn't clamp input value %v, err %v", e, err)
+	}
+
+	x := clamped - bv.midPoint
+	if err := bv.normalizedSum.AddE(x); err != nil {
+		return err
+	}
+	if err := bv.normalizedSumOfSquares.AddE(x*x - bv.midPointOfSquares); err != nil {
+		return err
+	}
+	return bv.count.IncrementE()
+}
+
+// Result returns a differentially private variance of elements added so far.
+// It can be called only once, after which no further operation can be done on the BoundedVarianceFloat64.
+func (bv *BoundedVarianceFloat64) Result() float64 {
+	result, err := bv.ResultE()
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ResultE is the same as Result, but returns an error instead of exiting the
+// program if the result has already been returned.
+func (bv *BoundedVarianceFloat64) ResultE() (float64, error) {
+	if bv.resultReturned {
+		return 0, fmt.Errorf("The variance has already been calculated and returned. It can only be returned once.")
+	}
+	bv.resultReturned = true
+	rawCount, err := bv.count.ResultE()
+	if err != nil {
+		return 0, err
+	}
+	noisedCount := float64(rawCount)
+	if noisedCount <= 1 {
+		// The variance of at most one entry is 0. Since this only depends on the
+		// noised count, it is a post-processing step and the DP bounds are preserved.
+		return 0, nil
+	}
+	maxDistFromMidpoint := bv.upper - bv.midPoint
+	noisedSum, err := bv.normalizedSum.ResultE()
+	if err != nil {
+		return 0, err
+	}
+	noisedSumOfSquares, err := bv.normalizedSumOfSquares.ResultE()
+	if err != nil {
+		return 0, err
+	}
+
+	// Clamping the intermediate means is a post-processing step and does not
+	// affect the DP guarantees.
+	normalizedMean, err := ClampFloat64(noisedSum/noisedCount, -maxDistFromMidpoint, maxDistFromMidpoint)
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't clamp the normalized mean, err %v", err)
+	}
+	normalizedMeanOfSquares, err := ClampFloat64(noisedSumOfSquares/noisedCount+bv.midPointOfSquares, 0, maxDistFromMidpoint*maxDistFromMidpoint)
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't clamp the normalized mean of squares, err %v", err)
+	}
+
+	// The variance of values in [lower, upper] is at most ((upper-lower)/2)².
+	clamped, err := ClampFloat64(normalizedMeanOfSquares-normalizedMean*normalizedMean, 0, maxDistFromMidpoint*maxDistFromMidpoint)
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't clamp the result, err %v", err)
+	}
+	return clamped, nil
+}
+
+// Merge merges bv2 into bv (i.e., adds to bv all entries that were added to
+// bv2). bv2 is consumed by this operation: bv2 may not be used after it is
+// merged into bv.
+func (bv *BoundedVarianceFloat64) Merge(bv2 *BoundedVarianceFloat64) {
+	if err := bv.MergeE(bv2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+}
+
+// MergeE is the same as Merge, but returns an error instead of exiting the
+// program if bv and bv2 cannot be merged.
+func (bv *BoundedVarianceFloat64) MergeE(bv2 *BoundedVarianceFloat64) error {
+	if err := checkMergeBoundedVarianceFloat64(bv, bv2); err != nil {
+		return err
+	}
+	bv.normalizedSum.sum += bv2.normalizedSum.sum
+	bv.normalizedSumOfSquares.sum += bv2.normalizedSumOfSquares.sum
+	bv.count.count += bv2.count.count
+	bv2.resultReturned = true
+	return nil
+}
+
+func checkMergeBoundedVarianceFloat64(bv1, bv2 *BoundedVarianceFloat64) error {
+	if bv1.resultReturned {
+		return fmt.Errorf("checkMergeBoundedVarianceFloat64: bv1 already returned the result, cannot be merged with another BoundedVariance instance")
+	}
+	if bv2.resultReturned {
+		return fmt.Errorf("checkMergeBoundedVarianceFloat64: bv2 already returned the result, cannot be merged with another BoundedVariance instance")
+	}
+
+	if !bvEquallyInitializedFloat64(bv1, bv2) {
+		return fmt.Errorf("checkMergeBoundedVarianceFloat64: bv1 and bv2 are not compatible")
+	}
+
+	return nil
+}
+
+// GobEncode encodes BoundedVarianceFloat64.
+func (bv *BoundedVarianceFloat64) GobEncode() ([]byte, error) {
+	enc := encodableBoundedVarianceFloat64{
+		Lower:                           bv.lower,
+		Upper:                           bv.upper,
+		EncodableCount:                  &bv.count,
+		EncodableNormalizedSum:          &bv.normalizedSum,
+		EncodableNormalizedSumOfSquares: &bv.normalizedSumOfSquares,
+		MidPoint:                        bv.midPoint,
+		MidPointOfSquares:               bv.midPointOfSquares,
+		ResultReturned:                  bv.resultReturned,
+	}
+	bv.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes BoundedVarianceFloat64.
+func (bv *BoundedVarianceFloat64) GobDecode(data []byte) error {
+	var enc encodableBoundedVarianceFloat64
+	err := decode(&enc, data)
+	if err != nil {
+		return err
+	}
+	*bv = BoundedVarianceFloat64{
+		lower:                  enc.Lower,
+		upper:                  enc.Upper,
+		count:                  *enc.EncodableCount,
+		normalizedSum:          *enc.EncodableNormalizedSum,
+		normalizedSumOfSquares: *enc.EncodableNormalizedSumOfSquares,
+		midPoint:               enc.MidPoint,
+		midPointOfSquares:      enc.MidPointOfSquares,
+		resultReturned:         enc.ResultReturned,
+	}
+	// As when bv was created, the count and the sums share the same noise, and
+	// hence the same Source.
+	bv.normalizedSum.noise = bv.count.noise
+	bv.normalizedSumOfSquares.noise = bv.count.noise
+	return nil
+}
+
+// encodableBoundedVarianceFloat64 can be encoded by the gob package.
+type encodableBoundedVarianceFloat64 struct {
+	Lower                           float64
+	Upper                           float64
+	EncodableCount                  *Count
+	EncodableNormalizedSum          *BoundedSumFloat64
+	EncodableNormalizedSumOfSquares *BoundedSumFloat64
+	MidPoint                        float64
+	MidPointOfSquares               float64
+	ResultReturned                  bool
+}
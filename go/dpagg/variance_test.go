@@ -0,0 +1,333 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/go/rand"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewBoundedVarianceFloat64(t *testing.T) {
+	opt := &BoundedVarianceFloat64Options{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		Lower:                        -1,
+		Upper:                        5,
+		Noise:                        noNoise{},
+		MaxContributionsPerPartition: 2,
+	}
+	want := &BoundedVarianceFloat64{
+		lower:             -1,
+		upper:             5,
+		resultReturned:    false,
+		midPoint:          2,
+		midPointOfSquares: 4.5,
+		count: Count{
+			epsilon:         ln3 / 3,
+			delta:           tenten / 3,
+			l0Sensitivity:   1,
+			lInfSensitivity: 2,
+			noise:           noNoise{},
+			count:           0,
+			resultReturned:  false,
+		},
+		normalizedSum: BoundedSumFloat64{
+			epsilon:         ln3 / 3,
+			delta:           tenten / 3,
+			l0Sensitivity:   1,
+			lInfSensitivity: 6,
+			lower:           -3,
+			upper:           3,
+			noise:           noNoise{},
+			sum:             0,
+			resultReturned:  false,
+		},
+		normalizedSumOfSquares: BoundedSumFloat64{
+			epsilon:         ln3 / 3,
+			delta:           tenten / 3,
+			l0Sensitivity:   1,
+			lInfSensitivity: 9,
+			lower:           -4.5,
+			upper:           4.5,
+			noise:           noNoise{},
+			sum:             0,
+			resultReturned:  false,
+		},
+	}
+	got := NewBoundedVarianceFloat64(opt)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewBoundedVarianceFloat64: when MaxPartitionsContributed is not set got %v, want %v", got, want)
+	}
+}
+
+func getNoiselessBVF() *BoundedVarianceFloat64 {
+	return NewBoundedVarianceFloat64(&BoundedVarianceFloat64Options{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		Lower:                        -1,
+		Upper:                        5,
+		Noise:                        noNoise{},
+	})
+}
+
+func TestBVNoInputFloat64(t *testing.T) {
+	bvf := getNoiselessBVF()
+	got := bvf.Result()
+	// count = 0 => variance = 0
+	want := 0.0
+	if !ApproxEqual(got, want) {
+		t.Errorf("BoundedVariance: when there is no input data got=%f, want=%f", got, want)
+	}
+}
+
+func TestBVAddFloat64(t *testing.T) {
+	bvf := getNoiselessBVF()
+	bvf.Add(1)
+	bvf.Add(1)
+	bvf.Add(2)
+	bvf.Add(4)
+	got := bvf.Result()
+	// mean = 2, variance = ((1-2)² + (1-2)² + (2-2)² + (4-2)²) / 4 = 1.5
+	want := 1.5
+	if !ApproxEqual(got, want) {
+		t.Errorf("Add: when dataset with elements inside boundaries got %f, want %f", got, want)
+	}
+}
+
+func TestBVAddFloat64IgnoresNaN(t *testing.T) {
+	bvf := getNoiselessBVF()
+	bvf.Add(1)
+	bvf.Add(math.NaN())
+	bvf.Add(3)
+	got := bvf.Result()
+	want := 1.0
+	if !ApproxEqual(got, want) {
+		t.Errorf("Add: when dataset contains NaN got %f, want %f", got, want)
+	}
+}
+
+func TestBVReturnsZeroIfSingleEntryIsAddedFloat64(t *testing.T) {
+	bvf := getNoiselessBVF()
+	bvf.Add(1.2345)
+	got := bvf.Result()
+	want := 0.0
+	if !ApproxEqual(got, want) {
+		t.Errorf("BoundedVariance: when dataset contains single entry got %f, want %f", got, want)
+	}
+}
+
+func TestBVClampFloat64(t *testing.T) {
+	bvf := getNoiselessBVF()
+	// lower = -1, upper = 5
+	bvf.Add(8.3)  // clamped to 5
+	bvf.Add(-7.5) // clamped to -1
+	got := bvf.Result()
+	// mean = 2, variance = (3² + 3²) / 2 = 9
+	want := 9.0
+	if !ApproxEqual(got, want) {
+		t.Errorf("Add: when dataset with elements outside boundaries got %f, want %f", got, want)
+	}
+}
+
+func TestBVReturnsResultInsideProvidedBoundariesFloat64(t *testing.T) {
+	lower := rand.Uniform() * 100
+	upper := lower + rand.Uniform()*100
+
+	bvf := NewBoundedVarianceFloat64(&BoundedVarianceFloat64Options{
+		Epsilon:                      ln3,
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		Lower:                        lower,
+		Upper:                        upper,
+		Noise:                        noise.Laplace(),
+	})
+
+	for i := 0; i <= 1000; i++ {
+		bvf.Add(rand.Uniform() * 300 * rand.Sign())
+	}
+
+	res := bvf.Result()
+	maxVariance := (upper - lower) * (upper - lower) / 4
+	if res < 0 {
+		t.Errorf("BoundedVariance: result is negative, got %f", res)
+	}
+	if res > maxVariance {
+		t.Errorf("BoundedVariance: result is larger than the maximal variance, got %f, want to be <= %f", res, maxVariance)
+	}
+}
+
+func TestMergeBoundedVarianceFloat64(t *testing.T) {
+	bv1 := getNoiselessBVF()
+	bv2 := getNoiselessBVF()
+	bv1.Add(1)
+	bv1.Add(1)
+	bv2.Add(2)
+	bv2.Add(4)
+	bv1.Merge(bv2)
+	got := bv1.Result()
+	want := 1.5
+	if !ApproxEqual(got, want) {
+		t.Errorf("Merge: when merging 2 instances of BoundedVariance got %f, want %f", got, want)
+	}
+	if !bv2.resultReturned {
+		t.Errorf("Merge: when merging 2 instances of BoundedVariance for resultReturned got false, want true")
+	}
+}
+
+func TestCheckMergeBoundedVarianceFloat64(t *testing.T) {
+	opt := func() *BoundedVarianceFloat64Options {
+		return &BoundedVarianceFloat64Options{
+			Epsilon:                      ln3,
+			Delta:                        tenten,
+			MaxPartitionsContributed:     1,
+			Lower:                        -1,
+			Upper:                        5,
+			Noise:                        noise.Gaussian(),
+			MaxContributionsPerPartition: 2,
+		}
+	}
+	differentEpsilon := opt()
+	differentEpsilon.Epsilon = 2
+	differentBounds := opt()
+	differentBounds.Upper = 6
+	differentNoise := opt()
+	differentNoise.Delta = 0
+	differentNoise.Noise = noise.Laplace()
+	differentContributions := opt()
+	differentContributions.MaxContributionsPerPartition = 3
+	for _, tc := range []struct {
+		desc            string
+		opt1            *BoundedVarianceFloat64Options
+		opt2            *BoundedVarianceFloat64Options
+		resultReturned1 bool
+		resultReturned2 bool
+		wantErr         bool
+	}{
+		{"same options", opt(), opt(), false, false, false},
+		{"same options, first result returned", opt(), opt(), true, false, true},
+		{"same options, second result returned", opt(), opt(), false, true, true},
+		{"different epsilon", opt(), differentEpsilon, false, false, true},
+		{"different bounds", opt(), differentBounds, false, false, true},
+		{"different noise", opt(), differentNoise, false, false, true},
+		{"different maxContributionsPerPartition", opt(), differentContributions, false, false, true},
+	} {
+		bv1 := NewBoundedVarianceFloat64(tc.opt1)
+		bv2 := NewBoundedVarianceFloat64(tc.opt2)
+
+		bv1.resultReturned = tc.resultReturned1
+		bv2.resultReturned = tc.resultReturned2
+
+		if err := checkMergeBoundedVarianceFloat64(bv1, bv2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %v for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func compareBoundedVarianceFloat64(bv1, bv2 *BoundedVarianceFloat64) bool {
+	return bv1.lower == bv2.lower &&
+		bv1.upper == bv2.upper &&
+		compareCount(&bv1.count, &bv2.count) &&
+		compareBoundedSumFloat64(&bv1.normalizedSum, &bv2.normalizedSum) &&
+		compareBoundedSumFloat64(&bv1.normalizedSumOfSquares, &bv2.normalizedSumOfSquares) &&
+		bv1.midPoint == bv2.midPoint &&
+		bv1.midPointOfSquares == bv2.midPointOfSquares &&
+		bv1.resultReturned == bv2.resultReturned
+}
+
+// Tests that serialization for BoundedVarianceFloat64 works as expected.
+func TestBVFloat64Serialization(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opts *BoundedVarianceFloat64Options
+	}{
+		{"default options", &BoundedVarianceFloat64Options{
+			Epsilon:                      ln3,
+			Lower:                        0,
+			Upper:                        1,
+			Delta:                        0,
+			MaxContributionsPerPartition: 1,
+		}},
+		{"non-default options", &BoundedVarianceFloat64Options{
+			Lower:                        -100,
+			Upper:                        555,
+			Epsilon:                      ln3,
+			Delta:                        1e-5,
+			MaxPartitionsContributed:     5,
+			MaxContributionsPerPartition: 6,
+			Noise:                        noise.Gaussian(),
+		}},
+	} {
+		bv, bvUnchanged := NewBoundedVarianceFloat64(tc.opts), NewBoundedVarianceFloat64(tc.opts)
+		bytes, err := encode(bv)
+		if err != nil {
+			t.Fatalf("encode(BoundedVarianceFloat64) error: %v", err)
+		}
+		bvUnmarshalled := new(BoundedVarianceFloat64)
+		if err := decode(bvUnmarshalled, bytes); err != nil {
+			t.Fatalf("decode(BoundedVarianceFloat64) error: %v", err)
+		}
+		// Check that encoding -> decoding is the identity function.
+		if !cmp.Equal(bvUnchanged, bvUnmarshalled, cmp.Comparer(compareBoundedVarianceFloat64)) {
+			t.Errorf("decode(encode(_)): when %s got %v, want %v", tc.desc, bvUnmarshalled, bv)
+		}
+		// Check that the original BoundedVariance has its resultReturned set to true after serialization.
+		if !bv.resultReturned {
+			t.Errorf("BoundedVariance %v should have its resultReturned set to true after being serialized", bv)
+		}
+	}
+}
+
+// Checks that BoundedVarianceFloat64s decoded from the same encoding, using
+// noise with a deterministic source, return the same result. Decoding used to
+// replace the source with the cryptographically secure one.
+func TestBVFloat64WithDeterministicSourceSerialization(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		newVariance := func() *BoundedVarianceFloat64 {
+			bv := NewBoundedVarianceFloat64(&BoundedVarianceFloat64Options{
+				Epsilon:                      ln3,
+				MaxContributionsPerPartition: 1,
+				Lower:                        -1,
+				Upper:                        5,
+				Noise:                        noise.WithSource(noise.Laplace(), rand.NewDeterministicSource(seed)),
+			})
+			for i := 0; i < 1000; i++ {
+				bv.Add(2.5)
+			}
+			return bv
+		}
+		bytes, err := encode(newVariance())
+		if err != nil {
+			t.Fatalf("encode(BoundedVarianceFloat64) error: %v", err)
+		}
+		decoded1, decoded2 := new(BoundedVarianceFloat64), new(BoundedVarianceFloat64)
+		for _, decoded := range []*BoundedVarianceFloat64{decoded1, decoded2} {
+			if err := decode(decoded, bytes); err != nil {
+				t.Fatalf("decode(BoundedVarianceFloat64) error: %v", err)
+			}
+		}
+		if got1, got2 := decoded1.Result(), decoded2.Result(); got1 != got2 {
+			t.Errorf("Result: for BoundedVarianceFloat64s decoded from the same encoding with seed %d got %f and %f, want identical results", seed, got1, got2)
+		}
+	}
+}
@@ -7,4 +7,5 @@ github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b
 github.com/google/go-cmp v0.4.2-0.20200609072101-23a2b5646fe0
 github.com/grd/stat v0.0.0-20130623202159-138af3fd5012
 gonum.org/v1/gonum v0.7.0
+google.golang.org/protobuf v1.24.1-0.20200612063355-beaa55256c57
 )
\ No newline at end of file
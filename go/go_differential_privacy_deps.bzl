@@ -80,6 +80,13 @@ def go_differential_privacy_deps():
         version = "v0.1.1",
     )
 
+    go_repository(
+        name = "org_golang_google_protobuf",
+        importpath = "google.golang.org/protobuf",
+        sum = "h1:uEMv1vd5zHshPT2daUB9Ef3zcKfeWHTpzckCcFkxNWM=",
+        version = "v1.24.1-0.20200612063355-beaa55256c57",
+    )
+
     go_repository(
         name = "org_golang_x_exp",
         importpath = "golang.org/x/exp",
@@ -0,0 +1,45 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package noise
+
+import "math"
+
+// ConfidenceInterval is an interval of values that contains the raw value of a
+// noisy result with a given probability.
+type ConfidenceInterval struct {
+	LowerBound, UpperBound float64
+}
+
+// symmetricConfidenceInterval returns the interval of the values that are
+// within halfWidth of noisedX.
+func symmetricConfidenceInterval(noisedX, halfWidth float64) ConfidenceInterval {
+	return ConfidenceInterval{LowerBound: noisedX - halfWidth, UpperBound: noisedX + halfWidth}
+}
+
+// geometricHalfWidth returns a w such that the noisy value computed by adding
+// gZ to x rounded to a multiple of the granularity g, where Z is drawn from a
+// two-sided geometric distribution (i.e. discrete Laplace distribution) with
+// scale λ/g, is within w of x with probability at least 1-α.
+func geometricHalfWidth(lambda, granularity, alpha float64) float64 {
+	// The probability of any integer z is proportional to q^|z|, with
+	// q = exp(-g/λ). For any w ≥ 0,
+	//   P[|gZ| > w] = 2q^(⌊w/g⌋+1)/(1+q) ≤ 2q^(w/g)/(1+q),
+	// which is equal to α for w = λln(2/(α(1+q))). Rounding x moves it by at
+	// most g/2.
+	q := math.Exp(-granularity / lambda)
+	return lambda*math.Log(2/(alpha*(1+q))) + granularity/2
+}
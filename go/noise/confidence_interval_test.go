@@ -0,0 +1,92 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package noise
+
+import (
+	"math"
+	"testing"
+)
+
+// Checks that confidence intervals contain the raw value with probability at
+// least 1-alpha.
+func TestComputeConfidenceIntervalFloat64Coverage(t *testing.T) {
+	const numberOfSamples = 20000
+	const x, alpha = 42.0, 0.1
+	for _, tc := range []struct {
+		desc  string
+		noise Noise
+		delta float64
+	}{
+		{"Laplace", lap, 0},
+		{"Gaussian", gauss, 1e-5},
+		{"discrete Laplace", DiscreteLaplace(), 0},
+		{"discrete Gaussian", DiscreteGaussian(), 1e-5},
+	} {
+		covered := 0
+		for i := 0; i < numberOfSamples; i++ {
+			noisedX := tc.noise.AddNoiseFloat64(x, 2, 3, ln3, tc.delta)
+			confInt := tc.noise.ComputeConfidenceIntervalFloat64(noisedX, 2, 3, ln3, tc.delta, alpha)
+			if confInt.LowerBound <= x && x <= confInt.UpperBound {
+				covered++
+			}
+		}
+		// The coverage is approximately Gaussian distributed with a standard
+		// deviation of at most sqrt(alpha(1-alpha)/numberOfSamples). The tolerance
+		// is set to its 99.9995% quantile, so the test falsely rejects with a
+		// probability of at most 10⁻⁵.
+		coverage := float64(covered) / numberOfSamples
+		if tolerance := 4.41717 * math.Sqrt(alpha*(1-alpha)/numberOfSamples); coverage < 1-alpha-tolerance {
+			t.Errorf("ComputeConfidenceIntervalFloat64 for %s noise: got coverage %f, want at least %f", tc.desc, coverage, 1-alpha)
+		}
+	}
+}
+
+func TestComputeConfidenceIntervalFloat64Laplace(t *testing.T) {
+	for _, tc := range []struct {
+		noisedX, epsilon, alpha float64
+	}{
+		{0, 1, 0.05},
+		{-10, ln3, 0.5},
+		{1e6, 0.1, 1e-3},
+	} {
+		// The interval is close to the one of continuous Laplace noise with scale
+		// λ, for which P[|noise| > λln(1/α)] = α.
+		got := lap.ComputeConfidenceIntervalFloat64(tc.noisedX, 1, 1, tc.epsilon, 0, tc.alpha)
+		halfWidth := math.Log(1/tc.alpha) / tc.epsilon
+		want := ConfidenceInterval{LowerBound: tc.noisedX - halfWidth, UpperBound: tc.noisedX + halfWidth}
+		if !nearEqual(got.LowerBound, want.LowerBound, 1e-6) || !nearEqual(got.UpperBound, want.UpperBound, 1e-6) {
+			t.Errorf("ComputeConfidenceIntervalFloat64(%f, 1, 1, %f, 0, %f): got %+v, want %+v", tc.noisedX, tc.epsilon, tc.alpha, got, want)
+		}
+	}
+}
+
+func TestComputeConfidenceIntervalFloat64Gaussian(t *testing.T) {
+	// For α = 0.05, the interval is noisedX ± 1.959964σ.
+	sigma := SigmaForGaussian(1, 1, ln3, 1e-5)
+	got := gauss.ComputeConfidenceIntervalFloat64(5, 1, 1, ln3, 1e-5, 0.05)
+	if !nearEqual(got.LowerBound, 5-1.959964*sigma, 1e-5) || !nearEqual(got.UpperBound, 5+1.959964*sigma, 1e-5) {
+		t.Errorf("ComputeConfidenceIntervalFloat64(5, 1, 1, ln3, 1e-5, 0.05): got %+v, want 5 ± %f", got, 1.959964*sigma)
+	}
+}
+
+func TestComputeConfidenceIntervalFloat64EInvalidAlpha(t *testing.T) {
+	for _, alpha := range []float64{0, 1, -0.1, math.NaN()} {
+		if _, err := ComputeConfidenceIntervalFloat64E(lap, 0, 1, 1, 1, 0, alpha); err == nil {
+			t.Errorf("ComputeConfidenceIntervalFloat64E with alpha %f: got no error, want error", alpha)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package noise
+
+import (
+	"math"
+	"math/big"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+)
+
+// discreteGaussianRhoAccuracy is the relative amount by which the ρ of the
+// discrete Gaussian noise is decreased, to make up for floating-point errors in
+// its computation.
+const discreteGaussianRhoAccuracy = 1e-12
+
+type discreteGaussian struct {
+	randomness
+}
+
+// DiscreteGaussian returns a Noise instance that adds discrete Gaussian noise
+// to its input.
+//
+// The noise is drawn with rand.DiscreteGaussian, which samples integers
+// exactly using only integer and rational arithmetic. AddNoiseInt64 adds such a
+// sample to its input directly, without any rounding, and AddNoiseFloat64 adds
+// a sample scaled to a power-of-two granularity.
+//
+// Discrete Gaussian noise with variance parameter σ², added to an integer
+// function with l2 sensitivity s, is ρ-zero-concentrated differentially private
+// for ρ = s²/(2σ²) (see Canonne, Kamath and Steinke's "The Discrete Gaussian
+// for Differential Privacy", https://arxiv.org/abs/2004.00010). σ is calibrated
+// with the (ε,δ) guarantee of ρ-zCDP, which adds more noise than Gaussian for
+// the same ε and δ.
+func DiscreteGaussian() Noise {
+	return discreteGaussian{}
+}
+
+// AddNoiseFloat64 adds discrete Gaussian noise to the specified float64, so
+// that its output is (ε,δ)-differentially private.
+func (dg discreteGaussian) AddNoiseFloat64(x float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) float64 {
+	noisy, err := dg.AddNoiseFloat64E(x, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	if err != nil {
+		log.Fatalf("discreteGaussian.AddNoiseFloat64(l0sensitivity %d, lInfSensitivity %f, epsilon %f, delta %e) checks failed with %v",
+			l0Sensitivity, lInfSensitivity, epsilon, delta, err)
+	}
+	return noisy
+}
+
+// AddNoiseFloat64E is the same as AddNoiseFloat64, but returns an error
+// instead of exiting the program if the parameters are invalid.
+func (dg discreteGaussian) AddNoiseFloat64E(x float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) (float64, error) {
+	if err := checkArgsDiscreteGaussian("AddNoiseFloat64 (discrete Gaussian)", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return 0, err
+	}
+	rho := discreteGaussianRho(epsilon, delta)
+	granularity := discreteGaussianGranularity(l0Sensitivity, lInfSensitivity, rho)
+	// Rounding x to a multiple of the granularity can increase the L_∞
+	// sensitivity by up to the granularity, so the L_∞ sensitivity in multiples
+	// of the granularity is (lInfSensitivity+granularity)/granularity.
+	g := new(big.Rat).SetFloat64(granularity)
+	lInf := new(big.Rat).SetFloat64(lInfSensitivity)
+	lInf.Add(lInf, g).Quo(lInf, g)
+	sample := dg.random().DiscreteGaussian(discreteGaussianSigmaSquared(l0Sensitivity, lInf, rho))
+	return roundToMultipleOfPowerOfTwo(x, granularity) + float64(sample)*granularity, nil
+}
+
+// AddNoiseInt64 adds discrete Gaussian noise to the specified int64, so that
+// the output is (ε,δ)-differentially private.
+func (dg discreteGaussian) AddNoiseInt64(x, l0Sensitivity, lInfSensitivity int64, epsilon, delta float64) int64 {
+	noisy, err := dg.AddNoiseInt64E(x, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	if err != nil {
+		log.Fatalf("discreteGaussian.AddNoiseInt64(l0sensitivity %d, lInfSensitivity %d, epsilon %f, delta %e) checks failed with %v",
+			l0Sensitivity, lInfSensitivity, epsilon, delta, err)
+	}
+	return noisy
+}
+
+// AddNoiseInt64E is the same as AddNoiseInt64, but returns an error instead of
+// exiting the program if the parameters are invalid.
+func (dg discreteGaussian) AddNoiseInt64E(x, l0Sensitivity, lInfSensitivity int64, epsilon, delta float64) (int64, error) {
+	if err := checkArgsDiscreteGaussian("AddNoiseInt64 (discrete Gaussian)", l0Sensitivity, float64(lInfSensitivity), epsilon, delta); err != nil {
+		return 0, err
+	}
+	rho := discreteGaussianRho(epsilon, delta)
+	sample := dg.random().DiscreteGaussian(discreteGaussianSigmaSquared(l0Sensitivity, big.NewRat(lInfSensitivity, 1), rho))
+	return x + sample, nil
+}
+
+// Threshold returns the smallest threshold k to use in a differentially private
+// histogram with added discrete Gaussian noise.
+func (dg discreteGaussian) Threshold(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) float64 {
+	threshold, err := dg.ThresholdE(l0Sensitivity, lInfSensitivity, epsilon, deltaNoise, deltaThreshold)
+	if err != nil {
+		log.Fatalf("discreteGaussian.Threshold(l0sensitivity %d, lInfSensitivity %f, epsilon %f, deltaNoise %e, deltaThreshold %e) checks failed with %v",
+			l0Sensitivity, lInfSensitivity, epsilon, deltaNoise, deltaThreshold, err)
+	}
+	return threshold
+}
+
+// ThresholdE is the same as Threshold, but returns an error instead of exiting
+// the program if the parameters are invalid.
+func (discreteGaussian) ThresholdE(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) (float64, error) {
+	if err := checkArgsDiscreteGaussian("Threshold (discrete Gaussian)", l0Sensitivity, lInfSensitivity, epsilon, deltaNoise); err != nil {
+		return 0, err
+	}
+	if err := checks.CheckDeltaStrict("Threshold (discrete Gaussian, deltaThreshold)", deltaThreshold); err != nil {
+		return 0, err
+	}
+	// The discrete Gaussian distribution with variance parameter σ² is
+	// σ²-subgaussian, so a sample Z satisfies P[Z ≥ t] ≤ exp(-t²/(2σ²)) for
+	// any t ≥ 0. AddNoiseFloat64 adds gZ, where g is the granularity and
+	// g²σ² = l0Sensitivity*(lInfSensitivity+g)²/(2ρ), and rounds partitions
+	// whose value is at most lInfSensitivity to at most lInfSensitivity+g. So
+	// they are kept with probability at most
+	//   P[gZ ≥ k-lInfSensitivity-g] ≤ exp(-(k-lInfSensitivity-g)²/(2g²σ²)),
+	// which is at most the per-partition δ_p for the returned k. See
+	// laplace.ThresholdE for the definition of δ_p. The same bound holds for
+	// AddNoiseInt64, which adds noise with σ² = l0Sensitivity*lInfSensitivity²/(2ρ)
+	// without rounding.
+	rho := discreteGaussianRho(epsilon, deltaNoise)
+	granularity := discreteGaussianGranularity(l0Sensitivity, lInfSensitivity, rho)
+	sigma := math.Sqrt(float64(l0Sensitivity)/(2*rho)) * (lInfSensitivity + granularity)
+	return lInfSensitivity + granularity + sigma*math.Sqrt(-2*math.Log(partitionDelta(l0Sensitivity, deltaThreshold))), nil
+}
+
+// ComputeConfidenceIntervalFloat64 returns a confidence interval that contains
+// the raw value x with probability at least 1-alpha, given the output noisedX
+// of AddNoiseFloat64(x, l0Sensitivity, lInfSensitivity, epsilon, delta).
+// The interval also contains the raw value of AddNoiseInt64 with probability
+// at least 1-alpha.
+func (dg discreteGaussian) ComputeConfidenceIntervalFloat64(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) ConfidenceInterval {
+	confInt, err := dg.ComputeConfidenceIntervalFloat64E(noisedX, l0Sensitivity, lInfSensitivity, epsilon, delta, alpha)
+	if err != nil {
+		log.Fatalf("discreteGaussian.ComputeConfidenceIntervalFloat64(l0sensitivity %d, lInfSensitivity %f, epsilon %f, delta %e, alpha %f) checks failed with %v",
+			l0Sensitivity, lInfSensitivity, epsilon, delta, alpha, err)
+	}
+	return confInt
+}
+
+// ComputeConfidenceIntervalFloat64E is the same as
+// ComputeConfidenceIntervalFloat64, but returns an error instead of exiting the
+// program if the parameters are invalid.
+func (discreteGaussian) ComputeConfidenceIntervalFloat64E(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) (ConfidenceInterval, error) {
+	if err := checkArgsDiscreteGaussian("ComputeConfidenceIntervalFloat64 (discrete Gaussian)", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return ConfidenceInterval{}, err
+	}
+	if err := checks.CheckAlpha("ComputeConfidenceIntervalFloat64 (discrete Gaussian)", alpha); err != nil {
+		return ConfidenceInterval{}, err
+	}
+	// With the notations of ThresholdE, P[|gZ| ≥ t] ≤ 2exp(-t²/(2g²σ²)), which
+	// is equal to α for t = gσ√(2ln(2/α)). Rounding x moves it by at most g/2.
+	// AddNoiseInt64 adds noise with a smaller σ, without rounding.
+	rho := discreteGaussianRho(epsilon, delta)
+	granularity := discreteGaussianGranularity(l0Sensitivity, lInfSensitivity, rho)
+	sigma := math.Sqrt(float64(l0Sensitivity)/(2*rho)) * (lInfSensitivity + granularity)
+	halfWidth := sigma*math.Sqrt(2*math.Log(2/alpha)) + granularity/2
+	return symmetricConfidenceInterval(noisedX, halfWidth), nil
+}
+
+func checkArgsDiscreteGaussian(label string, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) error {
+	if err := checks.CheckL0Sensitivity(label, l0Sensitivity); err != nil {
+		return err
+	}
+	if err := checks.CheckLInfSensitivity(label, lInfSensitivity); err != nil {
+		return err
+	}
+	if err := checks.CheckEpsilonStrict(label, epsilon); err != nil {
+		return err
+	}
+	return checks.CheckDeltaStrict(label, delta)
+}
+
+// discreteGaussianRho returns a ρ such that ρ-zCDP implies
+// (ε,δ)-differential privacy.
+func discreteGaussianRho(epsilon, delta float64) float64 {
+	// ρ-zCDP implies (ρ+2√(ρL), δ)-differential privacy, where L = ln(1/δ)
+	// (Bun and Steinke, https://arxiv.org/abs/1605.02065, Proposition 1.3).
+	// Solving ρ+2√(ρL) = ε gives √ρ = √(L+ε)-√L = ε/(√(L+ε)+√L).
+	l := -math.Log(delta)
+	sqrtRho := epsilon / (math.Sqrt(l+epsilon) + math.Sqrt(l))
+	return sqrtRho * sqrtRho * (1 - discreteGaussianRhoAccuracy)
+}
+
+// discreteGaussianGranularity returns the granularity of the noise that
+// discreteGaussian.AddNoiseFloat64 adds, such that the noise has a standard
+// deviation of about granularityParam multiples of the granularity.
+func discreteGaussianGranularity(l0Sensitivity int64, lInfSensitivity, rho float64) float64 {
+	sigma := math.Sqrt(float64(l0Sensitivity)/(2*rho)) * lInfSensitivity
+	return ceilPowerOfTwo(sigma / granularityParam)
+}
+
+// discreteGaussianSigmaSquared returns the variance parameter σ² of the
+// discrete Gaussian noise that makes a function with the given L_0 and L_∞
+// sensitivities ρ-zCDP. Since the noise of each partition is independent,
+// their ρ add up to ρ = l0Sensitivity*lInfSensitivity²/(2σ²).
+func discreteGaussianSigmaSquared(l0Sensitivity int64, lInfSensitivity *big.Rat, rho float64) *big.Rat {
+	sigmaSquared := new(big.Rat).Mul(lInfSensitivity, lInfSensitivity)
+	sigmaSquared.Mul(sigmaSquared, big.NewRat(l0Sensitivity, 1))
+	return sigmaSquared.Quo(sigmaSquared, new(big.Rat).SetFloat64(2*rho))
+}
@@ -0,0 +1,121 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package noise
+
+import (
+	"math"
+	"testing"
+
+	"github.com/grd/stat"
+)
+
+func TestDiscreteGaussianStatistics(t *testing.T) {
+	const numberOfSamples = 50000
+	for _, tc := range []struct {
+		l0Sensitivity   int64
+		lInfSensitivity int64
+		epsilon, delta  float64
+		mean            int64
+	}{
+		{l0Sensitivity: 1, lInfSensitivity: 1, epsilon: 1.0, delta: 1e-5, mean: 0},
+		{l0Sensitivity: 1, lInfSensitivity: 1, epsilon: ln3, delta: 1e-10, mean: 45941223},
+		{l0Sensitivity: 2, lInfSensitivity: 3, epsilon: 2.0 * ln3, delta: 1e-3, mean: 0},
+	} {
+		// The variance of the discrete Gaussian distribution is very close to its
+		// variance parameter σ² for σ ≥ 1.
+		rho := discreteGaussianRho(tc.epsilon, tc.delta)
+		variance := float64(tc.l0Sensitivity*tc.lInfSensitivity*tc.lInfSensitivity) / (2 * rho)
+
+		intSamples := make(stat.IntSlice, numberOfSamples)
+		floatSamples := make(stat.Float64Slice, numberOfSamples)
+		for i := 0; i < numberOfSamples; i++ {
+			intSamples[i] = DiscreteGaussian().AddNoiseInt64(tc.mean, tc.l0Sensitivity, tc.lInfSensitivity, tc.epsilon, tc.delta)
+			floatSamples[i] = DiscreteGaussian().AddNoiseFloat64(float64(tc.mean), tc.l0Sensitivity, float64(tc.lInfSensitivity), tc.epsilon, tc.delta)
+		}
+		for _, s := range []struct {
+			name           string
+			mean, variance float64
+		}{
+			{"int64", stat.Mean(intSamples), stat.Variance(intSamples)},
+			{"float64", stat.Mean(floatSamples), stat.Variance(floatSamples)},
+		} {
+			// The tolerances are set to the 99.9995% quantiles of the anticipated
+			// distributions, as in TestGaussianStatistics.
+			meanErrorTolerance := 4.41717 * math.Sqrt(variance/float64(numberOfSamples))
+			varianceErrorTolerance := 4.41717 * math.Sqrt(2.0) * variance / math.Sqrt(float64(numberOfSamples))
+			if !nearEqual(s.mean, float64(tc.mean), meanErrorTolerance) {
+				t.Errorf("%s got mean = %f, want %d (parameters %+v)", s.name, s.mean, tc.mean, tc)
+			}
+			if !nearEqual(s.variance, variance, varianceErrorTolerance) {
+				t.Errorf("%s got variance = %f, want %f (parameters %+v)", s.name, s.variance, variance, tc)
+			}
+		}
+	}
+}
+
+func TestDiscreteGaussianRho(t *testing.T) {
+	for _, tc := range []struct {
+		epsilon, delta float64
+	}{
+		{1, 1e-5},
+		{0.1, 1e-10},
+		{5, 0.01},
+	} {
+		rho := discreteGaussianRho(tc.epsilon, tc.delta)
+		// ρ-zCDP implies (ρ+2√(ρln(1/δ)), δ)-differential privacy.
+		if got := rho + 2*math.Sqrt(rho*math.Log(1/tc.delta)); got > tc.epsilon || got < tc.epsilon*(1-1e-9) {
+			t.Errorf("discreteGaussianRho(%f, %e): got ρ=%e, which implies ε=%f, want %f", tc.epsilon, tc.delta, rho, got, tc.epsilon)
+		}
+		// Discrete Gaussian noise adds more noise than Gaussian noise.
+		if gaussianRho := RhoForGaussian(tc.epsilon, tc.delta); rho > gaussianRho {
+			t.Errorf("discreteGaussianRho(%f, %e): got ρ=%e, want at most %e", tc.epsilon, tc.delta, rho, gaussianRho)
+		}
+	}
+}
+
+func TestThresholdDiscreteGaussian(t *testing.T) {
+	for _, tc := range []struct {
+		l0Sensitivity  int64
+		epsilon        float64
+		deltaNoise     float64
+		deltaThreshold float64
+	}{
+		{1, ln3, 1e-10, 1e-10},
+		{1, 0.1, 1e-5, 1e-5},
+		{10, 1, 1e-5, 0.1},
+	} {
+		k := DiscreteGaussian().Threshold(tc.l0Sensitivity, 1, tc.epsilon, tc.deltaNoise, tc.deltaThreshold)
+		// With AddNoiseInt64, a partition with value 1 is kept if its noise is
+		// at least ⌈k-1⌉. Its probability is computed from the probability mass
+		// function of the discrete Gaussian distribution.
+		rho := discreteGaussianRho(tc.epsilon, tc.deltaNoise)
+		sigmaSquared := float64(tc.l0Sensitivity) / (2 * rho)
+		var tail, total float64
+		bound := int64(20 * math.Sqrt(sigmaSquared))
+		m := int64(math.Ceil(k - 1))
+		for x := -bound; x <= bound; x++ {
+			p := math.Exp(-float64(x*x) / (2 * sigmaSquared))
+			total += p
+			if x >= m {
+				tail += p
+			}
+		}
+		if got, want := tail/total, partitionDelta(tc.l0Sensitivity, tc.deltaThreshold); got > want {
+			t.Errorf("Threshold(%d, 1, %f, %e, %e): got %f, which keeps partitions with probability %e, want at most %e", tc.l0Sensitivity, tc.epsilon, tc.deltaNoise, tc.deltaThreshold, k, got, want)
+		}
+	}
+}
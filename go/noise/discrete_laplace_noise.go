@@ -0,0 +1,184 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package noise
+
+import (
+	"math"
+	"math/big"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+)
+
+type discreteLaplace struct {
+	randomness
+}
+
+// DiscreteLaplace returns a Noise instance that adds discrete Laplace noise to
+// its input. Its AddNoise* functions will fail if called with a non-zero delta.
+//
+// The noise is drawn with rand.DiscreteLaplace, which samples integers exactly
+// using only integer and rational arithmetic. AddNoiseInt64 adds such a sample
+// to its input directly, without any rounding, and AddNoiseFloat64 adds a
+// sample scaled to a power-of-two granularity, as Laplace does.
+func DiscreteLaplace() Noise {
+	return discreteLaplace{}
+}
+
+// AddNoiseFloat64 adds discrete Laplace noise to the specified float64 x so
+// that the output is ε-differentially private given the L_0 and L_∞
+// sensitivities of the database.
+func (dl discreteLaplace) AddNoiseFloat64(x float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) float64 {
+	noisy, err := dl.AddNoiseFloat64E(x, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	if err != nil {
+		log.Fatalf("discreteLaplace.AddNoiseFloat64(l0sensitivity %d, lInfSensitivity %f, epsilon %f, delta %e) checks failed with %v",
+			l0Sensitivity, lInfSensitivity, epsilon, delta, err)
+	}
+	return noisy
+}
+
+// AddNoiseFloat64E is the same as AddNoiseFloat64, but returns an error
+// instead of exiting the program if the parameters are invalid.
+func (dl discreteLaplace) AddNoiseFloat64E(x float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) (float64, error) {
+	if err := checkArgsLaplace("AddNoiseFloat64 (discrete Laplace)", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return 0, err
+	}
+	l1Sensitivity := lInfSensitivity * float64(l0Sensitivity)
+	granularity := discreteLaplaceGranularity(l1Sensitivity, epsilon)
+	// Rounding x to a multiple of the granularity can increase the
+	// sensitivity by up to the granularity, so the scale in multiples of the
+	// granularity is (l1Sensitivity+granularity)/(granularity*ε). It is
+	// computed exactly.
+	scale := new(big.Rat).SetFloat64(lInfSensitivity)
+	scale.Mul(scale, big.NewRat(l0Sensitivity, 1))
+	scale.Add(scale, new(big.Rat).SetFloat64(granularity))
+	scale.Quo(scale, new(big.Rat).SetFloat64(granularity))
+	scale.Quo(scale, new(big.Rat).SetFloat64(epsilon))
+	return roundToMultipleOfPowerOfTwo(x, granularity) + float64(dl.random().DiscreteLaplace(scale))*granularity, nil
+}
+
+// AddNoiseInt64 adds discrete Laplace noise to the specified int64 x so that
+// the output is ε-differentially private given the L_0 and L_∞ sensitivities
+// of the database.
+func (dl discreteLaplace) AddNoiseInt64(x, l0Sensitivity, lInfSensitivity int64, epsilon, delta float64) int64 {
+	noisy, err := dl.AddNoiseInt64E(x, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	if err != nil {
+		log.Fatalf("discreteLaplace.AddNoiseInt64(l0sensitivity %d, lInfSensitivity %d, epsilon %f, delta %e) checks failed with %v",
+			l0Sensitivity, lInfSensitivity, epsilon, delta, err)
+	}
+	return noisy
+}
+
+// AddNoiseInt64E is the same as AddNoiseInt64, but returns an error instead of
+// exiting the program if the parameters are invalid.
+func (dl discreteLaplace) AddNoiseInt64E(x, l0Sensitivity, lInfSensitivity int64, epsilon, delta float64) (int64, error) {
+	if err := checkArgsLaplace("AddNoiseInt64 (discrete Laplace)", l0Sensitivity, float64(lInfSensitivity), epsilon, delta); err != nil {
+		return 0, err
+	}
+	// The scale l1Sensitivity/ε is computed exactly.
+	scale := new(big.Rat).SetInt64(lInfSensitivity)
+	scale.Mul(scale, big.NewRat(l0Sensitivity, 1))
+	scale.Quo(scale, new(big.Rat).SetFloat64(epsilon))
+	return x + dl.random().DiscreteLaplace(scale), nil
+}
+
+// Threshold returns the smallest threshold k to use in a differentially private
+// histogram with added discrete Laplace noise. Like other functions for
+// discrete Laplace noise, it fails if deltaNoise is non-zero.
+func (dl discreteLaplace) Threshold(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) float64 {
+	threshold, err := dl.ThresholdE(l0Sensitivity, lInfSensitivity, epsilon, deltaNoise, deltaThreshold)
+	if err != nil {
+		log.Fatalf("discreteLaplace.Threshold(l0sensitivity %d, lInfSensitivity %f, epsilon %f, deltaNoise %e, deltaThreshold %e) checks failed with %v",
+			l0Sensitivity, lInfSensitivity, epsilon, deltaNoise, deltaThreshold, err)
+	}
+	return threshold
+}
+
+// ThresholdE is the same as Threshold, but returns an error instead of exiting
+// the program if the parameters are invalid.
+func (discreteLaplace) ThresholdE(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) (float64, error) {
+	if err := checkArgsLaplace("Threshold (discrete Laplace)", l0Sensitivity, lInfSensitivity, epsilon, deltaNoise); err != nil {
+		return 0, err
+	}
+	if err := checks.CheckDeltaStrict("Threshold (discrete Laplace, deltaThreshold)", deltaThreshold); err != nil {
+		return 0, err
+	}
+	// AddNoiseFloat64 adds gZ, where g is the granularity and Z is discrete
+	// Laplace noise with scale λ = (l1Sensitivity+g)/(gε). For any integer
+	// m ≥ 0,
+	//   P[Z ≥ m] = exp(-m/λ)/(1+exp(-1/λ)) ≤ exp(-m/λ).
+	// A partition whose value is at most lInfSensitivity is rounded to at most
+	// lInfSensitivity+g, so it is kept with probability at most
+	//   P[gZ ≥ k-lInfSensitivity-g] ≤ exp(-(k-lInfSensitivity-g)ε/(l1Sensitivity+g)),
+	// which is at most the per-partition δ_p for the returned k. See
+	// laplace.ThresholdE for the definition of δ_p. The same bound holds for
+	// AddNoiseInt64, which adds noise with scale l1Sensitivity/ε without
+	// rounding.
+	l1Sensitivity := lInfSensitivity * float64(l0Sensitivity)
+	granularity := discreteLaplaceGranularity(l1Sensitivity, epsilon)
+	return lInfSensitivity + granularity - (l1Sensitivity+granularity)*math.Log(partitionDelta(l0Sensitivity, deltaThreshold))/epsilon, nil
+}
+
+// ComputeConfidenceIntervalFloat64 returns a confidence interval that contains
+// the raw value x with probability at least 1-alpha, given the output noisedX
+// of AddNoiseFloat64(x, l0Sensitivity, lInfSensitivity, epsilon, delta).
+// The interval also contains the raw value of AddNoiseInt64 with probability
+// at least 1-alpha.
+func (dl discreteLaplace) ComputeConfidenceIntervalFloat64(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) ConfidenceInterval {
+	confInt, err := dl.ComputeConfidenceIntervalFloat64E(noisedX, l0Sensitivity, lInfSensitivity, epsilon, delta, alpha)
+	if err != nil {
+		log.Fatalf("discreteLaplace.ComputeConfidenceIntervalFloat64(l0sensitivity %d, lInfSensitivity %f, epsilon %f, delta %e, alpha %f) checks failed with %v",
+			l0Sensitivity, lInfSensitivity, epsilon, delta, alpha, err)
+	}
+	return confInt
+}
+
+// ComputeConfidenceIntervalFloat64E is the same as
+// ComputeConfidenceIntervalFloat64, but returns an error instead of exiting the
+// program if the parameters are invalid.
+func (discreteLaplace) ComputeConfidenceIntervalFloat64E(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) (ConfidenceInterval, error) {
+	if err := checkArgsLaplace("ComputeConfidenceIntervalFloat64 (discrete Laplace)", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return ConfidenceInterval{}, err
+	}
+	if err := checks.CheckAlpha("ComputeConfidenceIntervalFloat64 (discrete Laplace)", alpha); err != nil {
+		return ConfidenceInterval{}, err
+	}
+	// AddNoiseInt64 adds noise with a smaller scale, without rounding.
+	l1Sensitivity := lInfSensitivity * float64(l0Sensitivity)
+	granularity := discreteLaplaceGranularity(l1Sensitivity, epsilon)
+	halfWidth := geometricHalfWidth((l1Sensitivity+granularity)/epsilon, granularity, alpha)
+	return symmetricConfidenceInterval(noisedX, halfWidth), nil
+}
+
+// discreteLaplaceGranularity returns the granularity of the noise that
+// discreteLaplace.AddNoiseFloat64 adds, which is the same as for Laplace.
+func discreteLaplaceGranularity(l1Sensitivity, epsilon float64) float64 {
+	return ceilPowerOfTwo((l1Sensitivity / epsilon) / granularityParam)
+}
+
+// partitionDelta returns the per-partition probability δ_p of keeping a
+// partition with a single user such that thresholding is
+// (0, deltaThreshold)-differentially private on databases with the given L_0
+// sensitivity.
+func partitionDelta(l0Sensitivity int64, deltaThreshold float64) float64 {
+	if deltaThreshold < deltaLowPrecisionThreshold {
+		// 1-deltaThreshold loses precision for small deltaThreshold, so fall back
+		// on the lower bound that doesn't assume independence between partitions.
+		return deltaThreshold / float64(l0Sensitivity)
+	}
+	return 1 - math.Pow(1-deltaThreshold, 1/float64(l0Sensitivity))
+}
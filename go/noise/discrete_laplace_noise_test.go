@@ -0,0 +1,99 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package noise
+
+import (
+	"math"
+	"testing"
+
+	"github.com/grd/stat"
+)
+
+func TestDiscreteLaplaceStatistics(t *testing.T) {
+	const numberOfSamples = 50000
+	for _, tc := range []struct {
+		l0Sensitivity   int64
+		lInfSensitivity int64
+		epsilon         float64
+		mean            int64
+	}{
+		{l0Sensitivity: 1, lInfSensitivity: 1, epsilon: 1.0, mean: 0},
+		{l0Sensitivity: 1, lInfSensitivity: 1, epsilon: ln3, mean: 45941223},
+		{l0Sensitivity: 2, lInfSensitivity: 3, epsilon: 2.0 * ln3, mean: 0},
+	} {
+		// The variance of discrete Laplace noise with scale λ is
+		// 2exp(-1/λ)/(1-exp(-1/λ))², which is close to the variance 2λ² of
+		// Laplace noise for large λ.
+		lambda := float64(tc.l0Sensitivity*tc.lInfSensitivity) / tc.epsilon
+		q := math.Exp(-1 / lambda)
+		intVariance := 2 * q / ((1 - q) * (1 - q))
+		floatVariance := 2 * lambda * lambda
+
+		intSamples := make(stat.IntSlice, numberOfSamples)
+		floatSamples := make(stat.Float64Slice, numberOfSamples)
+		for i := 0; i < numberOfSamples; i++ {
+			intSamples[i] = DiscreteLaplace().AddNoiseInt64(tc.mean, tc.l0Sensitivity, tc.lInfSensitivity, tc.epsilon, 0)
+			floatSamples[i] = DiscreteLaplace().AddNoiseFloat64(float64(tc.mean), tc.l0Sensitivity, float64(tc.lInfSensitivity), tc.epsilon, 0)
+		}
+		for _, s := range []struct {
+			name           string
+			mean, variance float64
+			wantVariance   float64
+		}{
+			{"int64", stat.Mean(intSamples), stat.Variance(intSamples), intVariance},
+			{"float64", stat.Mean(floatSamples), stat.Variance(floatSamples), floatVariance},
+		} {
+			// The tolerances are set to the 99.9995% quantiles of the anticipated
+			// distributions, as in TestLaplaceStatistics.
+			meanErrorTolerance := 4.41717 * math.Sqrt(s.wantVariance/float64(numberOfSamples))
+			varianceErrorTolerance := 4.41717 * math.Sqrt(5.0) * s.wantVariance / math.Sqrt(float64(numberOfSamples))
+			if !nearEqual(s.mean, float64(tc.mean), meanErrorTolerance) {
+				t.Errorf("%s got mean = %f, want %d (parameters %+v)", s.name, s.mean, tc.mean, tc)
+			}
+			if !nearEqual(s.variance, s.wantVariance, varianceErrorTolerance) {
+				t.Errorf("%s got variance = %f, want %f (parameters %+v)", s.name, s.variance, s.wantVariance, tc)
+			}
+		}
+	}
+}
+
+func TestThresholdDiscreteLaplace(t *testing.T) {
+	for _, tc := range []struct {
+		l0Sensitivity int64
+		epsilon       float64
+		delta         float64
+	}{
+		{1, ln3, 1e-10},
+		{1, 0.1, 1e-5},
+		{10, 1, 0.1},
+	} {
+		k := DiscreteLaplace().Threshold(tc.l0Sensitivity, 1, tc.epsilon, 0, tc.delta)
+		// With AddNoiseInt64, a partition with value 1 is kept if its noise Z
+		// is at least ⌈k-1⌉, which happens with probability
+		// exp(-m/λ)/(1+exp(-1/λ)) for m = ⌈k-1⌉.
+		lambda := float64(tc.l0Sensitivity) / tc.epsilon
+		m := math.Ceil(k - 1)
+		got := math.Exp(-m/lambda) / (1 + math.Exp(-1/lambda))
+		if want := partitionDelta(tc.l0Sensitivity, tc.delta); got > want {
+			t.Errorf("Threshold(%d, 1, %f, 0, %e): got %f, which keeps partitions with probability %e, want at most %e", tc.l0Sensitivity, tc.epsilon, tc.delta, k, got, want)
+		}
+		// The threshold isn't much larger than the threshold for Laplace noise.
+		if laplaceK := Laplace().Threshold(tc.l0Sensitivity, 1, tc.epsilon, 0, tc.delta); k > laplaceK+lambda*ln2+1e-6 {
+			t.Errorf("Threshold(%d, 1, %f, 0, %e): got %f, want at most %f", tc.l0Sensitivity, tc.epsilon, tc.delta, k, laplaceK+lambda*ln2)
+		}
+	}
+}
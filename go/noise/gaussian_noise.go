@@ -45,7 +45,9 @@ var (
 	gaussianSigmaAccuracy = 1e-3
 )
 
-type gaussian struct{}
+type gaussian struct {
+	randomness
+}
 
 // Gaussian returns a Noise instance that adds Gaussian noise to its input.
 //
@@ -59,58 +61,125 @@ func Gaussian() Noise {
 
 // AddNoiseFloat64 adds Gaussian noise to the specified float64, so that its
 // output is (ε,δ)-differentially private.
-func (gaussian) AddNoiseFloat64(x float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) float64 {
-	if err := checkArgsGaussian("AddGaussianFloat64", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+func (g gaussian) AddNoiseFloat64(x float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) float64 {
+	noisy, err := g.AddNoiseFloat64E(x, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	if err != nil {
 		log.Fatalf("gaussian.AddNoiseFloat64(l0sensitivity %d, lInfSensitivity %f, epsilon %f, delta %e) checks failed with %v",
 			l0Sensitivity, lInfSensitivity, epsilon, delta, err)
 	}
+	return noisy
+}
+
+// AddNoiseFloat64E is the same as AddNoiseFloat64, but returns an error
+// instead of exiting the program if the parameters are invalid.
+func (g gaussian) AddNoiseFloat64E(x float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) (float64, error) {
+	if err := checkArgsGaussian("AddGaussianFloat64", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return 0, err
+	}
 
 	sigma := SigmaForGaussian(l0Sensitivity, lInfSensitivity, epsilon, delta)
-	return addGaussian(x, sigma)
+	return addGaussian(g.random(), x, sigma), nil
 }
 
 // AddNoiseInt64 adds Gaussian noise to the specified int64, so that the
 // output is (ε,δ)-differentially private.
-func (gaussian) AddNoiseInt64(x, l0Sensitivity, lInfSensitivity int64, epsilon, delta float64) int64 {
-	if err := checkArgsGaussian("AddGaussianInt64", l0Sensitivity, float64(lInfSensitivity), epsilon, delta); err != nil {
+func (g gaussian) AddNoiseInt64(x, l0Sensitivity, lInfSensitivity int64, epsilon, delta float64) int64 {
+	noisy, err := g.AddNoiseInt64E(x, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	if err != nil {
 		log.Fatalf("gaussian.AddNoiseInt64(l0sensitivity %d, lInfSensitivity %d, epsilon %f, delta %e) checks failed with %v",
 			l0Sensitivity, lInfSensitivity, epsilon, delta, err)
 	}
+	return noisy
+}
+
+// AddNoiseInt64E is the same as AddNoiseInt64, but returns an error instead of
+// exiting the program if the parameters are invalid.
+func (g gaussian) AddNoiseInt64E(x, l0Sensitivity, lInfSensitivity int64, epsilon, delta float64) (int64, error) {
+	if err := checkArgsGaussian("AddGaussianInt64", l0Sensitivity, float64(lInfSensitivity), epsilon, delta); err != nil {
+		return 0, err
+	}
 
 	sigma := SigmaForGaussian(l0Sensitivity, float64(lInfSensitivity), epsilon, delta)
-	return int64(math.Round(addGaussian(float64(x), sigma)))
+	return int64(math.Round(addGaussian(g.random(), float64(x), sigma))), nil
 }
 
 // Threshold returns the smallest threshold k to use in a differentially private
 // histogram with added Gaussian noise.
 //
 // See https://github.com/google/differential-privacy/blob/master/common_docs/Delta_For_Thresholding.pdf for details on the math underlying this.
-func (gaussian) Threshold(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) float64 {
-	if err := checkArgsGaussian("Threshold (gaussian)", l0Sensitivity, lInfSensitivity, epsilon, deltaNoise); err != nil {
+func (g gaussian) Threshold(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) float64 {
+	threshold, err := g.ThresholdE(l0Sensitivity, lInfSensitivity, epsilon, deltaNoise, deltaThreshold)
+	if err != nil {
 		log.Fatalf("gaussian.Threshold(l0sensitivity %d, lInfSensitivity %f, epsilon %f, deltaNoise %e, deltaThreshold %e) checks failed with %v",
 			l0Sensitivity, lInfSensitivity, epsilon, deltaNoise, deltaThreshold, err)
 	}
+	return threshold
+}
+
+// ThresholdE is the same as Threshold, but returns an error instead of exiting
+// the program if the parameters are invalid.
+func (gaussian) ThresholdE(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) (float64, error) {
+	if err := checkArgsGaussian("Threshold (gaussian)", l0Sensitivity, lInfSensitivity, epsilon, deltaNoise); err != nil {
+		return 0, err
+	}
 	if err := checks.CheckDeltaStrict("Threshold (gaussian, deltaNoise)", deltaThreshold); err != nil {
-		log.Fatalf("CheckDelta failed with %v", err)
+		return 0, err
 	}
 
 	sigma := SigmaForGaussian(l0Sensitivity, lInfSensitivity, epsilon, deltaNoise)
 	noiseDist := distuv.Normal{Mu: 0, Sigma: sigma}
-	return lInfSensitivity + noiseDist.Quantile(math.Pow(1-deltaThreshold, 1.0/float64(l0Sensitivity)))
+	return lInfSensitivity + noiseDist.Quantile(math.Pow(1-deltaThreshold, 1.0/float64(l0Sensitivity))), nil
 }
 
 // DeltaForThreshold is the inverse operation of Threshold. Specifically, given
 // the parameters and a threshold, it returns the delta induced by thresholding.
 //
 // See https://github.com/google/differential-privacy/blob/master/common_docs/Delta_For_Thresholding.pdf for details on the math underlying this.
-func (gaussian) DeltaForThreshold(l0Sensitivity int64, lInfSensitivity, epsilon, delta, threshold float64) float64 {
-	if err := checkArgsGaussian("DeltaForThreshold (gaussian)", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+func (g gaussian) DeltaForThreshold(l0Sensitivity int64, lInfSensitivity, epsilon, delta, threshold float64) float64 {
+	d, err := g.DeltaForThresholdE(l0Sensitivity, lInfSensitivity, epsilon, delta, threshold)
+	if err != nil {
 		log.Fatalf("gaussian.DeltaForThreshold(l0sensitivity %d, lInfSensitivity %f, epsilon %f, delta %e, threshold %f) checks failed with %v",
 			l0Sensitivity, lInfSensitivity, epsilon, delta, threshold, err)
 	}
+	return d
+}
+
+// DeltaForThresholdE is the same as DeltaForThreshold, but returns an error
+// instead of exiting the program if the parameters are invalid.
+func (gaussian) DeltaForThresholdE(l0Sensitivity int64, lInfSensitivity, epsilon, delta, threshold float64) (float64, error) {
+	if err := checkArgsGaussian("DeltaForThreshold (gaussian)", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return 0, err
+	}
+	sigma := SigmaForGaussian(l0Sensitivity, lInfSensitivity, epsilon, delta)
+	noiseDist := distuv.Normal{Mu: 0, Sigma: sigma}
+	return 1 - math.Pow(noiseDist.CDF(threshold-lInfSensitivity), float64(l0Sensitivity)), nil
+}
+
+// ComputeConfidenceIntervalFloat64 returns a confidence interval that contains
+// the raw value x with probability at least 1-alpha, given the output noisedX
+// of AddNoiseFloat64(x, l0Sensitivity, lInfSensitivity, epsilon, delta).
+func (g gaussian) ComputeConfidenceIntervalFloat64(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) ConfidenceInterval {
+	confInt, err := g.ComputeConfidenceIntervalFloat64E(noisedX, l0Sensitivity, lInfSensitivity, epsilon, delta, alpha)
+	if err != nil {
+		log.Fatalf("gaussian.ComputeConfidenceIntervalFloat64(l0sensitivity %d, lInfSensitivity %f, epsilon %f, delta %e, alpha %f) checks failed with %v",
+			l0Sensitivity, lInfSensitivity, epsilon, delta, alpha, err)
+	}
+	return confInt
+}
+
+// ComputeConfidenceIntervalFloat64E is the same as
+// ComputeConfidenceIntervalFloat64, but returns an error instead of exiting the
+// program if the parameters are invalid.
+func (gaussian) ComputeConfidenceIntervalFloat64E(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) (ConfidenceInterval, error) {
+	if err := checkArgsGaussian("ComputeConfidenceIntervalFloat64 (Gaussian)", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return ConfidenceInterval{}, err
+	}
+	if err := checks.CheckAlpha("ComputeConfidenceIntervalFloat64 (Gaussian)", alpha); err != nil {
+		return ConfidenceInterval{}, err
+	}
 	sigma := SigmaForGaussian(l0Sensitivity, lInfSensitivity, epsilon, delta)
 	noiseDist := distuv.Normal{Mu: 0, Sigma: sigma}
-	return 1 - math.Pow(noiseDist.CDF(threshold-lInfSensitivity), float64(l0Sensitivity))
+	return symmetricConfidenceInterval(noisedX, noiseDist.Quantile(1-alpha/2)), nil
 }
 
 func checkArgsGaussian(label string, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) error {
@@ -126,15 +195,16 @@ func checkArgsGaussian(label string, l0Sensitivity int64, lInfSensitivity, epsil
 	return checks.CheckDeltaStrict(label, delta)
 }
 
-// addGaussian adds Gaussian noise of scale σ to the specified float64.
-func addGaussian(x, sigma float64) float64 {
+// addGaussian adds Gaussian noise of scale σ to the specified float64, drawing
+// randomness from r.
+func addGaussian(r *rand.Rand, x, sigma float64) float64 {
 	granularity := ceilPowerOfTwo(2.0 * sigma / binomialBound)
 
 	// sqrtN is chosen in a way that places it in the interval between binomialBound
 	// and binomialBound / 2. This ensures that the respective binomial distribution
 	// consists of enough Bernoulli samples to closely approximate a Gaussian distribution.
 	sqrtN := 2.0 * sigma / granularity
-	sample := symmetricBinomial(sqrtN)
+	sample := symmetricBinomial(r, sqrtN)
 	return roundToMultipleOfPowerOfTwo(x, granularity) + float64(sample)*granularity
 }
 
@@ -143,22 +213,22 @@ func addGaussian(x, sigma float64) float64 {
 // 0.5 each. The sampling technique is based on Bringmann et al.'s rejection sampling
 // approach proposed in "Internal DLA: Efficient Simulation of a Physical Growth Model"
 // (https://people.mpi-inf.mpg.de/~kbringma/paper/2014ICALP.pdf).
-func symmetricBinomial(sqrtN float64) int64 {
+func symmetricBinomial(r *rand.Rand, sqrtN float64) int64 {
 	stepSize := int64(math.Round(math.Sqrt2*sqrtN + 1.0))
 	var result int64
 	i := 0
 	for true {
 		// 1 is subtracted from the geometric sample to count the number of Bernoulli fails
 		// rather than the number of trials until the first success.
-		boundedGeometricSample := int64(math.Min(rand.Geometric()-1.0, float64(geometricBound)))
+		boundedGeometricSample := int64(math.Min(r.Geometric()-1.0, float64(geometricBound)))
 		twoSidedGeometricSample := boundedGeometricSample
-		if rand.Boolean() {
+		if r.Boolean() {
 			twoSidedGeometricSample = -twoSidedGeometricSample - 1
 		}
 
-		result = stepSize*twoSidedGeometricSample + rand.I63n(stepSize)
+		result = stepSize*twoSidedGeometricSample + r.I63n(stepSize)
 		resultProbability := binomialProbability(sqrtN, result)
-		rejectProbability := rand.Uniform()
+		rejectProbability := r.Uniform()
 		if resultProbability > 0.0 &&
 			rejectProbability < resultProbability*float64(stepSize)*math.Pow(2.0, float64(boundedGeometricSample))/4.0 {
 			break
@@ -230,12 +300,18 @@ func SigmaForGaussian(l0Sensitivity int64, lInfSensitivity, epsilon, delta float
 	if delta >= 1 {
 		return 0
 	}
+	_, upperBound := sigmaBoundsForGaussian(l0Sensitivity, lInfSensitivity, epsilon, delta, gaussianSigmaAccuracy)
+	return upperBound
+}
 
+// sigmaBoundsForGaussian returns a lower and an upper bound of the exact
+// standard deviation σ_tight of SigmaForGaussian, such that
+// upperBound - lowerBound <= accuracy*lowerBound. delta must be less than 1.
+func sigmaBoundsForGaussian(l0Sensitivity int64, lInfSensitivity, epsilon, delta, accuracy float64) (lowerBound, upperBound float64) {
 	// We use l2Sensitivity as a starting guess for the upper bound since the
 	// required noise grows linearly with sensitivity.
 	l2Sensitivity := lInfSensitivity * math.Sqrt(float64(l0Sensitivity))
-	upperBound := l2Sensitivity
-	var lowerBound float64
+	upperBound = l2Sensitivity
 
 	// Increase upperBound until it is actually an upper bound of σ_tight.
 	//
@@ -253,15 +329,15 @@ func SigmaForGaussian(l0Sensitivity int64, lInfSensitivity, epsilon, delta float
 	}
 
 	// Loop runtime:
-	//   O(log(1/σ_tight) + log(1/gaussianSigmaAccuracy)) if σ_tight < l2Sensitivity
-	//   O(log(1/gaussianSigmaAccuracy))                  otherwise.
+	//   O(log(1/σ_tight) + log(1/accuracy)) if σ_tight < l2Sensitivity
+	//   O(log(1/accuracy))                  otherwise.
 	//
 	// Proof. First, suppose σ_tight > l2Sensitivity. The prior for-loop guarantees that
 	//   (1) upperBound - lowerBound <= σ_tight
 	//   (2) lowerBound >= 0.5*σ_tight
 	// at the start of this loop.  Using (1), binary search takes
-	// O(log(1/gaussianSigmaAccuracy)) iterations to bound the solution within an interval
-	// of width 0.5*σ_tight*gaussianSigmaAccuracy. Since (2) holds over all iterations of
+	// O(log(1/accuracy)) iterations to bound the solution within an interval
+	// of width 0.5*σ_tight*accuracy. Since (2) holds over all iterations of
 	// this loop, that is sufficient iterations to meet the loop's exit criterion.
 	//
 	// Now suppose σ_tight <= l2Sensitivity. It takes
@@ -269,9 +345,9 @@ func SigmaForGaussian(l0Sensitivity int64, lInfSensitivity, epsilon, delta float
 	// than σ_tight. At that iteration, lowerBound is updated to be at least
 	// 0.5*σ_tight. After this first update to lowerBound, we use the argument of
 	// the preceding paragraph (noting that (1) and (2) now both hold) to see that
-	// it takes an additional O(log(l2Sensitivity/gaussianSigmaAccuracy)) iterations to
+	// it takes an additional O(log(l2Sensitivity/accuracy)) iterations to
 	// find a sufficiently accurate estimate of σ_tight to exit the loop.
-	for upperBound-lowerBound > gaussianSigmaAccuracy*lowerBound {
+	for upperBound-lowerBound > accuracy*lowerBound {
 		middle := lowerBound*0.5 + upperBound*0.5
 		if deltaForGaussian(middle, l0Sensitivity, lInfSensitivity, epsilon) > delta {
 			lowerBound = middle
@@ -280,5 +356,5 @@ func SigmaForGaussian(l0Sensitivity int64, lInfSensitivity, epsilon, delta float
 		}
 	}
 
-	return upperBound
+	return lowerBound, upperBound
 }
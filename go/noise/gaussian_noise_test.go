@@ -20,6 +20,7 @@ import (
 	"math"
 	"testing"
 
+	"github.com/google/differential-privacy/go/rand"
 	"github.com/grd/stat"
 )
 
@@ -142,7 +143,7 @@ func TestSymmetricBinomialStatisitcs(t *testing.T) {
 	} {
 		binomialSamples := make(stat.IntSlice, numberOfSamples)
 		for i := 0; i < numberOfSamples; i++ {
-			binomialSamples[i] = symmetricBinomial(tc.sqrtN)
+			binomialSamples[i] = symmetricBinomial(rand.Secure(), tc.sqrtN)
 		}
 		sampleMean, sampleVariance := stat.Mean(binomialSamples), stat.Variance(binomialSamples)
 		// Assuming that the binomial samples have a mean of 0 and the specified standard deviation
@@ -42,7 +42,9 @@ var (
 	deltaLowPrecisionThreshold = (1 - math.Nextafter(1.0, math.Inf(-1))) * 1e6
 )
 
-type laplace struct{}
+type laplace struct {
+	randomness
+}
 
 // Laplace returns a Noise instance that adds Laplace noise to its input.
 // Its AddNoise* functions will fail if called with a non-zero delta.
@@ -58,33 +60,63 @@ func Laplace() Noise {
 // AddNoiseFloat64 adds Laplace noise to the specified float64 x so that the
 // output is ε-differentially private given the L_0 and L_∞ sensitivities of the
 // database.
-func (laplace) AddNoiseFloat64(x float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) float64 {
-	if err := checkArgsLaplace("AddNoiseFloat64 (Laplace)", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+func (l laplace) AddNoiseFloat64(x float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) float64 {
+	noisy, err := l.AddNoiseFloat64E(x, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	if err != nil {
 		log.Fatalf("laplace.AddNoiseFloat64(l0sensitivity %d, lInfSensitivity %f, epsilon %f, delta %e) checks failed with %v",
 			l0Sensitivity, lInfSensitivity, epsilon, delta, err)
 	}
-	return addLaplace(x, epsilon, lInfSensitivity*float64(l0Sensitivity) /* l1Sensitivity */)
+	return noisy
+}
+
+// AddNoiseFloat64E is the same as AddNoiseFloat64, but returns an error
+// instead of exiting the program if the parameters are invalid.
+func (l laplace) AddNoiseFloat64E(x float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) (float64, error) {
+	if err := checkArgsLaplace("AddNoiseFloat64 (Laplace)", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return 0, err
+	}
+	return addLaplace(l.random(), x, epsilon, lInfSensitivity*float64(l0Sensitivity) /* l1Sensitivity */), nil
 }
 
 // AddNoiseInt64 adds Laplace noise to the specified int64 x so that the
 // output is ε-differentially private given the L_0 and L_∞ sensitivities of the
 // database.
-func (laplace) AddNoiseInt64(x, l0Sensitivity, lInfSensitivity int64, epsilon, delta float64) int64 {
-	if err := checkArgsLaplace("AddNoiseInt64 (Laplace)", l0Sensitivity, float64(lInfSensitivity), epsilon, delta); err != nil {
+func (l laplace) AddNoiseInt64(x, l0Sensitivity, lInfSensitivity int64, epsilon, delta float64) int64 {
+	noisy, err := l.AddNoiseInt64E(x, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	if err != nil {
 		log.Fatalf("laplace.AddNoiseInt64(l0sensitivity %d, lInfSensitivity %d, epsilon %f, delta %e) checks failed with %v",
 			l0Sensitivity, lInfSensitivity, epsilon, delta, err)
 	}
-	return int64(math.Round(addLaplace(float64(x), epsilon, float64(lInfSensitivity*l0Sensitivity) /* l1Sensitivity */)))
+	return noisy
+}
+
+// AddNoiseInt64E is the same as AddNoiseInt64, but returns an error instead of
+// exiting the program if the parameters are invalid.
+func (l laplace) AddNoiseInt64E(x, l0Sensitivity, lInfSensitivity int64, epsilon, delta float64) (int64, error) {
+	if err := checkArgsLaplace("AddNoiseInt64 (Laplace)", l0Sensitivity, float64(lInfSensitivity), epsilon, delta); err != nil {
+		return 0, err
+	}
+	return int64(math.Round(addLaplace(l.random(), float64(x), epsilon, float64(lInfSensitivity*l0Sensitivity) /* l1Sensitivity */))), nil
 }
 
 // Threshold returns the smallest threshold k to use in a differentially private
 // histogram with added Laplace noise. Like other functions for Laplace noise,
 // it fails if deltaNoise is non-zero.
-func (laplace) Threshold(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) float64 {
-	if err := checkArgsLaplace("ThresholdForLaplace", l0Sensitivity, lInfSensitivity, epsilon, deltaNoise); err != nil {
+func (l laplace) Threshold(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) float64 {
+	threshold, err := l.ThresholdE(l0Sensitivity, lInfSensitivity, epsilon, deltaNoise, deltaThreshold)
+	if err != nil {
 		log.Fatalf("laplace.Threshold(l0sensitivity %d, lInfSensitivity %f, epsilon %f, deltaNoise %e, deltaThreshold %e) checks failed with %v",
 			l0Sensitivity, lInfSensitivity, epsilon, deltaNoise, deltaThreshold, err)
 	}
+	return threshold
+}
+
+// ThresholdE is the same as Threshold, but returns an error instead of exiting
+// the program if the parameters are invalid.
+func (laplace) ThresholdE(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) (float64, error) {
+	if err := checkArgsLaplace("ThresholdForLaplace", l0Sensitivity, lInfSensitivity, epsilon, deltaNoise); err != nil {
+		return 0, err
+	}
 	// λ is the scale of the Laplace noise that needs to be added to each sum
 	// to get pure ε-differential privacy if all keys are the same.
 	lambda := laplaceLambda(l0Sensitivity, lInfSensitivity, epsilon)
@@ -126,20 +158,30 @@ func (laplace) Threshold(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoi
 		partitionDelta = deltaThreshold / float64(l0Sensitivity)
 	}
 	if partitionDelta <= 0.5 {
-		return lInfSensitivity - lambda*math.Log(2*partitionDelta)
+		return lInfSensitivity - lambda*math.Log(2*partitionDelta), nil
 	}
-	return lInfSensitivity + lambda*math.Log(2*(1-partitionDelta))
+	return lInfSensitivity + lambda*math.Log(2*(1-partitionDelta)), nil
 }
 
 // DeltaForThreshold is the inverse operation of Threshold: given the parameters
 // passed to AddNoise and a threshold, it returns the delta induced by
 // thresholding. Just like other functions for Laplace noise, it fails if
 // delta is non-zero.
-func (laplace) DeltaForThreshold(l0Sensitivity int64, lInfSensitivity, epsilon, delta, k float64) float64 {
-	if err := checkArgsLaplace("DeltaForThresholdedLaplace", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+func (l laplace) DeltaForThreshold(l0Sensitivity int64, lInfSensitivity, epsilon, delta, k float64) float64 {
+	d, err := l.DeltaForThresholdE(l0Sensitivity, lInfSensitivity, epsilon, delta, k)
+	if err != nil {
 		log.Fatalf("laplace.DeltaForThreshold(l0sensitivity %d, lInfSensitivity %f, epsilon %f, delta %e, k %f) checks failed with %v",
 			l0Sensitivity, lInfSensitivity, epsilon, delta, k, err)
 	}
+	return d
+}
+
+// DeltaForThresholdE is the same as DeltaForThreshold, but returns an error
+// instead of exiting the program if the parameters are invalid.
+func (laplace) DeltaForThresholdE(l0Sensitivity int64, lInfSensitivity, epsilon, delta, k float64) (float64, error) {
+	if err := checkArgsLaplace("DeltaForThresholdedLaplace", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return 0, err
+	}
 	lambda := laplaceLambda(l0Sensitivity, lInfSensitivity, epsilon)
 	var partitionDelta float64
 	if k >= lInfSensitivity {
@@ -152,9 +194,38 @@ func (laplace) DeltaForThreshold(l0Sensitivity int64, lInfSensitivity, epsilon,
 		// independence between coordinates. It has the advantage over the
 		// calculation below that uses independence between coordinates that it does
 		// not floating point lose precision as easily as the step 1-partitionDelta.
-		return math.Min(partitionDelta*float64(l0Sensitivity), 1)
+		return math.Min(partitionDelta*float64(l0Sensitivity), 1), nil
 	}
-	return 1 - math.Pow(1-partitionDelta, float64(l0Sensitivity))
+	return 1 - math.Pow(1-partitionDelta, float64(l0Sensitivity)), nil
+}
+
+// ComputeConfidenceIntervalFloat64 returns a confidence interval that contains
+// the raw value x with probability at least 1-alpha, given the output noisedX
+// of AddNoiseFloat64(x, l0Sensitivity, lInfSensitivity, epsilon, delta).
+func (l laplace) ComputeConfidenceIntervalFloat64(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) ConfidenceInterval {
+	confInt, err := l.ComputeConfidenceIntervalFloat64E(noisedX, l0Sensitivity, lInfSensitivity, epsilon, delta, alpha)
+	if err != nil {
+		log.Fatalf("laplace.ComputeConfidenceIntervalFloat64(l0sensitivity %d, lInfSensitivity %f, epsilon %f, delta %e, alpha %f) checks failed with %v",
+			l0Sensitivity, lInfSensitivity, epsilon, delta, alpha, err)
+	}
+	return confInt
+}
+
+// ComputeConfidenceIntervalFloat64E is the same as
+// ComputeConfidenceIntervalFloat64, but returns an error instead of exiting the
+// program if the parameters are invalid.
+func (laplace) ComputeConfidenceIntervalFloat64E(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) (ConfidenceInterval, error) {
+	if err := checkArgsLaplace("ComputeConfidenceIntervalFloat64 (Laplace)", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return ConfidenceInterval{}, err
+	}
+	if err := checks.CheckAlpha("ComputeConfidenceIntervalFloat64 (Laplace)", alpha); err != nil {
+		return ConfidenceInterval{}, err
+	}
+	l1Sensitivity := lInfSensitivity * float64(l0Sensitivity)
+	granularity := ceilPowerOfTwo((l1Sensitivity / epsilon) / granularityParam)
+	// See addLaplace for the distribution of the noise.
+	halfWidth := geometricHalfWidth((l1Sensitivity+granularity)/epsilon, granularity, alpha)
+	return symmetricConfidenceInterval(noisedX, halfWidth), nil
 }
 
 func checkArgsLaplace(label string, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) error {
@@ -171,10 +242,10 @@ func checkArgsLaplace(label string, l0Sensitivity int64, lInfSensitivity, epsilo
 }
 
 // addLaplace adds Laplace noise scaled to the given epsilon and l1Sensitivity to the
-// specified float64
-func addLaplace(x, epsilon, l1Sensitivity float64) float64 {
+// specified float64, drawing randomness from r.
+func addLaplace(r *rand.Rand, x, epsilon, l1Sensitivity float64) float64 {
 	granularity := ceilPowerOfTwo((l1Sensitivity / epsilon) / granularityParam)
-	sample := twoSidedGeometric(granularity * epsilon / (l1Sensitivity + granularity))
+	sample := twoSidedGeometric(r, granularity*epsilon/(l1Sensitivity+granularity))
 	return roundToMultipleOfPowerOfTwo(x, granularity) + float64(sample)*granularity
 }
 
@@ -194,9 +265,9 @@ func laplaceLambda(l0Sensitivity int64, lInfSensitivity, epsilon float64) float6
 //
 // Note that to ensure that a truncation happens with probability less than 10⁻⁶,
 // λ must be greater than 2⁻⁵⁹.
-func geometric(lambda float64) int64 {
+func geometric(r *rand.Rand, lambda float64) int64 {
 	// Return truncated sample in the case that the sample exceeds the max int64.
-	if rand.Uniform() > -1.0*math.Expm1(-1.0*lambda*math.MaxInt64) {
+	if r.Uniform() > -1.0*math.Expm1(-1.0*lambda*math.MaxInt64) {
 		return math.MaxInt64
 	}
 
@@ -228,7 +299,7 @@ func geometric(lambda float64) int64 {
 		//   q = Pr[X ≤ mid | left < X ≤ right]
 		// where X denotes the sample. The value of q should be approximately one half.
 		q := math.Expm1(lambda*float64(left-mid)) / math.Expm1(lambda*float64(left-right))
-		if rand.Uniform() <= q {
+		if r.Uniform() <= q {
 			right = mid
 		} else {
 			left = mid
@@ -241,14 +312,14 @@ func geometric(lambda float64) int64 {
 // mirrored at 0. The non-negative part of the distribution's PDF matches
 // the PDF of a geometric distribution of parameter p = 1 - e^-λ that is
 // shifted to the left by 1 and scaled accordingly.
-func twoSidedGeometric(lambda float64) int64 {
+func twoSidedGeometric(r *rand.Rand, lambda float64) int64 {
 	var sample int64 = 0
 	var sign int64 = -1
 	// Keep a sample of 0 only if the sign is positive. Otherwise, the
 	// probability of 0 would be twice as high as it should be.
 	for sample == 0 && sign == -1 {
-		sample = geometric(lambda) - 1
-		sign = int64(rand.Sign())
+		sample = geometric(r, lambda) - 1
+		sign = int64(r.Sign())
 	}
 	return sample * sign
 }
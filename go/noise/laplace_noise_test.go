@@ -20,6 +20,7 @@ import (
 	"math"
 	"testing"
 
+	"github.com/google/differential-privacy/go/rand"
 	"github.com/grd/stat"
 )
 
@@ -198,7 +199,7 @@ func TestGeometricStatistics(t *testing.T) {
 	} {
 		geometricSamples := make(stat.IntSlice, numberOfSamples)
 		for i := 0; i < numberOfSamples; i++ {
-			geometricSamples[i] = geometric(tc.lambda)
+			geometricSamples[i] = geometric(rand.Secure(), tc.lambda)
 		}
 		sampleMean := stat.Mean(geometricSamples)
 		// Assuming that the geometric samples have the specified mean tc.mean and the standard
@@ -19,6 +19,7 @@ package noise
 
 import (
 	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/rand"
 )
 
 // Kind is an enum type. Its values are the supported noise distributions types
@@ -29,6 +30,8 @@ type Kind int
 const (
 	GaussianNoise Kind = iota
 	LaplaceNoise
+	DiscreteGaussianNoise
+	DiscreteLaplaceNoise
 )
 
 // ToNoise converts a Kind into a Noise instance.
@@ -38,6 +41,10 @@ func ToNoise(k Kind) Noise {
 		return Gaussian()
 	case LaplaceNoise:
 		return Laplace()
+	case DiscreteGaussianNoise:
+		return DiscreteGaussian()
+	case DiscreteLaplaceNoise:
+		return DiscreteLaplace()
 	default:
 		log.Warningf("ToNoise: unknown kind (%v) specified", k)
 	}
@@ -46,17 +53,79 @@ func ToNoise(k Kind) Noise {
 
 // ToKind converts a Noise instance into a Kind.
 func ToKind(n Noise) Kind {
-	switch n {
-	case Gaussian():
+	switch n.(type) {
+	case gaussian:
 		return GaussianNoise
-	case Laplace():
+	case laplace:
 		return LaplaceNoise
+	case discreteGaussian:
+		return DiscreteGaussianNoise
+	case discreteLaplace:
+		return DiscreteLaplaceNoise
 	default:
 		log.Warningf("ToKind: unknown Noise (%v) specified", n)
 	}
 	return GaussianNoise
 }
 
+// randomness is embedded in the Noise instances of this package, and holds the
+// Rand from which they draw their noise. Its zero value draws from the
+// cryptographically secure source of the rand package.
+type randomness struct {
+	src rand.Source
+	r   *rand.Rand
+}
+
+func (rn randomness) random() *rand.Rand {
+	if rn.r == nil {
+		return rand.Secure()
+	}
+	return rn.r
+}
+
+// WithSource returns a Noise instance of the same kind as n that draws its
+// noise from src instead of the cryptographically secure source used by
+// default. If src is nil, it returns the default Noise instance of that kind.
+//
+// This is meant for tests that need reproducible results, with a source
+// returned by rand.NewDeterministicSource: the noise added is only
+// differentially private if src is cryptographically secure.
+func WithSource(n Noise, src rand.Source) Noise {
+	var rn randomness
+	if src != nil {
+		rn = randomness{src: src, r: rand.New(src)}
+	}
+	switch n.(type) {
+	case gaussian:
+		return gaussian{rn}
+	case laplace:
+		return laplace{rn}
+	case discreteGaussian:
+		return discreteGaussian{rn}
+	case discreteLaplace:
+		return discreteLaplace{rn}
+	default:
+		log.Warningf("WithSource: unknown Noise (%v) specified", n)
+	}
+	return n
+}
+
+// SourceOf returns the Source that n draws its noise from if it was created by
+// WithSource, and nil if n uses the default cryptographically secure source.
+func SourceOf(n Noise) rand.Source {
+	switch n := n.(type) {
+	case gaussian:
+		return n.src
+	case laplace:
+		return n.src
+	case discreteGaussian:
+		return n.src
+	case discreteLaplace:
+		return n.src
+	}
+	return nil
+}
+
 // Noise is an interface for primitives that add noise to data to make it differentially private.
 type Noise interface {
 	// AddNoiseInt64 noise to the specified int64 x so that the output is ε-differentially
@@ -100,4 +169,58 @@ type Noise interface {
 	// satisfies (epsilon,deltaNoise+deltaThreshold)-differential privacy under the
 	// given assumptions of L_0 and L_∞ sensitivities.
 	Threshold(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) float64
+
+	// ComputeConfidenceIntervalFloat64 returns a confidence interval that
+	// contains the raw value x with probability at least 1-alpha, given the
+	// output noisedX of AddNoiseFloat64(x, l0Sensitivity, lInfSensitivity,
+	// epsilon, delta). The interval only depends on noisedX and on public
+	// parameters, so computing it doesn't consume any privacy budget.
+	ComputeConfidenceIntervalFloat64(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) ConfidenceInterval
+}
+
+// NoiseE is implemented by the Noise instances of this package. Its methods are
+// the same as the methods of Noise, except that they return an error instead of
+// exiting the program if they are called with invalid parameters.
+type NoiseE interface {
+	Noise
+	AddNoiseInt64E(x, l0sensitivity, lInfSensitivity int64, epsilon, delta float64) (int64, error)
+	AddNoiseFloat64E(x float64, l0sensitivity int64, lInfSensitivity, epsilon, delta float64) (float64, error)
+	ThresholdE(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) (float64, error)
+	ComputeConfidenceIntervalFloat64E(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) (ConfidenceInterval, error)
+}
+
+// AddNoiseInt64E calls n.AddNoiseInt64E if n implements NoiseE, and
+// n.AddNoiseInt64 otherwise.
+func AddNoiseInt64E(n Noise, x, l0Sensitivity, lInfSensitivity int64, epsilon, delta float64) (int64, error) {
+	if ne, ok := n.(NoiseE); ok {
+		return ne.AddNoiseInt64E(x, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	}
+	return n.AddNoiseInt64(x, l0Sensitivity, lInfSensitivity, epsilon, delta), nil
+}
+
+// AddNoiseFloat64E calls n.AddNoiseFloat64E if n implements NoiseE, and
+// n.AddNoiseFloat64 otherwise.
+func AddNoiseFloat64E(n Noise, x float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) (float64, error) {
+	if ne, ok := n.(NoiseE); ok {
+		return ne.AddNoiseFloat64E(x, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	}
+	return n.AddNoiseFloat64(x, l0Sensitivity, lInfSensitivity, epsilon, delta), nil
+}
+
+// ThresholdE calls n.ThresholdE if n implements NoiseE, and n.Threshold
+// otherwise.
+func ThresholdE(n Noise, l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) (float64, error) {
+	if ne, ok := n.(NoiseE); ok {
+		return ne.ThresholdE(l0Sensitivity, lInfSensitivity, epsilon, deltaNoise, deltaThreshold)
+	}
+	return n.Threshold(l0Sensitivity, lInfSensitivity, epsilon, deltaNoise, deltaThreshold), nil
+}
+
+// ComputeConfidenceIntervalFloat64E calls n.ComputeConfidenceIntervalFloat64E
+// if n implements NoiseE, and n.ComputeConfidenceIntervalFloat64 otherwise.
+func ComputeConfidenceIntervalFloat64E(n Noise, noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) (ConfidenceInterval, error) {
+	if ne, ok := n.(NoiseE); ok {
+		return ne.ComputeConfidenceIntervalFloat64E(noisedX, l0Sensitivity, lInfSensitivity, epsilon, delta, alpha)
+	}
+	return n.ComputeConfidenceIntervalFloat64(noisedX, l0Sensitivity, lInfSensitivity, epsilon, delta, alpha), nil
 }
@@ -19,6 +19,8 @@ package noise
 import (
 	"math"
 	"testing"
+
+	"github.com/google/differential-privacy/go/rand"
 )
 
 var (
@@ -42,3 +44,91 @@ func BenchmarkLaplaceFloat64(b *testing.B) {
 	}
 	benchResultFloat64 = r
 }
+
+func TestNoiseEReturnsErrorForInvalidParameters(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		noise Noise
+		delta float64
+	}{
+		{"Laplace noise with non-zero delta", lap, 0.1},
+		{"Gaussian noise with zero delta", gauss, 0},
+		{"discrete Laplace noise with non-zero delta", DiscreteLaplace(), 0.1},
+		{"discrete Gaussian noise with zero delta", DiscreteGaussian(), 0},
+	} {
+		if _, err := AddNoiseFloat64E(tc.noise, 0, 1, 1, 1, tc.delta); err == nil {
+			t.Errorf("AddNoiseFloat64E: for %s got no error, want error", tc.desc)
+		}
+		if _, err := AddNoiseInt64E(tc.noise, 0, 1, 1, 1, tc.delta); err == nil {
+			t.Errorf("AddNoiseInt64E: for %s got no error, want error", tc.desc)
+		}
+		if _, err := AddNoiseFloat64E(tc.noise, 0, 1, 1, -1, 1e-5); err == nil {
+			t.Errorf("AddNoiseFloat64E: for %s with negative epsilon got no error, want error", tc.desc)
+		}
+	}
+}
+
+func TestNoiseEReturnsNoErrorForValidParameters(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		noise Noise
+		delta float64
+	}{
+		{"Laplace noise", lap, 0},
+		{"Gaussian noise", gauss, 1e-5},
+		{"discrete Laplace noise", DiscreteLaplace(), 0},
+		{"discrete Gaussian noise", DiscreteGaussian(), 1e-5},
+	} {
+		if _, err := AddNoiseFloat64E(tc.noise, 0, 1, 1, 1, tc.delta); err != nil {
+			t.Errorf("AddNoiseFloat64E: for %s got error %v", tc.desc, err)
+		}
+		if _, err := AddNoiseInt64E(tc.noise, 0, 1, 1, 1, tc.delta); err != nil {
+			t.Errorf("AddNoiseInt64E: for %s got error %v", tc.desc, err)
+		}
+		if _, err := ThresholdE(tc.noise, 1, 1, 1, tc.delta, 1e-5); err != nil {
+			t.Errorf("ThresholdE: for %s got error %v", tc.desc, err)
+		}
+	}
+}
+
+func TestToNoiseAndToKind(t *testing.T) {
+	for _, k := range []Kind{GaussianNoise, LaplaceNoise, DiscreteGaussianNoise, DiscreteLaplaceNoise} {
+		if got := ToKind(ToNoise(k)); got != k {
+			t.Errorf("ToKind(ToNoise(%v)): got %v, want %v", k, got, k)
+		}
+	}
+}
+
+func TestWithSourceIsReproducible(t *testing.T) {
+	for _, k := range []Kind{GaussianNoise, LaplaceNoise, DiscreteGaussianNoise, DiscreteLaplaceNoise} {
+		// Discrete Laplace noise fails with a non-zero delta.
+		delta := 1e-5
+		if k == LaplaceNoise || k == DiscreteLaplaceNoise {
+			delta = 0
+		}
+		n1 := WithSource(ToNoise(k), rand.NewDeterministicSource(42))
+		n2 := WithSource(ToNoise(k), rand.NewDeterministicSource(42))
+		if got := ToKind(n1); got != k {
+			t.Errorf("ToKind(WithSource(ToNoise(%v), src)): got %v, want %v", k, got, k)
+		}
+		for i := 0; i < 10; i++ {
+			got1, got2 := n1.AddNoiseFloat64(0, 1, 1, ln3, delta), n2.AddNoiseFloat64(0, 1, 1, ln3, delta)
+			if got1 != got2 {
+				t.Errorf("AddNoiseFloat64 with kind %v: got %f and %f for the same seed, want identical noise", k, got1, got2)
+			}
+		}
+	}
+}
+
+func TestSourceOf(t *testing.T) {
+	if src := SourceOf(Laplace()); src != nil {
+		t.Errorf("SourceOf(Laplace()): got %v, want nil", src)
+	}
+	src := rand.NewDeterministicSource(42)
+	if got := SourceOf(WithSource(Gaussian(), src)); got != src {
+		t.Errorf("SourceOf(WithSource(Gaussian(), src)): got %v, want %v", got, src)
+	}
+	if got := WithSource(WithSource(Gaussian(), src), nil); got != Gaussian() {
+		t.Errorf("WithSource(n, nil): got %v, want %v", got, Gaussian())
+	}
+}
@@ -0,0 +1,86 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package noise
+
+import "math"
+
+// The relative accuracy of the conversions between (ε,δ) and ρ for the
+// Gaussian mechanism.
+const zcdpAccuracy = 1e-12
+
+// A Gaussian mechanism with standard deviation σ, applied to a function with
+// l2 sensitivity s, is ρ-zero-concentrated differentially private (ρ-zCDP) for
+// ρ = s²/(2σ²) (see Bun and Steinke's "Concentrated Differential Privacy:
+// Simplifications, Extensions, and Lower Bounds", https://arxiv.org/abs/1605.02065).
+// The composition of Gaussian mechanisms with parameters ρ_1, …, ρ_k is exactly
+// a Gaussian mechanism with parameter ρ_1 + … + ρ_k, so ρ can be composed
+// additively, and the (ε,δ) guarantees of the composition can be computed
+// tightly with deltaForGaussian.
+//
+// Since σ grows linearly with s, these conversions don't depend on the
+// sensitivity of the function.
+
+// RhoForGaussian returns the ρ such that Gaussian noise calibrated with
+// SigmaForGaussian to be (ε,δ)-differentially private is ρ-zCDP. The returned
+// ρ is rounded up, so that it is valid for any sensitivity.
+func RhoForGaussian(epsilon, delta float64) float64 {
+	if delta >= 1 {
+		return math.Inf(1)
+	}
+	// SigmaForGaussian returns a σ that is at least σ_tight, so using a lower
+	// bound of σ_tight gives an upper bound of ρ.
+	sigma, _ := sigmaBoundsForGaussian(1, 1, epsilon, delta, zcdpAccuracy)
+	return 1 / (2 * sigma * sigma)
+}
+
+// DeltaForGaussianRho returns the smallest δ such that a ρ-zCDP Gaussian
+// mechanism is (ε,δ)-differentially private.
+func DeltaForGaussianRho(rho, epsilon float64) float64 {
+	if rho == 0 {
+		return 0
+	}
+	return deltaForGaussian(1/math.Sqrt(2*rho), 1, 1, epsilon)
+}
+
+// EpsilonForGaussianRho returns the smallest ε such that a ρ-zCDP Gaussian
+// mechanism is (ε,δ)-differentially private. The returned ε is rounded up, so
+// that the guarantee always holds.
+//
+// EpsilonForGaussianRho uses binary search on DeltaForGaussianRho, which is a
+// decreasing function of ε.
+func EpsilonForGaussianRho(rho, delta float64) float64 {
+	if rho == 0 || delta >= 1 {
+		return 0
+	}
+	if DeltaForGaussianRho(rho, 0) <= delta {
+		return 0
+	}
+	lowerBound, upperBound := 0.0, 1.0
+	for DeltaForGaussianRho(rho, upperBound) > delta {
+		lowerBound = upperBound
+		upperBound *= 2
+	}
+	for upperBound-lowerBound > zcdpAccuracy*upperBound {
+		middle := lowerBound*0.5 + upperBound*0.5
+		if DeltaForGaussianRho(rho, middle) > delta {
+			lowerBound = middle
+		} else {
+			upperBound = middle
+		}
+	}
+	return upperBound
+}
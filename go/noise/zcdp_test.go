@@ -0,0 +1,77 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package noise
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRhoForGaussian(t *testing.T) {
+	for _, tc := range []struct {
+		epsilon, delta float64
+	}{
+		{1, 1e-5},
+		{0.1, 1e-10},
+		{5, 0.01},
+	} {
+		rho := RhoForGaussian(tc.epsilon, tc.delta)
+		// Gaussian noise calibrated with SigmaForGaussian is at most as noisy as
+		// the noise of ρ-zCDP with the same sensitivity.
+		for _, l2Sensitivity := range []float64{1, 3.5} {
+			sigma := SigmaForGaussian(1, l2Sensitivity, tc.epsilon, tc.delta)
+			if got := l2Sensitivity * l2Sensitivity / (2 * sigma * sigma); got > rho || got < rho*(1-3*gaussianSigmaAccuracy) {
+				t.Errorf("RhoForGaussian(%f, %e): got ρ=%e, want ρ of SigmaForGaussian with l2 sensitivity %f=%e", tc.epsilon, tc.delta, rho, l2Sensitivity, got)
+			}
+		}
+	}
+}
+
+func TestEpsilonForGaussianRho(t *testing.T) {
+	for _, tc := range []struct {
+		epsilon, delta float64
+	}{
+		{1, 1e-5},
+		{0.1, 1e-10},
+		{5, 0.01},
+	} {
+		rho := RhoForGaussian(tc.epsilon, tc.delta)
+		if got := EpsilonForGaussianRho(rho, tc.delta); math.Abs(got-tc.epsilon) > 1e-9 {
+			t.Errorf("EpsilonForGaussianRho(RhoForGaussian(%f, %e), %e): got %f, want %f", tc.epsilon, tc.delta, tc.delta, got, tc.epsilon)
+		}
+		if got := DeltaForGaussianRho(rho, tc.epsilon); math.Abs(got-tc.delta) > 1e-9*tc.delta {
+			t.Errorf("DeltaForGaussianRho(RhoForGaussian(%f, %e), %f): got %e, want %e", tc.epsilon, tc.delta, tc.epsilon, got, tc.delta)
+		}
+	}
+	if got := EpsilonForGaussianRho(0, 1e-5); got != 0 {
+		t.Errorf("EpsilonForGaussianRho(0, 1e-5): got %f, want 0", got)
+	}
+	if got := EpsilonForGaussianRho(1e-3, 0.5); got != 0 {
+		t.Errorf("EpsilonForGaussianRho(1e-3, 0.5): got %f, want 0", got)
+	}
+}
+
+// Checks that composing Gaussian mechanisms in zCDP gives a smaller ε than
+// adding their ε.
+func TestGaussianRhoComposition(t *testing.T) {
+	const numMechanisms = 10
+	rho := numMechanisms * RhoForGaussian(0.1, 1e-6)
+	got := EpsilonForGaussianRho(rho, numMechanisms*1e-6)
+	if got >= numMechanisms*0.1 {
+		t.Errorf("EpsilonForGaussianRho: got ε=%f for %d composed (0.1, 1e-6) Gaussian mechanisms, want less than %f", got, numMechanisms, numMechanisms*0.1)
+	}
+}
@@ -0,0 +1,582 @@
+//
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// This file defines the input/output data format accepted by the differentially
+// private algorithms.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        (unknown)
+// source: proto/data.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Defining our own value type to restrict the acceptable data types.
+// It would change as per the future extensions.
+type ValueType struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Value:
+	//	*ValueType_IntValue
+	//	*ValueType_FloatValue
+	//	*ValueType_StringValue
+	Value isValueType_Value `protobuf_oneof:"value"`
+}
+
+func (x *ValueType) Reset() {
+	*x = ValueType{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_data_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ValueType) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValueType) ProtoMessage() {}
+
+func (x *ValueType) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_data_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValueType.ProtoReflect.Descriptor instead.
+func (*ValueType) Descriptor() ([]byte, []int) {
+	return file_proto_data_proto_rawDescGZIP(), []int{0}
+}
+
+func (m *ValueType) GetValue() isValueType_Value {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (x *ValueType) GetIntValue() int64 {
+	if x, ok := x.GetValue().(*ValueType_IntValue); ok {
+		return x.IntValue
+	}
+	return 0
+}
+
+func (x *ValueType) GetFloatValue() float64 {
+	if x, ok := x.GetValue().(*ValueType_FloatValue); ok {
+		return x.FloatValue
+	}
+	return 0
+}
+
+func (x *ValueType) GetStringValue() string {
+	if x, ok := x.GetValue().(*ValueType_StringValue); ok {
+		return x.StringValue
+	}
+	return ""
+}
+
+type isValueType_Value interface {
+	isValueType_Value()
+}
+
+type ValueType_IntValue struct {
+	IntValue int64 `protobuf:"varint,1,opt,name=int_value,json=intValue,oneof"`
+}
+
+type ValueType_FloatValue struct {
+	FloatValue float64 `protobuf:"fixed64,2,opt,name=float_value,json=floatValue,oneof"`
+}
+
+type ValueType_StringValue struct {
+	StringValue string `protobuf:"bytes,3,opt,name=string_value,json=stringValue,oneof"`
+}
+
+func (*ValueType_IntValue) isValueType_Value() {}
+
+func (*ValueType_FloatValue) isValueType_Value() {}
+
+func (*ValueType_StringValue) isValueType_Value() {}
+
+// Output data produced by a differentially private algorithm.
+type Output struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Elements []*Output_Element `protobuf:"bytes,1,rep,name=elements" json:"elements,omitempty"`
+	// Error report is attached if either the noise confidence interval or the
+	// bounding report is available.
+	ErrorReport *Output_ErrorReport `protobuf:"bytes,3,opt,name=error_report,json=errorReport" json:"error_report,omitempty"`
+}
+
+func (x *Output) Reset() {
+	*x = Output{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_data_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Output) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Output) ProtoMessage() {}
+
+func (x *Output) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_data_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Output.ProtoReflect.Descriptor instead.
+func (*Output) Descriptor() ([]byte, []int) {
+	return file_proto_data_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Output) GetElements() []*Output_Element {
+	if x != nil {
+		return x.Elements
+	}
+	return nil
+}
+
+func (x *Output) GetErrorReport() *Output_ErrorReport {
+	if x != nil {
+		return x.ErrorReport
+	}
+	return nil
+}
+
+// Accuracy information about results of automatic bounding algorithms.
+// When ApproxBounds is called by bounded algorithms, the BoundingReport can
+// be used to pass differentially private intermediate results to help users
+// understand the accuracy implications of the output.
+type BoundingReport struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Lower and upper bounds produced by the ApproxBounds algorithm.
+	LowerBound *ValueType `protobuf:"bytes,1,opt,name=lower_bound,json=lowerBound" json:"lower_bound,omitempty"`
+	UpperBound *ValueType `protobuf:"bytes,2,opt,name=upper_bound,json=upperBound" json:"upper_bound,omitempty"`
+	// Noisy number of total inputs to the bounding algorithm.
+	NumInputs *float64 `protobuf:"fixed64,3,opt,name=num_inputs,json=numInputs" json:"num_inputs,omitempty"`
+	// Noisy number of inputs lying outside the bounds.
+	NumOutside *float64 `protobuf:"fixed64,4,opt,name=num_outside,json=numOutside" json:"num_outside,omitempty"`
+}
+
+func (x *BoundingReport) Reset() {
+	*x = BoundingReport{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_data_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BoundingReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BoundingReport) ProtoMessage() {}
+
+func (x *BoundingReport) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_data_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BoundingReport.ProtoReflect.Descriptor instead.
+func (*BoundingReport) Descriptor() ([]byte, []int) {
+	return file_proto_data_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BoundingReport) GetLowerBound() *ValueType {
+	if x != nil {
+		return x.LowerBound
+	}
+	return nil
+}
+
+func (x *BoundingReport) GetUpperBound() *ValueType {
+	if x != nil {
+		return x.UpperBound
+	}
+	return nil
+}
+
+func (x *BoundingReport) GetNumInputs() float64 {
+	if x != nil && x.NumInputs != nil {
+		return *x.NumInputs
+	}
+	return 0
+}
+
+func (x *BoundingReport) GetNumOutside() float64 {
+	if x != nil && x.NumOutside != nil {
+		return *x.NumOutside
+	}
+	return 0
+}
+
+type Output_Element struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Diff. priv. result of the operation performed over the input data.
+	Value *ValueType `protobuf:"bytes,1,opt,name=value" json:"value,omitempty"`
+	// Approximated error in the result.
+	Error *ValueType `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (x *Output_Element) Reset() {
+	*x = Output_Element{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_data_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Output_Element) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Output_Element) ProtoMessage() {}
+
+func (x *Output_Element) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_data_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Output_Element.ProtoReflect.Descriptor instead.
+func (*Output_Element) Descriptor() ([]byte, []int) {
+	return file_proto_data_proto_rawDescGZIP(), []int{1, 0}
+}
+
+func (x *Output_Element) GetValue() *ValueType {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Output_Element) GetError() *ValueType {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+// Contains information about algorithm accuracy.
+type Output_ErrorReport struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	NoiseConfidenceInterval *ConfidenceInterval `protobuf:"bytes,1,opt,name=noise_confidence_interval,json=noiseConfidenceInterval" json:"noise_confidence_interval,omitempty"`
+	BoundingReport          *BoundingReport     `protobuf:"bytes,2,opt,name=bounding_report,json=boundingReport" json:"bounding_report,omitempty"`
+}
+
+func (x *Output_ErrorReport) Reset() {
+	*x = Output_ErrorReport{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_data_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Output_ErrorReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Output_ErrorReport) ProtoMessage() {}
+
+func (x *Output_ErrorReport) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_data_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Output_ErrorReport.ProtoReflect.Descriptor instead.
+func (*Output_ErrorReport) Descriptor() ([]byte, []int) {
+	return file_proto_data_proto_rawDescGZIP(), []int{1, 1}
+}
+
+func (x *Output_ErrorReport) GetNoiseConfidenceInterval() *ConfidenceInterval {
+	if x != nil {
+		return x.NoiseConfidenceInterval
+	}
+	return nil
+}
+
+func (x *Output_ErrorReport) GetBoundingReport() *BoundingReport {
+	if x != nil {
+		return x.BoundingReport
+	}
+	return nil
+}
+
+var File_proto_data_proto protoreflect.FileDescriptor
+
+var file_proto_data_proto_rawDesc = []byte{
+	0x0a, 0x10, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x64, 0x61, 0x74, 0x61, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x12, 0x14, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c,
+	0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x1a, 0x1f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f,
+	0x63, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x2d, 0x69, 0x6e, 0x74, 0x65, 0x72,
+	0x76, 0x61, 0x6c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x7b, 0x0a, 0x09, 0x56, 0x61, 0x6c,
+	0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1d, 0x0a, 0x09, 0x69, 0x6e, 0x74, 0x5f, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x48, 0x00, 0x52, 0x08, 0x69, 0x6e, 0x74,
+	0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x21, 0x0a, 0x0b, 0x66, 0x6c, 0x6f, 0x61, 0x74, 0x5f, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x0a, 0x66, 0x6c,
+	0x6f, 0x61, 0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x23, 0x0a, 0x0c, 0x73, 0x74, 0x72, 0x69,
+	0x6e, 0x67, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00,
+	0x52, 0x0b, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x07, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0xdb, 0x03, 0x0a, 0x06, 0x4f, 0x75, 0x74, 0x70, 0x75,
+	0x74, 0x12, 0x40, 0x0a, 0x08, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69,
+	0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x2e, 0x4f, 0x75, 0x74, 0x70, 0x75,
+	0x74, 0x2e, 0x45, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x08, 0x65, 0x6c, 0x65, 0x6d, 0x65,
+	0x6e, 0x74, 0x73, 0x12, 0x4b, 0x0a, 0x0c, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x5f, 0x72, 0x65, 0x70,
+	0x6f, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x64, 0x69, 0x66, 0x66,
+	0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79,
+	0x2e, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x65, 0x70,
+	0x6f, 0x72, 0x74, 0x52, 0x0b, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74,
+	0x1a, 0x77, 0x0a, 0x07, 0x45, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x35, 0x0a, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x64, 0x69, 0x66,
+	0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63,
+	0x79, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x12, 0x35, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1f, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c,
+	0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79,
+	0x70, 0x65, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x1a, 0xc2, 0x01, 0x0a, 0x0b, 0x45, 0x72,
+	0x72, 0x6f, 0x72, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x64, 0x0a, 0x19, 0x6e, 0x6f, 0x69,
+	0x73, 0x65, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x5f, 0x69, 0x6e,
+	0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x64,
+	0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76,
+	0x61, 0x63, 0x79, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x49, 0x6e,
+	0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x52, 0x17, 0x6e, 0x6f, 0x69, 0x73, 0x65, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x12,
+	0x4d, 0x0a, 0x0f, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x5f, 0x72, 0x65, 0x70, 0x6f,
+	0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65,
+	0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x2e,
+	0x42, 0x6f, 0x75, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x0e,
+	0x62, 0x6f, 0x75, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x4a, 0x04,
+	0x08, 0x02, 0x10, 0x03, 0x22, 0xd4, 0x01, 0x0a, 0x0e, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x69, 0x6e,
+	0x67, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x40, 0x0a, 0x0b, 0x6c, 0x6f, 0x77, 0x65, 0x72,
+	0x5f, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x64,
+	0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76,
+	0x61, 0x63, 0x79, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x0a, 0x6c,
+	0x6f, 0x77, 0x65, 0x72, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x12, 0x40, 0x0a, 0x0b, 0x75, 0x70, 0x70,
+	0x65, 0x72, 0x5f, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f,
+	0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72,
+	0x69, 0x76, 0x61, 0x63, 0x79, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52,
+	0x0a, 0x75, 0x70, 0x70, 0x65, 0x72, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x6e,
+	0x75, 0x6d, 0x5f, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x09, 0x6e, 0x75, 0x6d, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x6e, 0x75,
+	0x6d, 0x5f, 0x6f, 0x75, 0x74, 0x73, 0x69, 0x64, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x0a, 0x6e, 0x75, 0x6d, 0x4f, 0x75, 0x74, 0x73, 0x69, 0x64, 0x65, 0x42, 0x54, 0x0a, 0x1e, 0x63,
+	0x6f, 0x6d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72,
+	0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x5a, 0x32, 0x67,
+	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2f, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x2d, 0x70, 0x72,
+	0x69, 0x76, 0x61, 0x63, 0x79, 0x2f, 0x67, 0x6f, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x3b, 0x70,
+	0x62,
+}
+
+var (
+	file_proto_data_proto_rawDescOnce sync.Once
+	file_proto_data_proto_rawDescData = file_proto_data_proto_rawDesc
+)
+
+func file_proto_data_proto_rawDescGZIP() []byte {
+	file_proto_data_proto_rawDescOnce.Do(func() {
+		file_proto_data_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_data_proto_rawDescData)
+	})
+	return file_proto_data_proto_rawDescData
+}
+
+var file_proto_data_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_proto_data_proto_goTypes = []interface{}{
+	(*ValueType)(nil),          // 0: differential_privacy.ValueType
+	(*Output)(nil),             // 1: differential_privacy.Output
+	(*BoundingReport)(nil),     // 2: differential_privacy.BoundingReport
+	(*Output_Element)(nil),     // 3: differential_privacy.Output.Element
+	(*Output_ErrorReport)(nil), // 4: differential_privacy.Output.ErrorReport
+	(*ConfidenceInterval)(nil), // 5: differential_privacy.ConfidenceInterval
+}
+var file_proto_data_proto_depIdxs = []int32{
+	3, // 0: differential_privacy.Output.elements:type_name -> differential_privacy.Output.Element
+	4, // 1: differential_privacy.Output.error_report:type_name -> differential_privacy.Output.ErrorReport
+	0, // 2: differential_privacy.BoundingReport.lower_bound:type_name -> differential_privacy.ValueType
+	0, // 3: differential_privacy.BoundingReport.upper_bound:type_name -> differential_privacy.ValueType
+	0, // 4: differential_privacy.Output.Element.value:type_name -> differential_privacy.ValueType
+	0, // 5: differential_privacy.Output.Element.error:type_name -> differential_privacy.ValueType
+	5, // 6: differential_privacy.Output.ErrorReport.noise_confidence_interval:type_name -> differential_privacy.ConfidenceInterval
+	2, // 7: differential_privacy.Output.ErrorReport.bounding_report:type_name -> differential_privacy.BoundingReport
+	8, // [8:8] is the sub-list for method output_type
+	8, // [8:8] is the sub-list for method input_type
+	8, // [8:8] is the sub-list for extension type_name
+	8, // [8:8] is the sub-list for extension extendee
+	0, // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_proto_data_proto_init() }
+func file_proto_data_proto_init() {
+	if File_proto_data_proto != nil {
+		return
+	}
+	file_proto_confidence_interval_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_proto_data_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ValueType); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_data_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Output); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_data_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BoundingReport); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_data_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Output_Element); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_data_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Output_ErrorReport); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_proto_data_proto_msgTypes[0].OneofWrappers = []interface{}{
+		(*ValueType_IntValue)(nil),
+		(*ValueType_FloatValue)(nil),
+		(*ValueType_StringValue)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proto_data_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_proto_data_proto_goTypes,
+		DependencyIndexes: file_proto_data_proto_depIdxs,
+		MessageInfos:      file_proto_data_proto_msgTypes,
+	}.Build()
+	File_proto_data_proto = out.File
+	file_proto_data_proto_rawDesc = nil
+	file_proto_data_proto_goTypes = nil
+	file_proto_data_proto_depIdxs = nil
+}
@@ -0,0 +1,28 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package pb contains the Go code generated from the protocol buffers shared by
+// the C++, Go and Java differential privacy libraries, e.g. the Summary
+// messages that partial aggregations are serialized to.
+//
+// The generated *.pb.go files are checked in, so that the package can be built
+// with the go tool. Bazel generates the code from the definitions in the
+// top-level proto directory instead. After changing these definitions, run go
+// generate in this directory to update the checked-in files; this requires
+// protoc and protoc-gen-go.
+package pb
+
+//go:generate protoc --proto_path=../.. --go_out=. --go_opt=module=github.com/google/differential-privacy/go/proto proto/confidence-interval.proto proto/data.proto proto/summary.proto
@@ -0,0 +1,1062 @@
+//
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// This file defines the summary data format for the count algorithm.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        (unknown)
+// source: proto/summary.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type MechanismType int32
+
+const (
+	MechanismType_EMPTY    MechanismType = 0
+	MechanismType_LAPLACE  MechanismType = 1
+	MechanismType_GAUSSIAN MechanismType = 2
+)
+
+// Enum value maps for MechanismType.
+var (
+	MechanismType_name = map[int32]string{
+		0: "EMPTY",
+		1: "LAPLACE",
+		2: "GAUSSIAN",
+	}
+	MechanismType_value = map[string]int32{
+		"EMPTY":    0,
+		"LAPLACE":  1,
+		"GAUSSIAN": 2,
+	}
+)
+
+func (x MechanismType) Enum() *MechanismType {
+	p := new(MechanismType)
+	*p = x
+	return p
+}
+
+func (x MechanismType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MechanismType) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_summary_proto_enumTypes[0].Descriptor()
+}
+
+func (MechanismType) Type() protoreflect.EnumType {
+	return &file_proto_summary_proto_enumTypes[0]
+}
+
+func (x MechanismType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *MechanismType) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = MechanismType(num)
+	return nil
+}
+
+// Deprecated: Use MechanismType.Descriptor instead.
+func (MechanismType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_summary_proto_rawDescGZIP(), []int{0}
+}
+
+// Serialized summary data of a subset of the input data, to be merged at a
+// later time.
+type Summary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The summary data.
+	Data *anypb.Any `protobuf:"bytes,2,opt,name=data" json:"data,omitempty"`
+}
+
+func (x *Summary) Reset() {
+	*x = Summary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_summary_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Summary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Summary) ProtoMessage() {}
+
+func (x *Summary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_summary_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Summary.ProtoReflect.Descriptor instead.
+func (*Summary) Descriptor() ([]byte, []int) {
+	return file_proto_summary_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Summary) GetData() *anypb.Any {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type CountSummary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Count of the data subset.
+	Count *int64 `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
+	// TODO: Use below fields in C++ library.
+	// Count parameters:
+	Epsilon                      *float64       `protobuf:"fixed64,3,opt,name=epsilon" json:"epsilon,omitempty"`
+	Delta                        *float64       `protobuf:"fixed64,4,opt,name=delta" json:"delta,omitempty"`
+	MechanismType                *MechanismType `protobuf:"varint,5,opt,name=mechanism_type,json=mechanismType,enum=differential_privacy.MechanismType" json:"mechanism_type,omitempty"`
+	MaxPartitionsContributed     *int32         `protobuf:"varint,6,opt,name=max_partitions_contributed,json=maxPartitionsContributed" json:"max_partitions_contributed,omitempty"`
+	MaxContributionsPerPartition *int32         `protobuf:"varint,7,opt,name=max_contributions_per_partition,json=maxContributionsPerPartition" json:"max_contributions_per_partition,omitempty"`
+}
+
+func (x *CountSummary) Reset() {
+	*x = CountSummary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_summary_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CountSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountSummary) ProtoMessage() {}
+
+func (x *CountSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_summary_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountSummary.ProtoReflect.Descriptor instead.
+func (*CountSummary) Descriptor() ([]byte, []int) {
+	return file_proto_summary_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CountSummary) GetCount() int64 {
+	if x != nil && x.Count != nil {
+		return *x.Count
+	}
+	return 0
+}
+
+func (x *CountSummary) GetEpsilon() float64 {
+	if x != nil && x.Epsilon != nil {
+		return *x.Epsilon
+	}
+	return 0
+}
+
+func (x *CountSummary) GetDelta() float64 {
+	if x != nil && x.Delta != nil {
+		return *x.Delta
+	}
+	return 0
+}
+
+func (x *CountSummary) GetMechanismType() MechanismType {
+	if x != nil && x.MechanismType != nil {
+		return *x.MechanismType
+	}
+	return MechanismType_EMPTY
+}
+
+func (x *CountSummary) GetMaxPartitionsContributed() int32 {
+	if x != nil && x.MaxPartitionsContributed != nil {
+		return *x.MaxPartitionsContributed
+	}
+	return 0
+}
+
+func (x *CountSummary) GetMaxContributionsPerPartition() int32 {
+	if x != nil && x.MaxContributionsPerPartition != nil {
+		return *x.MaxContributionsPerPartition
+	}
+	return 0
+}
+
+type BoundedSumSummary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Partial sum data for the dataset. For automatically set bounds, partial
+	// sum values are stored corresponding to each ApproxBounds bin.
+	// For manually set bounds, clamped sum will be stored in pos_sum.
+	// Currently, used only by C++ library.
+	PosSum []*ValueType `protobuf:"bytes,1,rep,name=pos_sum,json=posSum" json:"pos_sum,omitempty"`
+	// neg_sum is used only when bounds are determined automatically.
+	NegSum []*ValueType `protobuf:"bytes,2,rep,name=neg_sum,json=negSum" json:"neg_sum,omitempty"`
+	// ApproxBounds data if available.
+	BoundsSummary *ApproxBoundsSummary `protobuf:"bytes,3,opt,name=bounds_summary,json=boundsSummary" json:"bounds_summary,omitempty"`
+	// partial_sum is used by the Java library to store partial sum.
+	// TODO: Use partial_sum in C++ library
+	//  when bounds are set manually.
+	PartialSum *ValueType `protobuf:"bytes,4,opt,name=partial_sum,json=partialSum" json:"partial_sum,omitempty"`
+	// TODO: Use below fields in C++ library.
+	// partial_sum is used by Java library to store sum
+	// Sum parameters:
+	Epsilon                      *float64       `protobuf:"fixed64,5,opt,name=epsilon" json:"epsilon,omitempty"`
+	Delta                        *float64       `protobuf:"fixed64,6,opt,name=delta" json:"delta,omitempty"`
+	MechanismType                *MechanismType `protobuf:"varint,7,opt,name=mechanism_type,json=mechanismType,enum=differential_privacy.MechanismType" json:"mechanism_type,omitempty"`
+	Lower                        *float64       `protobuf:"fixed64,8,opt,name=lower" json:"lower,omitempty"`
+	Upper                        *float64       `protobuf:"fixed64,9,opt,name=upper" json:"upper,omitempty"`
+	MaxPartitionsContributed     *int32         `protobuf:"varint,10,opt,name=max_partitions_contributed,json=maxPartitionsContributed" json:"max_partitions_contributed,omitempty"`
+	MaxContributionsPerPartition *int32         `protobuf:"varint,11,opt,name=max_contributions_per_partition,json=maxContributionsPerPartition" json:"max_contributions_per_partition,omitempty"`
+}
+
+func (x *BoundedSumSummary) Reset() {
+	*x = BoundedSumSummary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_summary_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BoundedSumSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BoundedSumSummary) ProtoMessage() {}
+
+func (x *BoundedSumSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_summary_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BoundedSumSummary.ProtoReflect.Descriptor instead.
+func (*BoundedSumSummary) Descriptor() ([]byte, []int) {
+	return file_proto_summary_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BoundedSumSummary) GetPosSum() []*ValueType {
+	if x != nil {
+		return x.PosSum
+	}
+	return nil
+}
+
+func (x *BoundedSumSummary) GetNegSum() []*ValueType {
+	if x != nil {
+		return x.NegSum
+	}
+	return nil
+}
+
+func (x *BoundedSumSummary) GetBoundsSummary() *ApproxBoundsSummary {
+	if x != nil {
+		return x.BoundsSummary
+	}
+	return nil
+}
+
+func (x *BoundedSumSummary) GetPartialSum() *ValueType {
+	if x != nil {
+		return x.PartialSum
+	}
+	return nil
+}
+
+func (x *BoundedSumSummary) GetEpsilon() float64 {
+	if x != nil && x.Epsilon != nil {
+		return *x.Epsilon
+	}
+	return 0
+}
+
+func (x *BoundedSumSummary) GetDelta() float64 {
+	if x != nil && x.Delta != nil {
+		return *x.Delta
+	}
+	return 0
+}
+
+func (x *BoundedSumSummary) GetMechanismType() MechanismType {
+	if x != nil && x.MechanismType != nil {
+		return *x.MechanismType
+	}
+	return MechanismType_EMPTY
+}
+
+func (x *BoundedSumSummary) GetLower() float64 {
+	if x != nil && x.Lower != nil {
+		return *x.Lower
+	}
+	return 0
+}
+
+func (x *BoundedSumSummary) GetUpper() float64 {
+	if x != nil && x.Upper != nil {
+		return *x.Upper
+	}
+	return 0
+}
+
+func (x *BoundedSumSummary) GetMaxPartitionsContributed() int32 {
+	if x != nil && x.MaxPartitionsContributed != nil {
+		return *x.MaxPartitionsContributed
+	}
+	return 0
+}
+
+func (x *BoundedSumSummary) GetMaxContributionsPerPartition() int32 {
+	if x != nil && x.MaxContributionsPerPartition != nil {
+		return *x.MaxContributionsPerPartition
+	}
+	return 0
+}
+
+type BoundedMeanSummary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Count of the data subset.
+	Count *int64 `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
+	// Partial sum data for the dataset.
+	PosSum []*ValueType `protobuf:"bytes,2,rep,name=pos_sum,json=posSum" json:"pos_sum,omitempty"`
+	NegSum []*ValueType `protobuf:"bytes,3,rep,name=neg_sum,json=negSum" json:"neg_sum,omitempty"`
+	// ApproxBounds data if available.
+	BoundsSummary *ApproxBoundsSummary `protobuf:"bytes,4,opt,name=bounds_summary,json=boundsSummary" json:"bounds_summary,omitempty"`
+}
+
+func (x *BoundedMeanSummary) Reset() {
+	*x = BoundedMeanSummary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_summary_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BoundedMeanSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BoundedMeanSummary) ProtoMessage() {}
+
+func (x *BoundedMeanSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_summary_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BoundedMeanSummary.ProtoReflect.Descriptor instead.
+func (*BoundedMeanSummary) Descriptor() ([]byte, []int) {
+	return file_proto_summary_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BoundedMeanSummary) GetCount() int64 {
+	if x != nil && x.Count != nil {
+		return *x.Count
+	}
+	return 0
+}
+
+func (x *BoundedMeanSummary) GetPosSum() []*ValueType {
+	if x != nil {
+		return x.PosSum
+	}
+	return nil
+}
+
+func (x *BoundedMeanSummary) GetNegSum() []*ValueType {
+	if x != nil {
+		return x.NegSum
+	}
+	return nil
+}
+
+func (x *BoundedMeanSummary) GetBoundsSummary() *ApproxBoundsSummary {
+	if x != nil {
+		return x.BoundsSummary
+	}
+	return nil
+}
+
+// Used for BoundedVariance and BoundedStandardDeviation algorithms.
+type BoundedVarianceSummary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Count of the dataset.
+	Count *int64 `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
+	// Partial sum data for the dataset. For manually set bounds, the clamped sum
+	// will be stored in pos_sum. For automatically set bounds, partial sum values
+	// stored corresponding to each ApproxBounds bin.
+	PosSum []*ValueType `protobuf:"bytes,2,rep,name=pos_sum,json=posSum" json:"pos_sum,omitempty"`
+	NegSum []*ValueType `protobuf:"bytes,3,rep,name=neg_sum,json=negSum" json:"neg_sum,omitempty"`
+	// Partial sum of squares for the dataset. For manually set bounds, clamped
+	// sum of squares is stored in pos_sum_of_squares.
+	PosSumOfSquares []float64 `protobuf:"fixed64,4,rep,name=pos_sum_of_squares,json=posSumOfSquares" json:"pos_sum_of_squares,omitempty"`
+	NegSumOfSquares []float64 `protobuf:"fixed64,5,rep,name=neg_sum_of_squares,json=negSumOfSquares" json:"neg_sum_of_squares,omitempty"`
+	// ApproxBounds data if available.
+	BoundsSummary *ApproxBoundsSummary `protobuf:"bytes,6,opt,name=bounds_summary,json=boundsSummary" json:"bounds_summary,omitempty"`
+}
+
+func (x *BoundedVarianceSummary) Reset() {
+	*x = BoundedVarianceSummary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_summary_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BoundedVarianceSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BoundedVarianceSummary) ProtoMessage() {}
+
+func (x *BoundedVarianceSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_summary_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BoundedVarianceSummary.ProtoReflect.Descriptor instead.
+func (*BoundedVarianceSummary) Descriptor() ([]byte, []int) {
+	return file_proto_summary_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BoundedVarianceSummary) GetCount() int64 {
+	if x != nil && x.Count != nil {
+		return *x.Count
+	}
+	return 0
+}
+
+func (x *BoundedVarianceSummary) GetPosSum() []*ValueType {
+	if x != nil {
+		return x.PosSum
+	}
+	return nil
+}
+
+func (x *BoundedVarianceSummary) GetNegSum() []*ValueType {
+	if x != nil {
+		return x.NegSum
+	}
+	return nil
+}
+
+func (x *BoundedVarianceSummary) GetPosSumOfSquares() []float64 {
+	if x != nil {
+		return x.PosSumOfSquares
+	}
+	return nil
+}
+
+func (x *BoundedVarianceSummary) GetNegSumOfSquares() []float64 {
+	if x != nil {
+		return x.NegSumOfSquares
+	}
+	return nil
+}
+
+func (x *BoundedVarianceSummary) GetBoundsSummary() *ApproxBoundsSummary {
+	if x != nil {
+		return x.BoundsSummary
+	}
+	return nil
+}
+
+type Elements struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Element []string `protobuf:"bytes,1,rep,name=element" json:"element,omitempty"`
+}
+
+func (x *Elements) Reset() {
+	*x = Elements{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_summary_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Elements) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Elements) ProtoMessage() {}
+
+func (x *Elements) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_summary_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Elements.ProtoReflect.Descriptor instead.
+func (*Elements) Descriptor() ([]byte, []int) {
+	return file_proto_summary_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Elements) GetElement() []string {
+	if x != nil {
+		return x.Element
+	}
+	return nil
+}
+
+type HistogramSummary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BinCount []int64 `protobuf:"varint,1,rep,name=bin_count,json=binCount" json:"bin_count,omitempty"`
+}
+
+func (x *HistogramSummary) Reset() {
+	*x = HistogramSummary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_summary_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HistogramSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistogramSummary) ProtoMessage() {}
+
+func (x *HistogramSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_summary_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistogramSummary.ProtoReflect.Descriptor instead.
+func (*HistogramSummary) Descriptor() ([]byte, []int) {
+	return file_proto_summary_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *HistogramSummary) GetBinCount() []int64 {
+	if x != nil {
+		return x.BinCount
+	}
+	return nil
+}
+
+type BinarySearchSummary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Store all inputs.
+	Input []*ValueType `protobuf:"bytes,2,rep,name=input" json:"input,omitempty"`
+}
+
+func (x *BinarySearchSummary) Reset() {
+	*x = BinarySearchSummary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_summary_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BinarySearchSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BinarySearchSummary) ProtoMessage() {}
+
+func (x *BinarySearchSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_summary_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BinarySearchSummary.ProtoReflect.Descriptor instead.
+func (*BinarySearchSummary) Descriptor() ([]byte, []int) {
+	return file_proto_summary_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *BinarySearchSummary) GetInput() []*ValueType {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+type ApproxBoundsSummary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PosBinCount []int64 `protobuf:"varint,1,rep,name=pos_bin_count,json=posBinCount" json:"pos_bin_count,omitempty"`
+	NegBinCount []int64 `protobuf:"varint,2,rep,name=neg_bin_count,json=negBinCount" json:"neg_bin_count,omitempty"`
+}
+
+func (x *ApproxBoundsSummary) Reset() {
+	*x = ApproxBoundsSummary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_summary_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ApproxBoundsSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApproxBoundsSummary) ProtoMessage() {}
+
+func (x *ApproxBoundsSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_summary_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApproxBoundsSummary.ProtoReflect.Descriptor instead.
+func (*ApproxBoundsSummary) Descriptor() ([]byte, []int) {
+	return file_proto_summary_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ApproxBoundsSummary) GetPosBinCount() []int64 {
+	if x != nil {
+		return x.PosBinCount
+	}
+	return nil
+}
+
+func (x *ApproxBoundsSummary) GetNegBinCount() []int64 {
+	if x != nil {
+		return x.NegBinCount
+	}
+	return nil
+}
+
+var File_proto_summary_proto protoreflect.FileDescriptor
+
+var file_proto_summary_proto_rawDesc = []byte{
+	0x0a, 0x13, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x73, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x14, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74,
+	0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x1a, 0x19, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x61, 0x6e, 0x79,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x10, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x64, 0x61,
+	0x74, 0x61, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x33, 0x0a, 0x07, 0x53, 0x75, 0x6d, 0x6d,
+	0x61, 0x72, 0x79, 0x12, 0x28, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x14, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x41, 0x6e, 0x79, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0xa5, 0x02,
+	0x0a, 0x0c, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x63,
+	0x6f, 0x75, 0x6e, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x65, 0x70, 0x73, 0x69, 0x6c, 0x6f, 0x6e, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x07, 0x65, 0x70, 0x73, 0x69, 0x6c, 0x6f, 0x6e, 0x12, 0x14,
+	0x0a, 0x05, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x64,
+	0x65, 0x6c, 0x74, 0x61, 0x12, 0x4a, 0x0a, 0x0e, 0x6d, 0x65, 0x63, 0x68, 0x61, 0x6e, 0x69, 0x73,
+	0x6d, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x23, 0x2e, 0x64,
+	0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76,
+	0x61, 0x63, 0x79, 0x2e, 0x4d, 0x65, 0x63, 0x68, 0x61, 0x6e, 0x69, 0x73, 0x6d, 0x54, 0x79, 0x70,
+	0x65, 0x52, 0x0d, 0x6d, 0x65, 0x63, 0x68, 0x61, 0x6e, 0x69, 0x73, 0x6d, 0x54, 0x79, 0x70, 0x65,
+	0x12, 0x3c, 0x0a, 0x1a, 0x6d, 0x61, 0x78, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x64, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x18, 0x6d, 0x61, 0x78, 0x50, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x64, 0x12, 0x45,
+	0x0a, 0x1f, 0x6d, 0x61, 0x78, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x5f, 0x70, 0x65, 0x72, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x1c, 0x6d, 0x61, 0x78, 0x43, 0x6f, 0x6e, 0x74,
+	0x72, 0x69, 0x62, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x50, 0x65, 0x72, 0x50, 0x61, 0x72, 0x74,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0xc8, 0x04, 0x0a, 0x11, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x65,
+	0x64, 0x53, 0x75, 0x6d, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x12, 0x38, 0x0a, 0x07, 0x70,
+	0x6f, 0x73, 0x5f, 0x73, 0x75, 0x6d, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x64,
+	0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76,
+	0x61, 0x63, 0x79, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x06, 0x70,
+	0x6f, 0x73, 0x53, 0x75, 0x6d, 0x12, 0x38, 0x0a, 0x07, 0x6e, 0x65, 0x67, 0x5f, 0x73, 0x75, 0x6d,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65,
+	0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x2e, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x06, 0x6e, 0x65, 0x67, 0x53, 0x75, 0x6d, 0x12,
+	0x50, 0x0a, 0x0e, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x5f, 0x73, 0x75, 0x6d, 0x6d, 0x61, 0x72,
+	0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x29, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72,
+	0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x2e, 0x41,
+	0x70, 0x70, 0x72, 0x6f, 0x78, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x53, 0x75, 0x6d, 0x6d, 0x61,
+	0x72, 0x79, 0x52, 0x0d, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72,
+	0x79, 0x12, 0x40, 0x0a, 0x0b, 0x70, 0x61, 0x72, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x73, 0x75, 0x6d,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65,
+	0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x2e, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x0a, 0x70, 0x61, 0x72, 0x74, 0x69, 0x61, 0x6c,
+	0x53, 0x75, 0x6d, 0x12, 0x18, 0x0a, 0x07, 0x65, 0x70, 0x73, 0x69, 0x6c, 0x6f, 0x6e, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x07, 0x65, 0x70, 0x73, 0x69, 0x6c, 0x6f, 0x6e, 0x12, 0x14, 0x0a,
+	0x05, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x18, 0x06, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x64, 0x65,
+	0x6c, 0x74, 0x61, 0x12, 0x4a, 0x0a, 0x0e, 0x6d, 0x65, 0x63, 0x68, 0x61, 0x6e, 0x69, 0x73, 0x6d,
+	0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x23, 0x2e, 0x64, 0x69,
+	0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61,
+	0x63, 0x79, 0x2e, 0x4d, 0x65, 0x63, 0x68, 0x61, 0x6e, 0x69, 0x73, 0x6d, 0x54, 0x79, 0x70, 0x65,
+	0x52, 0x0d, 0x6d, 0x65, 0x63, 0x68, 0x61, 0x6e, 0x69, 0x73, 0x6d, 0x54, 0x79, 0x70, 0x65, 0x12,
+	0x14, 0x0a, 0x05, 0x6c, 0x6f, 0x77, 0x65, 0x72, 0x18, 0x08, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05,
+	0x6c, 0x6f, 0x77, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x75, 0x70, 0x70, 0x65, 0x72, 0x18, 0x09,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x75, 0x70, 0x70, 0x65, 0x72, 0x12, 0x3c, 0x0a, 0x1a, 0x6d,
+	0x61, 0x78, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x5f, 0x63, 0x6f,
+	0x6e, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x64, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x18, 0x6d, 0x61, 0x78, 0x50, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x43, 0x6f,
+	0x6e, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x64, 0x12, 0x45, 0x0a, 0x1f, 0x6d, 0x61, 0x78,
+	0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x5f, 0x70,
+	0x65, 0x72, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x0b, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x1c, 0x6d, 0x61, 0x78, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x50, 0x65, 0x72, 0x50, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e,
+	0x22, 0xf0, 0x01, 0x0a, 0x12, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x65, 0x64, 0x4d, 0x65, 0x61, 0x6e,
+	0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x38, 0x0a,
+	0x07, 0x70, 0x6f, 0x73, 0x5f, 0x73, 0x75, 0x6d, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f,
+	0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72,
+	0x69, 0x76, 0x61, 0x63, 0x79, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52,
+	0x06, 0x70, 0x6f, 0x73, 0x53, 0x75, 0x6d, 0x12, 0x38, 0x0a, 0x07, 0x6e, 0x65, 0x67, 0x5f, 0x73,
+	0x75, 0x6d, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65,
+	0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x2e,
+	0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x06, 0x6e, 0x65, 0x67, 0x53, 0x75,
+	0x6d, 0x12, 0x50, 0x0a, 0x0e, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x5f, 0x73, 0x75, 0x6d, 0x6d,
+	0x61, 0x72, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x29, 0x2e, 0x64, 0x69, 0x66, 0x66,
+	0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79,
+	0x2e, 0x41, 0x70, 0x70, 0x72, 0x6f, 0x78, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x53, 0x75, 0x6d,
+	0x6d, 0x61, 0x72, 0x79, 0x52, 0x0d, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x53, 0x75, 0x6d, 0x6d,
+	0x61, 0x72, 0x79, 0x22, 0xce, 0x02, 0x0a, 0x16, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x65, 0x64, 0x56,
+	0x61, 0x72, 0x69, 0x61, 0x6e, 0x63, 0x65, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x63,
+	0x6f, 0x75, 0x6e, 0x74, 0x12, 0x38, 0x0a, 0x07, 0x70, 0x6f, 0x73, 0x5f, 0x73, 0x75, 0x6d, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e,
+	0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x2e, 0x56, 0x61, 0x6c,
+	0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x06, 0x70, 0x6f, 0x73, 0x53, 0x75, 0x6d, 0x12, 0x38,
+	0x0a, 0x07, 0x6e, 0x65, 0x67, 0x5f, 0x73, 0x75, 0x6d, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x1f, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70,
+	0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65,
+	0x52, 0x06, 0x6e, 0x65, 0x67, 0x53, 0x75, 0x6d, 0x12, 0x2b, 0x0a, 0x12, 0x70, 0x6f, 0x73, 0x5f,
+	0x73, 0x75, 0x6d, 0x5f, 0x6f, 0x66, 0x5f, 0x73, 0x71, 0x75, 0x61, 0x72, 0x65, 0x73, 0x18, 0x04,
+	0x20, 0x03, 0x28, 0x01, 0x52, 0x0f, 0x70, 0x6f, 0x73, 0x53, 0x75, 0x6d, 0x4f, 0x66, 0x53, 0x71,
+	0x75, 0x61, 0x72, 0x65, 0x73, 0x12, 0x2b, 0x0a, 0x12, 0x6e, 0x65, 0x67, 0x5f, 0x73, 0x75, 0x6d,
+	0x5f, 0x6f, 0x66, 0x5f, 0x73, 0x71, 0x75, 0x61, 0x72, 0x65, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28,
+	0x01, 0x52, 0x0f, 0x6e, 0x65, 0x67, 0x53, 0x75, 0x6d, 0x4f, 0x66, 0x53, 0x71, 0x75, 0x61, 0x72,
+	0x65, 0x73, 0x12, 0x50, 0x0a, 0x0e, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x5f, 0x73, 0x75, 0x6d,
+	0x6d, 0x61, 0x72, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x29, 0x2e, 0x64, 0x69, 0x66,
+	0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63,
+	0x79, 0x2e, 0x41, 0x70, 0x70, 0x72, 0x6f, 0x78, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x53, 0x75,
+	0x6d, 0x6d, 0x61, 0x72, 0x79, 0x52, 0x0d, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x53, 0x75, 0x6d,
+	0x6d, 0x61, 0x72, 0x79, 0x22, 0x24, 0x0a, 0x08, 0x45, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x73,
+	0x12, 0x18, 0x0a, 0x07, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x07, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x22, 0x2f, 0x0a, 0x10, 0x48, 0x69,
+	0x73, 0x74, 0x6f, 0x67, 0x72, 0x61, 0x6d, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x12, 0x1b,
+	0x0a, 0x09, 0x62, 0x69, 0x6e, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x03, 0x52, 0x08, 0x62, 0x69, 0x6e, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0x52, 0x0a, 0x13, 0x42,
+	0x69, 0x6e, 0x61, 0x72, 0x79, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x53, 0x75, 0x6d, 0x6d, 0x61,
+	0x72, 0x79, 0x12, 0x35, 0x0a, 0x05, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x1f, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c,
+	0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79,
+	0x70, 0x65, 0x52, 0x05, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x4a, 0x04, 0x08, 0x01, 0x10, 0x02, 0x22,
+	0x5d, 0x0a, 0x13, 0x41, 0x70, 0x70, 0x72, 0x6f, 0x78, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x53,
+	0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x12, 0x22, 0x0a, 0x0d, 0x70, 0x6f, 0x73, 0x5f, 0x62, 0x69,
+	0x6e, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0b, 0x70,
+	0x6f, 0x73, 0x42, 0x69, 0x6e, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x22, 0x0a, 0x0d, 0x6e, 0x65,
+	0x67, 0x5f, 0x62, 0x69, 0x6e, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x03, 0x52, 0x0b, 0x6e, 0x65, 0x67, 0x42, 0x69, 0x6e, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x2a, 0x35,
+	0x0a, 0x0d, 0x4d, 0x65, 0x63, 0x68, 0x61, 0x6e, 0x69, 0x73, 0x6d, 0x54, 0x79, 0x70, 0x65, 0x12,
+	0x09, 0x0a, 0x05, 0x45, 0x4d, 0x50, 0x54, 0x59, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x4c, 0x41,
+	0x50, 0x4c, 0x41, 0x43, 0x45, 0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x47, 0x41, 0x55, 0x53, 0x53,
+	0x49, 0x41, 0x4e, 0x10, 0x02, 0x42, 0x54, 0x0a, 0x1e, 0x63, 0x6f, 0x6d, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c,
+	0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x5a, 0x32, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x64, 0x69, 0x66, 0x66, 0x65,
+	0x72, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x2d, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x2f,
+	0x67, 0x6f, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x3b, 0x70, 0x62,
+}
+
+var (
+	file_proto_summary_proto_rawDescOnce sync.Once
+	file_proto_summary_proto_rawDescData = file_proto_summary_proto_rawDesc
+)
+
+func file_proto_summary_proto_rawDescGZIP() []byte {
+	file_proto_summary_proto_rawDescOnce.Do(func() {
+		file_proto_summary_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_summary_proto_rawDescData)
+	})
+	return file_proto_summary_proto_rawDescData
+}
+
+var file_proto_summary_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proto_summary_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_proto_summary_proto_goTypes = []interface{}{
+	(MechanismType)(0),             // 0: differential_privacy.MechanismType
+	(*Summary)(nil),                // 1: differential_privacy.Summary
+	(*CountSummary)(nil),           // 2: differential_privacy.CountSummary
+	(*BoundedSumSummary)(nil),      // 3: differential_privacy.BoundedSumSummary
+	(*BoundedMeanSummary)(nil),     // 4: differential_privacy.BoundedMeanSummary
+	(*BoundedVarianceSummary)(nil), // 5: differential_privacy.BoundedVarianceSummary
+	(*Elements)(nil),               // 6: differential_privacy.Elements
+	(*HistogramSummary)(nil),       // 7: differential_privacy.HistogramSummary
+	(*BinarySearchSummary)(nil),    // 8: differential_privacy.BinarySearchSummary
+	(*ApproxBoundsSummary)(nil),    // 9: differential_privacy.ApproxBoundsSummary
+	(*anypb.Any)(nil),              // 10: google.protobuf.Any
+	(*ValueType)(nil),              // 11: differential_privacy.ValueType
+}
+var file_proto_summary_proto_depIdxs = []int32{
+	10, // 0: differential_privacy.Summary.data:type_name -> google.protobuf.Any
+	0,  // 1: differential_privacy.CountSummary.mechanism_type:type_name -> differential_privacy.MechanismType
+	11, // 2: differential_privacy.BoundedSumSummary.pos_sum:type_name -> differential_privacy.ValueType
+	11, // 3: differential_privacy.BoundedSumSummary.neg_sum:type_name -> differential_privacy.ValueType
+	9,  // 4: differential_privacy.BoundedSumSummary.bounds_summary:type_name -> differential_privacy.ApproxBoundsSummary
+	11, // 5: differential_privacy.BoundedSumSummary.partial_sum:type_name -> differential_privacy.ValueType
+	0,  // 6: differential_privacy.BoundedSumSummary.mechanism_type:type_name -> differential_privacy.MechanismType
+	11, // 7: differential_privacy.BoundedMeanSummary.pos_sum:type_name -> differential_privacy.ValueType
+	11, // 8: differential_privacy.BoundedMeanSummary.neg_sum:type_name -> differential_privacy.ValueType
+	9,  // 9: differential_privacy.BoundedMeanSummary.bounds_summary:type_name -> differential_privacy.ApproxBoundsSummary
+	11, // 10: differential_privacy.BoundedVarianceSummary.pos_sum:type_name -> differential_privacy.ValueType
+	11, // 11: differential_privacy.BoundedVarianceSummary.neg_sum:type_name -> differential_privacy.ValueType
+	9,  // 12: differential_privacy.BoundedVarianceSummary.bounds_summary:type_name -> differential_privacy.ApproxBoundsSummary
+	11, // 13: differential_privacy.BinarySearchSummary.input:type_name -> differential_privacy.ValueType
+	14, // [14:14] is the sub-list for method output_type
+	14, // [14:14] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
+}
+
+func init() { file_proto_summary_proto_init() }
+func file_proto_summary_proto_init() {
+	if File_proto_summary_proto != nil {
+		return
+	}
+	file_proto_data_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_proto_summary_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Summary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_summary_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CountSummary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_summary_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BoundedSumSummary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_summary_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BoundedMeanSummary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_summary_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BoundedVarianceSummary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_summary_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Elements); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_summary_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HistogramSummary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_summary_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BinarySearchSummary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_summary_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ApproxBoundsSummary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proto_summary_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_proto_summary_proto_goTypes,
+		DependencyIndexes: file_proto_summary_proto_depIdxs,
+		EnumInfos:         file_proto_summary_proto_enumTypes,
+		MessageInfos:      file_proto_summary_proto_msgTypes,
+	}.Build()
+	File_proto_summary_proto = out.File
+	file_proto_summary_proto_rawDesc = nil
+	file_proto_summary_proto_goTypes = nil
+	file_proto_summary_proto_depIdxs = nil
+}
@@ -0,0 +1,166 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package rand
+
+import (
+	"math/big"
+
+	log "github.com/golang/glog"
+)
+
+// The samplers of this file follow Canonne, Kamath and Steinke's "The Discrete
+// Gaussian for Differential Privacy" (https://arxiv.org/abs/2004.00010). All
+// their parameters are rational numbers, and they only use exact arithmetic on
+// integers and rationals, so that their output distributions are exact and
+// free of floating-point artifacts.
+
+var bigOne = big.NewRat(1, 1)
+
+// bigIntn returns an integer from the set {0,...,n-1} uniformly at random.
+// The value of n must be positive.
+func (r *Rand) bigIntn(n *big.Int) *big.Int {
+	bitLen := n.BitLen()
+	b := make([]byte, (bitLen+7)/8)
+	// Mask of the bits of the most significant byte that can be set in a
+	// number smaller than 2^bitLen.
+	topMask := byte(0xff >> uint(len(b)*8-bitLen))
+	x := new(big.Int)
+	for {
+		if _, err := r.read(b); err != nil {
+			log.Fatalf("out of randomness, should never happen: %v", err)
+		}
+		b[0] &= topMask
+		if x.SetBytes(b).Cmp(n) < 0 {
+			return x
+		}
+	}
+}
+
+// bernoulli returns true with probability p, which must be in [0, 1].
+func (r *Rand) bernoulli(p *big.Rat) bool {
+	return r.bigIntn(p.Denom()).Cmp(p.Num()) < 0
+}
+
+// bernoulliExp returns true with probability exp(-γ), for a non-negative γ.
+func (r *Rand) bernoulliExp(gamma *big.Rat) bool {
+	// exp(-γ) = exp(-1)^⌊γ⌋ * exp(-(γ-⌊γ⌋)), and each factor is sampled
+	// independently.
+	fraction := new(big.Rat).Set(gamma)
+	for fraction.Cmp(bigOne) > 0 {
+		if !r.bernoulliExpAtMostOne(bigOne) {
+			return false
+		}
+		fraction.Sub(fraction, bigOne)
+	}
+	return r.bernoulliExpAtMostOne(fraction)
+}
+
+// bernoulliExpAtMostOne returns true with probability exp(-γ), for γ in
+// [0, 1].
+func (r *Rand) bernoulliExpAtMostOne(gamma *big.Rat) bool {
+	// The probability that the loop runs exactly k times is
+	// γ^(k-1)/(k-1)! - γ^k/k!, and the probabilities for odd k add up to
+	// exp(-γ).
+	k := int64(1)
+	p := new(big.Rat)
+	for r.bernoulli(p.Quo(gamma, big.NewRat(k, 1))) {
+		k++
+	}
+	return k%2 == 1
+}
+
+// DiscreteLaplace is the same as Rand.DiscreteLaplace, using the secure
+// source.
+func DiscreteLaplace(scale *big.Rat) int64 {
+	return secure.DiscreteLaplace(scale)
+}
+
+// DiscreteGaussian is the same as Rand.DiscreteGaussian, using the secure
+// source.
+func DiscreteGaussian(sigmaSquared *big.Rat) int64 {
+	return secure.DiscreteGaussian(sigmaSquared)
+}
+
+// DiscreteLaplace returns an integer drawn from the discrete Laplace
+// distribution with the given positive scale t, i.e. such that the
+// probability of any integer x is proportional to exp(-|x|/t).
+func (r *Rand) DiscreteLaplace(scale *big.Rat) int64 {
+	if scale.Sign() <= 0 {
+		log.Fatalf("DiscreteLaplace: scale must be positive, got %v", scale)
+	}
+	// scale = t/s with integers t and s.
+	t, s := scale.Num(), scale.Denom()
+	v, x := new(big.Int), new(big.Int)
+	for {
+		// u+t*v is a geometric sample with success probability 1-exp(-1/t),
+		// drawn without computing that probability: u is the remainder, which
+		// is accepted with probability exp(-u/t), and v the quotient.
+		u := r.bigIntn(t)
+		if !r.bernoulliExp(new(big.Rat).SetFrac(u, t)) {
+			continue
+		}
+		v.SetInt64(0)
+		for r.bernoulliExp(bigOne) {
+			v.Add(v, big.NewInt(1))
+		}
+		// Dividing by s gives a geometric sample with success probability
+		// 1-exp(-s/t).
+		x.Mul(t, v).Add(x, u).Quo(x, s)
+		negative := r.Boolean()
+		// Rejecting -0 gives 0 the right probability.
+		if negative && x.Sign() == 0 {
+			continue
+		}
+		if !x.IsInt64() {
+			log.Fatalf("DiscreteLaplace: sample %v overflows int64 for scale %v", x, scale)
+		}
+		if negative {
+			return -x.Int64()
+		}
+		return x.Int64()
+	}
+}
+
+// DiscreteGaussian returns an integer drawn from the discrete Gaussian
+// distribution with the given positive σ², i.e. such that the probability of
+// any integer x is proportional to exp(-x²/(2σ²)).
+func (r *Rand) DiscreteGaussian(sigmaSquared *big.Rat) int64 {
+	if sigmaSquared.Sign() <= 0 {
+		log.Fatalf("DiscreteGaussian: sigmaSquared must be positive, got %v", sigmaSquared)
+	}
+	// t = ⌊σ⌋+1, with ⌊σ⌋ = ⌊√⌊σ²⌋⌋.
+	t := new(big.Int).Quo(sigmaSquared.Num(), sigmaSquared.Denom())
+	t.Sqrt(t).Add(t, big.NewInt(1))
+	scale := new(big.Rat).SetInt(t)
+	// Samples from the discrete Laplace distribution with scale t are accepted
+	// with probability exp(-(|y|-σ²/t)²/(2σ²)).
+	center := new(big.Rat).Quo(sigmaSquared, scale)
+	twoSigmaSquared := new(big.Rat).Add(sigmaSquared, sigmaSquared)
+	gamma := new(big.Rat)
+	for {
+		y := r.DiscreteLaplace(scale)
+		abs := y
+		if abs < 0 {
+			abs = -abs
+		}
+		gamma.SetInt64(abs).Sub(gamma, center)
+		gamma.Mul(gamma, gamma).Quo(gamma, twoSigmaSquared)
+		if r.bernoulliExp(gamma) {
+			return y
+		}
+	}
+}
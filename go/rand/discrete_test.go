@@ -0,0 +1,123 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package rand
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+// numberOfDiscreteSamples is the number of samples drawn from the samplers
+// of discrete.go, which use exact arithmetic and are much slower than the
+// other samplers of this package.
+const numberOfDiscreteSamples = 10000
+
+// newSeededRand returns a Rand drawing from a deterministic source, so that
+// the statistical tests of this file are reproducible. Drawing from the secure
+// Rand would also be much slower with the race detector, which makes sync.Pool
+// drop secureStates at random, so that their buffers are refilled from
+// crypto/rand.
+func newSeededRand() *Rand {
+	return New(NewDeterministicSource(1))
+}
+
+// checkFrequencies checks that the frequencies of the integers in [-5, 5] in
+// samples are close to their probabilities given by pmf.
+func checkFrequencies(t *testing.T, name string, samples []int64, pmf func(x int64) float64) {
+	t.Helper()
+	counts := make(map[int64]int)
+	for _, s := range samples {
+		counts[s]++
+	}
+	for x := int64(-5); x <= 5; x++ {
+		p := pmf(x)
+		got := float64(counts[x]) / float64(len(samples))
+		// The frequency is approximately Gaussian distributed with a standard
+		// deviation of sqrt(p(1-p)/n). The tolerance is set to its 99.9995%
+		// quantile, so the test falsely rejects with a probability of about
+		// 10⁻⁵ for each x.
+		tolerance := 4.41717 * math.Sqrt(p*(1-p)/float64(len(samples)))
+		if math.Abs(got-p) > tolerance {
+			t.Errorf("%s: got frequency %f for %d, want %f", name, got, x, p)
+		}
+	}
+}
+
+func TestBernoulliExp(t *testing.T) {
+	r := newSeededRand()
+	for _, gamma := range []*big.Rat{big.NewRat(0, 1), big.NewRat(1, 3), big.NewRat(1, 1), big.NewRat(5, 2)} {
+		successes := 0
+		for i := 0; i < numberOfDiscreteSamples; i++ {
+			if r.bernoulliExp(gamma) {
+				successes++
+			}
+		}
+		g, _ := gamma.Float64()
+		p := math.Exp(-g)
+		got := float64(successes) / numberOfDiscreteSamples
+		if tolerance := 4.41717 * math.Sqrt(p*(1-p)/numberOfDiscreteSamples); math.Abs(got-p) > tolerance {
+			t.Errorf("bernoulliExp(%v): got frequency %f, want %f", gamma, got, p)
+		}
+	}
+}
+
+func TestBigIntn(t *testing.T) {
+	for _, n := range []int64{1, 2, 3, 255, 256, 257} {
+		for i := 0; i < 1000; i++ {
+			if got := secure.bigIntn(big.NewInt(n)); got.Sign() < 0 || got.Cmp(big.NewInt(n)) >= 0 {
+				t.Fatalf("bigIntn(%d): got %v, want a value in [0, %d)", n, got, n)
+			}
+		}
+	}
+}
+
+func TestDiscreteLaplace(t *testing.T) {
+	r := newSeededRand()
+	for _, scale := range []*big.Rat{big.NewRat(1, 2), big.NewRat(3, 2), big.NewRat(4, 1)} {
+		samples := make([]int64, numberOfDiscreteSamples)
+		for i := range samples {
+			samples[i] = r.DiscreteLaplace(scale)
+		}
+		s, _ := scale.Float64()
+		pmf := func(x int64) float64 {
+			// P[x] = (1-exp(-1/s))/(1+exp(-1/s)) * exp(-|x|/s).
+			q := math.Exp(-1 / s)
+			return (1 - q) / (1 + q) * math.Exp(-math.Abs(float64(x))/s)
+		}
+		checkFrequencies(t, "DiscreteLaplace("+scale.String()+")", samples, pmf)
+	}
+}
+
+func TestDiscreteGaussian(t *testing.T) {
+	r := newSeededRand()
+	for _, sigmaSquared := range []*big.Rat{big.NewRat(1, 4), big.NewRat(2, 1), big.NewRat(9, 1)} {
+		samples := make([]int64, numberOfDiscreteSamples)
+		for i := range samples {
+			samples[i] = r.DiscreteGaussian(sigmaSquared)
+		}
+		s, _ := sigmaSquared.Float64()
+		normalization := 0.0
+		for x := -100; x <= 100; x++ {
+			normalization += math.Exp(-float64(x*x) / (2 * s))
+		}
+		pmf := func(x int64) float64 {
+			return math.Exp(-float64(x*x)/(2*s)) / normalization
+		}
+		checkFrequencies(t, "DiscreteGaussian("+sigmaSquared.String()+")", samples, pmf)
+	}
+}
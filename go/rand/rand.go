@@ -34,68 +34,213 @@ import (
 // TODO: Add test coverage for the various exported
 // noise-generating functions.
 
-var (
-	randBufLock sync.Mutex
-	randBuf     io.Reader = bufio.NewReaderSize(cryptorand.Reader, 65536)
+// Source is a source of uniformly random bytes, from which a Rand draws its
+// randomness. Read must fill p entirely, or return an error.
+type Source interface {
+	Read(p []byte) (n int, err error)
+}
 
-	randBitLock sync.Mutex
-	randBitBuf  uint8
-	randBitPos  int8 = math.MaxInt8
-)
+// Rand generates random numbers from the distributions of this package,
+// drawing its randomness from a Source. It is safe for concurrent use.
+type Rand struct {
+	srcLock sync.Mutex
+	src     Source
+
+	bitLock sync.Mutex
+	bits    bitBuffer
 
-func readRandBuf(b []byte) (int, error) {
-	randBufLock.Lock()
-	defer randBufLock.Unlock()
-	return io.ReadFull(randBuf, b)
+	// If pool is set, src and bits are unused: each call instead takes a
+	// *secureState from the pool, so that concurrent goroutines draw from
+	// distinct buffers without contending on srcLock and bitLock.
+	pool *sync.Pool
 }
 
-// U64 returns a uniformly random uint64.
+// New returns a Rand drawing its randomness from src.
+func New(src Source) *Rand {
+	return &Rand{src: src}
+}
+
+// secureBufferSize is the size of each buffer of random bytes read from
+// crypto/rand by the secure Rand. There is up to one buffer per goroutine
+// drawing from the secure Rand at the same time.
+const secureBufferSize = 65536
+
+// secureState is a buffered reader of crypto/rand, along with the bits of the
+// last byte that Boolean hasn't used yet. Its random bytes are only handed out
+// once, whichever goroutine holds the state, so pooling states gives the same
+// security guarantees as a single buffer guarded by a lock.
+type secureState struct {
+	r    *bufio.Reader
+	bits bitBuffer
+}
+
+// secure is the Rand used by the functions of this package, and by default by
+// the rest of the library. It draws from a cryptographically secure source.
+var secure = &Rand{pool: &sync.Pool{
+	New: func() interface{} {
+		return &secureState{r: bufio.NewReaderSize(cryptorand.Reader, secureBufferSize)}
+	},
+}}
+
+// Secure returns the Rand drawing from a cryptographically secure source that
+// is used by the functions of this package.
+func Secure() *Rand {
+	return secure
+}
+
+// NewDeterministicSource returns a Source producing a deterministic stream of
+// bytes determined by seed. It is NOT cryptographically secure, and must only
+// be used in tests that need reproducible noise: differential privacy
+// guarantees don't hold for noise drawn from it.
+func NewDeterministicSource(seed int64) Source {
+	return deterministicSource{Rand: mathrand.New(mathrand.NewSource(seed)), seed: seed}
+}
+
+// deterministicSource is a Source returned by NewDeterministicSource, which
+// remembers its seed.
+type deterministicSource struct {
+	*mathrand.Rand
+	seed int64
+}
+
+// DeterministicSeed returns the seed of src if it was returned by
+// NewDeterministicSource, so that an equivalent Source can be created again,
+// e.g. when decoding an aggregation using src.
+func DeterministicSeed(src Source) (seed int64, ok bool) {
+	d, ok := src.(deterministicSource)
+	return d.seed, ok
+}
+
+func (r *Rand) read(b []byte) (int, error) {
+	if r.pool != nil {
+		s := r.pool.Get().(*secureState)
+		defer r.pool.Put(s)
+		return io.ReadFull(s.r, b)
+	}
+	r.srcLock.Lock()
+	defer r.srcLock.Unlock()
+	return io.ReadFull(r.src, b)
+}
+
+// bitBuffer holds the bits of a random byte that haven't been used yet.
+type bitBuffer struct {
+	buf  uint8
+	left int8 // Number of bits of buf that haven't been used yet.
+}
+
+// next returns the next unused bit of the buffer, refilling it from src if
+// all of its bits have been used.
+func (bb *bitBuffer) next(src io.Reader) bool {
+	if bb.left == 0 { // Out of random bits.
+		var b [1]uint8
+		if _, err := io.ReadFull(src, b[:]); err != nil {
+			log.Fatalf("out of randomness, should never happen: %v", err)
+		}
+		bb.buf = b[0]
+		bb.left = 8
+	}
+	res := bb.buf&1 > 0
+	bb.buf >>= 1
+	bb.left--
+	return res
+}
+
+// U64 returns a uniformly random uint64 drawn from the secure source.
 func U64() uint64 {
-	var r [8]uint8
-	if _, err := readRandBuf(r[:]); err != nil {
+	return secure.U64()
+}
+
+// U8 returns a uniformly random uint8 drawn from the secure source.
+func U8() uint8 {
+	return secure.U8()
+}
+
+// Sign returns +1.0 or -1.0 with equal probabilities, using the secure source.
+func Sign() float64 {
+	return secure.Sign()
+}
+
+// Boolean returns true or false with equal probability, using the secure
+// source.
+func Boolean() bool {
+	return secure.Boolean()
+}
+
+// I63n returns an integer from the set {0,...,n-1} uniformly at random, using
+// the secure source. The value of n must be positive.
+func I63n(n int64) int64 {
+	return secure.I63n(n)
+}
+
+// Uniform is the same as Rand.Uniform, using the secure source.
+func Uniform() float64 {
+	return secure.Uniform()
+}
+
+// Geometric is the same as Rand.Geometric, using the secure source.
+func Geometric() float64 {
+	return secure.Geometric()
+}
+
+// Normal returns a normally distributed float with mean 0 and standard
+// deviation 1, using the secure source.
+func Normal() float64 {
+	return secure.Normal()
+}
+
+// U64 returns a uniformly random uint64.
+func (r *Rand) U64() uint64 {
+	var b [8]uint8
+	if _, err := r.read(b[:]); err != nil {
 		log.Fatalf("out of randomness, should never happen: %v", err)
 	}
-	return binary.LittleEndian.Uint64(r[:])
+	return binary.LittleEndian.Uint64(b[:])
 }
 
 // U8 returns a uniformly random uint8.
-func U8() uint8 {
-	var r [1]uint8
-	if _, err := readRandBuf(r[:]); err != nil {
+func (r *Rand) U8() uint8 {
+	var b [1]uint8
+	if _, err := r.read(b[:]); err != nil {
 		log.Fatalf("out of randomness, should never happen: %v", err)
 	}
-	return r[0]
+	return b[0]
 }
 
 // Sign returns +1.0 or -1.0 with equal probabilities.
-func Sign() float64 {
-	if Boolean() {
+func (r *Rand) Sign() float64 {
+	if r.Boolean() {
 		return 1.0
 	}
 	return -1.0
 }
 
 // Boolean returns true or false with equal probability.
-func Boolean() bool {
-	randBitLock.Lock()
-	defer randBitLock.Unlock()
-	if randBitPos > 7 { // Out of random bits.
-		randBitBuf = U8()
-		randBitPos = 0
+func (r *Rand) Boolean() bool {
+	if r.pool != nil {
+		s := r.pool.Get().(*secureState)
+		defer r.pool.Put(s)
+		return s.bits.next(s.r)
 	}
-	res := randBitBuf&(1<<randBitPos) > 0
-	randBitPos++
-	return res
+	r.bitLock.Lock()
+	defer r.bitLock.Unlock()
+	return r.bits.next(readerFunc(r.read))
+}
+
+// readerFunc implements io.Reader with a function.
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
 }
 
 // I63n returns an integer from the set {0,...,n-1} uniformly at random.
 // The value of n must be positive.
-func I63n(n int64) int64 {
+func (r *Rand) I63n(n int64) int64 {
 	largestMultipleOfN := (math.MaxInt64 / n) * n
 	var positiveRandomInteger int64
 	for true {
 		// Draw random 64 bit sequence and set sign bit to 0.
-		positiveRandomInteger = int64(U64()) & 0x7fffffffffffffff
+		positiveRandomInteger = int64(r.U64()) & 0x7fffffffffffffff
 		if positiveRandomInteger < largestMultipleOfN {
 			break
 		}
@@ -108,45 +253,44 @@ func I63n(n int64) int64 {
 // distribution simulates a continuous uniform distribution on (0, 1].
 //
 // See http://g/go-nuts/GndbDnHKHuw/VNSrkl9vBQAJ for details.
-func Uniform() float64 {
-	i := U64() % (1 << 53)
-	r := (1 + float64(i)/(1<<53)) / math.Pow(2, Geometric())
+func (r *Rand) Uniform() float64 {
+	i := r.U64() % (1 << 53)
+	u := (1 + float64(i)/(1<<53)) / math.Pow(2, r.Geometric())
 	// We want to avoid returning 0, since we're taking the log of the output.
-	if r == 0 {
+	if u == 0 {
 		return 1
 	}
-	return r
+	return u
 }
 
 // Geometric returns a float64 that counts the number of Bernoulli trials until
 // the first success for a success probability of 0.5.
-func Geometric() float64 {
+func (r *Rand) Geometric() float64 {
 	// 1 plus the number of leading zeros from an infinite stream of random bits
 	// follows the desired geometric distribution.
 	b := 1
-	var r uint8
-	for r == 0 {
-		r = U8()
-		b += bits.LeadingZeros8(r)
+	var u uint8
+	for u == 0 {
+		u = r.U8()
+		b += bits.LeadingZeros8(u)
 	}
 	return float64(b)
 }
 
 // Normal returns a normally distributed float with mean 0 and standard deviation 1.
-func Normal() float64 {
-	return mathrand.New(&randSource{}).NormFloat64()
+func (r *Rand) Normal() float64 {
+	return mathrand.New(randSource{r}).NormFloat64()
 }
 
-// randSource implements a cryptographically secure implementation of math.Source.
-type randSource struct{}
+// randSource implements math.Source on top of a Rand. It is cryptographically
+// secure if the Source of the Rand is.
+type randSource struct {
+	r *Rand
+}
 
 // Int63 returns a uniformly random int64 in [0, 1<<63).
 func (rs randSource) Int63() int64 {
-	var r [8]uint8
-	if _, err := readRandBuf(r[:]); err != nil {
-		log.Fatalf("out of randomness, should never happen: %v", err)
-	}
-	i := int64(binary.LittleEndian.Uint64(r[:]))
+	i := int64(rs.r.U64())
 	if i < 0 {
 		return -i
 	}
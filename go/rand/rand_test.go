@@ -17,15 +17,18 @@
 package rand
 
 import (
+	"bufio"
 	"bytes"
+	cryptorand "crypto/rand"
+	"sync"
 	"testing"
 )
 
 func TestBooleanBufIsShifting(t *testing.T) {
-	randBuf = bytes.NewReader([]byte{
+	r := New(bytes.NewReader([]byte{
 		0b00100100,
 		0b10010000,
-	})
+	}))
 	for pos, want := range []bool{
 		// first byte
 		false,
@@ -46,8 +49,89 @@ func TestBooleanBufIsShifting(t *testing.T) {
 		false,
 		true,
 	} {
-		if got := Boolean(); got != want {
+		if got := r.Boolean(); got != want {
 			t.Errorf("Boolean: got %v, want %v in %v-th iteration", got, want, pos)
 		}
 	}
 }
+
+func TestDeterministicSource(t *testing.T) {
+	r1, r2, r3 := New(NewDeterministicSource(42)), New(NewDeterministicSource(42)), New(NewDeterministicSource(43))
+	same, different := true, true
+	for i := 0; i < 10; i++ {
+		u1, u2, u3 := r1.Uniform(), r2.Uniform(), r3.Uniform()
+		same = same && u1 == u2
+		different = different && u1 != u3
+	}
+	if !same {
+		t.Errorf("Uniform: got different samples for Rands with the same seed, want the same samples")
+	}
+	if !different {
+		t.Errorf("Uniform: got identical samples for Rands with different seeds, want different samples")
+	}
+}
+
+// Checks that goroutines drawing from the secure Rand at the same time never
+// get the same random bytes.
+func TestSecureConcurrentDrawsAreDistinct(t *testing.T) {
+	const goroutines, draws = 16, 1000
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[uint64]bool)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := make([]uint64, draws)
+			for i := range local {
+				local[i] = Secure().U64()
+				// Interleave Boolean calls, which use the same pooled states.
+				Secure().Boolean()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, u := range local {
+				if seen[u] {
+					t.Errorf("U64: got %d twice, want distinct samples", u)
+				}
+				seen[u] = true
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// lockedSecure reproduces the previous implementation of the secure Rand: a
+// single 64 KiB buffer of crypto/rand, guarded by a lock. It serves as a
+// baseline for the benchmarks below.
+var lockedSecure = New(bufio.NewReaderSize(cryptorand.Reader, 65536))
+
+func benchmarkParallel(b *testing.B, draw func(r *Rand)) {
+	for _, bc := range []struct {
+		name string
+		r    *Rand
+	}{
+		{"pooled", Secure()},
+		{"locked", lockedSecure},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					draw(bc.r)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkU64(b *testing.B) {
+	benchmarkParallel(b, func(r *Rand) { r.U64() })
+}
+
+func BenchmarkBoolean(b *testing.B) {
+	benchmarkParallel(b, func(r *Rand) { r.Boolean() })
+}
+
+func BenchmarkUniform(b *testing.B) {
+	benchmarkParallel(b, func(r *Rand) { r.Uniform() })
+}
@@ -0,0 +1,372 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/dpagg"
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/privacy-on-beam/internal/kv"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*aggregatePerKeyFn)(nil)))
+	beam.RegisterType(reflect.TypeOf(PerKeyAggregates{}))
+	beam.RegisterFunction(dropThresholdedPartitionsAggregatesFn)
+}
+
+// AggregateParams specifies the parameters associated with an AggregatePerKey
+// aggregation.
+type AggregateParams struct {
+	// Noise type (which is either LaplaceNoise{} or GaussianNoise{}).
+	//
+	// Defaults to LaplaceNoise{}.
+	NoiseKind NoiseKind
+	// Differential privacy budget consumed by this aggregation. If there is
+	// only one aggregation, both Epsilon and Delta can be left 0; in that
+	// case, the entire budget of the PrivacySpec is consumed.
+	Epsilon, Delta float64
+	// Relative weight of this aggregation when Epsilon and Delta are left 0
+	// and the PrivacySpec uses DeferredBudgetAllocation: the budget of the
+	// PrivacySpec is then split across aggregations proportionally to their
+	// weights.
+	//
+	// Defaults to 1.
+	BudgetWeight float64
+	// The maximum number of distinct keys that a given privacy identifier
+	// can influence. There is an inherent trade-off when choosing this
+	// parameter: a larger MaxPartitionsContributed leads to less data loss due
+	// to contribution bounding, but since the noise added in aggregations is
+	// scaled according to maxPartitionsContributed, it also means that more
+	// noise is added to each metric.
+	//
+	// Required.
+	MaxPartitionsContributed int64
+	// The maximum number of values that a given privacy identifier can
+	// contribute to a single key. There is an inherent trade-off when
+	// choosing this parameter: a larger MaxContributionsPerPartition leads to
+	// less data loss due to contribution bounding, but since the noise added
+	// in aggregations is scaled according to maxContributionsPerPartition, it
+	// also means that more noise is added to each metric.
+	//
+	// Required.
+	MaxContributionsPerPartition int64
+	// Each value is clamped to [MinValue, MaxValue] before being aggregated.
+	// There is an inherent trade-off when choosing MinValue and MaxValue: a
+	// small MinValue and a large MaxValue means that less values will be
+	// clamped, but that more noise will be added.
+	//
+	// Required.
+	MinValue, MaxValue float64
+	// How the keys that appear in the output are selected: the strategy, and
+	// the fraction of the budget spent on it.
+	//
+	// Optional.
+	PartitionSelection PartitionSelectionParams
+}
+
+// PerKeyAggregates contains the differentially private metrics computed by
+// AggregatePerKey for a single key.
+type PerKeyAggregates struct {
+	// Number of values associated with the key, clamped to be non-negative.
+	Count int64
+	// Sum of the values associated with the key.
+	Sum float64
+	// Mean of the values associated with the key. It is Sum/Count, clamped to
+	// [MinValue, MaxValue], or the midpoint of MinValue and MaxValue if Count
+	// is 0.
+	Mean float64
+}
+
+// AggregatePerKey computes the count, the sum and the mean of the values
+// associated with each key in a PrivatePCollection<K,V>, adding differentially
+// private noise to each metric.
+//
+// Unlike calling Count, SumPerKey and MeanPerKey separately, AggregatePerKey
+// does contribution bounding and partition selection only once: all metrics
+// are computed on the same bounded contributions, and the output contains the
+// same keys for all metrics. The part of the budget that is not spent on
+// partition selection is split equally between the count and the sum of the
+// distances of the values from the midpoint of [MinValue, MaxValue]; the sum
+// and the mean are both derived from these, so that the mean is consistent
+// with the sum and the count.
+//
+// Note: Do not use when your results may cause overflows for Int64 or Float64
+// values. This aggregation is not hardened for such applications yet.
+//
+// AggregatePerKey transforms a PrivatePCollection<K,V> into a
+// PCollection<K,PerKeyAggregates>.
+func AggregatePerKey(s beam.Scope, pcol PrivatePCollection, params AggregateParams) beam.PCollection {
+	aggregates, err := TryAggregatePerKey(s, pcol, params)
+	if err != nil {
+		log.Exit(err)
+	}
+	return aggregates
+}
+
+// TryAggregatePerKey is the same as AggregatePerKey, but returns an error
+// instead of exiting the program if the input or the parameters are invalid, or
+// if the privacy budget cannot be consumed. All parameters are checked before
+// the budget is consumed, except those that depend on the budget (ε,δ) that the
+// aggregation gets: if ε or δ is invalid for the noise kind, or if the noise of
+// the aggregation can't be calibrated to them with the other parameters, the
+// error is returned after the budget is consumed. With
+// DeferredBudgetAllocation, these checks are done by AllocateBudget instead.
+func TryAggregatePerKey(s beam.Scope, pcol PrivatePCollection, params AggregateParams) (beam.PCollection, error) {
+	s = s.Scope("pbeam.AggregatePerKey")
+	// Obtain & validate type information from the underlying PCollection<K,V>.
+	idT, kvT := beam.ValidateKVType(pcol.col)
+	if kvT.Type() != reflect.TypeOf(kv.Pair{}) {
+		return beam.PCollection{}, fmt.Errorf("AggregatePerKey must be used on a PrivatePCollection of type <K,V>, got type %v instead", kvT)
+	}
+	if pcol.codec == nil {
+		return beam.PCollection{}, fmt.Errorf("AggregatePerKey: no codec found for the input PrivatePCollection.")
+	}
+
+	var noiseKind noise.Kind
+	if params.NoiseKind == nil {
+		noiseKind = noise.LaplaceNoise
+		log.Infof("No NoiseKind specified, using Laplace Noise by default.")
+	} else {
+		noiseKind = params.NoiseKind.toNoiseKind()
+	}
+	// Get privacy parameters.
+	spec := pcol.privacySpec
+	err := checkAggregatePerKeyParams(params)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	// Do contribution bounding. Result is PCollection<partition, []float64>.
+	maxContributionsPerPartition, err := getMaxContributionsPerPartition(params.MaxContributionsPerPartition)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	maxPartitionsContributed, err := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	partialKV, err := boundFloat64ValuesPerPartition(s, pcol, idT, maxPartitionsContributed, maxContributionsPerPartition)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+
+	aggregateFn := new(aggregatePerKeyFn)
+	entry := BudgetLedgerEntry{
+		Scope:                        s.String(),
+		NoiseKind:                    noiseKindName(noiseKind),
+		MaxPartitionsContributed:     params.MaxPartitionsContributed,
+		MaxContributionsPerPartition: params.MaxContributionsPerPartition,
+		MinValue:                     params.MinValue,
+		MaxValue:                     params.MaxValue,
+		MultipleNoises:               true,
+	}
+	entry.PartitionSelectionEpsilonFraction, entry.PartitionSelectionDeltaFraction = partitionSelectionFractions(noiseKind, params.PartitionSelection)
+	err = spec.requestBudget(params.Epsilon, params.Delta, params.BudgetWeight, true, entry, func(epsilon, delta float64) error {
+		err := checkEpsilonAndDelta("pbeam.AggregatePerKey", epsilon, delta, noiseKind, false)
+		if err != nil {
+			return err
+		}
+		fn, err := newAggregatePerKeyFn(epsilon, delta, params.MaxPartitionsContributed, params.MaxContributionsPerPartition, params.MinValue, params.MaxValue, noiseKind, params.PartitionSelection)
+		if err != nil {
+			return err
+		}
+		*aggregateFn = *fn
+		aggregateFn.NoiseSeed = spec.noiseSeed
+		return nil
+	})
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+
+	// Compute all metrics for each partition. Result is
+	// PCollection<partition, PerKeyAggregates>.
+	aggregates := beam.CombinePerKey(s, aggregateFn, partialKV)
+	// Finally, drop thresholded partitions.
+	return beam.ParDo(s, dropThresholdedPartitionsAggregatesFn, aggregates), nil
+}
+
+func checkAggregatePerKeyParams(params AggregateParams) error {
+	err := checkPartitionSelectionParams("pbeam.AggregatePerKey", params.PartitionSelection, false)
+	if err != nil {
+		return err
+	}
+	err = checks.CheckBoundsFloat64("pbeam.AggregatePerKey", params.MinValue, params.MaxValue)
+	if err != nil {
+		return err
+	}
+	return checks.CheckMaxPartitionsContributed("pbeam.AggregatePerKey", params.MaxPartitionsContributed)
+}
+
+type aggregatesAccum struct {
+	// Count is a sum of the number of values contributed by each privacy
+	// identifier, since dpagg.Count only supports one contribution per
+	// partition.
+	Count *dpagg.BoundedSumInt64
+	// NormalizedSum is the sum of the distances of the clamped values from the
+	// midpoint of [Lower, Upper], like in dpagg.BoundedMeanFloat64. The sum and
+	// the mean are derived from it and from Count.
+	NormalizedSum *dpagg.BoundedSumFloat64
+	PS            partitionSelectionAccum
+}
+
+// aggregatePerKeyFn is a differentially private combineFn computing the count,
+// sum and mean of values. Do not initialize it yourself, use
+// newAggregatePerKeyFn to create an aggregatePerKeyFn instance.
+type aggregatePerKeyFn struct {
+	// Privacy spec parameters (set during initial construction). EpsilonNoise
+	// and DeltaNoise are the budget of each metric.
+	EpsilonNoise                 float64
+	EpsilonPartitionSelection    float64
+	DeltaNoise                   float64
+	DeltaPartitionSelection      float64
+	MaxPartitionsContributed     int64
+	MaxContributionsPerPartition int64
+	Lower                        float64
+	Upper                        float64
+	NoiseKind                    noise.Kind
+	noise                        noise.Noise // Set during Setup phase according to NoiseKind.
+	NoiseSeed                    *int64      // Set by NoiseSeedForTesting, nil otherwise.
+	// Partition selection strategy, DefaultPartitionSelection meaning
+	// PreAggPartitionSelection.
+	PartitionSelectionStrategy PartitionSelectionStrategy
+}
+
+// aggregatePerKeyMetrics is the number of noisy metrics sharing the noise
+// budget of an aggregatePerKeyFn: the count and the normalized sum.
+const aggregatePerKeyMetrics = 2
+
+// newAggregatePerKeyFn returns an aggregatePerKeyFn with the given budget and
+// parameters, or an error if noiseKind is not supported.
+func newAggregatePerKeyFn(epsilon, delta float64, maxPartitionsContributed, maxContributionsPerPartition int64, lower, upper float64, noiseKind noise.Kind, partitionSelection PartitionSelectionParams) (*aggregatePerKeyFn, error) {
+	fn := &aggregatePerKeyFn{
+		MaxPartitionsContributed:     maxPartitionsContributed,
+		MaxContributionsPerPartition: maxContributionsPerPartition,
+		Lower:                        lower,
+		Upper:                        upper,
+		NoiseKind:                    noiseKind,
+		PartitionSelectionStrategy:   partitionSelection.Strategy,
+	}
+	switch noiseKind {
+	case noise.GaussianNoise, noise.LaplaceNoise:
+		fn.EpsilonNoise, fn.EpsilonPartitionSelection, fn.DeltaNoise, fn.DeltaPartitionSelection = splitBudget(epsilon, delta, noiseKind, partitionSelection)
+	default:
+		return nil, fmt.Errorf("newAggregatePerKeyFn: unknown noise.Kind (%v) is specified. Please specify a valid noise.", noiseKind)
+	}
+	fn.EpsilonNoise /= aggregatePerKeyMetrics
+	fn.DeltaNoise /= aggregatePerKeyMetrics
+	return fn, nil
+}
+
+func (fn *aggregatePerKeyFn) Setup() {
+	fn.noise = noise.ToNoise(fn.NoiseKind)
+}
+
+func (fn *aggregatePerKeyFn) CreateAccumulator() aggregatesAccum {
+	src := seededSource(fn.NoiseSeed)
+	n := noise.WithSource(fn.noise, src)
+	// Each privacy identifier contributes the sum of the distances of at most
+	// MaxContributionsPerPartition values from the midpoint.
+	maxNormalizedSum := float64(fn.MaxContributionsPerPartition) * (fn.Upper - fn.midPoint())
+	return aggregatesAccum{
+		Count: dpagg.NewBoundedSumInt64(&dpagg.BoundedSumInt64Options{
+			Epsilon:                  fn.EpsilonNoise,
+			Delta:                    fn.DeltaNoise,
+			MaxPartitionsContributed: fn.MaxPartitionsContributed,
+			Lower:                    0,
+			Upper:                    fn.MaxContributionsPerPartition,
+			Noise:                    n,
+		}),
+		NormalizedSum: dpagg.NewBoundedSumFloat64(&dpagg.BoundedSumFloat64Options{
+			Epsilon:                  fn.EpsilonNoise,
+			Delta:                    fn.DeltaNoise,
+			MaxPartitionsContributed: fn.MaxPartitionsContributed,
+			Lower:                    -maxNormalizedSum,
+			Upper:                    maxNormalizedSum,
+			Noise:                    n,
+		}),
+		PS: newPartitionSelectionAccum(fn.PartitionSelectionStrategy, fn.EpsilonPartitionSelection, fn.DeltaPartitionSelection, fn.MaxPartitionsContributed, fn.NoiseKind, n, src),
+	}
+}
+
+// AddInput adds the values contributed by a single privacy identifier to the
+// partition.
+func (fn *aggregatePerKeyFn) AddInput(a aggregatesAccum, values []float64) aggregatesAccum {
+	var count int64
+	var normalizedSum float64
+	midPoint := fn.midPoint()
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		count++
+		normalizedSum += math.Min(math.Max(v, fn.Lower), fn.Upper) - midPoint
+	}
+	a.Count.Add(count)
+	a.NormalizedSum.Add(normalizedSum)
+	a.PS.add()
+	return a
+}
+
+func (fn *aggregatePerKeyFn) MergeAccumulators(a, b aggregatesAccum) aggregatesAccum {
+	a.Count.Merge(b.Count)
+	a.NormalizedSum.Merge(b.NormalizedSum)
+	a.PS.merge(b.PS)
+	return a
+}
+
+// ExtractOutput derives the sum and the mean from the noisy count and
+// normalized sum, which doesn't consume any additional budget: the sum is
+// NormalizedSum + Count·midpoint, and the mean is Sum/Count.
+func (fn *aggregatePerKeyFn) ExtractOutput(a aggregatesAccum) *PerKeyAggregates {
+	if !a.PS.result() {
+		return nil
+	}
+	count := a.Count.Result()
+	if count < 0 {
+		count = 0
+	}
+	normalizedSum := a.NormalizedSum.Result()
+	midPoint := fn.midPoint()
+	mean := normalizedSum/math.Max(1, float64(count)) + midPoint
+	return &PerKeyAggregates{
+		Count: count,
+		Sum:   normalizedSum + float64(count)*midPoint,
+		Mean:  math.Min(math.Max(mean, fn.Lower), fn.Upper),
+	}
+}
+
+// midPoint returns the midpoint of [Lower, Upper], computed in a way that
+// doesn't overflow.
+func (fn *aggregatePerKeyFn) midPoint() float64 {
+	return fn.Lower + (fn.Upper-fn.Lower)/2
+}
+
+func (fn *aggregatePerKeyFn) String() string {
+	return fmt.Sprintf("%#v", fn)
+}
+
+func dropThresholdedPartitionsAggregatesFn(k beam.V, r *PerKeyAggregates, emit func(beam.V, PerKeyAggregates)) {
+	if r != nil {
+		emit(k, *r)
+	}
+}
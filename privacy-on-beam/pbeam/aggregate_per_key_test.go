@@ -0,0 +1,221 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func init() {
+	beam.RegisterFunction(perKeyCountFn)
+	beam.RegisterFunction(perKeySumFn)
+	beam.RegisterFunction(perKeyMeanFn)
+}
+
+func TestNewAggregatePerKeyFn(t *testing.T) {
+	for _, tc := range []struct {
+		desc      string
+		noiseKind noise.Kind
+		want      *aggregatePerKeyFn
+	}{
+		{"Laplace", noise.LaplaceNoise,
+			&aggregatePerKeyFn{
+				EpsilonNoise:                 0.5 / 2,
+				EpsilonPartitionSelection:    0.5,
+				DeltaNoise:                   0,
+				DeltaPartitionSelection:      1e-5,
+				MaxPartitionsContributed:     17,
+				MaxContributionsPerPartition: 5,
+				Lower:                        0,
+				Upper:                        10,
+				NoiseKind:                    noise.LaplaceNoise,
+			}},
+		{"Gaussian", noise.GaussianNoise,
+			&aggregatePerKeyFn{
+				EpsilonNoise:                 0.5 / 2,
+				EpsilonPartitionSelection:    0.5,
+				DeltaNoise:                   5e-6 / 2,
+				DeltaPartitionSelection:      5e-6,
+				MaxPartitionsContributed:     17,
+				MaxContributionsPerPartition: 5,
+				Lower:                        0,
+				Upper:                        10,
+				NoiseKind:                    noise.GaussianNoise,
+			}},
+	} {
+		got, err := newAggregatePerKeyFn(1, 1e-5, 17, 5, 0, 10, tc.noiseKind, PartitionSelectionParams{})
+		if err != nil {
+			t.Fatalf("newAggregatePerKeyFn: for '%s' got error %v", tc.desc, err)
+		}
+		opts := []cmp.Option{
+			cmpopts.EquateApprox(0, 1e-10),
+			cmpopts.IgnoreUnexported(aggregatePerKeyFn{}),
+		}
+		if diff := cmp.Diff(tc.want, got, opts...); diff != "" {
+			t.Errorf("newAggregatePerKeyFn mismatch for '%s' (-want +got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func TestNewAggregatePerKeyFnWithUnknownNoiseKindReturnsError(t *testing.T) {
+	if _, err := newAggregatePerKeyFn(1, 1e-5, 17, 5, 0, 10, noise.Kind(42), PartitionSelectionParams{}); err == nil {
+		t.Errorf("newAggregatePerKeyFn: with an unknown noise kind got no error, want error")
+	}
+}
+
+func TestAggregatePerKeyFnAddInput(t *testing.T) {
+	// Since δ=0.5 and 2 privacy identifiers contribute, PreAggPartitionSelection
+	// always emits. Since ε=1e100, the noise is added with probability in the
+	// order of exp(-1e100), which means we don't have to worry about
+	// tolerance/flakiness calculations.
+	fn, err := newAggregatePerKeyFn(1e100, 0.5, 1, 2, 0, 5, noise.LaplaceNoise, PartitionSelectionParams{})
+	if err != nil {
+		t.Fatalf("newAggregatePerKeyFn: got error %v", err)
+	}
+	fn.Setup()
+
+	accum := fn.CreateAccumulator()
+	fn.AddInput(accum, []float64{2, 6}) // 6 is clamped to 5.
+	fn.AddInput(accum, []float64{4})
+
+	got := fn.ExtractOutput(accum)
+	want := &PerKeyAggregates{Count: 3, Sum: 11, Mean: 11.0 / 3}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 1e-6)); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestAggregatePerKeyFnMeanIsSumOverCount(t *testing.T) {
+	// With a small ε, the noisy count and sum are far from the raw ones, but
+	// the mean is still derived from them.
+	fn, err := newAggregatePerKeyFn(0.5, 0.5, 1, 2, 0, 5, noise.LaplaceNoise, PartitionSelectionParams{})
+	if err != nil {
+		t.Fatalf("newAggregatePerKeyFn: got error %v", err)
+	}
+	fn.Setup()
+
+	accum := fn.CreateAccumulator()
+	for i := 0; i < 1000; i++ {
+		fn.AddInput(accum, []float64{2, 4})
+	}
+	got := fn.ExtractOutput(accum)
+	if got == nil {
+		t.Fatalf("ExtractOutput: got nil for a partition with 1000 privacy units")
+	}
+	if got.Count > 0 && got.Mean > 0 && got.Mean < 5 {
+		if want := got.Sum / float64(got.Count); !cmp.Equal(got.Mean, want, cmpopts.EquateApprox(1e-9, 0)) {
+			t.Errorf("ExtractOutput: got mean %f, want Sum/Count=%f", got.Mean, want)
+		}
+	}
+}
+
+// perKeyCountFn, perKeySumFn and perKeyMeanFn extract a single metric from the
+// output of AggregatePerKey.
+func perKeyCountFn(k int, a PerKeyAggregates) (int, float64) {
+	return k, float64(a.Count)
+}
+
+func perKeySumFn(k int, a PerKeyAggregates) (int, float64) {
+	return k, a.Sum
+}
+
+func perKeyMeanFn(k int, a PerKeyAggregates) (int, float64) {
+	return k, a.Mean
+}
+
+// Checks that AggregatePerKey returns all metrics for the same partitions, and
+// that these metrics are consistent with each other.
+func TestAggregatePerKeyConsistentMetrics(t *testing.T) {
+	triples := concatenateTriplesWithFloatValue(
+		makeTripleWithFloatValue(7, 0, 2.0),
+		makeTripleWithFloatValueStartingFromKey(7, 100, 1, 1.5),
+		makeTripleWithFloatValueStartingFromKey(107, 150, 2, 2.5))
+	// Partition 0 only has 7 privacy IDs and is dropped.
+	wantCounts := []testFloat64Metric{{1, 100}, {2, 150}}
+	wantSums := []testFloat64Metric{{1, 150}, {2, 375}}
+	wantMeans := []testFloat64Metric{{1, 1.5}, {2, 2.5}}
+	p, s, col := ptest.CreateList(triples)
+	col = beam.ParDo(s, extractIDFromTripleWithFloatValue, col)
+
+	// ε=100, δ=10⁻²⁰⁰ and l0Sensitivity=1 gives a threshold of =11 for
+	// partition selection, which gets half of ε. The count and the normalized
+	// sum each get a quarter of ε.
+	epsilon := 100.0
+	delta := 1e-200
+	pcol := MakePrivate(s, col, NewPrivacySpec(epsilon, delta))
+	pcol = ParDo(s, tripleWithFloatValueToKV, pcol)
+	got := AggregatePerKey(s, pcol, AggregateParams{
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		MinValue:                     1,
+		MaxValue:                     3,
+		NoiseKind:                    LaplaceNoise{},
+	})
+
+	// The count and the normalized sum have an L1 sensitivity of 1. The sum
+	// is the normalized sum plus twice the count, and the mean is the sum
+	// divided by a count of at least 100-countTolerance.
+	countTolerance := laplaceTolerance(23, 1, epsilon/4)
+	sumTolerance := 3 * countTolerance
+	meanTolerance := 2 * sumTolerance / 90
+	for _, m := range []struct {
+		name      string
+		extractFn func(int, PerKeyAggregates) (int, float64)
+		want      []testFloat64Metric
+		tolerance float64
+	}{
+		{"Count", perKeyCountFn, wantCounts, countTolerance},
+		{"Sum", perKeySumFn, wantSums, sumTolerance},
+		{"Mean", perKeyMeanFn, wantMeans, meanTolerance},
+	} {
+		metric := beam.ParDo(s, m.extractFn, got)
+		want := beam.ParDo(s, float64MetricToKV, beam.CreateList(s, m.want))
+		if err := approxEqualsKVFloat64(s, metric, want, m.tolerance); err != nil {
+			t.Fatalf("TestAggregatePerKeyConsistentMetrics: %s: %v", m.name, err)
+		}
+	}
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestAggregatePerKeyConsistentMetrics: AggregatePerKey(%v) returned inconsistent metrics: %v", col, err)
+	}
+}
+
+func TestTryAggregatePerKeyReturnsError(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		params AggregateParams
+	}{
+		{"zero MaxPartitionsContributed", AggregateParams{MaxContributionsPerPartition: 1, MinValue: 0, MaxValue: 5}},
+		{"zero MaxContributionsPerPartition", AggregateParams{MaxPartitionsContributed: 1, MinValue: 0, MaxValue: 5}},
+		{"invalid bounds", AggregateParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, MinValue: 5, MaxValue: 0}},
+		{"invalid budget fraction", AggregateParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, MinValue: 0, MaxValue: 5, PartitionSelection: PartitionSelectionParams{BudgetFraction: 2}}},
+	} {
+		triples := makeDummyTripleWithIntValue(10, 0)
+		_, s, col := ptest.CreateList(triples)
+		col = beam.ParDo(s, extractIDFromTripleWithIntValue, col)
+		pcol := MakePrivate(s, col, NewPrivacySpec(1, 1e-5))
+		pcol = ParDo(s, tripleWithIntValueToKV, pcol)
+		if _, err := TryAggregatePerKey(s, pcol, tc.params); err == nil {
+			t.Errorf("TryAggregatePerKey: for %s got no error, want error", tc.desc)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package pbeam
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/rand"
 	"reflect"
@@ -192,30 +193,41 @@ func (fn *decodePairFloat64Fn) ProcessElement(pair pairFloat64) (beam.X, float64
 	return x, pair.M
 }
 
-func newBoundedSumFn(epsilon, delta float64, maxPartitionsContributed int64, lower, upper float64, noiseKind noise.Kind, vKind reflect.Kind) interface{} {
-	var err error
-	var bsFn interface{}
-
+// newBoundedSumFn returns a boundedSumInt64Fn or a boundedSumFloat64Fn
+// depending on vKind, or an error if the parameters are invalid.
+func newBoundedSumFn(epsilon, delta float64, maxPartitionsContributed int64, lower, upper float64, autoBounds bool, noiseKind noise.Kind, vKind reflect.Kind, partitionSelection PartitionSelectionParams, publicPartitions bool) (interface{}, error) {
 	switch vKind {
 	case reflect.Int64:
-		err = checks.CheckBoundsFloat64AsInt64("pbeam.newBoundedSumFn", lower, upper)
-		bsFn = newBoundedSumInt64Fn(epsilon, delta, maxPartitionsContributed, int64(lower), int64(upper), noiseKind)
+		if !autoBounds {
+			if err := checks.CheckBoundsFloat64AsInt64("pbeam.newBoundedSumFn", lower, upper); err != nil {
+				return nil, err
+			}
+		}
+		fn, err := newBoundedSumInt64Fn(epsilon, delta, maxPartitionsContributed, int64(lower), int64(upper), autoBounds, noiseKind, partitionSelection, publicPartitions)
+		if err != nil {
+			return nil, err
+		}
+		return fn, nil
 	case reflect.Float64:
-		err = checks.CheckBoundsFloat64("pbeam.newBoundedSumFn", lower, upper)
-		bsFn = newBoundedSumFloat64Fn(epsilon, delta, maxPartitionsContributed, lower, upper, noiseKind)
+		if !autoBounds {
+			if err := checks.CheckBoundsFloat64("pbeam.newBoundedSumFn", lower, upper); err != nil {
+				return nil, err
+			}
+		}
+		fn, err := newBoundedSumFloat64Fn(epsilon, delta, maxPartitionsContributed, lower, upper, autoBounds, noiseKind, partitionSelection, publicPartitions)
+		if err != nil {
+			return nil, err
+		}
+		return fn, nil
 	default:
-		log.Exitf("pbeam.newBoundedSumFn: vKind(%v) should be int64 or float64", vKind)
-	}
-
-	if err != nil {
-		log.Exit(err)
+		return nil, fmt.Errorf("pbeam.newBoundedSumFn: vKind(%v) should be int64 or float64", vKind)
 	}
-	return bsFn
 }
 
 type boundedSumAccumInt64 struct {
-	BS *dpagg.BoundedSumInt64
-	SP *dpagg.PreAggSelectPartition
+	BS               *dpagg.BoundedSumInt64
+	PS               partitionSelectionAccum
+	PublicPartitions bool
 }
 
 // boundedSumInt64Fn is a differentially private combineFn for summing values. Do not
@@ -229,31 +241,58 @@ type boundedSumInt64Fn struct {
 	MaxPartitionsContributed  int64
 	Lower                     int64
 	Upper                     int64
+	AutoBounds                bool
 	NoiseKind                 noise.Kind
 	noise                     noise.Noise // Set during Setup phase according to NoiseKind.
-}
-
-// newBoundedSumInt64Fn returns a boundedSumInt64Fn with the given budget and parameters.
-func newBoundedSumInt64Fn(epsilon, delta float64, maxPartitionsContributed, lower, upper int64, noiseKind noise.Kind) *boundedSumInt64Fn {
+	NoiseSeed                 *int64      // Set by NoiseSeedForTesting, nil otherwise.
+	// Partition selection strategy, DefaultPartitionSelection meaning
+	// PreAggPartitionSelection.
+	PartitionSelectionStrategy PartitionSelectionStrategy
+	// If true, partition selection is not needed and the entire budget is used
+	// for the noise.
+	PublicPartitions bool
+}
+
+// newBoundedSumInt64Fn returns a boundedSumInt64Fn with the given budget and
+// parameters, or an error if they are invalid.
+func newBoundedSumInt64Fn(epsilon, delta float64, maxPartitionsContributed, lower, upper int64, autoBounds bool, noiseKind noise.Kind, partitionSelection PartitionSelectionParams, publicPartitions bool) (*boundedSumInt64Fn, error) {
 	fn := &boundedSumInt64Fn{
 		MaxPartitionsContributed: maxPartitionsContributed,
 		Lower:                    lower,
 		Upper:                    upper,
+		AutoBounds:               autoBounds,
 		NoiseKind:                noiseKind,
+		PublicPartitions:         publicPartitions,
 	}
-	fn.EpsilonNoise = epsilon / 2
-	fn.EpsilonPartitionSelection = epsilon / 2
-	switch noiseKind {
-	case noise.GaussianNoise:
-		fn.DeltaNoise = delta / 2
-		fn.DeltaPartitionSelection = delta / 2
-	case noise.LaplaceNoise:
-		fn.DeltaNoise = 0
-		fn.DeltaPartitionSelection = delta
-	default:
-		log.Exitf("newBoundedSumInt64Fn: unknown noise.Kind (%v) is specified. Please specify a valid noise.", noiseKind)
+	if noiseKind != noise.GaussianNoise && noiseKind != noise.LaplaceNoise {
+		return nil, fmt.Errorf("newBoundedSumInt64Fn: unknown noise.Kind (%v) is specified. Please specify a valid noise.", noiseKind)
+	}
+	if publicPartitions {
+		fn.EpsilonNoise, fn.DeltaNoise = publicPartitionsBudget(epsilon, delta, noiseKind)
+	} else {
+		fn.PartitionSelectionStrategy = partitionSelection.Strategy
+		fn.EpsilonNoise, fn.EpsilonPartitionSelection, fn.DeltaNoise, fn.DeltaPartitionSelection = splitBudget(epsilon, delta, noiseKind, partitionSelection)
+	}
+	// Check the parameters of the underlying dpagg.BoundedSumInt64 now, since
+	// creating the accumulators would exit the program if they were invalid.
+	if _, err := dpagg.NewBoundedSumInt64E(fn.boundedSumOptions(noise.ToNoise(noiseKind))); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// boundedSumOptions returns the options of the dpagg.BoundedSumInt64 of an
+// accumulator, which uses the noise n.
+func (fn *boundedSumInt64Fn) boundedSumOptions(n noise.Noise) *dpagg.BoundedSumInt64Options {
+	return &dpagg.BoundedSumInt64Options{
+		Epsilon:                  fn.EpsilonNoise,
+		Delta:                    fn.DeltaNoise,
+		MaxPartitionsContributed: fn.MaxPartitionsContributed,
+		Lower:                    fn.Lower,
+		Upper:                    fn.Upper,
+		Noise:                    n,
+		AutoBounds:               fn.AutoBounds,
 	}
-	return fn
 }
 
 func (fn *boundedSumInt64Fn) Setup() {
@@ -261,38 +300,40 @@ func (fn *boundedSumInt64Fn) Setup() {
 }
 
 func (fn *boundedSumInt64Fn) CreateAccumulator() boundedSumAccumInt64 {
-	return boundedSumAccumInt64{
-		BS: dpagg.NewBoundedSumInt64(&dpagg.BoundedSumInt64Options{
-			Epsilon:                  fn.EpsilonNoise,
-			Delta:                    fn.DeltaNoise,
-			MaxPartitionsContributed: fn.MaxPartitionsContributed,
-			Lower:                    fn.Lower,
-			Upper:                    fn.Upper,
-			Noise:                    fn.noise,
-		}),
-		SP: dpagg.NewPreAggSelectPartition(&dpagg.PreAggSelectPartitionOptions{
-			Epsilon:                  fn.EpsilonPartitionSelection,
-			Delta:                    fn.DeltaPartitionSelection,
-			MaxPartitionsContributed: fn.MaxPartitionsContributed,
-		}),
+	src := seededSource(fn.NoiseSeed)
+	n := noise.WithSource(fn.noise, src)
+	accum := boundedSumAccumInt64{
+		BS:               dpagg.NewBoundedSumInt64(fn.boundedSumOptions(n)),
+		PublicPartitions: fn.PublicPartitions,
 	}
+	if !fn.PublicPartitions {
+		accum.PS = newPartitionSelectionAccum(fn.PartitionSelectionStrategy, fn.EpsilonPartitionSelection, fn.DeltaPartitionSelection, fn.MaxPartitionsContributed, fn.NoiseKind, n, src)
+	}
+	return accum
 }
 
 func (fn *boundedSumInt64Fn) AddInput(a boundedSumAccumInt64, value int64) boundedSumAccumInt64 {
 	a.BS.Add(value)
-	a.SP.Add()
+	if !a.PublicPartitions {
+		a.PS.add()
+	}
 	return a
 }
 
 func (fn *boundedSumInt64Fn) MergeAccumulators(a, b boundedSumAccumInt64) boundedSumAccumInt64 {
 	a.BS.Merge(b.BS)
-	a.SP.Merge(b.SP)
+	if !a.PublicPartitions {
+		a.PS.merge(b.PS)
+	}
 	return a
 }
 
 func (fn *boundedSumInt64Fn) ExtractOutput(a boundedSumAccumInt64) *int64 {
-	if a.SP.Result() {
-		result := a.BS.Result()
+	if a.PublicPartitions || a.PS.result() {
+		result, err := a.BS.ResultE()
+		if boundsNotFound(err) {
+			return nil
+		}
 		return &result
 	}
 	return nil
@@ -303,8 +344,9 @@ func (fn *boundedSumInt64Fn) String() string {
 }
 
 type boundedSumAccumFloat64 struct {
-	BS *dpagg.BoundedSumFloat64
-	SP *dpagg.PreAggSelectPartition
+	BS               *dpagg.BoundedSumFloat64
+	PS               partitionSelectionAccum
+	PublicPartitions bool
 }
 
 // boundedSumFloat64Fn is a differentially private combineFn for summing values. Do not
@@ -318,32 +360,60 @@ type boundedSumFloat64Fn struct {
 	MaxPartitionsContributed  int64
 	Lower                     float64
 	Upper                     float64
+	AutoBounds                bool
 	NoiseKind                 noise.Kind
 	// Noise, set during Setup phase according to NoiseKind.
 	noise noise.Noise
-}
-
-// newBoundedSumFloat64Fn returns a boundedSumFloat64Fn with the given budget and parameters.
-func newBoundedSumFloat64Fn(epsilon, delta float64, maxPartitionsContributed int64, lower, upper float64, noiseKind noise.Kind) *boundedSumFloat64Fn {
+	// Seed of the noise, set by NoiseSeedForTesting, nil otherwise.
+	NoiseSeed *int64
+	// Partition selection strategy, DefaultPartitionSelection meaning
+	// PreAggPartitionSelection.
+	PartitionSelectionStrategy PartitionSelectionStrategy
+	// If true, partition selection is not needed and the entire budget is used
+	// for the noise.
+	PublicPartitions bool
+}
+
+// newBoundedSumFloat64Fn returns a boundedSumFloat64Fn with the given budget and
+// parameters, or an error if they are invalid.
+func newBoundedSumFloat64Fn(epsilon, delta float64, maxPartitionsContributed int64, lower, upper float64, autoBounds bool, noiseKind noise.Kind, partitionSelection PartitionSelectionParams, publicPartitions bool) (*boundedSumFloat64Fn, error) {
 	fn := &boundedSumFloat64Fn{
 		MaxPartitionsContributed: maxPartitionsContributed,
 		Lower:                    lower,
 		Upper:                    upper,
+		AutoBounds:               autoBounds,
 		NoiseKind:                noiseKind,
+		PublicPartitions:         publicPartitions,
 	}
-	fn.EpsilonNoise = epsilon / 2
-	fn.EpsilonPartitionSelection = epsilon / 2
-	switch noiseKind {
-	case noise.GaussianNoise:
-		fn.DeltaNoise = delta / 2
-		fn.DeltaPartitionSelection = delta / 2
-	case noise.LaplaceNoise:
-		fn.DeltaNoise = 0
-		fn.DeltaPartitionSelection = delta
-	default:
-		log.Exitf("newBoundedSumFloat64Fn: unknown noise.Kind (%v) is specified. Please specify a valid noise.", noiseKind)
+	if noiseKind != noise.GaussianNoise && noiseKind != noise.LaplaceNoise {
+		return nil, fmt.Errorf("newBoundedSumFloat64Fn: unknown noise.Kind (%v) is specified. Please specify a valid noise.", noiseKind)
+	}
+	if publicPartitions {
+		fn.EpsilonNoise, fn.DeltaNoise = publicPartitionsBudget(epsilon, delta, noiseKind)
+	} else {
+		fn.PartitionSelectionStrategy = partitionSelection.Strategy
+		fn.EpsilonNoise, fn.EpsilonPartitionSelection, fn.DeltaNoise, fn.DeltaPartitionSelection = splitBudget(epsilon, delta, noiseKind, partitionSelection)
+	}
+	// Check the parameters of the underlying dpagg.BoundedSumFloat64 now, since
+	// creating the accumulators would exit the program if they were invalid.
+	if _, err := dpagg.NewBoundedSumFloat64E(fn.boundedSumOptions(noise.ToNoise(noiseKind))); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// boundedSumOptions returns the options of the dpagg.BoundedSumFloat64 of an
+// accumulator, which uses the noise n.
+func (fn *boundedSumFloat64Fn) boundedSumOptions(n noise.Noise) *dpagg.BoundedSumFloat64Options {
+	return &dpagg.BoundedSumFloat64Options{
+		Epsilon:                  fn.EpsilonNoise,
+		Delta:                    fn.DeltaNoise,
+		MaxPartitionsContributed: fn.MaxPartitionsContributed,
+		Lower:                    fn.Lower,
+		Upper:                    fn.Upper,
+		Noise:                    n,
+		AutoBounds:               fn.AutoBounds,
 	}
-	return fn
 }
 
 func (fn *boundedSumFloat64Fn) Setup() {
@@ -351,37 +421,40 @@ func (fn *boundedSumFloat64Fn) Setup() {
 }
 
 func (fn *boundedSumFloat64Fn) CreateAccumulator() boundedSumAccumFloat64 {
-	return boundedSumAccumFloat64{
-		BS: dpagg.NewBoundedSumFloat64(&dpagg.BoundedSumFloat64Options{
-			Epsilon:                  fn.EpsilonNoise,
-			Delta:                    fn.DeltaNoise,
-			MaxPartitionsContributed: fn.MaxPartitionsContributed,
-			Lower:                    fn.Lower,
-			Upper:                    fn.Upper,
-			Noise:                    fn.noise,
-		}),
-		SP: dpagg.NewPreAggSelectPartition(&dpagg.PreAggSelectPartitionOptions{
-			Epsilon:                  fn.EpsilonPartitionSelection,
-			Delta:                    fn.DeltaPartitionSelection,
-			MaxPartitionsContributed: fn.MaxPartitionsContributed,
-		})}
+	src := seededSource(fn.NoiseSeed)
+	n := noise.WithSource(fn.noise, src)
+	accum := boundedSumAccumFloat64{
+		BS:               dpagg.NewBoundedSumFloat64(fn.boundedSumOptions(n)),
+		PublicPartitions: fn.PublicPartitions,
+	}
+	if !fn.PublicPartitions {
+		accum.PS = newPartitionSelectionAccum(fn.PartitionSelectionStrategy, fn.EpsilonPartitionSelection, fn.DeltaPartitionSelection, fn.MaxPartitionsContributed, fn.NoiseKind, n, src)
+	}
+	return accum
 }
 
 func (fn *boundedSumFloat64Fn) AddInput(a boundedSumAccumFloat64, value float64) boundedSumAccumFloat64 {
 	a.BS.Add(value)
-	a.SP.Add()
+	if !a.PublicPartitions {
+		a.PS.add()
+	}
 	return a
 }
 
 func (fn *boundedSumFloat64Fn) MergeAccumulators(a, b boundedSumAccumFloat64) boundedSumAccumFloat64 {
 	a.BS.Merge(b.BS)
-	a.SP.Merge(b.SP)
+	if !a.PublicPartitions {
+		a.PS.merge(b.PS)
+	}
 	return a
 }
 
 func (fn *boundedSumFloat64Fn) ExtractOutput(a boundedSumAccumFloat64) *float64 {
-	if a.SP.Result() {
-		result := a.BS.Result()
+	if a.PublicPartitions || a.PS.result() {
+		result, err := a.BS.ResultE()
+		if boundsNotFound(err) {
+			return nil
+		}
 		return &result
 	}
 	return nil
@@ -391,6 +464,21 @@ func (fn *boundedSumFloat64Fn) String() string {
 	return fmt.Sprintf("%#v", fn)
 }
 
+// boundsNotFound returns true if err says that the bounds of an aggregation
+// with AutoBounds couldn't be determined, in which case its partition is
+// dropped instead of being released with an arbitrary value. It exits the
+// program on any other error.
+func boundsNotFound(err error) bool {
+	if errors.Is(err, dpagg.ErrBoundsNotFound) {
+		log.Warningf("Dropping a partition: %v", err)
+		return true
+	}
+	if err != nil {
+		log.Exit(err)
+	}
+	return false
+}
+
 func findDropThresholdedPartitionsFn(kind reflect.Kind) interface{} {
 	switch kind {
 	case reflect.Int64:
@@ -419,6 +507,14 @@ func dropThresholdedPartitionsFloat64Fn(v beam.V, r *float64, emit func(beam.V,
 	}
 }
 
+// dropThresholdedPartitionsFloat64SliceFn drops thresholded []float64 partitions, i.e.
+// those that have nil r, by emitting only non-thresholded partitions.
+func dropThresholdedPartitionsFloat64SliceFn(v beam.V, r []float64, emit func(beam.V, []float64)) {
+	if r != nil {
+		emit(v, r)
+	}
+}
+
 func findClampNegativePartitionsFn(kind reflect.Kind) interface{} {
 	switch kind {
 	case reflect.Int64:
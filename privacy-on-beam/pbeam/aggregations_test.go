@@ -81,7 +81,10 @@ func TestNewBoundedSumFn(t *testing.T) {
 				NoiseKind:                 noise.GaussianNoise,
 			}},
 	} {
-		got := newBoundedSumFn(1, 1e-5, 17, 0, 10, tc.noiseKind, tc.vKind)
+		got, err := newBoundedSumFn(1, 1e-5, 17, 0, 10, false, tc.noiseKind, tc.vKind, PartitionSelectionParams{}, false)
+		if err != nil {
+			t.Fatalf("newBoundedSumFn: for %q got error %v", tc.desc, err)
+		}
 		if diff := cmp.Diff(tc.want, got, opts...); diff != "" {
 			t.Errorf("newBoundedSumFn mismatch for '%s' (-want +got):\n%s", tc.desc, diff)
 		}
@@ -96,7 +99,10 @@ func TestBoundedSumFloat64FnSetup(t *testing.T) {
 	}{
 		{"Laplace noise kind", noise.LaplaceNoise, noise.Laplace()},
 		{"Gaussian noise kind", noise.GaussianNoise, noise.Gaussian()}} {
-		got := newBoundedSumFloat64Fn(1, 1e-5, 17, 0, 10, tc.noiseKind)
+		got, err := newBoundedSumFloat64Fn(1, 1e-5, 17, 0, 10, false, tc.noiseKind, PartitionSelectionParams{}, false)
+		if err != nil {
+			t.Fatalf("newBoundedSumFloat64Fn: for %q got error %v", tc.desc, err)
+		}
 		got.Setup()
 		if !cmp.Equal(tc.wantNoise, got.noise) {
 			t.Errorf("Setup: for %s got %v, want %v", tc.desc, got.noise, tc.wantNoise)
@@ -112,7 +118,10 @@ func TestBoundedSumInt64FnSetup(t *testing.T) {
 	}{
 		{"Laplace noise kind", noise.LaplaceNoise, noise.Laplace()},
 		{"Gaussian noise kind", noise.GaussianNoise, noise.Gaussian()}} {
-		got := newBoundedSumInt64Fn(1, 1e-5, 17, 0, 10, tc.noiseKind)
+		got, err := newBoundedSumInt64Fn(1, 1e-5, 17, 0, 10, false, tc.noiseKind, PartitionSelectionParams{}, false)
+		if err != nil {
+			t.Fatalf("newBoundedSumInt64Fn: for %q got error %v", tc.desc, err)
+		}
 		got.Setup()
 		if !cmp.Equal(tc.wantNoise, got.noise) {
 			t.Errorf("Setup: for %s got %v, want %v", tc.desc, got.noise, tc.wantNoise)
@@ -120,11 +129,65 @@ func TestBoundedSumInt64FnSetup(t *testing.T) {
 	}
 }
 
+func TestNewBoundedSumFnPartitionSelection(t *testing.T) {
+	params := PartitionSelectionParams{Strategy: NoisyThresholdPartitionSelection, BudgetFraction: 0.1}
+	want := &boundedSumInt64Fn{
+		EpsilonNoise:               0.9,
+		EpsilonPartitionSelection:  0.1,
+		DeltaNoise:                 9e-6,
+		DeltaPartitionSelection:    1e-6,
+		MaxPartitionsContributed:   17,
+		Lower:                      0,
+		Upper:                      10,
+		NoiseKind:                  noise.GaussianNoise,
+		PartitionSelectionStrategy: NoisyThresholdPartitionSelection,
+	}
+	got, err := newBoundedSumFn(1, 1e-5, 17, 0, 10, false, noise.GaussianNoise, reflect.Int64, params, false)
+	if err != nil {
+		t.Fatalf("newBoundedSumFn: got error %v", err)
+	}
+	opts := []cmp.Option{
+		cmpopts.EquateApprox(0, 1e-10),
+		cmpopts.IgnoreUnexported(boundedSumInt64Fn{}),
+	}
+	if diff := cmp.Diff(want, got, opts...); diff != "" {
+		t.Errorf("newBoundedSumFn mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBoundedSumInt64FnNoisyThresholdPartitionSelection(t *testing.T) {
+	// Since ε=1e100, the noise is added with probability in the order of
+	// exp(-1e100), and the threshold is barely above 1: partitions with 2 users
+	// are kept, and empty partitions are dropped.
+	fn, err := newBoundedSumInt64Fn(1e100, 0.5, 1, 0, 2, false, noise.LaplaceNoise, PartitionSelectionParams{Strategy: NoisyThresholdPartitionSelection}, false)
+	if err != nil {
+		t.Fatalf("newBoundedSumInt64Fn: got error %v", err)
+	}
+	fn.Setup()
+
+	accum := fn.CreateAccumulator()
+	if got := fn.ExtractOutput(accum); got != nil {
+		t.Errorf("ExtractOutput: for empty input got: %d, want nil", *got)
+	}
+
+	accum = fn.CreateAccumulator()
+	fn.AddInput(accum, 2)
+	fn.AddInput(accum, 2)
+	got := fn.ExtractOutput(accum)
+	want := int64Ptr(4)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
+
 func TestBoundedSumInt64FnAddInput(t *testing.T) {
 	// Since δ=0.5 and 2 entries are added, PreAggPartitionSelection always emits.
 	// Since ε=1e100, the noise is added with probability in the order of exp(-1e100),
 	// which means we don't have to worry about tolerance/flakiness calculations.
-	fn := newBoundedSumInt64Fn(1e100, 0.5, 1, 0, 2, noise.LaplaceNoise)
+	fn, err := newBoundedSumInt64Fn(1e100, 0.5, 1, 0, 2, false, noise.LaplaceNoise, PartitionSelectionParams{}, false)
+	if err != nil {
+		t.Fatalf("newBoundedSumInt64Fn: got error %v", err)
+	}
 	fn.Setup()
 
 	accum := fn.CreateAccumulator()
@@ -145,7 +208,10 @@ func TestBoundedSumInt64FnMergeAccumulators(t *testing.T) {
 	//
 	// Since ε=1e100, the noise is added with probability in the order of exp(-1e100),
 	// which means we don't have to worry about tolerance/flakiness calculations.
-	fn := newBoundedSumInt64Fn(1e100, 0.5, 1, 0, 2, noise.LaplaceNoise)
+	fn, err := newBoundedSumInt64Fn(1e100, 0.5, 1, 0, 2, false, noise.LaplaceNoise, PartitionSelectionParams{}, false)
+	if err != nil {
+		t.Fatalf("newBoundedSumInt64Fn: got error %v", err)
+	}
 	fn.Setup()
 
 	accum1 := fn.CreateAccumulator()
@@ -171,7 +237,10 @@ func TestBoundedSumInt64FnExtractOutputReturnsNilForSmallPartitions(t *testing.T
 		// The probability of keeping a partition with 1 user is equal to δ=1e-23 which results in a flakiness of 10⁻²³.
 		{"Input with 1 user", 1}} {
 
-		fn := newBoundedSumInt64Fn(1, 1e-23, 1, 0, 2, noise.LaplaceNoise)
+		fn, err := newBoundedSumInt64Fn(1, 1e-23, 1, 0, 2, false, noise.LaplaceNoise, PartitionSelectionParams{}, false)
+		if err != nil {
+			t.Fatalf("newBoundedSumInt64Fn: got error %v", err)
+		}
 		fn.Setup()
 		accum := fn.CreateAccumulator()
 		for i := 0; i < tc.inputSize; i++ {
@@ -190,7 +259,10 @@ func TestBoundedSumInt64FnExtractOutputReturnsNilForSmallPartitions(t *testing.T
 func TestBoundedSumFloat64FnAddInput(t *testing.T) {
 	// Since δ=0.5 and 2 entries are added, PreAggPartitionSelection always emits.
 	// Since ε=1e100, added noise is negligible.
-	fn := newBoundedSumFloat64Fn(1e100, 0.5, 1, 0, 2, noise.LaplaceNoise)
+	fn, err := newBoundedSumFloat64Fn(1e100, 0.5, 1, 0, 2, false, noise.LaplaceNoise, PartitionSelectionParams{}, false)
+	if err != nil {
+		t.Fatalf("newBoundedSumFloat64Fn: got error %v", err)
+	}
 	fn.Setup()
 
 	accum := fn.CreateAccumulator()
@@ -210,7 +282,10 @@ func TestBoundedSumFloat64FnMergeAccumulators(t *testing.T) {
 	// accumulators is also effecting our partition selection outcome.
 	//
 	// Since ε=1e100, added noise is negligible.
-	fn := newBoundedSumFloat64Fn(1e100, 0.5, 1, 0, 2, noise.LaplaceNoise)
+	fn, err := newBoundedSumFloat64Fn(1e100, 0.5, 1, 0, 2, false, noise.LaplaceNoise, PartitionSelectionParams{}, false)
+	if err != nil {
+		t.Fatalf("newBoundedSumFloat64Fn: got error %v", err)
+	}
 	fn.Setup()
 
 	accum1 := fn.CreateAccumulator()
@@ -236,7 +311,10 @@ func TestBoundedSumFloat64FnExtractOutputReturnsNilForSmallPartitions(t *testing
 		// The probability of keeping a partition with 1 user is equal to δ=1e-23 which results in a flakiness of 10⁻²³.
 		{"Input with 1 user", 1}} {
 
-		fn := newBoundedSumFloat64Fn(1, 1e-23, 1, 0, 2, noise.LaplaceNoise)
+		fn, err := newBoundedSumFloat64Fn(1, 1e-23, 1, 0, 2, false, noise.LaplaceNoise, PartitionSelectionParams{}, false)
+		if err != nil {
+			t.Fatalf("newBoundedSumFloat64Fn: got error %v", err)
+		}
 		fn.Setup()
 		accum := fn.CreateAccumulator()
 		for i := 0; i < tc.inputSize; i++ {
@@ -251,3 +329,18 @@ func TestBoundedSumFloat64FnExtractOutputReturnsNilForSmallPartitions(t *testing
 		}
 	}
 }
+
+func TestBoundedSumFloat64FnExtractOutputReturnsNilWhenBoundsNotFound(t *testing.T) {
+	// With public partitions, the partition is kept regardless of the number
+	// of privacy identifiers, but ApproxBounds cannot find bounds for an empty
+	// partition (except with probability 1-SuccessProbability=10⁻⁹).
+	fn, err := newBoundedSumFloat64Fn(1, 0, 1, 0, 0, true, noise.LaplaceNoise, PartitionSelectionParams{}, true)
+	if err != nil {
+		t.Fatalf("newBoundedSumFloat64Fn: got error %v", err)
+	}
+	fn.Setup()
+
+	if got := fn.ExtractOutput(fn.CreateAccumulator()); got != nil {
+		t.Errorf("ExtractOutput: when the bounds cannot be determined got %f, want nil", *got)
+	}
+}
@@ -0,0 +1,172 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// DeferredBudgetAllocation is a PrivacySpecOption that defers the allocation
+// of the privacy budget of a PrivacySpec until the pipeline is fully built.
+//
+// With this option, aggregations that don't specify their Epsilon and Delta
+// don't consume the entire budget of the PrivacySpec. Instead, they register
+// with a relative weight (the BudgetWeight field of their parameters, 1 by
+// default), and once all aggregations are added to the pipeline, a call to
+// PrivacySpec.AllocateBudget splits the remaining budget across them,
+// proportionally to their weights. Aggregations that specify their Epsilon
+// and Delta still consume this budget immediately.
+//
+// AllocateBudget must be called after the pipeline is constructed, and before
+// it is run.
+type DeferredBudgetAllocation struct{}
+
+func (DeferredBudgetAllocation) updatePrivacySpec(ps *PrivacySpec) {
+	ps.deferred = true
+}
+
+// budgetRequest is an aggregation waiting for its share of the privacy budget
+// of a PrivacySpec using DeferredBudgetAllocation.
+type budgetRequest struct {
+	weight float64
+	// Whether the aggregation uses δ. If not, it gets no share of δ.
+	usesDelta bool
+	entry     BudgetLedgerEntry
+	allocate  func(epsilon, delta float64) error
+}
+
+// requestBudget gets a privacy budget (ε,δ) for an aggregation and calls
+// allocate with it. allocate is expected to check the parameters of the
+// aggregation and to initialize its DoFns.
+//
+// If ps uses DeferredBudgetAllocation and epsilon and delta are 0, allocate is
+// only called by AllocateBudget, with a share of the budget proportional to
+// weight. usesDelta must be false for aggregations that don't accept a δ, so
+// that they don't get any. Otherwise, the budget is consumed immediately as
+// with consumeBudget, and allocate is called before requestBudget returns.
+func (ps *PrivacySpec) requestBudget(epsilon, delta, weight float64, usesDelta bool, entry BudgetLedgerEntry, allocate func(epsilon, delta float64) error) error {
+	if weight < 0 {
+		return fmt.Errorf("BudgetWeight must be non-negative, got %f", weight)
+	}
+	if weight == 0 {
+		weight = 1
+	}
+	ps.mux.Lock()
+	if ps.allocated {
+		ps.mux.Unlock()
+		return fmt.Errorf("couldn't consume budget: the budget of the PrivacySpec has already been allocated")
+	}
+	if ps.deferred && epsilon == 0 && delta == 0 {
+		entry.Weight = weight
+		ps.requests = append(ps.requests, budgetRequest{
+			weight:    weight,
+			usesDelta: usesDelta,
+			entry:     entry,
+			allocate:  allocate,
+		})
+		ps.mux.Unlock()
+		return nil
+	}
+	ps.mux.Unlock()
+	eps, del, err := ps.consumeBudget(epsilon, delta, entry)
+	if err != nil {
+		return fmt.Errorf("couldn't consume budget: %v", err)
+	}
+	return allocate(eps, del)
+}
+
+// AllocateBudget splits the privacy budget of a PrivacySpec using
+// DeferredBudgetAllocation across the aggregations that didn't specify their
+// Epsilon and Delta, proportionally to their BudgetWeight, and initializes
+// them. With PLDAccounting, their budgets are larger than with naive
+// composition. It must be called once, after all aggregations using ps are added to
+// the pipeline and before the pipeline is run; no budget can be consumed from
+// ps afterwards.
+//
+// It returns an error if the parameters of one of these aggregations are
+// invalid for the budget that it gets.
+func (ps *PrivacySpec) AllocateBudget() error {
+	ps.mux.Lock()
+	if !ps.deferred {
+		ps.mux.Unlock()
+		return fmt.Errorf("AllocateBudget: the PrivacySpec doesn't use DeferredBudgetAllocation")
+	}
+	if ps.allocated {
+		ps.mux.Unlock()
+		return fmt.Errorf("AllocateBudget: the budget of the PrivacySpec has already been allocated")
+	}
+	if ps.zcdp {
+		ps.mux.Unlock()
+		return fmt.Errorf("AllocateBudget: deferred budget allocation can't be used with ZCDPAccounting")
+	}
+	requests := ps.requests
+	var epsilonWeights, deltaWeights float64
+	for _, r := range requests {
+		epsilonWeights += r.weight
+		if r.usesDelta {
+			deltaWeights += r.weight
+		}
+	}
+	epsilons := make([]float64, len(requests))
+	deltas := make([]float64, len(requests))
+	for i, r := range requests {
+		epsilons[i] = ps.epsilon * r.weight / epsilonWeights
+		if r.usesDelta {
+			deltas[i] = ps.delta * r.weight / deltaWeights
+		}
+	}
+	if ps.pldDiscretization > 0 && len(requests) > 1 {
+		entries := make([]BudgetLedgerEntry, len(requests))
+		for i, r := range requests {
+			entries[i] = r.entry
+		}
+		var err error
+		if epsilons, deltas, err = pldBudgets(entries, epsilons, deltas, ps.epsilon, ps.delta, ps.pldDiscretization); err != nil {
+			ps.mux.Unlock()
+			return fmt.Errorf("AllocateBudget: couldn't compose privacy loss distributions: %v", err)
+		}
+	}
+	ps.allocated = true
+	ps.requests = nil
+	for i, r := range requests {
+		r.entry.Epsilon, r.entry.Delta = epsilons[i], deltas[i]
+		ps.ledger = append(ps.ledger, r.entry)
+	}
+	if len(requests) > 0 {
+		ps.epsilon = 0
+		if deltaWeights > 0 {
+			ps.delta = 0
+		}
+		ps.partiallyConsumed = true
+	}
+	ps.mux.Unlock()
+
+	for i, r := range requests {
+		if err := r.allocate(epsilons[i], deltas[i]); err != nil {
+			return fmt.Errorf("AllocateBudget: %s: %v", r.entry.Scope, err)
+		}
+	}
+	return nil
+}
+
+// setFn sets the fn pointed to by dst to the fn pointed to by src, which must
+// have the same type. It is used to initialize fns that are added to the
+// pipeline before their privacy budget is known.
+func setFn(dst, src interface{}) {
+	reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(src).Elem())
+}
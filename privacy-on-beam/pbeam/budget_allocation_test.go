@@ -0,0 +1,220 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+type allocatedBudget struct {
+	Epsilon, Delta float64
+}
+
+// recordBudget returns an allocate function for requestBudget that records the
+// budget it gets in b.
+func recordBudget(b *allocatedBudget) func(epsilon, delta float64) error {
+	return func(epsilon, delta float64) error {
+		*b = allocatedBudget{Epsilon: epsilon, Delta: delta}
+		return nil
+	}
+}
+
+// Checks that the budget is split across aggregations proportionally to their
+// weights, once AllocateBudget is called.
+func TestDeferredBudgetAllocation(t *testing.T) {
+	spec := NewPrivacySpec(1, 1e-10, DeferredBudgetAllocation{})
+	var got [4]allocatedBudget
+	requests := []struct {
+		epsilon, delta, weight float64
+		usesDelta              bool
+	}{
+		// Explicit budgets are consumed immediately.
+		{epsilon: 0.2, delta: 2e-11, weight: 0, usesDelta: true},
+		{epsilon: 0, delta: 0, weight: 0, usesDelta: true},
+		{epsilon: 0, delta: 0, weight: 2, usesDelta: true},
+		// Aggregations that don't use δ get only a share of ε.
+		{epsilon: 0, delta: 0, weight: 1, usesDelta: false},
+	}
+	for i, r := range requests {
+		if err := spec.requestBudget(r.epsilon, r.delta, r.weight, r.usesDelta, BudgetLedgerEntry{}, recordBudget(&got[i])); err != nil {
+			t.Fatalf("requestBudget(%+v): got error %v", r, err)
+		}
+	}
+	wantBeforeAllocation := [4]allocatedBudget{{Epsilon: 0.2, Delta: 2e-11}}
+	if diff := cmp.Diff(wantBeforeAllocation, got); diff != "" {
+		t.Errorf("requestBudget: budgets before AllocateBudget mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := spec.AllocateBudget(); err != nil {
+		t.Fatalf("AllocateBudget: got error %v", err)
+	}
+	want := [4]allocatedBudget{
+		{Epsilon: 0.2, Delta: 2e-11},
+		{Epsilon: 0.2, Delta: 8e-11 / 3},
+		{Epsilon: 0.4, Delta: 16e-11 / 3},
+		{Epsilon: 0.2, Delta: 0},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(1e-9, 0)); diff != "" {
+		t.Errorf("AllocateBudget: allocated budgets mismatch (-want +got):\n%s", diff)
+	}
+	ledger := spec.BudgetLedger()
+	if len(ledger.Entries) != 4 {
+		t.Errorf("BudgetLedger: got %d entries, want 4", len(ledger.Entries))
+	}
+	if ledger.RemainingEpsilon != 0 || ledger.RemainingDelta != 0 {
+		t.Errorf("BudgetLedger: got remaining budget (%f, %e), want (0, 0)", ledger.RemainingEpsilon, ledger.RemainingDelta)
+	}
+
+	// No budget can be consumed once it is allocated.
+	var b allocatedBudget
+	if err := spec.requestBudget(0, 0, 1, true, BudgetLedgerEntry{}, recordBudget(&b)); err == nil {
+		t.Errorf("requestBudget: got no error after AllocateBudget")
+	}
+	if err := spec.AllocateBudget(); err == nil {
+		t.Errorf("AllocateBudget: got no error when called twice")
+	}
+}
+
+// Checks that without DeferredBudgetAllocation, requestBudget allocates the
+// budget immediately.
+func TestRequestBudgetWithoutDeferredAllocation(t *testing.T) {
+	spec := NewPrivacySpec(1, 1e-10)
+	var got allocatedBudget
+	if err := spec.requestBudget(0, 0, 1, true, BudgetLedgerEntry{}, recordBudget(&got)); err != nil {
+		t.Fatalf("requestBudget: got error %v", err)
+	}
+	if want := (allocatedBudget{Epsilon: 1, Delta: 1e-10}); got != want {
+		t.Errorf("requestBudget: got budget %+v, want %+v", got, want)
+	}
+	if err := spec.AllocateBudget(); err == nil {
+		t.Errorf("AllocateBudget: got no error for a PrivacySpec without DeferredBudgetAllocation")
+	}
+}
+
+func TestRequestBudgetNegativeWeight(t *testing.T) {
+	spec := NewPrivacySpec(1, 1e-10, DeferredBudgetAllocation{})
+	var got allocatedBudget
+	if err := spec.requestBudget(0, 0, -1, true, BudgetLedgerEntry{}, recordBudget(&got)); err == nil {
+		t.Errorf("requestBudget: got no error for a negative weight")
+	}
+}
+
+// Checks that aggregations using a PrivacySpec with DeferredBudgetAllocation
+// get their budget when AllocateBudget is called, and that AllocateBudget
+// returns an error if their parameters are invalid.
+func TestDeferredBudgetAllocationAggregations(t *testing.T) {
+	pairs := makePairsWithFixedV(10, 0)
+	_, s, col := ptest.CreateList(pairs)
+	col = beam.ParDo(s, pairToKV, col)
+
+	spec := NewPrivacySpec(1, 1e-10, DeferredBudgetAllocation{})
+	pcol := MakePrivate(s, col, spec)
+	if _, err := TryCount(s, pcol, CountParams{MaxPartitionsContributed: 1, MaxValue: 1, BudgetWeight: 3}); err != nil {
+		t.Fatalf("TryCount: got error %v", err)
+	}
+	if _, err := TryDistinctPrivacyID(s, pcol, DistinctPrivacyIDParams{MaxPartitionsContributed: 1}); err != nil {
+		t.Fatalf("TryDistinctPrivacyID: got error %v", err)
+	}
+	if err := spec.AllocateBudget(); err != nil {
+		t.Fatalf("AllocateBudget: got error %v", err)
+	}
+	var got []allocatedBudget
+	for _, e := range spec.BudgetLedger().Entries {
+		got = append(got, allocatedBudget{Epsilon: e.Epsilon, Delta: e.Delta})
+	}
+	want := []allocatedBudget{{Epsilon: 0.75, Delta: 7.5e-11}, {Epsilon: 0.25, Delta: 2.5e-11}}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(1e-9, 0)); diff != "" {
+		t.Errorf("AllocateBudget: allocated budgets mismatch (-want +got):\n%s", diff)
+	}
+
+	spec = NewPrivacySpec(1, 0, DeferredBudgetAllocation{})
+	pcol = MakePrivate(s, col, spec)
+	// Gaussian noise needs a positive δ, which is only detected once the budget
+	// is allocated.
+	if _, err := TryCount(s, pcol, CountParams{MaxPartitionsContributed: 1, MaxValue: 1, NoiseKind: GaussianNoise{}}); err != nil {
+		t.Fatalf("TryCount: got error %v", err)
+	}
+	if err := spec.AllocateBudget(); err == nil {
+		t.Errorf("AllocateBudget: got no error for an aggregation with invalid parameters")
+	}
+}
+
+// Checks that aggregations with invalid parameters return an error before
+// consuming any privacy budget.
+func TestTryAggregationsWithInvalidParamsDoNotConsumeBudget(t *testing.T) {
+	// MaxPartitionsContributed is 0 for all aggregations, which is invalid.
+	for _, tc := range []struct {
+		desc      string
+		aggregate func(s beam.Scope, values, pairs PrivatePCollection) error
+	}{
+		{"Count", func(s beam.Scope, values, _ PrivatePCollection) error {
+			_, err := TryCount(s, values, CountParams{MaxValue: 1})
+			return err
+		}},
+		{"DistinctPrivacyID", func(s beam.Scope, values, _ PrivatePCollection) error {
+			_, err := TryDistinctPrivacyID(s, values, DistinctPrivacyIDParams{})
+			return err
+		}},
+		{"SelectPartitions", func(s beam.Scope, values, _ PrivatePCollection) error {
+			_, err := TrySelectPartitions(s, values, SelectPartitionsParams{})
+			return err
+		}},
+		{"SumPerKey", func(s beam.Scope, _, pairs PrivatePCollection) error {
+			_, err := TrySumPerKey(s, pairs, SumParams{MinValue: 0, MaxValue: 5})
+			return err
+		}},
+		{"MeanPerKey", func(s beam.Scope, _, pairs PrivatePCollection) error {
+			_, err := TryMeanPerKey(s, pairs, MeanParams{MaxContributionsPerPartition: 1, MinValue: 0, MaxValue: 5})
+			return err
+		}},
+		{"VariancePerKey", func(s beam.Scope, _, pairs PrivatePCollection) error {
+			_, err := TryVariancePerKey(s, pairs, VarianceParams{MaxContributionsPerPartition: 1, MinValue: 0, MaxValue: 5})
+			return err
+		}},
+		{"QuantilesPerKey", func(s beam.Scope, _, pairs PrivatePCollection) error {
+			_, err := TryQuantilesPerKey(s, pairs, QuantilesParams{MaxContributionsPerPartition: 1, MinValue: 0, MaxValue: 5, Ranks: []float64{0.5}})
+			return err
+		}},
+		{"AggregatePerKey", func(s beam.Scope, _, pairs PrivatePCollection) error {
+			_, err := TryAggregatePerKey(s, pairs, AggregateParams{MaxContributionsPerPartition: 1, MinValue: 0, MaxValue: 5})
+			return err
+		}},
+	} {
+		_, s, col := ptest.CreateList(makePairsWithFixedV(10, 0))
+		col = beam.ParDo(s, pairToKV, col)
+		triples := beam.CreateList(s, makeDummyTripleWithIntValue(10, 0))
+		triples = beam.ParDo(s, extractIDFromTripleWithIntValue, triples)
+
+		spec := NewPrivacySpec(1, 1e-10)
+		values := MakePrivate(s, col, spec)
+		pairs := ParDo(s, tripleWithIntValueToKV, MakePrivate(s, triples, spec))
+		if err := tc.aggregate(s, values, pairs); err == nil {
+			t.Errorf("%s: got no error for MaxPartitionsContributed=0", tc.desc)
+		}
+		if got := spec.BudgetLedger().Entries; len(got) != 0 {
+			t.Errorf("%s: got ledger entries %v after an error, want no entries", tc.desc, got)
+		}
+		if _, _, err := spec.consumeBudget(1, 1e-10, BudgetLedgerEntry{}); err != nil {
+			t.Errorf("%s: couldn't consume the entire budget after an error: %v", tc.desc, err)
+		}
+	}
+}
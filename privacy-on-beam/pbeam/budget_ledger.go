@@ -0,0 +1,121 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// BudgetLedgerEntry records a single consumption of the privacy budget of a
+// PrivacySpec by an aggregation.
+type BudgetLedgerEntry struct {
+	// Name of the scope of the aggregation that consumed the budget, e.g.
+	// "root/pbeam.Count".
+	Scope string `json:"scope"`
+	// Privacy budget (ε,δ) consumed by the aggregation.
+	Epsilon float64 `json:"epsilon"`
+	Delta   float64 `json:"delta"`
+	// Relative weight of the aggregation, if its budget was allocated by
+	// PrivacySpec.AllocateBudget.
+	Weight float64 `json:"weight,omitempty"`
+	// Noise added by the aggregation, e.g. "Laplace" or "Gaussian". Empty for
+	// aggregations that don't add noise to their output, like
+	// SelectPartitions.
+	NoiseKind string `json:"noise_kind,omitempty"`
+	// Whether the aggregation uses public partitions, i.e. spends its entire
+	// budget on noise.
+	PublicPartitions bool `json:"public_partitions,omitempty"`
+	// Fractions of ε and δ that the aggregation spends on partition selection
+	// (or on thresholding its noisy output) rather than on noise. Both 0 with
+	// public partitions.
+	PartitionSelectionEpsilonFraction float64 `json:"partition_selection_epsilon_fraction,omitempty"`
+	PartitionSelectionDeltaFraction   float64 `json:"partition_selection_delta_fraction,omitempty"`
+	// With ZCDPAccounting, the ρ consumed by the aggregation if it only adds
+	// Gaussian noise; its budget is then composed in zCDP rather than by
+	// adding its ε and δ.
+	Rho float64 `json:"rho,omitempty"`
+	// The contribution bounds of the aggregation, which determine its
+	// sensitivities. MaxPartitionsContributed is the l0 sensitivity.
+	MaxPartitionsContributed     int64 `json:"max_partitions_contributed"`
+	MaxContributionsPerPartition int64 `json:"max_contributions_per_partition,omitempty"`
+	// Bounds of the values (or of the sum of the values of a privacy
+	// identifier in a partition, for SumPerKey). Both 0 for aggregations that
+	// don't use bounds, or if AutoBounds is set.
+	MinValue   float64 `json:"min_value,omitempty"`
+	MaxValue   float64 `json:"max_value,omitempty"`
+	AutoBounds bool    `json:"auto_bounds,omitempty"`
+	// Whether the aggregation splits its noise budget across several noises
+	// added to each partition, e.g. MeanPerKey, which adds noise to a count
+	// and to a sum.
+	MultipleNoises bool `json:"multiple_noises,omitempty"`
+}
+
+// BudgetLedger lists all consumptions of the privacy budget of a
+// PrivacySpec.
+type BudgetLedger struct {
+	// Total privacy budget (ε,δ) of the PrivacySpec.
+	TotalEpsilon float64 `json:"total_epsilon"`
+	TotalDelta   float64 `json:"total_delta"`
+	// Privacy budget (ε,δ) that has not been consumed yet.
+	RemainingEpsilon float64 `json:"remaining_epsilon"`
+	RemainingDelta   float64 `json:"remaining_delta"`
+	// With ZCDPAccounting, the total ρ consumed by aggregations that only add
+	// Gaussian noise.
+	Rho float64 `json:"rho,omitempty"`
+	// Consumptions of the budget, in the order in which the aggregations were
+	// added to the pipeline.
+	Entries []BudgetLedgerEntry `json:"entries"`
+}
+
+// BudgetLedger returns a copy of the ledger of all consumptions of the
+// privacy budget of ps so far. Budget is consumed when aggregations are added
+// to a pipeline, so the ledger is complete once the pipeline is constructed.
+func (ps *PrivacySpec) BudgetLedger() BudgetLedger {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+	return BudgetLedger{
+		TotalEpsilon:     ps.totalEpsilon,
+		TotalDelta:       ps.totalDelta,
+		RemainingEpsilon: ps.epsilon,
+		RemainingDelta:   ps.delta,
+		Rho:              ps.gaussianRho,
+		Entries:          append([]BudgetLedgerEntry{}, ps.ledger...),
+	}
+}
+
+// JSON returns the JSON encoding of the ledger.
+func (l BudgetLedger) JSON() ([]byte, error) {
+	return json.MarshalIndent(l, "", "  ")
+}
+
+func noiseKindName(k noise.Kind) string {
+	switch k {
+	case noise.LaplaceNoise:
+		return "Laplace"
+	case noise.GaussianNoise:
+		return "Gaussian"
+	case noise.DiscreteLaplaceNoise:
+		return "discrete Laplace"
+	case noise.DiscreteGaussianNoise:
+		return "discrete Gaussian"
+	default:
+		return fmt.Sprintf("unknown noise kind %d", k)
+	}
+}
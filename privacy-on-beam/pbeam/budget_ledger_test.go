@@ -0,0 +1,89 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// Checks that the budget consumed by each aggregation is recorded in the
+// ledger of the PrivacySpec.
+func TestBudgetLedger(t *testing.T) {
+	pairs := makePairsWithFixedV(10, 0)
+	_, s, col := ptest.CreateList(pairs)
+	col = beam.ParDo(s, pairToKV, col)
+	spec := NewPrivacySpec(1, 1e-10)
+	pcol := MakePrivate(s, col, spec)
+	Count(s, pcol, CountParams{Epsilon: 0.25, Delta: 1e-11, MaxPartitionsContributed: 2, MaxValue: 3, NoiseKind: GaussianNoise{}})
+	DistinctPrivacyID(s, pcol, DistinctPrivacyIDParams{Epsilon: 0.5, Delta: 2e-11, MaxPartitionsContributed: 4})
+
+	got := spec.BudgetLedger()
+	want := BudgetLedger{
+		TotalEpsilon:     1,
+		TotalDelta:       1e-10,
+		RemainingEpsilon: 0.25,
+		RemainingDelta:   7e-11,
+		Entries: []BudgetLedgerEntry{
+			{Epsilon: 0.25, Delta: 1e-11, NoiseKind: "Gaussian", PartitionSelectionEpsilonFraction: 0.5, PartitionSelectionDeltaFraction: 0.5, MaxPartitionsContributed: 2, MaxContributionsPerPartition: 3},
+			// The noisy count of DistinctPrivacyID is thresholded by default.
+			{Epsilon: 0.5, Delta: 2e-11, NoiseKind: "Laplace", PartitionSelectionDeltaFraction: 1, MaxPartitionsContributed: 4, MaxContributionsPerPartition: 1},
+		},
+	}
+	// The scope names are set by Beam, only check that they are present.
+	withoutScopes := got
+	withoutScopes.Entries = nil
+	for _, e := range got.Entries {
+		if e.Scope == "" {
+			t.Errorf("BudgetLedger: got entry %+v with no scope", e)
+		}
+		e.Scope = ""
+		withoutScopes.Entries = append(withoutScopes.Entries, e)
+	}
+	if diff := cmp.Diff(want, withoutScopes, cmpopts.EquateApprox(0, 1e-20)); diff != "" {
+		t.Errorf("BudgetLedger: mismatch (-want +got):\n%s", diff)
+	}
+
+	// The JSON encoding of the ledger can be decoded back.
+	encoded, err := got.JSON()
+	if err != nil {
+		t.Fatalf("JSON: got error %v", err)
+	}
+	var decoded BudgetLedger
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal(%s): got error %v", encoded, err)
+	}
+	if diff := cmp.Diff(got, decoded); diff != "" {
+		t.Errorf("JSON: decoded ledger mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// Checks that a failed budget consumption is not recorded in the ledger.
+func TestBudgetLedgerIgnoresFailedConsumption(t *testing.T) {
+	spec := NewPrivacySpec(1, 1e-10)
+	if _, _, err := spec.consumeBudget(2, 1e-10, BudgetLedgerEntry{}); err == nil {
+		t.Fatalf("consumeBudget: got no error when consuming more than the budget")
+	}
+	if got := spec.BudgetLedger().Entries; len(got) != 0 {
+		t.Errorf("BudgetLedger: got entries %v, want no entries", got)
+	}
+}
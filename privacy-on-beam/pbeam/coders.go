@@ -32,6 +32,11 @@ func init() {
 	beam.RegisterCoder(reflect.TypeOf(boundedSumAccumFloat64{}), encodeBoundedSumAccumFloat64, decodeBoundedSumAccumFloat64)
 	beam.RegisterCoder(reflect.TypeOf(boundedMeanAccumFloat64{}), encodeBoundedMeanAccumFloat64, decodeBoundedMeanAccumFloat64)
 	beam.RegisterCoder(reflect.TypeOf(expandValuesAccum{}), encodeExpandValuesAccum, decodeExpandValuesAccum)
+	beam.RegisterCoder(reflect.TypeOf(boundedQuantilesAccum{}), encodeBoundedQuantilesAccum, decodeBoundedQuantilesAccum)
+	beam.RegisterCoder(reflect.TypeOf(boundedVarianceAccumFloat64{}), encodeBoundedVarianceAccumFloat64, decodeBoundedVarianceAccumFloat64)
+	beam.RegisterCoder(reflect.TypeOf(partitionSet{}), encodePartitionSet, decodePartitionSet)
+	beam.RegisterCoder(reflect.TypeOf(selectPartitionAccum{}), encodeSelectPartitionAccum, decodeSelectPartitionAccum)
+	beam.RegisterCoder(reflect.TypeOf(aggregatesAccum{}), encodeAggregatesAccum, decodeAggregatesAccum)
 }
 
 func encodeCountAccum(ca countAccum) ([]byte, error) {
@@ -74,6 +79,26 @@ func decodeBoundedMeanAccumFloat64(data []byte) (boundedMeanAccumFloat64, error)
 	return ret, err
 }
 
+func encodeBoundedQuantilesAccum(v boundedQuantilesAccum) ([]byte, error) {
+	return encode(v)
+}
+
+func decodeBoundedQuantilesAccum(data []byte) (boundedQuantilesAccum, error) {
+	var ret boundedQuantilesAccum
+	err := decode(&ret, data)
+	return ret, err
+}
+
+func encodeBoundedVarianceAccumFloat64(v boundedVarianceAccumFloat64) ([]byte, error) {
+	return encode(v)
+}
+
+func decodeBoundedVarianceAccumFloat64(data []byte) (boundedVarianceAccumFloat64, error) {
+	var ret boundedVarianceAccumFloat64
+	err := decode(&ret, data)
+	return ret, err
+}
+
 func encodeExpandValuesAccum(v expandValuesAccum) ([]byte, error) {
 	return encode(v)
 }
@@ -94,3 +119,33 @@ func encode(v interface{}) ([]byte, error) {
 func decode(v interface{}, data []byte) error {
 	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
 }
+
+func encodePartitionSet(set partitionSet) ([]byte, error) {
+	return encode(set)
+}
+
+func decodePartitionSet(data []byte) (partitionSet, error) {
+	var ret partitionSet
+	err := decode(&ret, data)
+	return ret, err
+}
+
+func encodeSelectPartitionAccum(a selectPartitionAccum) ([]byte, error) {
+	return encode(a)
+}
+
+func decodeSelectPartitionAccum(data []byte) (selectPartitionAccum, error) {
+	var ret selectPartitionAccum
+	err := decode(&ret, data)
+	return ret, err
+}
+
+func encodeAggregatesAccum(a aggregatesAccum) ([]byte, error) {
+	return encode(a)
+}
+
+func decodeAggregatesAccum(data []byte) (aggregatesAccum, error) {
+	var ret aggregatesAccum
+	err := decode(&ret, data)
+	return ret, err
+}
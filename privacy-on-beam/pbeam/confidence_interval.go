@@ -0,0 +1,364 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+)
+
+// This file contains the combineFns & ParDos used by aggregations that return
+// confidence intervals along with their results, i.e. when ConfidenceLevel is
+// set in their params.
+func init() {
+	beam.RegisterType(reflect.TypeOf(Int64WithConfidenceInterval{}))
+	beam.RegisterType(reflect.TypeOf(Float64WithConfidenceInterval{}))
+	beam.RegisterType(reflect.TypeOf((*boundedSumInt64WithConfidenceIntervalFn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*boundedSumFloat64WithConfidenceIntervalFn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*boundedMeanFloat64WithConfidenceIntervalFn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*fillPublicPartitionsSumInt64WithConfidenceIntervalFn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*fillPublicPartitionsSumFloat64WithConfidenceIntervalFn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*fillPublicPartitionsMeanFloat64WithConfidenceIntervalFn)(nil)))
+	beam.RegisterFunction(dropThresholdedPartitionsInt64WithConfidenceIntervalFn)
+	beam.RegisterFunction(dropThresholdedPartitionsFloat64WithConfidenceIntervalFn)
+	beam.RegisterFunction(clampNegativePartitionsInt64WithConfidenceIntervalFn)
+	beam.RegisterFunction(clampNegativePartitionsFloat64WithConfidenceIntervalFn)
+}
+
+// Int64WithConfidenceInterval is the output value of Count and SumPerKey on
+// integer values when a ConfidenceLevel is set. ConfidenceInterval contains
+// the raw (i.e. contribution-bounded, but not noisy) result with probability
+// at least ConfidenceLevel.
+type Int64WithConfidenceInterval struct {
+	Value              int64
+	ConfidenceInterval noise.ConfidenceInterval
+}
+
+// Float64WithConfidenceInterval is the output value of SumPerKey on float
+// values and of MeanPerKey when a ConfidenceLevel is set. ConfidenceInterval
+// contains the raw (i.e. contribution-bounded, but not noisy) result with
+// probability at least ConfidenceLevel.
+type Float64WithConfidenceInterval struct {
+	Value              float64
+	ConfidenceInterval noise.ConfidenceInterval
+}
+
+// checkConfidenceLevel returns an error if confidenceLevel is set but isn't
+// strictly between 0 and 1.
+func checkConfidenceLevel(label string, confidenceLevel float64) error {
+	if confidenceLevel == 0 {
+		return nil
+	}
+	if math.IsNaN(confidenceLevel) || confidenceLevel < 0 || confidenceLevel >= 1 {
+		return fmt.Errorf("%s: ConfidenceLevel is %f, should be strictly between 0 and 1 (or 0 if no confidence interval is requested)", label, confidenceLevel)
+	}
+	return nil
+}
+
+// confidenceIntervalOrUnbounded returns confInt, or the unbounded interval if
+// it couldn't be computed.
+func confidenceIntervalOrUnbounded(confInt noise.ConfidenceInterval, err error) noise.ConfidenceInterval {
+	if err != nil {
+		log.Warningf("Couldn't compute the confidence interval, returning (-∞, +∞): %v", err)
+		return noise.ConfidenceInterval{LowerBound: math.Inf(-1), UpperBound: math.Inf(1)}
+	}
+	return confInt
+}
+
+// newSumWithConfidenceIntervalFn returns the combineFn wrapping sumFn, which
+// must have been created by newBoundedSumFn, that also returns confidence
+// intervals for the given alpha.
+func newSumWithConfidenceIntervalFn(sumFn interface{}, alpha float64) (interface{}, error) {
+	switch fn := sumFn.(type) {
+	case *boundedSumInt64Fn:
+		return &boundedSumInt64WithConfidenceIntervalFn{SumFn: fn, Alpha: alpha}, nil
+	case *boundedSumFloat64Fn:
+		return &boundedSumFloat64WithConfidenceIntervalFn{SumFn: fn, Alpha: alpha}, nil
+	default:
+		return nil, fmt.Errorf("unexpected sum fn type %T", sumFn)
+	}
+}
+
+// boundedSumInt64WithConfidenceIntervalFn is a boundedSumInt64Fn that also
+// returns the confidence interval of each sum with a 1-Alpha confidence level.
+// The confidence interval is computed from the noisy sum and the parameters of
+// the aggregation only, so it doesn't consume any privacy budget.
+type boundedSumInt64WithConfidenceIntervalFn struct {
+	SumFn *boundedSumInt64Fn
+	Alpha float64
+}
+
+func (fn *boundedSumInt64WithConfidenceIntervalFn) Setup() {
+	fn.SumFn.Setup()
+}
+
+func (fn *boundedSumInt64WithConfidenceIntervalFn) CreateAccumulator() boundedSumAccumInt64 {
+	return fn.SumFn.CreateAccumulator()
+}
+
+func (fn *boundedSumInt64WithConfidenceIntervalFn) AddInput(a boundedSumAccumInt64, value int64) boundedSumAccumInt64 {
+	return fn.SumFn.AddInput(a, value)
+}
+
+func (fn *boundedSumInt64WithConfidenceIntervalFn) MergeAccumulators(a, b boundedSumAccumInt64) boundedSumAccumInt64 {
+	return fn.SumFn.MergeAccumulators(a, b)
+}
+
+func (fn *boundedSumInt64WithConfidenceIntervalFn) ExtractOutput(a boundedSumAccumInt64) *Int64WithConfidenceInterval {
+	result := fn.SumFn.ExtractOutput(a)
+	if result == nil {
+		return nil
+	}
+	return &Int64WithConfidenceInterval{
+		Value:              *result,
+		ConfidenceInterval: confidenceIntervalOrUnbounded(a.BS.ConfidenceIntervalE(fn.Alpha)),
+	}
+}
+
+func (fn *boundedSumInt64WithConfidenceIntervalFn) String() string {
+	return fmt.Sprintf("%#v", fn)
+}
+
+// boundedSumFloat64WithConfidenceIntervalFn is a boundedSumFloat64Fn that also
+// returns the confidence interval of each sum with a 1-Alpha confidence level.
+// The confidence interval is computed from the noisy sum and the parameters of
+// the aggregation only, so it doesn't consume any privacy budget.
+type boundedSumFloat64WithConfidenceIntervalFn struct {
+	SumFn *boundedSumFloat64Fn
+	Alpha float64
+}
+
+func (fn *boundedSumFloat64WithConfidenceIntervalFn) Setup() {
+	fn.SumFn.Setup()
+}
+
+func (fn *boundedSumFloat64WithConfidenceIntervalFn) CreateAccumulator() boundedSumAccumFloat64 {
+	return fn.SumFn.CreateAccumulator()
+}
+
+func (fn *boundedSumFloat64WithConfidenceIntervalFn) AddInput(a boundedSumAccumFloat64, value float64) boundedSumAccumFloat64 {
+	return fn.SumFn.AddInput(a, value)
+}
+
+func (fn *boundedSumFloat64WithConfidenceIntervalFn) MergeAccumulators(a, b boundedSumAccumFloat64) boundedSumAccumFloat64 {
+	return fn.SumFn.MergeAccumulators(a, b)
+}
+
+func (fn *boundedSumFloat64WithConfidenceIntervalFn) ExtractOutput(a boundedSumAccumFloat64) *Float64WithConfidenceInterval {
+	result := fn.SumFn.ExtractOutput(a)
+	if result == nil {
+		return nil
+	}
+	return &Float64WithConfidenceInterval{
+		Value:              *result,
+		ConfidenceInterval: confidenceIntervalOrUnbounded(a.BS.ConfidenceIntervalE(fn.Alpha)),
+	}
+}
+
+func (fn *boundedSumFloat64WithConfidenceIntervalFn) String() string {
+	return fmt.Sprintf("%#v", fn)
+}
+
+// boundedMeanFloat64WithConfidenceIntervalFn is a boundedMeanFloat64Fn that
+// also returns the confidence interval of each mean with a 1-Alpha confidence
+// level. The mean is the ratio of a noisy normalized sum and of a noisy count,
+// and its confidence interval is derived from the confidence intervals of both
+// by dpagg.BoundedMeanFloat64; it doesn't consume any privacy budget.
+type boundedMeanFloat64WithConfidenceIntervalFn struct {
+	MeanFn *boundedMeanFloat64Fn
+	Alpha  float64
+}
+
+func (fn *boundedMeanFloat64WithConfidenceIntervalFn) Setup() {
+	fn.MeanFn.Setup()
+}
+
+func (fn *boundedMeanFloat64WithConfidenceIntervalFn) CreateAccumulator() boundedMeanAccumFloat64 {
+	return fn.MeanFn.CreateAccumulator()
+}
+
+func (fn *boundedMeanFloat64WithConfidenceIntervalFn) AddInput(a boundedMeanAccumFloat64, values []float64) boundedMeanAccumFloat64 {
+	return fn.MeanFn.AddInput(a, values)
+}
+
+func (fn *boundedMeanFloat64WithConfidenceIntervalFn) MergeAccumulators(a, b boundedMeanAccumFloat64) boundedMeanAccumFloat64 {
+	return fn.MeanFn.MergeAccumulators(a, b)
+}
+
+func (fn *boundedMeanFloat64WithConfidenceIntervalFn) ExtractOutput(a boundedMeanAccumFloat64) *Float64WithConfidenceInterval {
+	result := fn.MeanFn.ExtractOutput(a)
+	if result == nil {
+		return nil
+	}
+	return &Float64WithConfidenceInterval{
+		Value:              *result,
+		ConfidenceInterval: confidenceIntervalOrUnbounded(a.BM.ConfidenceIntervalE(fn.Alpha)),
+	}
+}
+
+func (fn *boundedMeanFloat64WithConfidenceIntervalFn) String() string {
+	return fmt.Sprintf("%#v", fn)
+}
+
+func findDropThresholdedPartitionsWithConfidenceIntervalFn(kind reflect.Kind) interface{} {
+	switch kind {
+	case reflect.Int64:
+		return dropThresholdedPartitionsInt64WithConfidenceIntervalFn
+	case reflect.Float64:
+		return dropThresholdedPartitionsFloat64WithConfidenceIntervalFn
+	default:
+		log.Exitf("pbeam.findDropThresholdedPartitionsWithConfidenceIntervalFn: kind(%v) should be int64 or float64", kind)
+	}
+	return nil
+}
+
+// dropThresholdedPartitionsInt64WithConfidenceIntervalFn drops thresholded int
+// partitions, i.e. those that have nil r, by emitting only non-thresholded
+// partitions.
+func dropThresholdedPartitionsInt64WithConfidenceIntervalFn(v beam.V, r *Int64WithConfidenceInterval, emit func(beam.V, Int64WithConfidenceInterval)) {
+	if r != nil {
+		emit(v, *r)
+	}
+}
+
+// dropThresholdedPartitionsFloat64WithConfidenceIntervalFn drops thresholded
+// float partitions, i.e. those that have nil r, by emitting only
+// non-thresholded partitions.
+func dropThresholdedPartitionsFloat64WithConfidenceIntervalFn(v beam.V, r *Float64WithConfidenceInterval, emit func(beam.V, Float64WithConfidenceInterval)) {
+	if r != nil {
+		emit(v, *r)
+	}
+}
+
+func findClampNegativePartitionsWithConfidenceIntervalFn(kind reflect.Kind) interface{} {
+	switch kind {
+	case reflect.Int64:
+		return clampNegativePartitionsInt64WithConfidenceIntervalFn
+	case reflect.Float64:
+		return clampNegativePartitionsFloat64WithConfidenceIntervalFn
+	default:
+		log.Exitf("pbeam.findClampNegativePartitionsWithConfidenceIntervalFn: kind(%v) should be int64 or float64", kind)
+	}
+	return nil
+}
+
+// Clamp negative partitions to zero for int64 partitions, along with the bounds
+// of their confidence intervals. The raw result is nonnegative, so this
+// doesn't change the probability that the interval contains it.
+func clampNegativePartitionsInt64WithConfidenceIntervalFn(v beam.V, r Int64WithConfidenceInterval) (beam.V, Int64WithConfidenceInterval) {
+	if r.Value < 0 {
+		r.Value = 0
+	}
+	r.ConfidenceInterval.LowerBound = math.Max(0, r.ConfidenceInterval.LowerBound)
+	r.ConfidenceInterval.UpperBound = math.Max(0, r.ConfidenceInterval.UpperBound)
+	return v, r
+}
+
+// Clamp negative partitions to zero for float64 partitions, along with the
+// bounds of their confidence intervals.
+func clampNegativePartitionsFloat64WithConfidenceIntervalFn(v beam.V, r Float64WithConfidenceInterval) (beam.V, Float64WithConfidenceInterval) {
+	r.Value = math.Max(0, r.Value)
+	r.ConfidenceInterval.LowerBound = math.Max(0, r.ConfidenceInterval.LowerBound)
+	r.ConfidenceInterval.UpperBound = math.Max(0, r.ConfidenceInterval.UpperBound)
+	return v, r
+}
+
+// fillPublicPartitionsSumInt64WithConfidenceIntervalFn emits the result of the
+// sum of each public partition and its confidence interval, or a noisy empty
+// sum if the partition has no data.
+type fillPublicPartitionsSumInt64WithConfidenceIntervalFn struct {
+	SumFn *boundedSumInt64WithConfidenceIntervalFn
+}
+
+func (fn *fillPublicPartitionsSumInt64WithConfidenceIntervalFn) Setup() {
+	fn.SumFn.Setup()
+}
+
+func (fn *fillPublicPartitionsSumInt64WithConfidenceIntervalFn) ProcessElement(k beam.W, resultIter func(*Int64WithConfidenceInterval) bool, publicIter func(*int64) bool, emit func(beam.W, Int64WithConfidenceInterval)) {
+	var result Int64WithConfidenceInterval
+	var public int64
+	if !publicIter(&public) {
+		return
+	}
+	if !resultIter(&result) {
+		empty := fn.SumFn.ExtractOutput(fn.SumFn.CreateAccumulator())
+		if empty == nil {
+			return
+		}
+		result = *empty
+	}
+	emit(k, result)
+}
+
+// fillPublicPartitionsSumFloat64WithConfidenceIntervalFn emits the result of
+// the sum of each public partition and its confidence interval, or a noisy
+// empty sum if the partition has no data.
+type fillPublicPartitionsSumFloat64WithConfidenceIntervalFn struct {
+	SumFn *boundedSumFloat64WithConfidenceIntervalFn
+}
+
+func (fn *fillPublicPartitionsSumFloat64WithConfidenceIntervalFn) Setup() {
+	fn.SumFn.Setup()
+}
+
+func (fn *fillPublicPartitionsSumFloat64WithConfidenceIntervalFn) ProcessElement(k beam.W, resultIter func(*Float64WithConfidenceInterval) bool, publicIter func(*int64) bool, emit func(beam.W, Float64WithConfidenceInterval)) {
+	var result Float64WithConfidenceInterval
+	var public int64
+	if !publicIter(&public) {
+		return
+	}
+	if !resultIter(&result) {
+		empty := fn.SumFn.ExtractOutput(fn.SumFn.CreateAccumulator())
+		if empty == nil {
+			return
+		}
+		result = *empty
+	}
+	emit(k, result)
+}
+
+// fillPublicPartitionsMeanFloat64WithConfidenceIntervalFn emits the result of
+// the mean of each public partition and its confidence interval, or a noisy
+// empty mean if the partition has no data.
+type fillPublicPartitionsMeanFloat64WithConfidenceIntervalFn struct {
+	MeanFn *boundedMeanFloat64WithConfidenceIntervalFn
+}
+
+func (fn *fillPublicPartitionsMeanFloat64WithConfidenceIntervalFn) Setup() {
+	fn.MeanFn.Setup()
+}
+
+func (fn *fillPublicPartitionsMeanFloat64WithConfidenceIntervalFn) ProcessElement(k beam.W, resultIter func(*Float64WithConfidenceInterval) bool, publicIter func(*int64) bool, emit func(beam.W, Float64WithConfidenceInterval)) {
+	var result Float64WithConfidenceInterval
+	var public int64
+	if !publicIter(&public) {
+		return
+	}
+	if !resultIter(&result) {
+		empty := fn.MeanFn.ExtractOutput(fn.MeanFn.CreateAccumulator())
+		if empty == nil {
+			return
+		}
+		result = *empty
+	}
+	emit(k, result)
+}
@@ -0,0 +1,215 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+func init() {
+	beam.RegisterFunction(checkConfidenceIntervalsInt64Fn)
+	beam.RegisterFunction(checkConfidenceIntervalsFloat64Fn)
+}
+
+// The confidence level used in tests: with α = 10⁻¹⁰, the confidence interval
+// of each partition fails to contain the raw value with probability at most
+// 10⁻¹⁰.
+const testConfidenceLevel = 1 - 1e-10
+
+// checkConfidenceIntervalsInt64Fn returns an error if the result of a partition
+// doesn't exist, or if its confidence interval doesn't contain the raw value
+// or the noisy value.
+func checkConfidenceIntervalsInt64Fn(k int, gotIter func(*Int64WithConfidenceInterval) bool, wantIter func(*int64) bool) error {
+	var got Int64WithConfidenceInterval
+	var want int64
+	if !wantIter(&want) {
+		return nil
+	}
+	if !gotIter(&got) {
+		return fmt.Errorf("for k=%d: got no result, want a result with raw value %d", k, want)
+	}
+	confInt := got.ConfidenceInterval
+	if float64(want) < confInt.LowerBound || float64(want) > confInt.UpperBound {
+		return fmt.Errorf("for k=%d: got confidence interval %+v, want it to contain the raw value %d", k, confInt, want)
+	}
+	if float64(got.Value) < confInt.LowerBound || float64(got.Value) > confInt.UpperBound {
+		return fmt.Errorf("for k=%d: got confidence interval %+v, want it to contain the noisy value %d", k, confInt, got.Value)
+	}
+	return nil
+}
+
+// checkConfidenceIntervalsFloat64Fn is the same as
+// checkConfidenceIntervalsInt64Fn for float64 results.
+func checkConfidenceIntervalsFloat64Fn(k int, gotIter func(*Float64WithConfidenceInterval) bool, wantIter func(*float64) bool) error {
+	var got Float64WithConfidenceInterval
+	var want float64
+	if !wantIter(&want) {
+		return nil
+	}
+	if !gotIter(&got) {
+		return fmt.Errorf("for k=%d: got no result, want a result with raw value %f", k, want)
+	}
+	confInt := got.ConfidenceInterval
+	if want < confInt.LowerBound || want > confInt.UpperBound {
+		return fmt.Errorf("for k=%d: got confidence interval %+v, want it to contain the raw value %f", k, confInt, want)
+	}
+	if got.Value < confInt.LowerBound || got.Value > confInt.UpperBound {
+		return fmt.Errorf("for k=%d: got confidence interval %+v, want it to contain the noisy value %f", k, confInt, got.Value)
+	}
+	return nil
+}
+
+func TestCheckConfidenceLevel(t *testing.T) {
+	for _, tc := range []struct {
+		confidenceLevel float64
+		wantErr         bool
+	}{
+		{0, false},
+		{0.5, false},
+		{0.999, false},
+		{1, true},
+		{-0.5, true},
+		{1.5, true},
+		{math.NaN(), true},
+	} {
+		if err := checkConfidenceLevel("test", tc.confidenceLevel); (err != nil) != tc.wantErr {
+			t.Errorf("checkConfidenceLevel(%f): got error %v, wantErr=%t", tc.confidenceLevel, err, tc.wantErr)
+		}
+	}
+}
+
+// Checks that Count returns confidence intervals that contain the raw counts,
+// including for public partitions with no data.
+func TestCountWithConfidenceIntervals(t *testing.T) {
+	pairs := concatenatePairs(
+		makePairsWithFixedVStartingFromKey(0, 7, 0),
+		makePairsWithFixedVStartingFromKey(7+52, 99, 2),
+		makePairsWithFixedVStartingFromKey(7+52, 99, 2),
+		makePairsWithFixedVStartingFromKey(7+52, 99, 2),
+	)
+	result := []testInt64Metric{
+		{0, 7},
+		{2, 198}, // 99*2
+		{3, 0},
+	}
+	p, s, col, want := ptest.CreateList2(pairs, result)
+	col = beam.ParDo(s, pairToKV, col)
+	publicPartitions := beam.CreateList(s, []int{0, 2, 3})
+
+	pcol := MakePrivate(s, col, NewPrivacySpec(1, 0))
+	got := Count(s, pcol, CountParams{MaxValue: 2, MaxPartitionsContributed: 1, NoiseKind: LaplaceNoise{}, PublicPartitions: publicPartitions, ConfidenceLevel: testConfidenceLevel})
+	if err := checkValueType(got, reflect.TypeOf(Int64WithConfidenceInterval{})); err != nil {
+		t.Fatalf("TestCountWithConfidenceIntervals: %v", err)
+	}
+	want = beam.ParDo(s, int64MetricToKV, want)
+	beam.ParDo0(s, checkConfidenceIntervalsInt64Fn, beam.CoGroupByKey(s, got, want))
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestCountWithConfidenceIntervals: Count(%v) = %v, want confidence intervals containing %v: %v", col, got, want, err)
+	}
+}
+
+// Checks that SumPerKey returns confidence intervals that contain the raw
+// sums for float values.
+func TestSumPerKeyWithConfidenceIntervalsFloat(t *testing.T) {
+	triples := concatenateTriplesWithFloatValue(
+		makeTripleWithFloatValue(7, 0, 2.0),
+		makeTripleWithFloatValueStartingFromKey(7, 100, 1, 1.5))
+	result := []testFloat64Metric{
+		{0, 14},
+		{1, 150},
+		{2, 0},
+	}
+	p, s, col, want := ptest.CreateList2(triples, result)
+	col = beam.ParDo(s, extractIDFromTripleWithFloatValue, col)
+	publicPartitions := beam.CreateList(s, []int{0, 1, 2})
+
+	pcol := MakePrivate(s, col, NewPrivacySpec(1, 1e-5))
+	pcol = ParDo(s, tripleWithFloatValueToKV, pcol)
+	got := SumPerKey(s, pcol, SumParams{MaxPartitionsContributed: 1, MinValue: 0, MaxValue: 2, NoiseKind: GaussianNoise{}, PublicPartitions: publicPartitions, ConfidenceLevel: testConfidenceLevel})
+	if err := checkValueType(got, reflect.TypeOf(Float64WithConfidenceInterval{})); err != nil {
+		t.Fatalf("TestSumPerKeyWithConfidenceIntervalsFloat: %v", err)
+	}
+	want = beam.ParDo(s, float64MetricToKV, want)
+	beam.ParDo0(s, checkConfidenceIntervalsFloat64Fn, beam.CoGroupByKey(s, got, want))
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestSumPerKeyWithConfidenceIntervalsFloat: SumPerKey(%v) = %v, want confidence intervals containing %v: %v", col, got, want, err)
+	}
+}
+
+// Checks that MeanPerKey returns confidence intervals that contain the raw
+// means, even though the means are ratios of two noisy values.
+func TestMeanPerKeyWithConfidenceIntervals(t *testing.T) {
+	triples := concatenateTriplesWithFloatValue(
+		makeTripleWithFloatValueStartingFromKey(0, 100, 1, 1.3),
+		makeTripleWithFloatValueStartingFromKey(100, 150, 1, 2.5))
+	result := []testFloat64Metric{
+		{1, (1.3*100 + 2.5*150) / 250},
+	}
+	p, s, col, want := ptest.CreateList2(triples, result)
+	col = beam.ParDo(s, extractIDFromTripleWithFloatValue, col)
+
+	// The partition has 250 privacy IDs, which is far above the partition
+	// selection threshold for ε=1 and δ=10⁻⁵ (half of the budget is used for
+	// partition selection).
+	pcol := MakePrivate(s, col, NewPrivacySpec(2, 1e-5))
+	pcol = ParDo(s, tripleWithFloatValueToKV, pcol)
+	got := MeanPerKey(s, pcol, MeanParams{
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		MinValue:                     1,
+		MaxValue:                     3,
+		NoiseKind:                    LaplaceNoise{},
+		ConfidenceLevel:              testConfidenceLevel,
+	})
+	if err := checkValueType(got, reflect.TypeOf(Float64WithConfidenceInterval{})); err != nil {
+		t.Fatalf("TestMeanPerKeyWithConfidenceIntervals: %v", err)
+	}
+	want = beam.ParDo(s, float64MetricToKV, want)
+	beam.ParDo0(s, checkConfidenceIntervalsFloat64Fn, beam.CoGroupByKey(s, got, want))
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestMeanPerKeyWithConfidenceIntervals: MeanPerKey(%v) = %v, want confidence intervals containing %v: %v", col, got, want, err)
+	}
+}
+
+func TestClampNegativePartitionsInt64WithConfidenceIntervalFn(t *testing.T) {
+	_, got := clampNegativePartitionsInt64WithConfidenceIntervalFn(0, Int64WithConfidenceInterval{
+		Value:              -3,
+		ConfidenceInterval: noise.ConfidenceInterval{LowerBound: -10, UpperBound: 4},
+	})
+	want := Int64WithConfidenceInterval{Value: 0, ConfidenceInterval: noise.ConfidenceInterval{LowerBound: 0, UpperBound: 4}}
+	if got != want {
+		t.Errorf("clampNegativePartitionsInt64WithConfidenceIntervalFn: got %+v, want %+v", got, want)
+	}
+}
+
+func TestConfidenceIntervalOrUnbounded(t *testing.T) {
+	confInt := noise.ConfidenceInterval{LowerBound: 1, UpperBound: 2}
+	if got := confidenceIntervalOrUnbounded(confInt, nil); got != confInt {
+		t.Errorf("confidenceIntervalOrUnbounded(%+v, nil): got %+v, want %+v", confInt, got, confInt)
+	}
+	got := confidenceIntervalOrUnbounded(confInt, fmt.Errorf("no bounds"))
+	if !math.IsInf(got.LowerBound, -1) || !math.IsInf(got.UpperBound, 1) {
+		t.Errorf("confidenceIntervalOrUnbounded(%+v, err): got %+v, want (-∞, +∞)", confInt, got)
+	}
+}
@@ -37,6 +37,13 @@ type CountParams struct {
 	// only one aggregation, both Epsilon and Delta can be left 0; in that
 	// case, the entire budget of the PrivacySpec is consumed.
 	Epsilon, Delta float64
+	// Relative weight of this aggregation when Epsilon and Delta are left 0
+	// and the PrivacySpec uses DeferredBudgetAllocation: the budget of the
+	// PrivacySpec is then split across aggregations proportionally to their
+	// weights.
+	//
+	// Defaults to 1.
+	BudgetWeight float64
 	// The maximum number of distinct values that a given privacy identifier
 	// can influence. If a privacy identifier is associated to more values,
 	// random values will be dropped. There is an inherent trade-off when
@@ -57,6 +64,33 @@ type CountParams struct {
 	//
 	// Required.
 	MaxValue int64
+	// How the partitions that appear in the output are selected: the
+	// strategy, and the fraction of the budget spent on it. Must be left
+	// unset when PublicPartitions is set.
+	//
+	// Optional.
+	PartitionSelection PartitionSelectionParams
+	// The partitions (i.e. values of the PrivatePCollection) that should be
+	// present in the output, specified as a beam.PCollection<V> or a non-empty
+	// slice of V. Public partitions must not be derived from private data.
+	//
+	// When set, no privacy budget is spent on partition selection: each public
+	// partition appears in the output, with a noisy count even if it has no
+	// data, and the values that are not public partitions are dropped. With
+	// Laplace noise, Delta must then be 0.
+	//
+	// Optional.
+	PublicPartitions interface{}
+	// If set, each output value is an Int64WithConfidenceInterval instead,
+	// which contains the noisy count and a confidence interval that contains
+	// the raw count (i.e. after contribution bounding, but before noise is
+	// added) with probability at least ConfidenceLevel. The confidence
+	// interval is derived from the noisy count and the parameters of the
+	// aggregation, so it doesn't consume any privacy budget. Must be strictly
+	// between 0 and 1 when set.
+	//
+	// Optional.
+	ConfidenceLevel float64
 }
 
 // Count counts the number of times a value appears in a PrivatePCollection,
@@ -67,23 +101,29 @@ type CountParams struct {
 // Note: Do not use when your results may cause overflows for Int64 values.
 // This aggregation is not hardened for such applications yet.
 //
-// Count transforms a PrivatePCollection<V> into a PCollection<V, int64>.
+// Count transforms a PrivatePCollection<V> into a PCollection<V, int64>, or
+// into a PCollection<V, Int64WithConfidenceInterval> if ConfidenceLevel is set.
 func Count(s beam.Scope, pcol PrivatePCollection, params CountParams) beam.PCollection {
-	s = s.Scope("pbeam.Count")
-	// Obtain type information from the underlying PCollection<K,V>.
-	idT, partitionT := beam.ValidateKVType(pcol.col)
-
-	// Get privacy parameters.
-	spec := pcol.privacySpec
-	epsilon, delta, err := spec.consumeBudget(params.Epsilon, params.Delta)
-	err = checkCountParams(params, epsilon, delta)
+	counts, err := TryCount(s, pcol, params)
 	if err != nil {
 		log.Exit(err)
 	}
+	return counts
+}
+
+// TryCount is the same as Count, but returns an error instead of exiting the
+// program if the parameters are invalid or if the privacy budget cannot be
+// consumed. All parameters are checked before the budget is consumed, except
+// those that depend on the budget (ε,δ) that the aggregation gets: if ε or δ is
+// invalid for the noise kind, or if the noise of the aggregation can't be
+// calibrated to them with the other parameters, the error is returned after the
+// budget is consumed. With DeferredBudgetAllocation, these checks are done by
+// AllocateBudget instead.
+func TryCount(s beam.Scope, pcol PrivatePCollection, params CountParams) (beam.PCollection, error) {
+	s = s.Scope("pbeam.Count")
+	// Obtain type information from the underlying PCollection<K,V>.
+	idT, partitionT := beam.ValidateKVType(pcol.col)
 
-	if err != nil {
-		log.Exitf("couldn't consume budget: %v", err)
-	}
 	var noiseKind noise.Kind
 	if params.NoiseKind == nil {
 		noiseKind = noise.LaplaceNoise
@@ -91,10 +131,61 @@ func Count(s beam.Scope, pcol PrivatePCollection, params CountParams) beam.PColl
 	} else {
 		noiseKind = params.NoiseKind.toNoiseKind()
 	}
-	maxPartitionsContributed := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
+	// Get privacy parameters.
+	spec := pcol.privacySpec
+	usePublicPartitions := params.PublicPartitions != nil
+	if err := checkPublicPartitionsWindowing("pbeam.Count", pcol, usePublicPartitions); err != nil {
+		return beam.PCollection{}, err
+	}
+	err := checkCountParams(params)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	maxPartitionsContributed, err := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	col := pcol.col
+	var publicPartitions beam.PCollection
+	if usePublicPartitions {
+		publicPartitions, err = getPublicPartitions(s, params.PublicPartitions, partitionT.Type())
+		if err != nil {
+			return beam.PCollection{}, err
+		}
+		// Drop the values that are not public partitions before contribution
+		// bounding, so that they don't use up the contributions of a user.
+		col = dropNonPublicPartitionsV(s, pcol, publicPartitions, partitionT.Type())
+	}
+	sumFn := new(boundedSumInt64Fn)
+	entry := BudgetLedgerEntry{
+		Scope:                        s.String(),
+		NoiseKind:                    noiseKindName(noiseKind),
+		PublicPartitions:             usePublicPartitions,
+		MaxPartitionsContributed:     params.MaxPartitionsContributed,
+		MaxContributionsPerPartition: params.MaxValue,
+	}
+	if !usePublicPartitions {
+		entry.PartitionSelectionEpsilonFraction, entry.PartitionSelectionDeltaFraction = partitionSelectionFractions(noiseKind, params.PartitionSelection)
+	}
+	err = spec.requestBudget(params.Epsilon, params.Delta, params.BudgetWeight, usesDelta(noiseKind, usePublicPartitions), entry, func(epsilon, delta float64) error {
+		err := checkEpsilonAndDelta("pbeam.Count", epsilon, delta, noiseKind, usePublicPartitions)
+		if err != nil {
+			return err
+		}
+		fn, err := newBoundedSumInt64Fn(epsilon, delta, params.MaxPartitionsContributed, 0, params.MaxValue, false, noiseKind, params.PartitionSelection, usePublicPartitions)
+		if err != nil {
+			return err
+		}
+		*sumFn = *fn
+		sumFn.NoiseSeed = spec.noiseSeed
+		return nil
+	})
+	if err != nil {
+		return beam.PCollection{}, err
+	}
 	// First, encode KV pairs, count how many times each one appears,
 	// and re-key by the original privacy key.
-	coded := beam.ParDo(s, kv.NewEncodeFn(idT, partitionT), pcol.col)
+	coded := beam.ParDo(s, kv.NewEncodeFn(idT, partitionT), col)
 	kvCounts := stats.Count(s, coded)
 	counts64 := beam.ParDo(s, vToInt64Fn, kvCounts)
 	rekeyed := beam.ParDo(s, rekeyInt64Fn, counts64)
@@ -107,21 +198,29 @@ func Count(s beam.Scope, pcol PrivatePCollection, params CountParams) beam.PColl
 		newDecodePairInt64Fn(partitionT.Type()),
 		countPairs,
 		beam.TypeDefinition{Var: beam.XType, T: partitionT.Type()})
-	sums := beam.CombinePerKey(s,
-		newBoundedSumInt64Fn(epsilon, delta, maxPartitionsContributed, 0, params.MaxValue, noiseKind),
-		countsKV)
+	if params.ConfidenceLevel != 0 {
+		// Same as below, but with the confidence interval of each count.
+		ciFn := &boundedSumInt64WithConfidenceIntervalFn{SumFn: sumFn, Alpha: 1 - params.ConfidenceLevel}
+		sums := beam.CombinePerKey(s, ciFn, countsKV)
+		counts := beam.ParDo(s, dropThresholdedPartitionsInt64WithConfidenceIntervalFn, sums)
+		if usePublicPartitions {
+			counts = fillPublicPartitions(s, counts, publicPartitions, &fillPublicPartitionsSumInt64WithConfidenceIntervalFn{SumFn: ciFn})
+		}
+		return beam.ParDo(s, clampNegativePartitionsInt64WithConfidenceIntervalFn, counts), nil
+	}
+	sums := beam.CombinePerKey(s, sumFn, countsKV)
 	// Drop thresholded partitions.
 	counts := beam.ParDo(s, dropThresholdedPartitionsInt64Fn, sums)
+	if usePublicPartitions {
+		// Add the public partitions that have no data.
+		counts = fillPublicPartitions(s, counts, publicPartitions, &fillPublicPartitionsSumInt64Fn{SumFn: sumFn})
+	}
 	// Clamp negative counts to zero and return.
-	return beam.ParDo(s, clampNegativePartitionsInt64Fn, counts)
+	return beam.ParDo(s, clampNegativePartitionsInt64Fn, counts), nil
 }
 
-func checkCountParams(params CountParams, epsilon, delta float64) error{
-	err := checks.CheckEpsilon("pbeam.Count", epsilon)
-	if err != nil {
-		return err
-	}
-	err = checks.CheckDeltaStrict("pbeam.Count", delta)
+func checkCountParams(params CountParams) error {
+	err := checkPartitionSelectionParams("pbeam.Count", params.PartitionSelection, params.PublicPartitions != nil)
 	if err != nil {
 		return err
 	}
@@ -132,5 +231,5 @@ func checkCountParams(params CountParams, epsilon, delta float64) error{
 	if params.MaxValue <= 0 {
 		return fmt.Errorf("pbeam.Count: MaxValue should be strictly positive, got %d", params.MaxValue)
 	}
-	return nil
+	return checkConfidenceLevel("pbeam.Count", params.ConfidenceLevel)
 }
\ No newline at end of file
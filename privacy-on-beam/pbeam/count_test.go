@@ -68,6 +68,47 @@ func TestCountNoNoise(t *testing.T) {
 }
 
 // Checks that Count is performing a random partition selection.
+// Checks that Count with public partitions keeps all public partitions, even
+// those that have few or no users, and drops the others.
+func TestCountWithPublicPartitionsNoNoise(t *testing.T) {
+	// In this test, we set the per-partition l1Sensitivity to 2, and:
+	// - value 0 is associated to 7 users, it is public so it should be kept;
+	// - value 1 is associated to 52 users, but it is not public so it should
+	//   be dropped;
+	// - value 2 is associated to 99 users appearing 3 times each, but the
+	//   l1Sensitivity is 2, so each should only be counted twice;
+	// - value 3 is public but has no users, so its count should be 0.
+	// Each user contributes to at most 1 partition.
+	pairs := concatenatePairs(
+		makePairsWithFixedVStartingFromKey(0, 7, 0),
+		makePairsWithFixedVStartingFromKey(7, 52, 1),
+		makePairsWithFixedVStartingFromKey(7+52, 99, 2),
+		makePairsWithFixedVStartingFromKey(7+52, 99, 2),
+		makePairsWithFixedVStartingFromKey(7+52, 99, 2),
+	)
+	result := []testInt64Metric{
+		{0, 7},
+		{2, 198}, // 99*2
+		{3, 0},
+	}
+	p, s, col, want := ptest.CreateList2(pairs, result)
+	col = beam.ParDo(s, pairToKV, col)
+	publicPartitions := beam.CreateList(s, []int{0, 2, 3})
+
+	// We have 3 partitions. So, to get an overall flakiness of 10⁻²³,
+	// we need to have each partition pass with 1-10⁻²⁵ probability (k=25).
+	epsilon, delta, k, l1Sensitivity := 50.0, 0.0, 25.0, 2.0
+	pcol := MakePrivate(s, col, NewPrivacySpec(epsilon, delta))
+	got := Count(s, pcol, CountParams{MaxValue: 2, MaxPartitionsContributed: 1, NoiseKind: LaplaceNoise{}, PublicPartitions: publicPartitions})
+	want = beam.ParDo(s, int64MetricToKV, want)
+	if err := approxEqualsKVInt64(s, got, want, laplaceTolerance(k, l1Sensitivity, epsilon)); err != nil {
+		t.Fatalf("TestCountWithPublicPartitionsNoNoise: %v", err)
+	}
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestCountWithPublicPartitionsNoNoise: Count(%v) = %v, expected %v: %v", col, got, want, err)
+	}
+}
+
 func TestCountPartitionSelectionNonDeterministic(t *testing.T) {
 	for _, tc := range []struct {
 		name          string
@@ -250,3 +291,28 @@ func TestCountReturnsNonNegative(t *testing.T) {
 		t.Errorf("TestCountReturnsNonNegative returned errors: %v", err)
 	}
 }
+
+// Checks that TryCount returns an error instead of exiting when the parameters
+// are invalid or when the privacy budget is exhausted.
+func TestTryCountReturnsError(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		epsilon float64
+		params  CountParams
+	}{
+		{"zero MaxValue", 1, CountParams{MaxPartitionsContributed: 1}},
+		{"zero MaxPartitionsContributed", 1, CountParams{MaxValue: 1}},
+		{"budget larger than available", 2, CountParams{Epsilon: 3, Delta: 1e-5, MaxValue: 1, MaxPartitionsContributed: 1}},
+		{"public partitions with Laplace noise and non-zero delta", 1, CountParams{MaxValue: 1, MaxPartitionsContributed: 1, PublicPartitions: []int{0}}},
+		{"public partitions of the wrong type", 1, CountParams{NoiseKind: GaussianNoise{}, MaxValue: 1, MaxPartitionsContributed: 1, PublicPartitions: []string{"0"}}},
+		{"confidence level of 1", 1, CountParams{MaxValue: 1, MaxPartitionsContributed: 1, ConfidenceLevel: 1}},
+	} {
+		pairs := makePairsWithFixedV(10, 0)
+		_, s, col := ptest.CreateList(pairs)
+		col = beam.ParDo(s, pairToKV, col)
+		pcol := MakePrivate(s, col, NewPrivacySpec(tc.epsilon, 1e-5))
+		if _, err := TryCount(s, pcol, tc.params); err == nil {
+			t.Errorf("TryCount: for %s got no error, want error", tc.desc)
+		}
+	}
+}
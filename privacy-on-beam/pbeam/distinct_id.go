@@ -45,6 +45,13 @@ type DistinctPrivacyIDParams struct {
 	// only one aggregation, both Epsilon and Delta can be left 0; in that
 	// case, the entire budget of the PrivacySpec is consumed.
 	Epsilon, Delta float64
+	// Relative weight of this aggregation when Epsilon and Delta are left 0
+	// and the PrivacySpec uses DeferredBudgetAllocation: the budget of the
+	// PrivacySpec is then split across aggregations proportionally to their
+	// weights.
+	//
+	// Defaults to 1.
+	BudgetWeight float64
 	// The maximum number of distinct values that a given privacy identifier
 	// can influence. If a privacy identifier is associated to more values,
 	// random values will be dropped. There is an inherent trade-off when
@@ -55,6 +62,23 @@ type DistinctPrivacyIDParams struct {
 	//
 	// Required.
 	MaxPartitionsContributed int64
+	// How the partitions that appear in the output are selected: the
+	// strategy, and the fraction of the budget spent on it. Must be left
+	// unset when PublicPartitions is set.
+	//
+	// Optional.
+	PartitionSelection PartitionSelectionParams
+	// The partitions (i.e. values of the PrivatePCollection) that should be
+	// present in the output, specified as a beam.PCollection<V> or a non-empty
+	// slice of V. Public partitions must not be derived from private data.
+	//
+	// When set, no privacy budget is spent on partition selection: each public
+	// partition appears in the output, with a noisy count even if it has no
+	// data, and the values that are not public partitions are dropped. With
+	// Laplace noise, Delta must then be 0.
+	//
+	// Optional.
+	PublicPartitions interface{}
 }
 
 // DistinctPrivacyID counts the number of distinct privacy identifiers
@@ -69,6 +93,20 @@ type DistinctPrivacyIDParams struct {
 // DistinctPrivacyID transforms a PrivatePCollection<V> into a
 // PCollection<V,int64>.
 func DistinctPrivacyID(s beam.Scope, pcol PrivatePCollection, params DistinctPrivacyIDParams) beam.PCollection {
+	counts, err := TryDistinctPrivacyID(s, pcol, params)
+	if err != nil {
+		log.Exit(err)
+	}
+	return counts
+}
+
+// TryDistinctPrivacyID is the same as DistinctPrivacyID, but returns an error
+// instead of exiting the program if the parameters are invalid or if the
+// privacy budget cannot be consumed. All parameters are checked before the
+// budget is consumed, except the budget (ε,δ) that the aggregation gets: if ε
+// or δ is invalid, the error is returned after the budget is consumed. With
+// DeferredBudgetAllocation, this check is done by AllocateBudget instead.
+func TryDistinctPrivacyID(s beam.Scope, pcol PrivatePCollection, params DistinctPrivacyIDParams) (beam.PCollection, error) {
 	s = s.Scope("pbeam.DistinctPrivacyID")
 	// Obtain type information from the underlying PCollection<K,V>.
 	idT, partitionT := beam.ValidateKVType(pcol.col)
@@ -82,18 +120,58 @@ func DistinctPrivacyID(s beam.Scope, pcol PrivatePCollection, params DistinctPri
 	}
 	// Get privacy parameters.
 	spec := pcol.privacySpec
-	epsilon, delta, err := spec.consumeBudget(params.Epsilon, params.Delta)
+	usePublicPartitions := params.PublicPartitions != nil
+	if err := checkPublicPartitionsWindowing("pbeam.DistinctPrivacyID", pcol, usePublicPartitions); err != nil {
+		return beam.PCollection{}, err
+	}
+	err := checkDistinctPrivacyIDParams(params)
 	if err != nil {
-		log.Exitf("couldn't consume budget: %v", err)
+		return beam.PCollection{}, err
 	}
-	err = checkDistinctPrivacyIDParams(params, noiseKind, epsilon, delta)
+	maxPartitionsContributed, err := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
 	if err != nil {
-		log.Exit(err)
+		return beam.PCollection{}, err
+	}
+	col := pcol.col
+	var publicPartitions beam.PCollection
+	if usePublicPartitions {
+		publicPartitions, err = getPublicPartitions(s, params.PublicPartitions, partitionT.Type())
+		if err != nil {
+			return beam.PCollection{}, err
+		}
+		// Drop the values that are not public partitions before contribution
+		// bounding, so that they don't use up the contributions of a user.
+		col = dropNonPublicPartitionsV(s, pcol, publicPartitions, partitionT.Type())
+	}
+	cFn := new(countFn)
+	entry := BudgetLedgerEntry{
+		Scope:                        s.String(),
+		NoiseKind:                    noiseKindName(noiseKind),
+		PublicPartitions:             usePublicPartitions,
+		MaxPartitionsContributed:     params.MaxPartitionsContributed,
+		MaxContributionsPerPartition: 1,
+	}
+	if !usePublicPartitions {
+		entry.PartitionSelectionEpsilonFraction, entry.PartitionSelectionDeltaFraction = partitionSelectionFractions(noiseKind, params.PartitionSelection)
+		if params.PartitionSelection.Strategy != PreAggPartitionSelection {
+			// The noisy count is thresholded, so the entire ε is used for it.
+			entry.PartitionSelectionEpsilonFraction = 0
+		}
+	}
+	err = spec.requestBudget(params.Epsilon, params.Delta, params.BudgetWeight, usesDelta(noiseKind, usePublicPartitions), entry, func(epsilon, delta float64) error {
+		err := checkDistinctPrivacyIDBudget(params, noiseKind, epsilon, delta)
+		if err != nil {
+			return err
+		}
+		*cFn = *newCountFn(epsilon, delta, params.MaxPartitionsContributed, noiseKind, params.PartitionSelection, usePublicPartitions)
+		cFn.NoiseSeed = spec.noiseSeed
+		return nil
+	})
+	if err != nil {
+		return beam.PCollection{}, err
 	}
-
-	maxPartitionsContributed := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
 	// First, deduplicate KV pairs by encoding them and calling Distinct.
-	coded := beam.ParDo(s, kv.NewEncodeFn(idT, partitionT), pcol.col)
+	coded := beam.ParDo(s, kv.NewEncodeFn(idT, partitionT), col)
 	distinct := filter.Distinct(s, coded)
 	decoded := beam.ParDo(s,
 		kv.NewDecodeFn(idT, partitionT),
@@ -106,29 +184,40 @@ func DistinctPrivacyID(s beam.Scope, pcol PrivatePCollection, params DistinctPri
 	// done, remove the keys and count how many times each value appears.
 	values := beam.DropKey(s, decoded)
 	dummyCounts := beam.ParDo(s, addOneValueFn, values)
-	noisedCounts := beam.CombinePerKey(s,
-		newCountFn(epsilon, delta, maxPartitionsContributed, noiseKind),
-		dummyCounts)
+	noisedCounts := beam.CombinePerKey(s, cFn, dummyCounts)
 	// Finally, drop thresholded partitions and return the result
-	return beam.ParDo(s, dropThresholdedPartitionsInt64Fn, noisedCounts)
+	counts := beam.ParDo(s, dropThresholdedPartitionsInt64Fn, noisedCounts)
+	if usePublicPartitions {
+		// Add the public partitions that have no data. Since the counts of
+		// public partitions are not thresholded, clamp negative counts to zero.
+		counts = fillPublicPartitions(s, counts, publicPartitions, &fillPublicPartitionsCountFn{CountFn: cFn})
+		counts = beam.ParDo(s, clampNegativePartitionsInt64Fn, counts)
+	}
+	return counts, nil
 }
 
-func checkDistinctPrivacyIDParams(params DistinctPrivacyIDParams, noiseKind noise.Kind, epsilon, delta float64) error {
-	err := checks.CheckEpsilon("pbeam.DistinctPrivacyID", epsilon)
-	if err != nil {
-		return err
-	}
-	if noiseKind == noise.LaplaceNoise {
-		err = checks.CheckDelta("pbeam.DistinctPrivacyID", delta)
-	} else {
-		err = checks.CheckDeltaStrict("pbeam.DistinctPrivacyID", delta)
-	}
+func checkDistinctPrivacyIDParams(params DistinctPrivacyIDParams) error {
+	err := checkPartitionSelectionParams("pbeam.DistinctPrivacyID", params.PartitionSelection, params.PublicPartitions != nil)
 	if err != nil {
 		return err
 	}
 	return checks.CheckMaxPartitionsContributed("pbeam.DistinctPrivacyID", params.MaxPartitionsContributed)
 }
 
+// checkDistinctPrivacyIDBudget is the same as checkEpsilonAndDelta, except
+// that δ may be 0 with Laplace noise if the partitions are selected with a
+// strategy that doesn't need any δ.
+func checkDistinctPrivacyIDBudget(params DistinctPrivacyIDParams, noiseKind noise.Kind, epsilon, delta float64) error {
+	if params.PublicPartitions == nil && noiseKind == noise.LaplaceNoise && params.PartitionSelection.Strategy != PreAggPartitionSelection {
+		err := checks.CheckEpsilon("pbeam.DistinctPrivacyID", epsilon)
+		if err != nil {
+			return err
+		}
+		return checks.CheckDelta("pbeam.DistinctPrivacyID", delta)
+	}
+	return checkEpsilonAndDelta("pbeam.DistinctPrivacyID", epsilon, delta, noiseKind, params.PublicPartitions != nil)
+}
+
 func addOneValueFn(v beam.V) (beam.V, int64) {
 	return v, 1
 }
@@ -142,22 +231,39 @@ type countFn struct {
 	MaxPartitionsContributed int64
 	NoiseKind                noise.Kind
 	noise                    noise.Noise // Set during Setup phase according to NoiseKind.
+	NoiseSeed                *int64      // Set by NoiseSeedForTesting, nil otherwise.
+	// Budget of PreAggPartitionSelection, only used with this strategy.
+	EpsilonPartitionSelection float64
+	DeltaPartitionSelection   float64
+	// Partition selection strategy, DefaultPartitionSelection meaning
+	// NoisyThresholdPartitionSelection.
+	PartitionSelectionStrategy PartitionSelectionStrategy
+	// If true, no thresholding is done and the entire budget is used for the
+	// noise.
+	PublicPartitions bool
 }
 
 // newCountFn returns a newCountFn with the given budget and parameters.
-func newCountFn(epsilon, delta float64, maxPartitionsContributed int64, noiseKind noise.Kind) *countFn {
+func newCountFn(epsilon, delta float64, maxPartitionsContributed int64, noiseKind noise.Kind, partitionSelection PartitionSelectionParams, publicPartitions bool) *countFn {
 	fn := &countFn{
 		MaxPartitionsContributed: maxPartitionsContributed,
 		NoiseKind:                noiseKind,
+		PublicPartitions:         publicPartitions,
 	}
-	fn.Epsilon = epsilon
+	if publicPartitions {
+		fn.Epsilon, fn.DeltaNoise = publicPartitionsBudget(epsilon, delta, noiseKind)
+		return fn
+	}
+	fn.PartitionSelectionStrategy = partitionSelection.Strategy
 	switch noiseKind {
-	case noise.GaussianNoise:
-		fn.DeltaNoise = delta / 2
-		fn.DeltaThreshold = delta / 2
-	case noise.LaplaceNoise:
-		fn.DeltaNoise = 0
-		fn.DeltaThreshold = delta
+	case noise.GaussianNoise, noise.LaplaceNoise:
+		if partitionSelection.Strategy == PreAggPartitionSelection {
+			fn.Epsilon, fn.EpsilonPartitionSelection, fn.DeltaNoise, fn.DeltaPartitionSelection = splitBudget(epsilon, delta, noiseKind, partitionSelection)
+		} else {
+			// The noisy count is thresholded, so the entire ε is used for it.
+			_, _, fn.DeltaNoise, fn.DeltaThreshold = splitBudget(epsilon, delta, noiseKind, partitionSelection)
+			fn.Epsilon = epsilon
+		}
 	default:
 		log.Exitf("newCountFn: unknown NoiseKind (%v) is specified. Please specify a valid noise.", noiseKind)
 	}
@@ -169,31 +275,60 @@ func (fn *countFn) Setup() {
 }
 
 type countAccum struct {
-	C *dpagg.Count
+	C  *dpagg.Count
+	SP *dpagg.PreAggSelectPartition // Only set with PreAggPartitionSelection.
 }
 
 func (fn *countFn) CreateAccumulator() countAccum {
-	return countAccum{C: dpagg.NewCount(&dpagg.CountOptions{
+	src := seededSource(fn.NoiseSeed)
+	n := noise.WithSource(fn.noise, src)
+	accum := countAccum{C: dpagg.NewCount(&dpagg.CountOptions{
 		Epsilon:                  fn.Epsilon,
 		Delta:                    fn.DeltaNoise,
 		MaxPartitionsContributed: fn.MaxPartitionsContributed,
-		Noise:                    fn.noise,
+		Noise:                    n,
 	})}
+	if !fn.PublicPartitions && fn.PartitionSelectionStrategy == PreAggPartitionSelection {
+		accum.SP = dpagg.NewPreAggSelectPartition(&dpagg.PreAggSelectPartitionOptions{
+			Epsilon:                  fn.EpsilonPartitionSelection,
+			Delta:                    fn.DeltaPartitionSelection,
+			MaxPartitionsContributed: fn.MaxPartitionsContributed,
+			Source:                   src,
+		})
+	}
+	return accum
 }
 
 // AddInput adds one to the count of observed values. It ignores the actual
 // contents of value.
 func (fn *countFn) AddInput(a countAccum, value beam.X) countAccum {
 	a.C.Increment()
+	if a.SP != nil {
+		a.SP.Add()
+	}
 	return a
 }
 
 func (fn *countFn) MergeAccumulators(a, b countAccum) countAccum {
 	a.C.Merge(b.C)
+	if a.SP != nil {
+		a.SP.Merge(b.SP)
+	}
 	return a
 }
 
 func (fn *countFn) ExtractOutput(a countAccum) *int64 {
+	if fn.PublicPartitions {
+		result := a.C.Result()
+		return &result
+	}
+	if a.SP != nil {
+		if !a.SP.Result() {
+			return nil
+		}
+		result := a.C.Result()
+		return &result
+	}
 	return a.C.ThresholdedResult(fn.DeltaThreshold)
 }
 
@@ -47,6 +47,13 @@ type MeanParams struct {
 	// only one aggregation, both Epsilon and Delta can be left 0; in that
 	// case, the entire budget of the PrivacySpec is consumed.
 	Epsilon, Delta float64
+	// Relative weight of this aggregation when Epsilon and Delta are left 0
+	// and the PrivacySpec uses DeferredBudgetAllocation: the budget of the
+	// PrivacySpec is then split across aggregations proportionally to their
+	// weights.
+	//
+	// Defaults to 1.
+	BudgetWeight float64
 	// The maximum number of distinct values that a given privacy identifier
 	// can influence. There is an inherent trade-off when choosing this
 	// parameter: a larger MaxPartitionsContributed leads to less data loss due
@@ -76,8 +83,44 @@ type MeanParams struct {
 	// large MaxValue means that less records will be clamped, but that more
 	// noise will be added.
 	//
-	// Required.
+	// Required, unless AutoBounds is set.
 	MinValue, MaxValue float64
+	// If true, MinValue and MaxValue must be left 0, and the bounds are
+	// determined from the data in a differentially private way, using part of
+	// the privacy budget of the aggregation: half of the budget that would be
+	// spent on noise is spent on finding the bounds with dpagg.ApproxBounds.
+	// This only works well for partitions with a large number of privacy
+	// identifiers; partitions for which no bounds can be found are dropped.
+	// Must be false when PublicPartitions is set.
+	AutoBounds bool
+	// How the partitions that appear in the output are selected: the
+	// strategy, and the fraction of the budget spent on it. Must be left
+	// unset when PublicPartitions is set.
+	//
+	// Optional.
+	PartitionSelection PartitionSelectionParams
+	// The partitions (i.e. keys of the PrivatePCollection) that should be
+	// present in the output, specified as a beam.PCollection<K> or a non-empty
+	// slice of K. Public partitions must not be derived from private data.
+	//
+	// When set, no privacy budget is spent on partition selection: each public
+	// partition appears in the output, with a noisy mean even if it has no
+	// data, and the keys that are not public partitions are dropped. With
+	// Laplace noise, Delta must then be 0.
+	//
+	// Optional.
+	PublicPartitions interface{}
+	// If set, each output value is a Float64WithConfidenceInterval instead,
+	// which contains the noisy mean and a confidence interval that contains
+	// the raw mean (i.e. after contribution bounding, but before noise is
+	// added) with probability at least ConfidenceLevel. The mean divides a
+	// noisy sum by a noisy count, and its confidence interval accounts for the
+	// noise of both. It is derived from the noisy values and the parameters of
+	// the aggregation, so it doesn't consume any privacy budget. Must be
+	// strictly between 0 and 1 when set.
+	//
+	// Optional.
+	ConfidenceLevel float64
 }
 
 // MeanPerKey obtains the mean of the values associated with each key in a
@@ -88,28 +131,37 @@ type MeanParams struct {
 // Note: Do not use when your results may cause overflows for Int64 or Float64
 // values.  This aggregation is not hardened for such applications yet.
 //
-// MeanPerKey transforms a PrivatePCollection<K,V> into a PCollection<K,float64>.
+// MeanPerKey transforms a PrivatePCollection<K,V> into a PCollection<K,float64>,
+// or into a PCollection<K,Float64WithConfidenceInterval> if ConfidenceLevel is
+// set. V can be any integer or float type: the values are converted to float64
+// before aggregation.
 func MeanPerKey(s beam.Scope, pcol PrivatePCollection, params MeanParams) beam.PCollection {
+	means, err := TryMeanPerKey(s, pcol, params)
+	if err != nil {
+		log.Exit(err)
+	}
+	return means
+}
+
+// TryMeanPerKey is the same as MeanPerKey, but returns an error instead of
+// exiting the program if the input or the parameters are invalid, or if the
+// privacy budget cannot be consumed. All parameters are checked before the
+// budget is consumed, except those that depend on the budget (ε,δ) that the
+// aggregation gets: if ε or δ is invalid for the noise kind, or if the noise of
+// the aggregation can't be calibrated to them with the other parameters, the
+// error is returned after the budget is consumed. With
+// DeferredBudgetAllocation, these checks are done by AllocateBudget instead.
+func TryMeanPerKey(s beam.Scope, pcol PrivatePCollection, params MeanParams) (beam.PCollection, error) {
 	s = s.Scope("pbeam.MeanPerKey")
 	// Obtain & validate type information from the underlying PCollection<K,V>.
 	idT, kvT := beam.ValidateKVType(pcol.col)
 	if kvT.Type() != reflect.TypeOf(kv.Pair{}) {
-		log.Exitf("MeanPerKey must be used on a PrivatePCollection of type <K,V>, got type %v instead", kvT)
+		return beam.PCollection{}, fmt.Errorf("MeanPerKey must be used on a PrivatePCollection of type <K,V>, got type %v instead", kvT)
 	}
 	if pcol.codec == nil {
-		log.Exitf("MeanPerKey: no codec found for the input PrivatePCollection.")
+		return beam.PCollection{}, fmt.Errorf("MeanPerKey: no codec found for the input PrivatePCollection.")
 	}
 
-	// Get privacy parameters.
-	spec := pcol.privacySpec
-	epsilon, delta, err := spec.consumeBudget(params.Epsilon, params.Delta)
-	if err != nil {
-		log.Exitf("couldn't consume budget: %v", err)
-	}
-	err = checkMeanPerKeyParams(params, epsilon, delta)
-	if err != nil {
-		log.Exit(err)
-	}
 	var noiseKind noise.Kind
 	if params.NoiseKind == nil {
 		noiseKind = noise.LaplaceNoise
@@ -117,7 +169,103 @@ func MeanPerKey(s beam.Scope, pcol PrivatePCollection, params MeanParams) beam.P
 	} else {
 		noiseKind = params.NoiseKind.toNoiseKind()
 	}
+	// Get privacy parameters.
+	spec := pcol.privacySpec
+	usePublicPartitions := params.PublicPartitions != nil
+	if err := checkPublicPartitionsWindowing("pbeam.MeanPerKey", pcol, usePublicPartitions); err != nil {
+		return beam.PCollection{}, err
+	}
+	err := checkMeanPerKeyParams(params)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	var publicPartitions beam.PCollection
+	if usePublicPartitions {
+		publicPartitions, err = getPublicPartitions(s, params.PublicPartitions, pcol.codec.KType.T)
+		if err != nil {
+			return beam.PCollection{}, err
+		}
+		// Drop the keys that are not public partitions before contribution
+		// bounding, so that they don't use up the contributions of a user.
+		pcol.col = dropNonPublicPartitionsKV(s, pcol, publicPartitions)
+	}
 
+	// Do contribution bounding. Result is PCollection<partition, []float64>.
+	maxContributionsPerPartition, err := getMaxContributionsPerPartition(params.MaxContributionsPerPartition)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	maxPartitionsContributed, err := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	partialKV, err := boundFloat64ValuesPerPartition(s, pcol, idT, maxPartitionsContributed, maxContributionsPerPartition)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+
+	meanFn := new(boundedMeanFloat64Fn)
+	entry := BudgetLedgerEntry{
+		Scope:                        s.String(),
+		NoiseKind:                    noiseKindName(noiseKind),
+		PublicPartitions:             usePublicPartitions,
+		MaxPartitionsContributed:     params.MaxPartitionsContributed,
+		MaxContributionsPerPartition: params.MaxContributionsPerPartition,
+		MinValue:                     params.MinValue,
+		MaxValue:                     params.MaxValue,
+		AutoBounds:                   params.AutoBounds,
+		MultipleNoises:               true,
+	}
+	if !usePublicPartitions {
+		entry.PartitionSelectionEpsilonFraction, entry.PartitionSelectionDeltaFraction = partitionSelectionFractions(noiseKind, params.PartitionSelection)
+	}
+	err = spec.requestBudget(params.Epsilon, params.Delta, params.BudgetWeight, usesDelta(noiseKind, usePublicPartitions), entry, func(epsilon, delta float64) error {
+		err := checkEpsilonAndDelta("pbeam.MeanPerKey", epsilon, delta, noiseKind, usePublicPartitions)
+		if err != nil {
+			return err
+		}
+		fn, err := newBoundedMeanFloat64Fn(epsilon, delta, params.MaxPartitionsContributed, params.MaxContributionsPerPartition, params.MinValue, params.MaxValue, params.AutoBounds, noiseKind, params.PartitionSelection, usePublicPartitions)
+		if err != nil {
+			return err
+		}
+		*meanFn = *fn
+		meanFn.NoiseSeed = spec.noiseSeed
+		return nil
+	})
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+
+	if params.ConfidenceLevel != 0 {
+		// Same as below, but with the confidence interval of each mean.
+		ciFn := &boundedMeanFloat64WithConfidenceIntervalFn{MeanFn: meanFn, Alpha: 1 - params.ConfidenceLevel}
+		means := beam.CombinePerKey(s, ciFn, partialKV)
+		means = beam.ParDo(s, dropThresholdedPartitionsFloat64WithConfidenceIntervalFn, means)
+		if usePublicPartitions {
+			means = fillPublicPartitions(s, means, publicPartitions, &fillPublicPartitionsMeanFloat64WithConfidenceIntervalFn{MeanFn: ciFn})
+		}
+		return means, nil
+	}
+	// Compute the mean for each partition. Result is PCollection<partition, float64>.
+	means := beam.CombinePerKey(s, meanFn, partialKV)
+	// Finally, drop thresholded partitions.
+	means = beam.ParDo(s, dropThresholdedPartitionsFloat64Fn, means)
+	if usePublicPartitions {
+		// Add the public partitions that have no data.
+		means = fillPublicPartitions(s, means, publicPartitions, &fillPublicPartitionsMeanFloat64Fn{MeanFn: meanFn})
+	}
+	return means, nil
+}
+
+// boundFloat64ValuesPerPartition does per-partition and cross-partition
+// contribution bounding on a PrivatePCollection<K,V>, converts the values to
+// float64 and drops the privacy IDs. It is used by aggregations that need
+// access to all the individual values of a partition, like MeanPerKey.
+//
+// boundFloat64ValuesPerPartition transforms a PrivatePCollection<K,V> into a
+// PCollection<K,[]float64>, where each slice contains the values contributed
+// by a single privacy identifier to the partition.
+func boundFloat64ValuesPerPartition(s beam.Scope, pcol PrivatePCollection, idT typex.FullType, maxPartitionsContributed, maxContributionsPerPartition int64) (beam.PCollection, error) {
 	// First, group together the privacy ID and the partition ID and do per-partition contribution bounding.
 	// Result is PCollection<kv.Pair{ID,K},V>
 	decoded := beam.ParDo(s,
@@ -125,7 +273,6 @@ func MeanPerKey(s beam.Scope, pcol PrivatePCollection, params MeanParams) beam.P
 		pcol.col,
 		beam.TypeDefinition{Var: beam.VType, T: pcol.codec.VType.T})
 
-	maxContributionsPerPartition := getMaxContributionsPerPartition(params.MaxContributionsPerPartition)
 	decoded = boundContributions(s, decoded, maxContributionsPerPartition)
 
 	// Convert value to float64.
@@ -133,7 +280,7 @@ func MeanPerKey(s beam.Scope, pcol PrivatePCollection, params MeanParams) beam.P
 	_, valueT := beam.ValidateKVType(decoded)
 	convertFn, err := findConvertToFloat64Fn(valueT)
 	if err != nil {
-		log.Exit(err)
+		return beam.PCollection{}, err
 	}
 	converted := beam.ParDo(s, convertFn, decoded)
 
@@ -144,7 +291,6 @@ func MeanPerKey(s beam.Scope, pcol PrivatePCollection, params MeanParams) beam.P
 		converted)
 
 	// Result is PCollection<ID, pairArrayFloat64>.
-	maxPartitionsContributed := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
 	rekeyed := beam.ParDo(s, rekeyArrayFloat64Fn, combined)
 	// Do cross-partition contribution bounding.
 	rekeyed = boundContributions(s, rekeyed, maxPartitionsContributed)
@@ -153,33 +299,26 @@ func MeanPerKey(s beam.Scope, pcol PrivatePCollection, params MeanParams) beam.P
 	// Result is PCollection<partition, []float64>.
 	partialPairs := beam.DropKey(s, rekeyed)
 	partitionT := pcol.codec.KType.T
-	partialKV := beam.ParDo(s,
+	return beam.ParDo(s,
 		newDecodePairArrayFloat64Fn(partitionT),
 		partialPairs,
-		beam.TypeDefinition{Var: beam.XType, T: partitionT})
-
-	// Compute the mean for each partition. Result is PCollection<partition, float64>.
-	means := beam.CombinePerKey(s,
-		newBoundedMeanFloat64Fn(epsilon, delta, maxPartitionsContributed, params.MaxContributionsPerPartition, params.MinValue, params.MaxValue, noiseKind),
-		partialKV)
-	// Finally, drop thresholded partitions.
-	return beam.ParDo(s, dropThresholdedPartitionsFloat64Fn, means)
+		beam.TypeDefinition{Var: beam.XType, T: partitionT}), nil
 }
 
-func checkMeanPerKeyParams(params MeanParams, epsilon, delta float64) error {
-	err := checks.CheckEpsilon("pbeam.MeanPerKey", epsilon)
+func checkMeanPerKeyParams(params MeanParams) error {
+	err := checkPartitionSelectionParams("pbeam.MeanPerKey", params.PartitionSelection, params.PublicPartitions != nil)
 	if err != nil {
 		return err
 	}
-	err = checks.CheckDeltaStrict("pbeam.MeanPerKey", delta)
+	err = checkBoundsOrAutoBounds("pbeam.MeanPerKey", params.MinValue, params.MaxValue, params.AutoBounds, params.PublicPartitions != nil)
 	if err != nil {
 		return err
 	}
-	err = checks.CheckBoundsFloat64("pbeam.MeanPerKey", params.MinValue, params.MaxValue)
+	err = checks.CheckMaxPartitionsContributed("pbeam.MeanPerKey", params.MaxPartitionsContributed)
 	if err != nil {
 		return err
 	}
-	return checks.CheckMaxPartitionsContributed("pbeam.MeanPerKey", params.MaxPartitionsContributed)
+	return checkConfidenceLevel("pbeam.MeanPerKey", params.ConfidenceLevel)
 }
 
 // decodePairArrayFloat64Fn transforms a PCollection<pairArrayFloat64<codedX,[]float64>> into a
@@ -335,8 +474,9 @@ func rekeyArrayFloat64Fn(kv kv.Pair, m []float64) ([]byte, pairArrayFloat64) {
 }
 
 type boundedMeanAccumFloat64 struct {
-	BM *dpagg.BoundedMeanFloat64
-	SP *dpagg.PreAggSelectPartition
+	BM               *dpagg.BoundedMeanFloat64
+	PS               partitionSelectionAccum
+	PublicPartitions bool
 }
 
 // boundedMeanFloat64Fn is a differentially private combineFn for obtaining mean of values. Do not
@@ -351,33 +491,61 @@ type boundedMeanFloat64Fn struct {
 	MaxContributionsPerPartition int64
 	Lower                        float64
 	Upper                        float64
+	AutoBounds                   bool
 	NoiseKind                    noise.Kind
 	noise                        noise.Noise // Set during Setup phase according to NoiseKind.
-}
-
-// newBoundedMeanFloat6464Fn returns a boundedMeanFloat64Fn with the given budget and parameters.
-func newBoundedMeanFloat64Fn(epsilon, delta float64, maxPartitionsContributed, maxContributionsPerPartition int64, lower, upper float64, noiseKind noise.Kind) *boundedMeanFloat64Fn {
+	NoiseSeed                    *int64      // Set by NoiseSeedForTesting, nil otherwise.
+	// Partition selection strategy, DefaultPartitionSelection meaning
+	// PreAggPartitionSelection.
+	PartitionSelectionStrategy PartitionSelectionStrategy
+	// If true, partition selection is not needed and the entire budget is used
+	// for the noise.
+	PublicPartitions bool
+}
+
+// newBoundedMeanFloat64Fn returns a boundedMeanFloat64Fn with the given budget
+// and parameters, or an error if they are invalid.
+func newBoundedMeanFloat64Fn(epsilon, delta float64, maxPartitionsContributed, maxContributionsPerPartition int64, lower, upper float64, autoBounds bool, noiseKind noise.Kind, partitionSelection PartitionSelectionParams, publicPartitions bool) (*boundedMeanFloat64Fn, error) {
 	fn := &boundedMeanFloat64Fn{
 		MaxPartitionsContributed:     maxPartitionsContributed,
 		MaxContributionsPerPartition: maxContributionsPerPartition,
 		Lower:                        lower,
 		Upper:                        upper,
+		AutoBounds:                   autoBounds,
 		NoiseKind:                    noiseKind,
+		PublicPartitions:             publicPartitions,
 	}
-	fn.EpsilonNoise = epsilon / 2
-	fn.EpsilonPartitionSelection = epsilon / 2
-	switch noiseKind {
-	case noise.GaussianNoise:
-		fn.DeltaNoise = delta / 2
-		fn.DeltaPartitionSelection = delta / 2
-	case noise.LaplaceNoise:
-		fn.DeltaNoise = 0
-		fn.DeltaPartitionSelection = delta
-	default:
-		// TODO: return error instead
-		log.Exitf("newBoundedMeanFloat64Fn: unknown noise.Kind (%v) is specified. Please specify a valid noise.", noiseKind)
+	if noiseKind != noise.GaussianNoise && noiseKind != noise.LaplaceNoise {
+		return nil, fmt.Errorf("newBoundedMeanFloat64Fn: unknown noise.Kind (%v) is specified. Please specify a valid noise.", noiseKind)
+	}
+	if publicPartitions {
+		fn.EpsilonNoise, fn.DeltaNoise = publicPartitionsBudget(epsilon, delta, noiseKind)
+	} else {
+		fn.PartitionSelectionStrategy = partitionSelection.Strategy
+		fn.EpsilonNoise, fn.EpsilonPartitionSelection, fn.DeltaNoise, fn.DeltaPartitionSelection = splitBudget(epsilon, delta, noiseKind, partitionSelection)
+	}
+	// Check the parameters of the underlying dpagg.BoundedMeanFloat64 now,
+	// since creating the accumulators would exit the program if they were
+	// invalid.
+	if _, err := dpagg.NewBoundedMeanFloat64E(fn.boundedMeanOptions(noise.ToNoise(noiseKind))); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// boundedMeanOptions returns the options of the dpagg.BoundedMeanFloat64 of an
+// accumulator, which uses the noise n.
+func (fn *boundedMeanFloat64Fn) boundedMeanOptions(n noise.Noise) *dpagg.BoundedMeanFloat64Options {
+	return &dpagg.BoundedMeanFloat64Options{
+		Epsilon:                      fn.EpsilonNoise,
+		Delta:                        fn.DeltaNoise,
+		MaxPartitionsContributed:     fn.MaxPartitionsContributed,
+		MaxContributionsPerPartition: fn.MaxContributionsPerPartition,
+		Lower:                        fn.Lower,
+		Upper:                        fn.Upper,
+		Noise:                        n,
+		AutoBounds:                   fn.AutoBounds,
 	}
-	return fn
 }
 
 func (fn *boundedMeanFloat64Fn) Setup() {
@@ -385,22 +553,16 @@ func (fn *boundedMeanFloat64Fn) Setup() {
 }
 
 func (fn *boundedMeanFloat64Fn) CreateAccumulator() boundedMeanAccumFloat64 {
-	return boundedMeanAccumFloat64{
-		BM: dpagg.NewBoundedMeanFloat64(&dpagg.BoundedMeanFloat64Options{
-			Epsilon:                      fn.EpsilonNoise,
-			Delta:                        fn.DeltaNoise,
-			MaxPartitionsContributed:     fn.MaxPartitionsContributed,
-			MaxContributionsPerPartition: fn.MaxContributionsPerPartition,
-			Lower:                        fn.Lower,
-			Upper:                        fn.Upper,
-			Noise:                        fn.noise,
-		}),
-		SP: dpagg.NewPreAggSelectPartition(&dpagg.PreAggSelectPartitionOptions{
-			Epsilon:                  fn.EpsilonPartitionSelection,
-			Delta:                    fn.DeltaPartitionSelection,
-			MaxPartitionsContributed: fn.MaxPartitionsContributed,
-		}),
+	src := seededSource(fn.NoiseSeed)
+	n := noise.WithSource(fn.noise, src)
+	accum := boundedMeanAccumFloat64{
+		BM:               dpagg.NewBoundedMeanFloat64(fn.boundedMeanOptions(n)),
+		PublicPartitions: fn.PublicPartitions,
 	}
+	if !fn.PublicPartitions {
+		accum.PS = newPartitionSelectionAccum(fn.PartitionSelectionStrategy, fn.EpsilonPartitionSelection, fn.DeltaPartitionSelection, fn.MaxPartitionsContributed, fn.NoiseKind, n, src)
+	}
+	return accum
 }
 
 func (fn *boundedMeanFloat64Fn) AddInput(a boundedMeanAccumFloat64, values []float64) boundedMeanAccumFloat64 {
@@ -410,19 +572,26 @@ func (fn *boundedMeanFloat64Fn) AddInput(a boundedMeanAccumFloat64, values []flo
 	for _, v := range values {
 		a.BM.Add(v)
 	}
-	a.SP.Add()
+	if !a.PublicPartitions {
+		a.PS.add()
+	}
 	return a
 }
 
 func (fn *boundedMeanFloat64Fn) MergeAccumulators(a, b boundedMeanAccumFloat64) boundedMeanAccumFloat64 {
 	a.BM.Merge(b.BM)
-	a.SP.Merge(b.SP)
+	if !a.PublicPartitions {
+		a.PS.merge(b.PS)
+	}
 	return a
 }
 
 func (fn *boundedMeanFloat64Fn) ExtractOutput(a boundedMeanAccumFloat64) *float64 {
-	if a.SP.Result() {
-		result := a.BM.Result()
+	if a.PublicPartitions || a.PS.result() {
+		result, err := a.BM.ResultE()
+		if boundsNotFound(err) {
+			return nil
+		}
 		return &result
 	}
 	return nil
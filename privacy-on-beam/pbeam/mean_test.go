@@ -65,7 +65,10 @@ func TestNewBoundedMeanFloat64Fn(t *testing.T) {
 				NoiseKind:                    noise.GaussianNoise,
 			}},
 	} {
-		got := newBoundedMeanFloat64Fn(1, 1e-5, 17, 5, 0, 10, tc.noiseKind)
+		got, err := newBoundedMeanFloat64Fn(1, 1e-5, 17, 5, 0, 10, false, tc.noiseKind, PartitionSelectionParams{}, false)
+		if err != nil {
+			t.Fatalf("newBoundedMeanFloat64Fn: for %q got error %v", tc.desc, err)
+		}
 		if diff := cmp.Diff(tc.want, got, opts...); diff != "" {
 			t.Errorf("newBoundedMeanFn: for %q (-want +got):\n%s", tc.desc, diff)
 		}
@@ -80,7 +83,10 @@ func TestBoundedMeanFloat64FnSetup(t *testing.T) {
 	}{
 		{"Laplace noise kind", noise.LaplaceNoise, noise.Laplace()},
 		{"Gaussian noise kind", noise.GaussianNoise, noise.Gaussian()}} {
-		got := newBoundedMeanFloat64Fn(1, 1e-5, 17, 5, 0, 10, tc.noiseKind)
+		got, err := newBoundedMeanFloat64Fn(1, 1e-5, 17, 5, 0, 10, false, tc.noiseKind, PartitionSelectionParams{}, false)
+		if err != nil {
+			t.Fatalf("newBoundedMeanFloat64Fn: for %q got error %v", tc.desc, err)
+		}
 		got.Setup()
 		if !cmp.Equal(tc.wantNoise, got.noise) {
 			t.Errorf("Setup: for %s got %v, want %v", tc.desc, got.noise, tc.wantNoise)
@@ -98,7 +104,10 @@ func TestBoundedMeanFloat64FnAddInput(t *testing.T) {
 	lower := 0.0
 	upper := 5.0
 	// ε is split by 2 for noise and for partition selection, so we use 2*ε to get a Laplace noise with ε.
-	fn := newBoundedMeanFloat64Fn(2*epsilon, delta, maxPartitionsContributed, maxContributionsPerPartition, lower, upper, noise.LaplaceNoise)
+	fn, err := newBoundedMeanFloat64Fn(2*epsilon, delta, maxPartitionsContributed, maxContributionsPerPartition, lower, upper, false, noise.LaplaceNoise, PartitionSelectionParams{}, false)
+	if err != nil {
+		t.Fatalf("newBoundedMeanFloat64Fn: got error %v", err)
+	}
 	fn.Setup()
 
 	accum := fn.CreateAccumulator()
@@ -129,7 +138,10 @@ func TestBoundedMeanFloat64FnMergeAccumulators(t *testing.T) {
 	lower := 0.0
 	upper := 5.0
 	// ε is split by 2 for noise and for partition selection, so we use 2*ε to get a Laplace noise with ε.
-	fn := newBoundedMeanFloat64Fn(2*epsilon, delta, maxPartitionsContributed, maxContributionsPerPartition, lower, upper, noise.LaplaceNoise)
+	fn, err := newBoundedMeanFloat64Fn(2*epsilon, delta, maxPartitionsContributed, maxContributionsPerPartition, lower, upper, false, noise.LaplaceNoise, PartitionSelectionParams{}, false)
+	if err != nil {
+		t.Fatalf("newBoundedMeanFloat64Fn: got error %v", err)
+	}
 	fn.Setup()
 
 	accum1 := fn.CreateAccumulator()
@@ -167,7 +179,10 @@ func TestBoundedMeanFloat64FnExtractOutputReturnsNilForSmallPartitions(t *testin
 
 		// The choice of ε=1e100, δ=10⁻²³, and l0Sensitivity=1 gives a threshold of =2.
 		// ε is split by 2 for noise and for partition selection, so we use 2*ε to get a Laplace noise with ε.
-		fn := newBoundedMeanFloat64Fn(2*1e100, 1e-23, 1, 1, 0, 10, noise.LaplaceNoise)
+		fn, err := newBoundedMeanFloat64Fn(2*1e100, 1e-23, 1, 1, 0, 10, false, noise.LaplaceNoise, PartitionSelectionParams{}, false)
+		if err != nil {
+			t.Fatalf("newBoundedMeanFloat64Fn: got error %v", err)
+		}
 		fn.Setup()
 		accum := fn.CreateAccumulator()
 		for i := 0; i < tc.inputSize; i++ {
@@ -785,3 +800,27 @@ func TestFindConvertToFloat64Fn(t *testing.T) {
 		}
 	}
 }
+
+// Checks that TryMeanPerKey returns an error instead of exiting when the
+// parameters are invalid.
+func TestTryMeanPerKeyReturnsError(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		params MeanParams
+	}{
+		{"zero MaxContributionsPerPartition", MeanParams{MaxPartitionsContributed: 1, MinValue: 0, MaxValue: 1}},
+		{"invalid bounds", MeanParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, MinValue: 5, MaxValue: 0}},
+		{"negative confidence level", MeanParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, MinValue: 0, MaxValue: 1, ConfidenceLevel: -0.5}},
+		{"AutoBounds with public partitions", MeanParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, AutoBounds: true, PublicPartitions: []int{0}}},
+		{"bounds whose range overflows", MeanParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, MinValue: -1e308, MaxValue: 1e308}},
+	} {
+		triples := makeDummyTripleWithFloatValue(10, 0)
+		_, s, col := ptest.CreateList(triples)
+		col = beam.ParDo(s, extractIDFromTripleWithFloatValue, col)
+		pcol := MakePrivate(s, col, NewPrivacySpec(1, 1e-5))
+		pcol = ParDo(s, tripleWithFloatValueToKV, pcol)
+		if _, err := TryMeanPerKey(s, pcol, tc.params); err == nil {
+			t.Errorf("TryMeanPerKey: for %s got no error, want error", tc.desc)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"github.com/google/differential-privacy/go/rand"
+)
+
+// NoiseSeedForTesting is a PrivacySpecOption that makes the aggregations of a
+// PrivacySpec draw their noise, and their partition selection decisions, from
+// a deterministic source seeded with Seed instead of a cryptographically
+// secure source. This makes the output of a pipeline reproducible, e.g. for
+// golden tests.
+//
+// It must only be used in tests: the output of aggregations isn't
+// differentially private anymore.
+//
+// The source is seeded again for each partition, so that the noise added to a
+// partition doesn't depend on the order in which the runner processes
+// partitions; partitions with the same raw value therefore get the same
+// noise. Contribution bounding still selects the contributions it keeps at
+// random, so outputs are only reproducible if no privacy unit exceeds the
+// contribution bounds.
+type NoiseSeedForTesting struct {
+	Seed int64
+}
+
+func (ns NoiseSeedForTesting) updatePrivacySpec(ps *PrivacySpec) {
+	seed := ns.Seed
+	ps.noiseSeed = &seed
+}
+
+// seededSource returns nil if seed is nil, and otherwise a new deterministic
+// source seeded with *seed. Combine fns call it for each accumulator they
+// create, with the seed of their PrivacySpec.
+func seededSource(seed *int64) rand.Source {
+	if seed == nil {
+		return nil
+	}
+	return rand.NewDeterministicSource(*seed)
+}
@@ -0,0 +1,86 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+func TestNoiseSeedForTesting(t *testing.T) {
+	if spec := NewPrivacySpec(1, 0); spec.noiseSeed != nil {
+		t.Errorf("NewPrivacySpec: got noise seed %d, want none", *spec.noiseSeed)
+	}
+	spec := NewPrivacySpec(1, 0, NoiseSeedForTesting{Seed: 42})
+	if spec.noiseSeed == nil || *spec.noiseSeed != 42 {
+		t.Errorf("NewPrivacySpec with NoiseSeedForTesting{42}: got noise seed %v, want 42", spec.noiseSeed)
+	}
+	if src := seededSource(nil); src != nil {
+		t.Errorf("seededSource(nil): got %v, want nil", src)
+	}
+}
+
+// Checks that two Count aggregations on PrivacySpecs with the same
+// NoiseSeedForTesting return exactly the same output, including which
+// partitions are kept by partition selection.
+func TestCountWithNoiseSeedForTestingIsReproducible(t *testing.T) {
+	var pairs []pairII
+	for p := 0; p < 50; p++ {
+		// Partition p has p privacy IDs, so that some partitions are dropped by
+		// partition selection and others aren't.
+		pairs = append(pairs, makePairsWithFixedVStartingFromKey(p*p, p, p)...)
+	}
+	p, s, col := ptest.CreateList(pairs)
+	col = beam.ParDo(s, pairToKV, col)
+
+	var got [2]beam.PCollection
+	for i := range got {
+		pcol := MakePrivate(s, col, NewPrivacySpec(1, 1e-5, NoiseSeedForTesting{Seed: 42}))
+		got[i] = Count(s, pcol, CountParams{MaxValue: 1, MaxPartitionsContributed: 1, NoiseKind: LaplaceNoise{}})
+	}
+	if err := approxEqualsKVInt64(s, got[0], got[1], 0); err != nil {
+		t.Fatalf("TestCountWithNoiseSeedForTestingIsReproducible: %v", err)
+	}
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestCountWithNoiseSeedForTestingIsReproducible: Count(%v) with the same seed returned different outputs: %v", col, err)
+	}
+}
+
+// Checks that two SumPerKey aggregations with Gaussian noise on PrivacySpecs
+// with the same NoiseSeedForTesting return exactly the same output.
+func TestSumPerKeyWithNoiseSeedForTestingIsReproducible(t *testing.T) {
+	triples := concatenateTriplesWithFloatValue(
+		makeTripleWithFloatValue(100, 0, 1.5),
+		makeTripleWithFloatValueStartingFromKey(100, 100, 1, 2.5))
+	p, s, col := ptest.CreateList(triples)
+	col = beam.ParDo(s, extractIDFromTripleWithFloatValue, col)
+
+	var got [2]beam.PCollection
+	for i := range got {
+		pcol := MakePrivate(s, col, NewPrivacySpec(1, 1e-5, NoiseSeedForTesting{Seed: 42}))
+		pcol = ParDo(s, tripleWithFloatValueToKV, pcol)
+		got[i] = SumPerKey(s, pcol, SumParams{MaxPartitionsContributed: 1, MinValue: 0, MaxValue: 3, NoiseKind: GaussianNoise{}})
+	}
+	if err := approxEqualsKVFloat64(s, got[0], got[1], 0); err != nil {
+		t.Fatalf("TestSumPerKeyWithNoiseSeedForTestingIsReproducible: %v", err)
+	}
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestSumPerKeyWithNoiseSeedForTestingIsReproducible: SumPerKey(%v) with the same seed returned different outputs: %v", col, err)
+	}
+}
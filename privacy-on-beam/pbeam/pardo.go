@@ -85,12 +85,14 @@ func ParDo(s beam.Scope, doFn interface{}, pcol PrivatePCollection) PrivatePColl
 			col:         beam.ParDo(s, anonDoFn.fn, pcol.col, anonDoFn.typeDef),
 			codec:       anonDoFn.codec,
 			privacySpec: pcol.privacySpec,
+			windowFn:    pcol.windowFn,
 		}
 	}
 	return PrivatePCollection{
 		col:         beam.ParDo(s, anonDoFn.fn, pcol.col),
 		codec:       anonDoFn.codec,
 		privacySpec: pcol.privacySpec,
+		windowFn:    pcol.windowFn,
 	}
 }
 
@@ -124,7 +126,7 @@ func buildDoFn(doFn interface{}) (*anonDoFn, error) {
 		return nil, fmt.Errorf("pbeam.ParDo doesn't support DoFns with side inputs")
 	}
 	if len(funcxFn.Params(funcx.FnEventTime|funcx.FnWindow)) > 0 {
-		return nil, fmt.Errorf("pbeam.PrivatePCollection don't support streaming mode, so DoFns with EventTime or Window arguments are forbidden")
+		return nil, fmt.Errorf("pbeam.ParDo doesn't support DoFns with EventTime or Window arguments; use pbeam.WindowInto to aggregate records per window")
 	}
 	if len(funcxFn.Params(funcx.FnIllegal|funcx.FnType)) > 0 {
 		return nil, fmt.Errorf("illegal DoFn argument in pbeam.ParDo")
@@ -133,7 +135,7 @@ func buildDoFn(doFn interface{}) (*anonDoFn, error) {
 		return nil, fmt.Errorf("the DoFn parameter in pbeam.ParDo should have one or two value argument")
 	}
 	if len(funcxFn.Returns(funcx.RetEventTime)) > 0 {
-		return nil, fmt.Errorf("pbeam.PrivatePCollection don't support streaming mode, so DoFns who return EventTime are forbidden")
+		return nil, fmt.Errorf("pbeam.ParDo doesn't support DoFns that return an EventTime: timestamps must be set on the PCollection given to MakePrivate")
 	}
 	if len(funcxFn.Returns(funcx.RetIllegal)) > 0 {
 		return nil, fmt.Errorf("illegal DoFn return parameter in pbeam.ParDo")
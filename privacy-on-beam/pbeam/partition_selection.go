@@ -0,0 +1,172 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"fmt"
+
+	"github.com/google/differential-privacy/go/dpagg"
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/go/rand"
+)
+
+// PartitionSelectionStrategy is the strategy used by an aggregation to decide
+// which partitions appear in its output, so that the presence of a partition
+// doesn't reveal the presence of a single privacy identifier.
+type PartitionSelectionStrategy int
+
+const (
+	// DefaultPartitionSelection uses the default strategy of the aggregation:
+	// PreAggPartitionSelection for Count, SumPerKey and MeanPerKey, and
+	// NoisyThresholdPartitionSelection for DistinctPrivacyID.
+	DefaultPartitionSelection PartitionSelectionStrategy = iota
+	// PreAggPartitionSelection keeps each partition with a probability that
+	// depends on its number of distinct privacy identifiers, using
+	// dpagg.PreAggSelectPartition.
+	PreAggPartitionSelection
+	// NoisyThresholdPartitionSelection keeps each partition whose noisy number
+	// of distinct privacy identifiers is above a threshold.
+	NoisyThresholdPartitionSelection
+)
+
+// PartitionSelectionParams specifies how an aggregation selects the partitions
+// that appear in its output.
+type PartitionSelectionParams struct {
+	// The partition selection strategy.
+	//
+	// Defaults to DefaultPartitionSelection.
+	Strategy PartitionSelectionStrategy
+	// The fraction of the privacy budget of the aggregation that is spent on
+	// partition selection; the rest of the budget is spent on the noise added
+	// to the results. Must be in (0, 1). With Laplace noise, the noise uses no
+	// δ, so the entire δ is spent on partition selection.
+	//
+	// With NoisyThresholdPartitionSelection, DistinctPrivacyID thresholds the
+	// noisy count that it returns, so the entire ε is spent on this count and
+	// BudgetFraction only applies to δ.
+	//
+	// Defaults to 0.5.
+	BudgetFraction float64
+}
+
+func (params PartitionSelectionParams) budgetFraction() float64 {
+	if params.BudgetFraction == 0 {
+		return 0.5
+	}
+	return params.BudgetFraction
+}
+
+func checkPartitionSelectionParams(label string, params PartitionSelectionParams, publicPartitions bool) error {
+	if publicPartitions && params != (PartitionSelectionParams{}) {
+		return fmt.Errorf("%s: PartitionSelection must not be set when PublicPartitions is set", label)
+	}
+	switch params.Strategy {
+	case DefaultPartitionSelection, PreAggPartitionSelection, NoisyThresholdPartitionSelection:
+	default:
+		return fmt.Errorf("%s: unknown PartitionSelection.Strategy %d", label, params.Strategy)
+	}
+	if params.BudgetFraction < 0 || params.BudgetFraction >= 1 {
+		return fmt.Errorf("%s: PartitionSelection.BudgetFraction must be in (0, 1), got %f", label, params.BudgetFraction)
+	}
+	return nil
+}
+
+// splitBudget splits the budget of an aggregation between the noise and
+// partition selection, according to the given PartitionSelectionParams.
+func splitBudget(epsilon, delta float64, noiseKind noise.Kind, params PartitionSelectionParams) (epsilonNoise, epsilonPartitionSelection, deltaNoise, deltaPartitionSelection float64) {
+	fraction := params.budgetFraction()
+	epsilonPartitionSelection = epsilon * fraction
+	epsilonNoise = epsilon - epsilonPartitionSelection
+	if noiseKind == noise.LaplaceNoise {
+		return epsilonNoise, epsilonPartitionSelection, 0, delta
+	}
+	deltaPartitionSelection = delta * fraction
+	deltaNoise = delta - deltaPartitionSelection
+	return epsilonNoise, epsilonPartitionSelection, deltaNoise, deltaPartitionSelection
+}
+
+// partitionSelectionFractions returns the fractions of ε and δ that
+// splitBudget spends on partition selection.
+func partitionSelectionFractions(noiseKind noise.Kind, params PartitionSelectionParams) (epsilonFraction, deltaFraction float64) {
+	fraction := params.budgetFraction()
+	if noiseKind == noise.LaplaceNoise {
+		return fraction, 1
+	}
+	return fraction, fraction
+}
+
+// partitionSelectionAccum decides whether a partition appears in the output of
+// an aggregation. Each call to add corresponds to a distinct privacy
+// identifier. Only one of SP and C is set, depending on the strategy.
+type partitionSelectionAccum struct {
+	SP *dpagg.PreAggSelectPartition
+	C  *dpagg.Count
+	// The δ used to compute the threshold of C.
+	DeltaThreshold float64
+}
+
+// newPartitionSelectionAccum returns a partitionSelectionAccum spending the
+// given budget on partition selection. DefaultPartitionSelection is treated as
+// PreAggPartitionSelection, which draws its randomness from src if it is set.
+func newPartitionSelectionAccum(strategy PartitionSelectionStrategy, epsilon, delta float64, maxPartitionsContributed int64, noiseKind noise.Kind, n noise.Noise, src rand.Source) partitionSelectionAccum {
+	if strategy != NoisyThresholdPartitionSelection {
+		return partitionSelectionAccum{SP: dpagg.NewPreAggSelectPartition(&dpagg.PreAggSelectPartitionOptions{
+			Epsilon:                  epsilon,
+			Delta:                    delta,
+			MaxPartitionsContributed: maxPartitionsContributed,
+			Source:                   src,
+		})}
+	}
+	// With Gaussian noise, δ is split between the noise of the count and the
+	// threshold.
+	deltaNoise, deltaThreshold := 0.0, delta
+	if noiseKind == noise.GaussianNoise {
+		deltaNoise, deltaThreshold = delta/2, delta/2
+	}
+	return partitionSelectionAccum{
+		C: dpagg.NewCount(&dpagg.CountOptions{
+			Epsilon:                  epsilon,
+			Delta:                    deltaNoise,
+			MaxPartitionsContributed: maxPartitionsContributed,
+			Noise:                    n,
+		}),
+		DeltaThreshold: deltaThreshold,
+	}
+}
+
+func (a *partitionSelectionAccum) add() {
+	if a.SP != nil {
+		a.SP.Add()
+	} else {
+		a.C.Increment()
+	}
+}
+
+func (a *partitionSelectionAccum) merge(b partitionSelectionAccum) {
+	if a.SP != nil {
+		a.SP.Merge(b.SP)
+	} else {
+		a.C.Merge(b.C)
+	}
+}
+
+func (a *partitionSelectionAccum) result() bool {
+	if a.SP != nil {
+		return a.SP.Result()
+	}
+	return a.C.ThresholdedResult(a.DeltaThreshold) != nil
+}
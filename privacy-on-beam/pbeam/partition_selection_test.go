@@ -0,0 +1,68 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestCheckPartitionSelectionParams(t *testing.T) {
+	for _, tc := range []struct {
+		desc             string
+		params           PartitionSelectionParams
+		publicPartitions bool
+		wantErr          bool
+	}{
+		{"default params", PartitionSelectionParams{}, false, false},
+		{"default params with public partitions", PartitionSelectionParams{}, true, false},
+		{"valid params", PartitionSelectionParams{Strategy: NoisyThresholdPartitionSelection, BudgetFraction: 0.1}, false, false},
+		{"params set with public partitions", PartitionSelectionParams{BudgetFraction: 0.1}, true, true},
+		{"unknown strategy", PartitionSelectionParams{Strategy: 42}, false, true},
+		{"negative budget fraction", PartitionSelectionParams{BudgetFraction: -0.1}, false, true},
+		{"budget fraction of 1", PartitionSelectionParams{BudgetFraction: 1}, false, true},
+	} {
+		if err := checkPartitionSelectionParams("test", tc.params, tc.publicPartitions); (err != nil) != tc.wantErr {
+			t.Errorf("checkPartitionSelectionParams: when %s got err %v, wantErr=%t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestSplitBudget(t *testing.T) {
+	for _, tc := range []struct {
+		desc      string
+		noiseKind noise.Kind
+		params    PartitionSelectionParams
+		// ε of the noise, ε of partition selection, δ of the noise and δ of
+		// partition selection.
+		want []float64
+	}{
+		{"Laplace default", noise.LaplaceNoise, PartitionSelectionParams{}, []float64{0.5, 0.5, 0, 1e-4}},
+		{"Gaussian default", noise.GaussianNoise, PartitionSelectionParams{}, []float64{0.5, 0.5, 5e-5, 5e-5}},
+		{"Laplace 25%", noise.LaplaceNoise, PartitionSelectionParams{BudgetFraction: 0.25}, []float64{0.75, 0.25, 0, 1e-4}},
+		{"Gaussian 25%", noise.GaussianNoise, PartitionSelectionParams{BudgetFraction: 0.25}, []float64{0.75, 0.25, 7.5e-5, 2.5e-5}},
+	} {
+		epsilonNoise, epsilonSelection, deltaNoise, deltaSelection := splitBudget(1, 1e-4, tc.noiseKind, tc.params)
+		got := []float64{epsilonNoise, epsilonSelection, deltaNoise, deltaSelection}
+		if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 1e-10)); diff != "" {
+			t.Errorf("splitBudget: mismatch for %s (-want +got):\n%s", tc.desc, diff)
+		}
+	}
+}
@@ -104,6 +104,42 @@
 // that crash the pipeline, which could be abused to leak raw data. There is no
 // protection against timing or side-channel attacks, as we assume that the only
 // thing malicious users have access to is the output data.
+//
+// Windowed aggregations
+//
+// By default, PrivatePCollections are assumed to be in the global window, and
+// each aggregation outputs a single result per partition. To aggregate an
+// unbounded PCollection, e.g. a stream of events, the records of a
+// PrivatePCollection can be assigned to fixed windows with WindowInto:
+//
+//  // events is a PCollection<userID,event> whose timestamps are the event times.
+//  pcol := MakePrivate(s, events, NewPrivacySpec(1, 1e-10))
+//  pcol = WindowInto(s, window.NewFixedWindows(time.Hour), pcol)
+//  pcol = ParDo(s, extractPage, pcol) // pcol is a PrivatePCollection<page>
+//  ocol := Count(s, pcol, CountParams{MaxPartitionsContributed: 5, MaxValue: 10})
+//
+// Here, ocol contains, for each hour, the number of views of each page during
+// that hour. Aggregations are computed independently for each window: the
+// contribution bounds apply to the records of a privacy identifier within a
+// single window (above, each user contributes to at most 5 pages per hour),
+// and each window is output as if it were the only input of the aggregation.
+//
+// The privacy budget of the PrivacySpec is therefore a budget per window: the
+// outputs of all aggregations for a given window are (ε,δ)-differentially
+// private, in the sense above, with respect to the records of that window.
+// Since fixed windows are disjoint, the privacy loss of a privacy identifier
+// over the whole sequence of windows adds up over the windows it contributes
+// to: if it contributes to at most k windows, all the outputs are (k·ε,k·δ)
+// differentially private for it, by sequential composition. There is no such
+// bound for a privacy unit that may contribute to an unbounded number of
+// windows; in that case, either bound the number of windows each privacy
+// identifier contributes to upstream (e.g., by including the window in the
+// privacy unit, and accepting a per-window guarantee), or choose ε and δ such
+// that the composed budget over the expected lifetime of a privacy identifier
+// is acceptable.
+//
+// Only fixed windows and the default trigger are supported, and aggregations
+// on windowed PrivatePCollections cannot use public partitions.
 package pbeam
 
 import (
@@ -114,9 +150,11 @@ import (
 	"sync"
 
 	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
 	"github.com/google/differential-privacy/go/noise"
 	"github.com/google/differential-privacy/privacy-on-beam/internal/kv"
 	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -135,23 +173,49 @@ func init() {
 // different privacy budgets, call NewPrivacySpec multiple times and give a
 // different PrivacySpec to each PrivatePCollection.
 type PrivacySpec struct {
-	epsilon           float64 // ε budget available for this PrivatePCollection.
-	delta             float64 // δ budget available for this PrivatePCollection.
-	partiallyConsumed bool    // Whether some privacy budget has already been consumed from this PrivacySpec.
+	epsilon           float64             // ε budget available for this PrivatePCollection.
+	delta             float64             // δ budget available for this PrivatePCollection.
+	partiallyConsumed bool                // Whether some privacy budget has already been consumed from this PrivacySpec.
+	totalEpsilon      float64             // Initial ε budget of this PrivacySpec.
+	totalDelta        float64             // Initial δ budget of this PrivacySpec.
+	ledger            []BudgetLedgerEntry // All consumptions of the budget so far.
+	deferred          bool                // Whether this PrivacySpec uses DeferredBudgetAllocation.
+	allocated         bool                // Whether AllocateBudget has been called.
+	requests          []budgetRequest     // Aggregations waiting for AllocateBudget.
+	pldDiscretization float64             // Discretization interval of PLDAccounting, or 0 if it isn't used.
+	zcdp              bool                // Whether this PrivacySpec uses ZCDPAccounting.
+	gaussianRho       float64             // With ZCDPAccounting, ρ consumed by aggregations that only add Gaussian noise.
+	otherEpsilon      float64             // With ZCDPAccounting, ε consumed by other aggregations.
+	otherDelta        float64             // With ZCDPAccounting, δ consumed by other aggregations.
+	noiseSeed         *int64              // Seed set by NoiseSeedForTesting, or nil.
 	mux sync.Mutex
 }
 
 // consumeBudget consumes a differential privacy budget (ε,δ) from a
 // PrivacySpec. If epsilon and delta are 0, it consumes the entire budget,
 // which is only possible if this is the first time its budget is consumed.
-// Returns the budget consumed.
-func (ps *PrivacySpec) consumeBudget(epsilon, delta float64) (eps, del float64, err error) {
+// Returns the budget consumed. The consumption is recorded in the ledger of
+// the PrivacySpec, as entry with the budget consumed filled in.
+func (ps *PrivacySpec) consumeBudget(epsilon, delta float64, entry BudgetLedgerEntry) (eps, del float64, err error) {
 	ps.mux.Lock()
 	defer ps.mux.Unlock()
-	if epsilon == 0 && delta == 0 {
-		return ps.consumeEntireBudget()
+	switch {
+	case epsilon == 0 && delta == 0:
+		eps, del, err = ps.consumeEntireBudget()
+		if err == nil && ps.zcdp {
+			ps.recordZCDPBudget(eps, del, &entry)
+		}
+	case ps.zcdp:
+		eps, del, err = ps.consumeZCDPBudget(epsilon, delta, &entry)
+	default:
+		eps, del, err = ps.consumePartialBudget(epsilon, delta)
 	}
-	return ps.consumePartialBudget(epsilon, delta)
+	if err != nil {
+		return 0, 0, err
+	}
+	entry.Epsilon, entry.Delta = eps, del
+	ps.ledger = append(ps.ledger, entry)
+	return eps, del, nil
 }
 
 func (ps *PrivacySpec) consumeEntireBudget() (eps, del float64, err error) {
@@ -202,23 +266,54 @@ type PrivacySpecOption interface {
 }
 
 // getMaxPartitionsContributed returns a maxPartitionsContributed parameter
-// if it greater than zero, otherwise it fails.
-func getMaxPartitionsContributed(spec *PrivacySpec, maxPartitionsContributed int64) int64 {
+// if it greater than zero, otherwise it returns an error.
+func getMaxPartitionsContributed(spec *PrivacySpec, maxPartitionsContributed int64) (int64, error) {
 	if maxPartitionsContributed <= 0 {
-		// TODO: return error instead
-		log.Exitf("MaxPartitionsContributed must be set to a positive value.")
+		return 0, fmt.Errorf("MaxPartitionsContributed must be set to a positive value, got %d", maxPartitionsContributed)
 	}
-	return maxPartitionsContributed
+	return maxPartitionsContributed, nil
 }
 
 // getMaxContributionsPerPartition returns a maxContributionsPerPartition parameter
-// if it greater than zero, otherwise it fails.
-func getMaxContributionsPerPartition(maxContributionsPerPartition int64) int64 {
+// if it greater than zero, otherwise it returns an error.
+func getMaxContributionsPerPartition(maxContributionsPerPartition int64) (int64, error) {
 	if maxContributionsPerPartition <= 0 {
-		// TODO: return error instead
-		log.Exitf("MaxContributionsPerPartition must be set to a positive value.")
+		return 0, fmt.Errorf("MaxContributionsPerPartition must be set to a positive value, got %d", maxContributionsPerPartition)
+	}
+	return maxContributionsPerPartition, nil
+}
+
+// checkBoundsOrAutoBounds checks that minValue and maxValue are valid bounds if
+// autoBounds is false, and that they are both 0 otherwise. AutoBounds cannot be
+// used with public partitions: the public partitions whose bounds cannot be
+// determined, e.g. those without data, would be missing from the output.
+func checkBoundsOrAutoBounds(label string, minValue, maxValue float64, autoBounds, publicPartitions bool) error {
+	if !autoBounds {
+		return checks.CheckBoundsFloat64(label, minValue, maxValue)
+	}
+	if publicPartitions {
+		return fmt.Errorf("%s: AutoBounds cannot be set when PublicPartitions is set", label)
+	}
+	if minValue != 0 || maxValue != 0 {
+		return fmt.Errorf("%s: MinValue and MaxValue must be 0 when AutoBounds is set, got MinValue=%f and MaxValue=%f", label, minValue, maxValue)
+	}
+	return nil
+}
+
+// checkEpsilonAndDelta checks the privacy budget (ε,δ) allocated to an
+// aggregation. δ must be 0 for aggregations with public partitions and Laplace
+// noise, and strictly positive otherwise. The other parameters of aggregations
+// are checked before they request their budget, so that an aggregation with
+// invalid parameters doesn't consume any budget.
+func checkEpsilonAndDelta(label string, epsilon, delta float64, noiseKind noise.Kind, publicPartitions bool) error {
+	err := checks.CheckEpsilon(label, epsilon)
+	if err != nil {
+		return err
+	}
+	if publicPartitions {
+		return checkPublicPartitionsDelta(label, delta, noiseKind)
 	}
-	return maxContributionsPerPartition
+	return checks.CheckDeltaStrict(label, delta)
 }
 
 // NoiseKind represents the kind of noise to be used in an aggregations.
@@ -246,11 +341,14 @@ func (ln LaplaceNoise) toNoiseKind() noise.Kind {
 // The epsilon and delta arguments are the total (ε,δ)-differential privacy
 // budget for the pipeline. If there is only one aggregation, the entire budget
 // will be used for this aggregation. Otherwise, the user must specify how the
-// privacy budget is split across aggregations.
+// privacy budget is split across aggregations, or use the
+// DeferredBudgetAllocation or PLDAccounting options to split it automatically.
 func NewPrivacySpec(epsilon, delta float64, options ...PrivacySpecOption) *PrivacySpec {
 	ps := &PrivacySpec{
-		epsilon: epsilon,
-		delta:   delta,
+		epsilon:      epsilon,
+		delta:        delta,
+		totalEpsilon: epsilon,
+		totalDelta:   delta,
 	}
 	for _, opt := range options {
 		opt.updatePrivacySpec(ps)
@@ -283,6 +381,9 @@ type PrivatePCollection struct {
 	codec *kv.Codec
 	// Privacy budget and parameters attached to this PrivatePCollection
 	privacySpec *PrivacySpec
+	// Fixed windows the records were assigned to by WindowInto, or nil if the
+	// records are in the global window.
+	windowFn *window.Fn
 }
 
 // MakePrivate transforms a PCollection<K,V> into a PrivatePCollection<V>,
@@ -291,7 +291,7 @@ func TestBudgetFullyConsumed(t *testing.T) {
 		t.Errorf("expected no error but got error: %v", err)
 	}
 	// Try consuming 1% of the initial budget.
-	if eps, del, err := spec.consumeBudget(0.01, 1e-32); err != nil {
+	if eps, del, err := spec.consumeBudget(0.01, 1e-32, BudgetLedgerEntry{}); err != nil {
 		t.Errorf("expected spec to be out of budget, but could consume (%f,%e) without any error", eps, del)
 	}
 }
@@ -316,10 +316,10 @@ func TestTwoDistinctBudgets(t *testing.T) {
 		t.Errorf("expected no error but got error: %v", err)
 	}
 	// Try consuming 1% of the initial budget independently for ε and δ.
-	if eps, del, err := spec1.consumeBudget(0, 1e-32); err != nil {
+	if eps, del, err := spec1.consumeBudget(0, 1e-32, BudgetLedgerEntry{}); err != nil {
 		t.Errorf("expected spec1 to be out of budget, but could consume (%f,%e) without any error", eps, del)
 	}
-	if eps, del, err := spec2.consumeBudget(0.01, 0); err != nil {
+	if eps, del, err := spec2.consumeBudget(0.01, 0, BudgetLedgerEntry{}); err != nil {
 		t.Errorf("expected spec2 to be out of budget, but could consume (%f,%e) without any error", eps, del)
 	}
 }
@@ -348,7 +348,7 @@ func TestBudgetRounding(t *testing.T) {
 		t.Errorf("expected no error but got error: %v", err)
 	}
 	// Now, the budget should be really empty.
-	if eps, del, err := spec.consumeBudget(1e-20, 1e-50); err != nil {
+	if eps, del, err := spec.consumeBudget(1e-20, 1e-50, BudgetLedgerEntry{}); err != nil {
 		t.Errorf("expected spec to be out of budget, but could consume (%f,%e) without any error", eps, del)
 	}
 }
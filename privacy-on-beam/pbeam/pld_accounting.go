@@ -0,0 +1,211 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"math"
+
+	"github.com/google/differential-privacy/go/accounting"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// Default interval to which privacy losses are rounded with PLDAccounting.
+const defaultPLDDiscretizationInterval = 1e-3
+
+// Number of steps of the binary search for the largest budget that
+// aggregations can get with PLDAccounting.
+const pldScaleSearchIterations = 20
+
+// PLDAccounting is a PrivacySpecOption that splits the privacy budget of a
+// PrivacySpec across aggregations using privacy loss distributions (PLDs),
+// instead of adding their ε and δ. It implies DeferredBudgetAllocation.
+//
+// When AllocateBudget is called, half of the remaining δ is split across
+// aggregations as with DeferredBudgetAllocation, and the other half is kept
+// for their composition. The ε of all aggregations are then scaled up by the
+// same factor, as much as possible while the composition of their PLDs is
+// still within the remaining budget. With many aggregations, each of them gets
+// a much larger ε, and so adds less noise, than with naive composition. If
+// the PLD composition gives no improvement, e.g. if the PrivacySpec has no δ,
+// the budget is split as with DeferredBudgetAllocation.
+//
+// The PLD of an aggregation is that of the noise it adds, given its noise
+// kind and contribution bounds, composed with the PLD of a generic
+// (ε,δ)-differentially private mechanism for the budget it spends on
+// partition selection. Aggregations adding several Gaussian noises, e.g.
+// MeanPerKey with GaussianNoise, are accounted for as adding a single Gaussian
+// noise with their entire noise budget, whose privacy loss is larger than
+// theirs. There is no such bound for several Laplace noises, so the PLD of a
+// generic mechanism is used for the noise budget of aggregations adding them.
+// It is also used for the entire budget of aggregations with AutoBounds, and of
+// aggregations whose noise is unknown, like SelectPartitions.
+//
+// Since the budgets of aggregations are no longer added, the ε recorded in the
+// BudgetLedger of the PrivacySpec can add up to more than its total ε.
+type PLDAccounting struct {
+	// Interval to which privacy losses are rounded. Smaller values give a
+	// tighter composition, but take longer to compute. Defaults to 1e-3.
+	DiscretizationInterval float64
+}
+
+func (pa PLDAccounting) updatePrivacySpec(ps *PrivacySpec) {
+	ps.deferred = true
+	ps.pldDiscretization = pa.DiscretizationInterval
+	if ps.pldDiscretization == 0 {
+		ps.pldDiscretization = defaultPLDDiscretizationInterval
+	}
+}
+
+// pldBudgets returns the budgets of aggregations sharing a total budget
+// (totalEpsilon, totalDelta) with PLD accounting, given their ledger entries
+// and their budgets epsilons and deltas with naive composition.
+func pldBudgets(entries []BudgetLedgerEntry, epsilons, deltas []float64, totalEpsilon, totalDelta, discretization float64) ([]float64, []float64, error) {
+	if totalEpsilon == 0 || totalDelta == 0 {
+		// The composition of ε-differentially private mechanisms isn't tighter
+		// than naive composition for δ = 0.
+		return epsilons, deltas, nil
+	}
+	halfDeltas := make([]float64, len(deltas))
+	for i, delta := range deltas {
+		halfDeltas[i] = delta / 2
+	}
+	// Returns the ε of the composition of all aggregations when their ε are
+	// multiplied by scale.
+	composedEpsilon := func(scale float64) (float64, error) {
+		var composed *accounting.PrivacyLossDistribution
+		for i, epsilon := range epsilons {
+			pld, err := aggregationPLD(entries[i], scale*epsilon, halfDeltas[i], discretization)
+			if err != nil {
+				return 0, err
+			}
+			if composed == nil {
+				composed = pld
+			} else if composed, err = composed.Compose(pld); err != nil {
+				return 0, err
+			}
+		}
+		return composed.GetEpsilonForDelta(totalDelta), nil
+	}
+
+	epsilon, err := composedEpsilon(1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if epsilon > totalEpsilon {
+		return epsilons, deltas, nil
+	}
+	// Binary search of the largest scale; lower is always valid. No aggregation
+	// can get more than the total ε, which bounds the scale.
+	maxEpsilon := 0.0
+	for _, epsilon := range epsilons {
+		maxEpsilon = math.Max(maxEpsilon, epsilon)
+	}
+	lower, upper := 1.0, totalEpsilon/maxEpsilon
+	for i := 0; i < pldScaleSearchIterations; i++ {
+		mid := (lower + upper) / 2
+		if epsilon, err = composedEpsilon(mid); err != nil {
+			return nil, nil, err
+		}
+		if epsilon <= totalEpsilon {
+			lower = mid
+		} else {
+			upper = mid
+		}
+	}
+	if lower == 1 {
+		return epsilons, deltas, nil
+	}
+	scaled := make([]float64, len(epsilons))
+	for i, epsilon := range epsilons {
+		scaled[i] = lower * epsilon
+	}
+	return scaled, halfDeltas, nil
+}
+
+// aggregationPLD returns the PLD of the aggregation of entry with the budget
+// (ε,δ).
+func aggregationPLD(entry BudgetLedgerEntry, epsilon, delta, discretization float64) (*accounting.PrivacyLossDistribution, error) {
+	// Same split as splitBudget.
+	epsilonPartitionSelection := epsilon * entry.PartitionSelectionEpsilonFraction
+	deltaPartitionSelection := delta * entry.PartitionSelectionDeltaFraction
+	noisePLD, err := additiveNoisePLD(entry, epsilon-epsilonPartitionSelection, delta-deltaPartitionSelection, discretization)
+	if err != nil {
+		return nil, err
+	}
+	if noisePLD == nil {
+		return accounting.NewPLDFromPrivacyParameters(epsilon, delta, discretization)
+	}
+	if epsilonPartitionSelection == 0 && deltaPartitionSelection == 0 {
+		return noisePLD, nil
+	}
+	partitionSelectionPLD, err := accounting.NewPLDFromPrivacyParameters(epsilonPartitionSelection, deltaPartitionSelection, discretization)
+	if err != nil {
+		return nil, err
+	}
+	return noisePLD.Compose(partitionSelectionPLD)
+}
+
+// additiveNoisePLD returns the PLD of the noise added by the aggregation of
+// entry with the noise budget (ε,δ), or nil if it is unknown.
+func additiveNoisePLD(entry BudgetLedgerEntry, epsilon, delta, discretization float64) (*accounting.PrivacyLossDistribution, error) {
+	l0Sensitivity := entry.MaxPartitionsContributed
+	lInfSensitivity := lInfSensitivity(entry)
+	if entry.AutoBounds || epsilon <= 0 || l0Sensitivity <= 0 || lInfSensitivity <= 0 {
+		return nil, nil
+	}
+	l1Sensitivity := float64(l0Sensitivity) * lInfSensitivity
+	switch entry.NoiseKind {
+	case noiseKindName(noise.LaplaceNoise):
+		if entry.MultipleNoises {
+			// Each noise gets a share of ε, and their composition is
+			// ε-differentially private.
+			return accounting.NewPLDFromPrivacyParameters(epsilon, 0, discretization)
+		}
+		return accounting.NewLaplacePLD(l1Sensitivity/epsilon, l1Sensitivity, discretization)
+	case noiseKindName(noise.GaussianNoise):
+		if delta <= 0 {
+			return nil, nil
+		}
+		sigma := noise.SigmaForGaussian(l0Sensitivity, lInfSensitivity, epsilon, delta)
+		return accounting.NewGaussianPLD(sigma, math.Sqrt(float64(l0Sensitivity))*lInfSensitivity, discretization)
+	case noiseKindName(noise.DiscreteLaplaceNoise):
+		if entry.MultipleNoises {
+			return accounting.NewPLDFromPrivacyParameters(epsilon, 0, discretization)
+		}
+		if l1Sensitivity != math.Trunc(l1Sensitivity) {
+			return nil, nil
+		}
+		return accounting.NewDiscreteLaplacePLD(epsilon/l1Sensitivity, int64(l1Sensitivity), discretization)
+	default:
+		return nil, nil
+	}
+}
+
+// lInfSensitivity returns the largest change of the output of the
+// aggregation of entry in a partition when a privacy identifier is removed,
+// or 0 if it is unknown.
+func lInfSensitivity(entry BudgetLedgerEntry) float64 {
+	if entry.MinValue == 0 && entry.MaxValue == 0 {
+		// Aggregations without bounds count contributions.
+		return float64(entry.MaxContributionsPerPartition)
+	}
+	bound := math.Max(math.Abs(entry.MinValue), math.Abs(entry.MaxValue))
+	if entry.MaxContributionsPerPartition > 0 {
+		bound *= float64(entry.MaxContributionsPerPartition)
+	}
+	return bound
+}
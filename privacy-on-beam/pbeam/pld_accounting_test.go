@@ -0,0 +1,229 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"testing"
+
+	"github.com/google/differential-privacy/go/accounting"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// Checks that with PLDAccounting, aggregations get a larger ε than with naive
+// composition, and that the composition of their budgets is within the
+// budget of the PrivacySpec.
+func TestPLDAccounting(t *testing.T) {
+	const numAggregations = 50
+	const epsilon, delta = 1.0, 1e-5
+	spec := NewPrivacySpec(epsilon, delta, PLDAccounting{})
+	var got [numAggregations]allocatedBudget
+	for i := range got {
+		if err := spec.requestBudget(0, 0, 1, true, BudgetLedgerEntry{}, recordBudget(&got[i])); err != nil {
+			t.Fatalf("requestBudget: got error %v", err)
+		}
+	}
+	if err := spec.AllocateBudget(); err != nil {
+		t.Fatalf("AllocateBudget: got error %v", err)
+	}
+
+	var composed *accounting.PrivacyLossDistribution
+	for _, b := range got {
+		if b.Epsilon <= epsilon/numAggregations {
+			t.Errorf("AllocateBudget: got ε=%f, want more than %f", b.Epsilon, epsilon/numAggregations)
+		}
+		if b.Delta != delta/numAggregations/2 {
+			t.Errorf("AllocateBudget: got δ=%e, want %e", b.Delta, delta/numAggregations/2)
+		}
+		pld, err := accounting.NewPLDFromPrivacyParameters(b.Epsilon, b.Delta, defaultPLDDiscretizationInterval)
+		if err != nil {
+			t.Fatalf("NewPLDFromPrivacyParameters: got error %v", err)
+		}
+		if composed == nil {
+			composed = pld
+		} else if composed, err = composed.Compose(pld); err != nil {
+			t.Fatalf("Compose: got error %v", err)
+		}
+	}
+	if got := composed.GetEpsilonForDelta(delta); got > epsilon {
+		t.Errorf("AllocateBudget: composition of the allocated budgets has ε=%f, want at most %f", got, epsilon)
+	}
+}
+
+// Checks that without δ, PLDAccounting splits the budget as
+// DeferredBudgetAllocation does.
+func TestPLDAccountingWithoutDelta(t *testing.T) {
+	spec := NewPrivacySpec(1, 0, PLDAccounting{})
+	var got [2]allocatedBudget
+	for i := range got {
+		if err := spec.requestBudget(0, 0, float64(i+1), false, BudgetLedgerEntry{}, recordBudget(&got[i])); err != nil {
+			t.Fatalf("requestBudget: got error %v", err)
+		}
+	}
+	if err := spec.AllocateBudget(); err != nil {
+		t.Fatalf("AllocateBudget: got error %v", err)
+	}
+	want := [2]allocatedBudget{{Epsilon: 1.0 / 3}, {Epsilon: 2.0 / 3}}
+	if got != want {
+		t.Errorf("AllocateBudget: got budgets %+v, want %+v", got, want)
+	}
+}
+
+func TestPLDAccountingInvalidDiscretization(t *testing.T) {
+	spec := NewPrivacySpec(1, 1e-5, PLDAccounting{DiscretizationInterval: -1})
+	var got [2]allocatedBudget
+	for i := range got {
+		if err := spec.requestBudget(0, 0, 1, true, BudgetLedgerEntry{}, recordBudget(&got[i])); err != nil {
+			t.Fatalf("requestBudget: got error %v", err)
+		}
+	}
+	if err := spec.AllocateBudget(); err == nil {
+		t.Errorf("AllocateBudget: got no error for a negative discretization interval")
+	}
+}
+
+// Checks that PLDAccounting uses the PLDs of the noise added by aggregations,
+// which gives them a larger ε than with the PLDs of generic mechanisms.
+func TestPLDAccountingUsesNoisePLDs(t *testing.T) {
+	const numAggregations = 10
+	const epsilon, delta = 1.0, 1e-5
+	for _, tc := range []struct {
+		desc  string
+		entry BudgetLedgerEntry
+	}{
+		{"Gaussian noise with public partitions", BudgetLedgerEntry{
+			NoiseKind:                    noiseKindName(noise.GaussianNoise),
+			PublicPartitions:             true,
+			MaxPartitionsContributed:     3,
+			MaxContributionsPerPartition: 2,
+		}},
+		{"Laplace noise with partition selection", BudgetLedgerEntry{
+			NoiseKind:                         noiseKindName(noise.LaplaceNoise),
+			PartitionSelectionEpsilonFraction: 0.5,
+			PartitionSelectionDeltaFraction:   1,
+			MaxPartitionsContributed:          1,
+			MinValue:                          -5,
+			MaxValue:                          5,
+		}},
+	} {
+		var generic, got allocatedBudget
+		for _, r := range []struct {
+			entry BudgetLedgerEntry
+			b     *allocatedBudget
+		}{{BudgetLedgerEntry{}, &generic}, {tc.entry, &got}} {
+			spec := NewPrivacySpec(epsilon, delta, PLDAccounting{})
+			for i := 0; i < numAggregations; i++ {
+				if err := spec.requestBudget(0, 0, 1, true, r.entry, recordBudget(r.b)); err != nil {
+					t.Fatalf("requestBudget: for %s got error %v", tc.desc, err)
+				}
+			}
+			if err := spec.AllocateBudget(); err != nil {
+				t.Fatalf("AllocateBudget: for %s got error %v", tc.desc, err)
+			}
+		}
+		if got.Epsilon <= generic.Epsilon {
+			t.Errorf("AllocateBudget: for %s got ε=%f, want more than %f with generic PLDs", tc.desc, got.Epsilon, generic.Epsilon)
+		}
+
+		var composed *accounting.PrivacyLossDistribution
+		for i := 0; i < numAggregations; i++ {
+			pld, err := aggregationPLD(tc.entry, got.Epsilon, got.Delta, defaultPLDDiscretizationInterval)
+			if err != nil {
+				t.Fatalf("aggregationPLD: for %s got error %v", tc.desc, err)
+			}
+			if composed == nil {
+				composed = pld
+			} else if composed, err = composed.Compose(pld); err != nil {
+				t.Fatalf("Compose: for %s got error %v", tc.desc, err)
+			}
+		}
+		if eps := composed.GetEpsilonForDelta(delta); eps > epsilon {
+			t.Errorf("AllocateBudget: for %s composition of the allocated budgets has ε=%f, want at most %f", tc.desc, eps, epsilon)
+		}
+	}
+}
+
+// Checks that with PLDAccounting, the composition of the noises actually added
+// by MeanPerKey and Count aggregations with Laplace noise, and of their
+// partition selections, is within the budget of the PrivacySpec.
+func TestPLDAccountingMeanAndCountWithinBudget(t *testing.T) {
+	const numAggregations = 5
+	const epsilon, delta = 1.0, 1e-5
+	epsilonFraction, deltaFraction := partitionSelectionFractions(noise.LaplaceNoise, PartitionSelectionParams{})
+	mean := BudgetLedgerEntry{
+		NoiseKind:                         noiseKindName(noise.LaplaceNoise),
+		PartitionSelectionEpsilonFraction: epsilonFraction,
+		PartitionSelectionDeltaFraction:   deltaFraction,
+		MaxPartitionsContributed:          1,
+		MaxContributionsPerPartition:      1,
+		MinValue:                          0,
+		MaxValue:                          10,
+		MultipleNoises:                    true,
+	}
+	count := BudgetLedgerEntry{
+		NoiseKind:                         noiseKindName(noise.LaplaceNoise),
+		PartitionSelectionEpsilonFraction: epsilonFraction,
+		PartitionSelectionDeltaFraction:   deltaFraction,
+		MaxPartitionsContributed:          1,
+		MaxContributionsPerPartition:      1,
+	}
+	spec := NewPrivacySpec(epsilon, delta, PLDAccounting{})
+	var means, counts [numAggregations]allocatedBudget
+	for i := 0; i < numAggregations; i++ {
+		if err := spec.requestBudget(0, 0, 1, true, mean, recordBudget(&means[i])); err != nil {
+			t.Fatalf("requestBudget: got error %v", err)
+		}
+		if err := spec.requestBudget(0, 0, 1, true, count, recordBudget(&counts[i])); err != nil {
+			t.Fatalf("requestBudget: got error %v", err)
+		}
+	}
+	if err := spec.AllocateBudget(); err != nil {
+		t.Fatalf("AllocateBudget: got error %v", err)
+	}
+
+	var composed *accounting.PrivacyLossDistribution
+	compose := func(pld *accounting.PrivacyLossDistribution, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("creating PLD: got error %v", err)
+		}
+		if composed == nil {
+			composed = pld
+		} else if composed, err = composed.Compose(pld); err != nil {
+			t.Fatalf("Compose: got error %v", err)
+		}
+	}
+	for i := 0; i < numAggregations; i++ {
+		for _, a := range []struct {
+			budget allocatedBudget
+			noises int
+		}{{means[i], 2}, {counts[i], 1}} {
+			epsilonPartitionSelection := a.budget.Epsilon * epsilonFraction
+			deltaPartitionSelection := a.budget.Delta * deltaFraction
+			// The noise budget is split equally across the noises, e.g. the
+			// count and the normalized sum of MeanPerKey. Only the ratio of
+			// the sensitivity to the scale of the noise matters.
+			epsilonNoise := (a.budget.Epsilon - epsilonPartitionSelection) / float64(a.noises)
+			for j := 0; j < a.noises; j++ {
+				compose(accounting.NewLaplacePLD(1/epsilonNoise, 1, defaultPLDDiscretizationInterval))
+			}
+			compose(accounting.NewPLDFromPrivacyParameters(epsilonPartitionSelection, deltaPartitionSelection, defaultPLDDiscretizationInterval))
+		}
+	}
+	if got := composed.GetEpsilonForDelta(delta); got > epsilon {
+		t.Errorf("AllocateBudget: composition of the noises of the aggregations has ε=%f, want at most %f", got, epsilon)
+	}
+}
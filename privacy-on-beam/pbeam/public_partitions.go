@@ -0,0 +1,361 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/privacy-on-beam/internal/kv"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*partitionSetFn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*dropNonPublicPartitionsVFn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*dropNonPublicPartitionsKVFn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*fillPublicPartitionsSumInt64Fn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*fillPublicPartitionsSumFloat64Fn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*fillPublicPartitionsMeanFloat64Fn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*fillPublicPartitionsCountFn)(nil)))
+}
+
+// publicPartitionsBudget returns the budget used for the noise of an
+// aggregation with public partitions. No partition selection is needed in this
+// case, so the entire budget is used for the noise. Laplace noise doesn't use
+// δ.
+func publicPartitionsBudget(epsilon, delta float64, noiseKind noise.Kind) (epsilonNoise, deltaNoise float64) {
+	if noiseKind == noise.LaplaceNoise {
+		return epsilon, 0
+	}
+	return epsilon, delta
+}
+
+// usesDelta returns whether an aggregation with the given noise accepts a δ.
+// With public partitions, no budget is spent on partition selection, so a δ is
+// only needed for Gaussian noise.
+func usesDelta(noiseKind noise.Kind, publicPartitions bool) bool {
+	return !publicPartitions || noiseKind != noise.LaplaceNoise
+}
+
+// checkPublicPartitionsDelta returns an error if delta is incompatible with
+// an aggregation with public partitions: since the entire budget is used for
+// the noise, δ must be 0 for Laplace noise and strictly positive for Gaussian
+// noise.
+func checkPublicPartitionsDelta(label string, delta float64, noiseKind noise.Kind) error {
+	if noiseKind == noise.LaplaceNoise {
+		return checks.CheckNoDelta(label, delta)
+	}
+	return checks.CheckDeltaStrict(label, delta)
+}
+
+// getPublicPartitions returns the public partitions specified in the params
+// of an aggregation as a PCollection<K>, where K is partitionT. They must be
+// either a PCollection<K> or a non-empty slice of K.
+func getPublicPartitions(s beam.Scope, publicPartitions interface{}, partitionT reflect.Type) (beam.PCollection, error) {
+	if col, ok := publicPartitions.(beam.PCollection); ok {
+		if !col.IsValid() {
+			return beam.PCollection{}, fmt.Errorf("PublicPartitions is not a valid PCollection")
+		}
+		if col.Type().Type() != partitionT {
+			return beam.PCollection{}, fmt.Errorf("PublicPartitions must be a PCollection of type %v, got type %v instead", partitionT, col.Type().Type())
+		}
+		return col, nil
+	}
+	t := reflect.TypeOf(publicPartitions)
+	if t.Kind() != reflect.Slice {
+		return beam.PCollection{}, fmt.Errorf("PublicPartitions must be a beam.PCollection or a slice, got type %v instead", t)
+	}
+	if t.Elem() != partitionT {
+		return beam.PCollection{}, fmt.Errorf("PublicPartitions must be a slice of type %v, got type %v instead", partitionT, t)
+	}
+	if reflect.ValueOf(publicPartitions).Len() == 0 {
+		return beam.PCollection{}, fmt.Errorf("PublicPartitions must contain at least one partition")
+	}
+	return beam.CreateList(s, publicPartitions), nil
+}
+
+// partitionSet is a set of encoded partitions. The encoded partitions are
+// stored in hexadecimal: they are arbitrary bytes, e.g. varint-encoded integers
+// ≥128 are not valid UTF-8, and would collide if the set were encoded by a
+// coder that only supports UTF-8 strings, like Beam's JSON coder.
+type partitionSet map[string]bool
+
+// add adds the encoded partition to the set.
+func (set partitionSet) add(encoded []byte) {
+	set[hex.EncodeToString(encoded)] = true
+}
+
+// contains returns whether the encoded partition is in the set.
+func (set partitionSet) contains(encoded []byte) bool {
+	return set[hex.EncodeToString(encoded)]
+}
+
+// partitionSetFn is a CombineFn that collects all the elements of a
+// PCollection<K> into a partitionSet.
+type partitionSetFn struct {
+	PartitionType beam.EncodedType
+	partitionEnc  beam.ElementEncoder
+}
+
+func newPartitionSetFn(partitionT reflect.Type) *partitionSetFn {
+	return &partitionSetFn{PartitionType: beam.EncodedType{partitionT}}
+}
+
+func (fn *partitionSetFn) Setup() {
+	fn.partitionEnc = beam.NewElementEncoder(fn.PartitionType.T)
+}
+
+func (fn *partitionSetFn) CreateAccumulator() partitionSet {
+	return make(partitionSet)
+}
+
+func (fn *partitionSetFn) AddInput(set partitionSet, partition beam.W) partitionSet {
+	var buf bytes.Buffer
+	if err := fn.partitionEnc.Encode(partition, &buf); err != nil {
+		log.Exitf("pbeam.partitionSetFn.AddInput: couldn't encode partition %v: %v", partition, err)
+	}
+	set.add(buf.Bytes())
+	return set
+}
+
+func (fn *partitionSetFn) MergeAccumulators(a, b partitionSet) partitionSet {
+	for partition := range b {
+		a[partition] = true
+	}
+	return a
+}
+
+// readPartitionSet reads a partitionSet from a singleton side input. It returns
+// an empty set if the side input is empty.
+func readPartitionSet(partitionsIter func(*partitionSet) bool) partitionSet {
+	var set partitionSet
+	partitionsIter(&set)
+	if set == nil {
+		set = make(partitionSet)
+	}
+	return set
+}
+
+// dropNonPublicPartitionsV drops the records of a PrivatePCollection<V> whose
+// value is not one of the public partitions. It is used by aggregations whose
+// partitions are the values of the PrivatePCollection, like Count.
+//
+// dropNonPublicPartitionsV transforms a PCollection<ID,V> into a
+// PCollection<ID,V>.
+func dropNonPublicPartitionsV(s beam.Scope, pcol PrivatePCollection, publicPartitions beam.PCollection, partitionT reflect.Type) beam.PCollection {
+	set := beam.Combine(s, newPartitionSetFn(partitionT), publicPartitions)
+	return beam.ParDo(s, newDropNonPublicPartitionsVFn(partitionT), pcol.col, beam.SideInput{Input: set})
+}
+
+// dropNonPublicPartitionsKV drops the records of a PrivatePCollection<K,V>
+// whose key is not one of the public partitions. It is used by aggregations on
+// <K,V> pairs, like SumPerKey.
+//
+// dropNonPublicPartitionsKV transforms a PCollection<ID,kv.Pair> into a
+// PCollection<ID,kv.Pair>.
+func dropNonPublicPartitionsKV(s beam.Scope, pcol PrivatePCollection, publicPartitions beam.PCollection) beam.PCollection {
+	set := beam.Combine(s, newPartitionSetFn(pcol.codec.KType.T), publicPartitions)
+	return beam.ParDo(s, &dropNonPublicPartitionsKVFn{}, pcol.col, beam.SideInput{Input: set})
+}
+
+// dropNonPublicPartitionsVFn emits the <ID, V> pairs whose value is one of the
+// public partitions. The public partitions are decoded from the side input once
+// per bundle, rather than for each element.
+type dropNonPublicPartitionsVFn struct {
+	PartitionType beam.EncodedType
+	partitionEnc  beam.ElementEncoder
+	partitions    partitionSet
+}
+
+func newDropNonPublicPartitionsVFn(partitionT reflect.Type) *dropNonPublicPartitionsVFn {
+	return &dropNonPublicPartitionsVFn{PartitionType: beam.EncodedType{partitionT}}
+}
+
+func (fn *dropNonPublicPartitionsVFn) Setup() {
+	fn.partitionEnc = beam.NewElementEncoder(fn.PartitionType.T)
+}
+
+func (fn *dropNonPublicPartitionsVFn) StartBundle() {
+	fn.partitions = nil
+}
+
+func (fn *dropNonPublicPartitionsVFn) ProcessElement(id beam.U, partition beam.V, partitionsIter func(*partitionSet) bool, emit func(beam.U, beam.V)) {
+	if fn.partitions == nil {
+		fn.partitions = readPartitionSet(partitionsIter)
+	}
+	var buf bytes.Buffer
+	if err := fn.partitionEnc.Encode(partition, &buf); err != nil {
+		log.Exitf("pbeam.dropNonPublicPartitionsVFn.ProcessElement: couldn't encode partition %v: %v", partition, err)
+	}
+	if fn.partitions.contains(buf.Bytes()) {
+		emit(id, partition)
+	}
+}
+
+// dropNonPublicPartitionsKVFn emits the <ID, kv.Pair> pairs whose encoded key
+// is one of the public partitions. Like dropNonPublicPartitionsVFn, it decodes
+// the public partitions once per bundle.
+type dropNonPublicPartitionsKVFn struct {
+	partitions partitionSet
+}
+
+func (fn *dropNonPublicPartitionsKVFn) StartBundle() {
+	fn.partitions = nil
+}
+
+func (fn *dropNonPublicPartitionsKVFn) ProcessElement(id beam.U, pair kv.Pair, partitionsIter func(*partitionSet) bool, emit func(beam.U, kv.Pair)) {
+	if fn.partitions == nil {
+		fn.partitions = readPartitionSet(partitionsIter)
+	}
+	if fn.partitions.contains(pair.K) {
+		emit(id, pair)
+	}
+}
+
+// fillPublicPartitions adds the public partitions that have no data to the
+// results of an aggregation, and drops the results of partitions that are not
+// public. fillFn must be one of the fillPublicPartitions*Fn, and is called on
+// the CoGroupByKey of the results and the public partitions.
+//
+// fillPublicPartitions transforms a PCollection<K,R> into a PCollection<K,R>.
+func fillPublicPartitions(s beam.Scope, results, publicPartitions beam.PCollection, fillFn interface{}) beam.PCollection {
+	publicKV := beam.ParDo(s, addOneValueFn, publicPartitions)
+	grouped := beam.CoGroupByKey(s, results, publicKV)
+	return beam.ParDo(s, fillFn, grouped)
+}
+
+// newFillPublicPartitionsSumFn returns the fillPublicPartitions*Fn matching
+// sumFn, which must have been created by newBoundedSumFn or by
+// newSumWithConfidenceIntervalFn.
+func newFillPublicPartitionsSumFn(sumFn interface{}) (interface{}, error) {
+	switch fn := sumFn.(type) {
+	case *boundedSumInt64Fn:
+		return &fillPublicPartitionsSumInt64Fn{SumFn: fn}, nil
+	case *boundedSumFloat64Fn:
+		return &fillPublicPartitionsSumFloat64Fn{SumFn: fn}, nil
+	case *boundedSumInt64WithConfidenceIntervalFn:
+		return &fillPublicPartitionsSumInt64WithConfidenceIntervalFn{SumFn: fn}, nil
+	case *boundedSumFloat64WithConfidenceIntervalFn:
+		return &fillPublicPartitionsSumFloat64WithConfidenceIntervalFn{SumFn: fn}, nil
+	default:
+		return nil, fmt.Errorf("unexpected sum fn type %T", sumFn)
+	}
+}
+
+// fillPublicPartitionsSumInt64Fn emits the result of the sum of each public
+// partition, or a noisy empty sum if the partition has no data.
+type fillPublicPartitionsSumInt64Fn struct {
+	SumFn *boundedSumInt64Fn
+}
+
+func (fn *fillPublicPartitionsSumInt64Fn) Setup() {
+	fn.SumFn.Setup()
+}
+
+func (fn *fillPublicPartitionsSumInt64Fn) ProcessElement(k beam.W, resultIter func(*int64) bool, publicIter func(*int64) bool, emit func(beam.W, int64)) {
+	var result, public int64
+	if !publicIter(&public) {
+		return
+	}
+	if !resultIter(&result) {
+		empty := fn.SumFn.ExtractOutput(fn.SumFn.CreateAccumulator())
+		if empty == nil {
+			return
+		}
+		result = *empty
+	}
+	emit(k, result)
+}
+
+// fillPublicPartitionsSumFloat64Fn emits the result of the sum of each public
+// partition, or a noisy empty sum if the partition has no data.
+type fillPublicPartitionsSumFloat64Fn struct {
+	SumFn *boundedSumFloat64Fn
+}
+
+func (fn *fillPublicPartitionsSumFloat64Fn) Setup() {
+	fn.SumFn.Setup()
+}
+
+func (fn *fillPublicPartitionsSumFloat64Fn) ProcessElement(k beam.W, resultIter func(*float64) bool, publicIter func(*int64) bool, emit func(beam.W, float64)) {
+	var result float64
+	var public int64
+	if !publicIter(&public) {
+		return
+	}
+	if !resultIter(&result) {
+		empty := fn.SumFn.ExtractOutput(fn.SumFn.CreateAccumulator())
+		if empty == nil {
+			return
+		}
+		result = *empty
+	}
+	emit(k, result)
+}
+
+// fillPublicPartitionsMeanFloat64Fn emits the result of the mean of each
+// public partition, or a noisy empty mean if the partition has no data.
+type fillPublicPartitionsMeanFloat64Fn struct {
+	MeanFn *boundedMeanFloat64Fn
+}
+
+func (fn *fillPublicPartitionsMeanFloat64Fn) Setup() {
+	fn.MeanFn.Setup()
+}
+
+func (fn *fillPublicPartitionsMeanFloat64Fn) ProcessElement(k beam.W, resultIter func(*float64) bool, publicIter func(*int64) bool, emit func(beam.W, float64)) {
+	var result float64
+	var public int64
+	if !publicIter(&public) {
+		return
+	}
+	if !resultIter(&result) {
+		empty := fn.MeanFn.ExtractOutput(fn.MeanFn.CreateAccumulator())
+		if empty == nil {
+			return
+		}
+		result = *empty
+	}
+	emit(k, result)
+}
+
+// fillPublicPartitionsCountFn emits the number of distinct privacy IDs of each
+// public partition, or a noisy zero if the partition has no data.
+type fillPublicPartitionsCountFn struct {
+	CountFn *countFn
+}
+
+func (fn *fillPublicPartitionsCountFn) Setup() {
+	fn.CountFn.Setup()
+}
+
+func (fn *fillPublicPartitionsCountFn) ProcessElement(k beam.W, resultIter func(*int64) bool, publicIter func(*int64) bool, emit func(beam.W, int64)) {
+	var result, public int64
+	if !publicIter(&public) {
+		return
+	}
+	if !resultIter(&result) {
+		result = *fn.CountFn.ExtractOutput(fn.CountFn.CreateAccumulator())
+	}
+	emit(k, result)
+}
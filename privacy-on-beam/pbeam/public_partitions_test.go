@@ -0,0 +1,113 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/differential-privacy/privacy-on-beam/internal/kv"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+)
+
+// Checks that dropNonPublicPartitionsKVFn decodes the public partitions once
+// per bundle, and not for each element.
+func TestDropNonPublicPartitionsKVFnReadsPartitionsOncePerBundle(t *testing.T) {
+	reads := 0
+	partitionsIter := func(set *partitionSet) bool {
+		reads++
+		*set = make(partitionSet)
+		set.add([]byte("public"))
+		return true
+	}
+	var got []string
+	emit := func(_ beam.U, pair kv.Pair) {
+		got = append(got, string(pair.K))
+	}
+
+	fn := &dropNonPublicPartitionsKVFn{}
+	for bundle := 0; bundle < 2; bundle++ {
+		fn.StartBundle()
+		for _, k := range []string{"public", "private", "public"} {
+			fn.ProcessElement(1, kv.Pair{K: []byte(k)}, partitionsIter, emit)
+		}
+	}
+	if reads != 2 {
+		t.Errorf("ProcessElement: read the public partitions %d times in 2 bundles, want 2", reads)
+	}
+	if len(got) != 4 {
+		t.Errorf("ProcessElement: emitted %v, want the 4 elements of public partitions", got)
+	}
+}
+
+// Checks that partitionSetFn tells apart partitions whose encoding is not valid
+// UTF-8, like varint-encoded integers ≥128, after the set is encoded and
+// decoded, whether with its registered coder or with JSON.
+func TestPartitionSetFnRoundTripsNonUTF8Partitions(t *testing.T) {
+	fn := newPartitionSetFn(reflect.TypeOf(0))
+	fn.Setup()
+	set := fn.AddInput(fn.CreateAccumulator(), 200)
+	setT := reflect.TypeOf(partitionSet{})
+
+	for _, tc := range []struct {
+		desc      string
+		roundTrip func(partitionSet) (partitionSet, error)
+	}{
+		{"registered coder", func(set partitionSet) (partitionSet, error) {
+			var buf bytes.Buffer
+			if err := beam.NewElementEncoder(setT).Encode(set, &buf); err != nil {
+				return nil, err
+			}
+			decoded, err := beam.NewElementDecoder(setT).Decode(&buf)
+			if err != nil {
+				return nil, err
+			}
+			return decoded.(partitionSet), nil
+		}},
+		{"JSON", func(set partitionSet) (partitionSet, error) {
+			data, err := json.Marshal(set)
+			if err != nil {
+				return nil, err
+			}
+			var decoded partitionSet
+			err = json.Unmarshal(data, &decoded)
+			return decoded, err
+		}},
+	} {
+		decoded, err := tc.roundTrip(set)
+		if err != nil {
+			t.Fatalf("With %s, round trip of %v: got error %v", tc.desc, set, err)
+		}
+		for _, p := range []struct {
+			partition int
+			want      bool
+		}{
+			{200, true},
+			{201, false},
+		} {
+			var buf bytes.Buffer
+			if err := fn.partitionEnc.Encode(p.partition, &buf); err != nil {
+				t.Fatalf("Couldn't encode partition %d: %v", p.partition, err)
+			}
+			if got := decoded.contains(buf.Bytes()); got != p.want {
+				t.Errorf("With %s, contains(%d) on the decoded set of partition 200: got %t, want %t", tc.desc, p.partition, got, p.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,321 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"fmt"
+	"reflect"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/dpagg"
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/privacy-on-beam/internal/kv"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*boundedQuantilesFn)(nil)))
+}
+
+// QuantilesParams specifies the parameters associated with a Quantiles aggregation.
+type QuantilesParams struct {
+	// Noise type (which is either LaplaceNoise{} or GaussianNoise{}).
+	//
+	// Defaults to LaplaceNoise{}.
+	NoiseKind NoiseKind
+	// Differential privacy budget consumed by this aggregation. If there is
+	// only one aggregation, both Epsilon and Delta can be left 0; in that
+	// case, the entire budget of the PrivacySpec is consumed.
+	Epsilon, Delta float64
+	// Relative weight of this aggregation when Epsilon and Delta are left 0
+	// and the PrivacySpec uses DeferredBudgetAllocation: the budget of the
+	// PrivacySpec is then split across aggregations proportionally to their
+	// weights.
+	//
+	// Defaults to 1.
+	BudgetWeight float64
+	// The maximum number of distinct values that a given privacy identifier
+	// can influence. There is an inherent trade-off when choosing this
+	// parameter: a larger MaxPartitionsContributed leads to less data loss due
+	// to contribution bounding, but since the noise added in aggregations is
+	// scaled according to maxPartitionsContributed, it also means that more
+	// noise is added to each quantile.
+	//
+	// Required.
+	MaxPartitionsContributed int64
+	// The maximum number of contributions from a given privacy identifier
+	// for each key. There is an inherent trade-off when choosing this
+	// parameter: a larger MaxContributionsPerPartition leads to less data loss due
+	// to contribution bounding, but since the noise added in aggregations is
+	// scaled according to maxContributionsPerPartition, it also means that more
+	// noise is added to each quantile.
+	//
+	// Required.
+	MaxContributionsPerPartition int64
+	// The contributions of a given privacy identifier to a partition must be
+	// at least MinValue, and at most MaxValue; otherwise they will be clamped
+	// to these bounds. There is an inherent trade-off when choosing MinValue
+	// and MaxValue: a small MinValue and a large MaxValue means that less
+	// records will be clamped, but that the quantiles will be less accurate.
+	//
+	// Required.
+	MinValue, MaxValue float64
+	// Percentile ranks that the quantiles should be computed for. Each rank must
+	// be between 0 and 1. For example, [0.5] computes the median and
+	// [0.5, 0.9, 0.99] computes the 50th, 90th and 99th percentiles. The
+	// quantiles are computed in the same order as the ranks. Computing
+	// additional quantiles does not consume more privacy budget.
+	//
+	// Required.
+	Ranks []float64
+}
+
+// QuantilesPerKey computes one or multiple quantiles of the values associated
+// with each key in a PrivatePCollection<K,V>, adding differentially private
+// noise to the quantiles and doing pre-aggregation thresholding to remove
+// partitions with a low number of distinct privacy identifiers.
+//
+// Note: Do not use when your results may cause overflows for Float64 values.
+// This aggregation is not hardened for such applications yet.
+//
+// QuantilesPerKey transforms a PrivatePCollection<K,V> into a
+// PCollection<K,[]float64>, where the slice contains the quantiles for each
+// rank of QuantilesParams.Ranks, in the same order.
+func QuantilesPerKey(s beam.Scope, pcol PrivatePCollection, params QuantilesParams) beam.PCollection {
+	quantiles, err := TryQuantilesPerKey(s, pcol, params)
+	if err != nil {
+		log.Exit(err)
+	}
+	return quantiles
+}
+
+// TryQuantilesPerKey is the same as QuantilesPerKey, but returns an error
+// instead of exiting the program if the input or the parameters are invalid, or
+// if the privacy budget cannot be consumed. All parameters are checked before
+// the budget is consumed, except those that depend on the budget (ε,δ) that the
+// aggregation gets: if ε or δ is invalid for the noise kind, or if the noise of
+// the aggregation can't be calibrated to them with the other parameters, the
+// error is returned after the budget is consumed. With
+// DeferredBudgetAllocation, these checks are done by AllocateBudget instead.
+func TryQuantilesPerKey(s beam.Scope, pcol PrivatePCollection, params QuantilesParams) (beam.PCollection, error) {
+	s = s.Scope("pbeam.QuantilesPerKey")
+	// Obtain & validate type information from the underlying PCollection<K,V>.
+	idT, kvT := beam.ValidateKVType(pcol.col)
+	if kvT.Type() != reflect.TypeOf(kv.Pair{}) {
+		return beam.PCollection{}, fmt.Errorf("QuantilesPerKey must be used on a PrivatePCollection of type <K,V>, got type %v instead", kvT)
+	}
+	if pcol.codec == nil {
+		return beam.PCollection{}, fmt.Errorf("QuantilesPerKey: no codec found for the input PrivatePCollection.")
+	}
+
+	var noiseKind noise.Kind
+	if params.NoiseKind == nil {
+		noiseKind = noise.LaplaceNoise
+		log.Infof("No NoiseKind specified, using Laplace Noise by default.")
+	} else {
+		noiseKind = params.NoiseKind.toNoiseKind()
+	}
+	// Get privacy parameters.
+	spec := pcol.privacySpec
+	err := checkQuantilesPerKeyParams(params)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	// Do contribution bounding. Result is PCollection<partition, []float64>.
+	maxContributionsPerPartition, err := getMaxContributionsPerPartition(params.MaxContributionsPerPartition)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	maxPartitionsContributed, err := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	partialKV, err := boundFloat64ValuesPerPartition(s, pcol, idT, maxPartitionsContributed, maxContributionsPerPartition)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+
+	quantilesFn := new(boundedQuantilesFn)
+	entry := BudgetLedgerEntry{
+		Scope:                        s.String(),
+		NoiseKind:                    noiseKindName(noiseKind),
+		MaxPartitionsContributed:     params.MaxPartitionsContributed,
+		MaxContributionsPerPartition: params.MaxContributionsPerPartition,
+		MinValue:                     params.MinValue,
+		MaxValue:                     params.MaxValue,
+		MultipleNoises:               true,
+	}
+	entry.PartitionSelectionEpsilonFraction, entry.PartitionSelectionDeltaFraction = partitionSelectionFractions(noiseKind, PartitionSelectionParams{})
+	err = spec.requestBudget(params.Epsilon, params.Delta, params.BudgetWeight, true, entry, func(epsilon, delta float64) error {
+		err := checkEpsilonAndDelta("pbeam.QuantilesPerKey", epsilon, delta, noiseKind, false)
+		if err != nil {
+			return err
+		}
+		fn, err := newBoundedQuantilesFn(epsilon, delta, params.MaxPartitionsContributed, params.MaxContributionsPerPartition, params.MinValue, params.MaxValue, noiseKind, params.Ranks)
+		if err != nil {
+			return err
+		}
+		*quantilesFn = *fn
+		quantilesFn.NoiseSeed = spec.noiseSeed
+		return nil
+	})
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+
+	// Compute the quantiles for each partition. Result is PCollection<partition, []float64>.
+	quantiles := beam.CombinePerKey(s, quantilesFn, partialKV)
+	// Finally, drop thresholded partitions.
+	return beam.ParDo(s, dropThresholdedPartitionsFloat64SliceFn, quantiles), nil
+}
+
+func checkQuantilesPerKeyParams(params QuantilesParams) error {
+	err := checks.CheckBoundsFloat64("pbeam.QuantilesPerKey", params.MinValue, params.MaxValue)
+	if err != nil {
+		return err
+	}
+	if len(params.Ranks) == 0 {
+		return fmt.Errorf("pbeam.QuantilesPerKey: Ranks must contain at least one rank")
+	}
+	for _, rank := range params.Ranks {
+		if rank < 0 || rank > 1 {
+			return fmt.Errorf("pbeam.QuantilesPerKey: Ranks must be between 0 and 1, got %f", rank)
+		}
+	}
+	return checks.CheckMaxPartitionsContributed("pbeam.QuantilesPerKey", params.MaxPartitionsContributed)
+}
+
+type boundedQuantilesAccum struct {
+	BQ *dpagg.BoundedQuantiles
+	SP *dpagg.PreAggSelectPartition
+}
+
+// boundedQuantilesFn is a differentially private combineFn for computing quantiles of values. Do not
+// initialize it yourself, use newBoundedQuantilesFn to create a boundedQuantilesFn instance.
+type boundedQuantilesFn struct {
+	// Privacy spec parameters (set during initial construction).
+	EpsilonNoise                 float64
+	EpsilonPartitionSelection    float64
+	DeltaNoise                   float64
+	DeltaPartitionSelection      float64
+	MaxPartitionsContributed     int64
+	MaxContributionsPerPartition int64
+	Lower                        float64
+	Upper                        float64
+	NoiseKind                    noise.Kind
+	noise                        noise.Noise // Set during Setup phase according to NoiseKind.
+	NoiseSeed                    *int64      // Set by NoiseSeedForTesting, nil otherwise.
+	Ranks                        []float64
+}
+
+// newBoundedQuantilesFn returns a boundedQuantilesFn with the given budget and
+// parameters, or an error if they are invalid.
+func newBoundedQuantilesFn(epsilon, delta float64, maxPartitionsContributed, maxContributionsPerPartition int64, lower, upper float64, noiseKind noise.Kind, ranks []float64) (*boundedQuantilesFn, error) {
+	fn := &boundedQuantilesFn{
+		MaxPartitionsContributed:     maxPartitionsContributed,
+		MaxContributionsPerPartition: maxContributionsPerPartition,
+		Lower:                        lower,
+		Upper:                        upper,
+		NoiseKind:                    noiseKind,
+		Ranks:                        ranks,
+	}
+	fn.EpsilonNoise = epsilon / 2
+	fn.EpsilonPartitionSelection = epsilon / 2
+	switch noiseKind {
+	case noise.GaussianNoise:
+		fn.DeltaNoise = delta / 2
+		fn.DeltaPartitionSelection = delta / 2
+	case noise.LaplaceNoise:
+		fn.DeltaNoise = 0
+		fn.DeltaPartitionSelection = delta
+	default:
+		return nil, fmt.Errorf("newBoundedQuantilesFn: unknown noise.Kind (%v) is specified. Please specify a valid noise.", noiseKind)
+	}
+	// Check the parameters of the underlying dpagg.BoundedQuantiles now, since
+	// creating the accumulators would exit the program if they were invalid.
+	if _, err := dpagg.NewBoundedQuantilesE(fn.boundedQuantilesOptions(noise.ToNoise(noiseKind))); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// boundedQuantilesOptions returns the options of the dpagg.BoundedQuantiles of
+// an accumulator, which uses the noise n.
+func (fn *boundedQuantilesFn) boundedQuantilesOptions(n noise.Noise) *dpagg.BoundedQuantilesOptions {
+	return &dpagg.BoundedQuantilesOptions{
+		Epsilon:                      fn.EpsilonNoise,
+		Delta:                        fn.DeltaNoise,
+		MaxPartitionsContributed:     fn.MaxPartitionsContributed,
+		MaxContributionsPerPartition: fn.MaxContributionsPerPartition,
+		Lower:                        fn.Lower,
+		Upper:                        fn.Upper,
+		Noise:                        n,
+	}
+}
+
+func (fn *boundedQuantilesFn) Setup() {
+	fn.noise = noise.ToNoise(fn.NoiseKind)
+}
+
+func (fn *boundedQuantilesFn) CreateAccumulator() boundedQuantilesAccum {
+	src := seededSource(fn.NoiseSeed)
+	n := noise.WithSource(fn.noise, src)
+	return boundedQuantilesAccum{
+		BQ: dpagg.NewBoundedQuantiles(fn.boundedQuantilesOptions(n)),
+		SP: dpagg.NewPreAggSelectPartition(&dpagg.PreAggSelectPartitionOptions{
+			Epsilon:                  fn.EpsilonPartitionSelection,
+			Delta:                    fn.DeltaPartitionSelection,
+			MaxPartitionsContributed: fn.MaxPartitionsContributed,
+			Source:                   src,
+		}),
+	}
+}
+
+func (fn *boundedQuantilesFn) AddInput(a boundedQuantilesAccum, values []float64) boundedQuantilesAccum {
+	// We can have multiple values for each (privacy_key, partition_key) pair.
+	// We need to add each value to BoundedQuantiles as input but we need to add a single input
+	// for each privacy_key to SelectPartition.
+	for _, v := range values {
+		a.BQ.Add(v)
+	}
+	a.SP.Add()
+	return a
+}
+
+func (fn *boundedQuantilesFn) MergeAccumulators(a, b boundedQuantilesAccum) boundedQuantilesAccum {
+	a.BQ.Merge(b.BQ)
+	a.SP.Merge(b.SP)
+	return a
+}
+
+// ExtractOutput returns the quantiles for each rank in fn.Ranks, or nil if the
+// partition is dropped.
+func (fn *boundedQuantilesFn) ExtractOutput(a boundedQuantilesAccum) []float64 {
+	if a.SP.Result() {
+		result := make([]float64, len(fn.Ranks))
+		for i, rank := range fn.Ranks {
+			result[i] = a.BQ.Result(rank)
+		}
+		return result
+	}
+	return nil
+}
+
+func (fn *boundedQuantilesFn) String() string {
+	return fmt.Sprintf("%#v", fn)
+}
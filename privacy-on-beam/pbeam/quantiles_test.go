@@ -0,0 +1,221 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestNewBoundedQuantilesFn(t *testing.T) {
+	opts := []cmp.Option{
+		cmpopts.EquateApprox(0, 1e-10),
+		cmpopts.IgnoreUnexported(boundedQuantilesFn{}),
+	}
+	for _, tc := range []struct {
+		desc      string
+		noiseKind noise.Kind
+		want      interface{}
+	}{
+		{"Laplace noise kind", noise.LaplaceNoise,
+			&boundedQuantilesFn{
+				EpsilonNoise:                 0.5,
+				EpsilonPartitionSelection:    0.5,
+				DeltaNoise:                   0,
+				DeltaPartitionSelection:      1e-5,
+				MaxPartitionsContributed:     17,
+				MaxContributionsPerPartition: 5,
+				Lower:                        0,
+				Upper:                        10,
+				NoiseKind:                    noise.LaplaceNoise,
+				Ranks:                        []float64{0.1, 0.5, 0.9},
+			}},
+		{"Gaussian noise kind", noise.GaussianNoise,
+			&boundedQuantilesFn{
+				EpsilonNoise:                 0.5,
+				EpsilonPartitionSelection:    0.5,
+				DeltaNoise:                   5e-6,
+				DeltaPartitionSelection:      5e-6,
+				MaxPartitionsContributed:     17,
+				MaxContributionsPerPartition: 5,
+				Lower:                        0,
+				Upper:                        10,
+				NoiseKind:                    noise.GaussianNoise,
+				Ranks:                        []float64{0.1, 0.5, 0.9},
+			}},
+	} {
+		got, err := newBoundedQuantilesFn(1, 1e-5, 17, 5, 0, 10, tc.noiseKind, []float64{0.1, 0.5, 0.9})
+		if err != nil {
+			t.Fatalf("newBoundedQuantilesFn: for %q got error %v", tc.desc, err)
+		}
+		if diff := cmp.Diff(tc.want, got, opts...); diff != "" {
+			t.Errorf("newBoundedQuantilesFn: for %q (-want +got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func TestNewBoundedQuantilesFnWithUnknownNoiseKindReturnsError(t *testing.T) {
+	if _, err := newBoundedQuantilesFn(1, 1e-5, 17, 5, 0, 10, noise.Kind(42), []float64{0.5}); err == nil {
+		t.Errorf("newBoundedQuantilesFn: with an unknown noise kind got no error, want error")
+	}
+}
+
+// Checks that TryQuantilesPerKey returns an error instead of exiting when the
+// parameters pass the checks of QuantilesPerKey but are invalid for
+// dpagg.BoundedQuantiles.
+func TestTryQuantilesPerKeyReturnsError(t *testing.T) {
+	triples := makeDummyTripleWithFloatValue(10, 0)
+	_, s, col := ptest.CreateList(triples)
+	col = beam.ParDo(s, extractIDFromTripleWithFloatValue, col)
+	pcol := MakePrivate(s, col, NewPrivacySpec(1, 1e-5))
+	pcol = ParDo(s, tripleWithFloatValueToKV, pcol)
+	// The L0 sensitivity of the quantile tree is MaxPartitionsContributed
+	// times the height of the tree, which overflows.
+	params := QuantilesParams{
+		MaxPartitionsContributed:     math.MaxInt64 / 2,
+		MaxContributionsPerPartition: 1,
+		MinValue:                     0,
+		MaxValue:                     1,
+		Ranks:                        []float64{0.5},
+	}
+	if _, err := TryQuantilesPerKey(s, pcol, params); err == nil {
+		t.Errorf("TryQuantilesPerKey: with an overflowing L0 sensitivity got no error, want error")
+	}
+}
+
+func TestBoundedQuantilesFnAddInputAndMergeAccumulators(t *testing.T) {
+	// δ=10⁻²³, ε=1e100 and l0Sensitivity=1 gives a threshold of =2.
+	// Since ε=1e100, the noise is added with probability in the order of exp(-1e100).
+	lower := 0.0
+	upper := 10.0
+	ranks := []float64{0.0, 0.25, 0.5, 0.75, 1.0}
+	// ε is split by 2 for noise and for partition selection, so we use 2*ε to get a Laplace noise with ε.
+	fn, err := newBoundedQuantilesFn(2*1e100, 1e-23, 1, 100, lower, upper, noise.LaplaceNoise, ranks)
+	if err != nil {
+		t.Fatalf("newBoundedQuantilesFn: got error %v", err)
+	}
+	fn.Setup()
+
+	accum1 := fn.CreateAccumulator()
+	accum2 := fn.CreateAccumulator()
+	for i := 0; i < 50; i++ {
+		fn.AddInput(accum1, []float64{float64(i) / 10})
+	}
+	fn.AddInput(accum2, []float64{5.0, 6.0, 7.0, 8.0, 9.0, 10.0})
+	fn.MergeAccumulators(accum1, accum2)
+
+	got := fn.ExtractOutput(accum1)
+	// The 56 values are 0.0, 0.1, …, 4.9 and 5.0, 6.0, …, 10.0, so e.g. the
+	// quantile of rank 0.25 is the 14th smallest value, 1.3.
+	want := []float64{0.0, 1.3, 2.7, 4.1, 10.0}
+	// Results are accurate up to the width of a leaf of the quantile tree.
+	tolerance := 2 * (upper - lower) / 65536
+	if !cmp.Equal(want, got, cmpopts.EquateApprox(0, tolerance)) {
+		t.Errorf("ExtractOutput: got %v, want %v", got, want)
+	}
+}
+
+func TestBoundedQuantilesFnExtractOutputReturnsNilForSmallPartitions(t *testing.T) {
+	for _, tc := range []struct {
+		desc              string
+		inputSize         int
+		datapointsPerUser int
+	}{
+		// It's a special case for partition selection in which the algorithm should always eliminate the partition.
+		{"Empty input", 0, 0},
+		{"Input with 1 user with 1 contribution", 1, 1},
+	} {
+		// The choice of ε=1e100, δ=10⁻²³, and l0Sensitivity=1 gives a threshold of =2.
+		// ε is split by 2 for noise and for partition selection, so we use 2*ε to get a Laplace noise with ε.
+		fn, err := newBoundedQuantilesFn(2*1e100, 1e-23, 1, 1, 0, 10, noise.LaplaceNoise, []float64{0.5})
+		if err != nil {
+			t.Fatalf("newBoundedQuantilesFn: got error %v", err)
+		}
+		fn.Setup()
+		accum := fn.CreateAccumulator()
+		for i := 0; i < tc.inputSize; i++ {
+			values := make([]float64, tc.datapointsPerUser)
+			for i := 0; i < tc.datapointsPerUser; i++ {
+				values[i] = 1.0
+			}
+			fn.AddInput(accum, values)
+		}
+
+		got := fn.ExtractOutput(accum)
+
+		// Should return nil output for small partitions.
+		if got != nil {
+			t.Errorf("ExtractOutput: for %s got: %v, want nil", tc.desc, got)
+		}
+	}
+}
+
+// medianFn extracts the first quantile of a per-key slice of quantiles.
+func medianFn(k int, quantiles []float64) (int, float64) {
+	return k, quantiles[0]
+}
+
+// Checks that QuantilesPerKey returns a correct answer with float values.
+func TestQuantilesPerKeyNoNoiseFloatValues(t *testing.T) {
+	triples := concatenateTriplesWithFloatValue(
+		makeTripleWithFloatValue(7, 0, 2.0),
+		makeTripleWithFloatValueStartingFromKey(7, 100, 1, 1.3),
+		makeTripleWithFloatValueStartingFromKey(107, 150, 1, 2.5))
+
+	// Partition 0 only has 7 privacy IDs and is dropped, partition 1 has 250
+	// values: 100 times 1.3 and 150 times 2.5.
+	result := []testFloat64Metric{
+		{1, 2.5},
+	}
+	p, s, col, want := ptest.CreateList2(triples, result)
+	col = beam.ParDo(s, extractIDFromTripleWithFloatValue, col)
+
+	// ε=50, δ=10⁻²⁰⁰ and l0Sensitivity=1 gives a threshold of =11.
+	epsilon := 50.0
+	delta := 1e-200
+	lower := 1.0
+	upper := 3.0
+
+	// ε is split by 2 for noise and for partition selection, so we use 2*ε to get a Laplace noise with ε.
+	pcol := MakePrivate(s, col, NewPrivacySpec(2*epsilon, delta))
+	pcol = ParDo(s, tripleWithFloatValueToKV, pcol)
+	got := QuantilesPerKey(s, pcol, QuantilesParams{
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		MinValue:                     lower,
+		MaxValue:                     upper,
+		NoiseKind:                    LaplaceNoise{},
+		Ranks:                        []float64{0.5},
+	})
+	got = beam.ParDo(s, medianFn, got)
+
+	want = beam.ParDo(s, float64MetricToKV, want)
+	// Results are accurate up to the width of a leaf of the quantile tree.
+	tolerance := 2 * (upper - lower) / 65536
+	if err := approxEqualsKVFloat64(s, got, want, tolerance); err != nil {
+		t.Fatalf("TestQuantilesPerKeyNoNoiseFloatValues: %v", err)
+	}
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestQuantilesPerKeyNoNoiseFloatValues: QuantilesPerKey(%v) = %v, want %v, error %v", col, got, want, err)
+	}
+}
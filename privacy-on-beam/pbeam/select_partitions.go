@@ -0,0 +1,235 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"fmt"
+	"reflect"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/dpagg"
+	"github.com/google/differential-privacy/privacy-on-beam/internal/kv"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/transforms/filter"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*selectPartitionFn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*dropValuesFn)(nil)))
+	beam.RegisterFunction(dropUnselectedPartitionsFn)
+}
+
+// SelectPartitionsParams specifies the parameters associated with a
+// SelectPartitions aggregation.
+type SelectPartitionsParams struct {
+	// Differential privacy budget consumed by this aggregation. If there is
+	// only one aggregation, both Epsilon and Delta can be left 0; in that
+	// case, the entire budget of the PrivacySpec is consumed.
+	Epsilon, Delta float64
+	// Relative weight of this aggregation when Epsilon and Delta are left 0
+	// and the PrivacySpec uses DeferredBudgetAllocation: the budget of the
+	// PrivacySpec is then split across aggregations proportionally to their
+	// weights.
+	//
+	// Defaults to 1.
+	BudgetWeight float64
+	// The maximum number of distinct partitions that a given privacy
+	// identifier can influence. If a privacy identifier is associated to more
+	// partitions, random partitions will be dropped. There is an inherent
+	// trade-off when choosing this parameter: a larger MaxPartitionsContributed
+	// leads to less data loss due to contribution bounding, but since the
+	// threshold used for partition selection grows with
+	// MaxPartitionsContributed, it also means that partitions need more
+	// distinct privacy identifiers to be selected.
+	//
+	// Required.
+	MaxPartitionsContributed int64
+}
+
+// SelectPartitions returns the partitions of a PrivatePCollection that can be
+// released in a differentially private way: the values of a
+// PrivatePCollection<V>, or the keys of a PrivatePCollection<K,V>. Partitions
+// with a low number of distinct privacy identifiers are dropped, using
+// dpagg.PreAggSelectPartition.
+//
+// The output is not private data anymore, so it can be used as the public
+// partitions of other aggregations on the same PrivatePCollection. Note that
+// these aggregations consume their own privacy budget.
+//
+// SelectPartitions transforms a PrivatePCollection<V> into a PCollection<V>,
+// or a PrivatePCollection<K,V> into a PCollection<K>.
+func SelectPartitions(s beam.Scope, pcol PrivatePCollection, params SelectPartitionsParams) beam.PCollection {
+	partitions, err := TrySelectPartitions(s, pcol, params)
+	if err != nil {
+		log.Exit(err)
+	}
+	return partitions
+}
+
+// TrySelectPartitions is the same as SelectPartitions, but returns an error
+// instead of exiting the program if the input or the parameters are invalid, or
+// if the privacy budget cannot be consumed. All parameters are checked before
+// the budget is consumed, except the budget (ε,δ) that the aggregation gets: if
+// ε or δ is invalid, the error is returned after the budget is consumed. With
+// DeferredBudgetAllocation, this check is done by AllocateBudget instead.
+func TrySelectPartitions(s beam.Scope, pcol PrivatePCollection, params SelectPartitionsParams) (beam.PCollection, error) {
+	s = s.Scope("pbeam.SelectPartitions")
+	// Obtain type information from the underlying PCollection<K,V>.
+	idT, partitionT := beam.ValidateKVType(pcol.col)
+	col := pcol.col
+	if partitionT.Type() == reflect.TypeOf(kv.Pair{}) {
+		if pcol.codec == nil {
+			return beam.PCollection{}, fmt.Errorf("SelectPartitions: no codec found for the input PrivatePCollection.")
+		}
+		// Only keep the keys of the <K,V> pairs.
+		col = beam.ParDo(s,
+			&dropValuesFn{Codec: pcol.codec},
+			col,
+			beam.TypeDefinition{Var: beam.VType, T: pcol.codec.KType.T})
+		_, partitionT = beam.ValidateKVType(col)
+	}
+
+	// Get privacy parameters.
+	spec := pcol.privacySpec
+	err := checkSelectPartitionsParams(params)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	maxPartitionsContributed, err := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	selectFn := new(selectPartitionFn)
+	err = spec.requestBudget(params.Epsilon, params.Delta, params.BudgetWeight, true, BudgetLedgerEntry{
+		Scope:                        s.String(),
+		MaxPartitionsContributed:     params.MaxPartitionsContributed,
+		MaxContributionsPerPartition: 1,
+	}, func(epsilon, delta float64) error {
+		err := checks.CheckEpsilon("pbeam.SelectPartitions", epsilon)
+		if err != nil {
+			return err
+		}
+		err = checks.CheckDeltaStrict("pbeam.SelectPartitions", delta)
+		if err != nil {
+			return err
+		}
+		*selectFn = *newSelectPartitionFn(epsilon, delta, params.MaxPartitionsContributed)
+		selectFn.NoiseSeed = spec.noiseSeed
+		return nil
+	})
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+
+	// First, deduplicate KV pairs by encoding them and calling Distinct.
+	coded := beam.ParDo(s, kv.NewEncodeFn(idT, partitionT), col)
+	distinct := filter.Distinct(s, coded)
+	decoded := beam.ParDo(s,
+		kv.NewDecodeFn(idT, partitionT),
+		distinct,
+		beam.TypeDefinition{Var: beam.TType, T: idT.Type()},
+		beam.TypeDefinition{Var: beam.VType, T: partitionT.Type()})
+	// Second, do contribution bounding.
+	decoded = boundContributions(s, decoded, maxPartitionsContributed)
+	// Third, now that KV pairs are deduplicated and contribution bounding is
+	// done, remove the keys and decide for each partition whether to keep it.
+	partitions := beam.DropKey(s, decoded)
+	dummyCounts := beam.ParDo(s, addOneValueFn, partitions)
+	selected := beam.CombinePerKey(s, selectFn, dummyCounts)
+	// Finally, drop the partitions that were not selected.
+	return beam.ParDo(s, dropUnselectedPartitionsFn, selected), nil
+}
+
+func checkSelectPartitionsParams(params SelectPartitionsParams) error {
+	return checks.CheckMaxPartitionsContributed("pbeam.SelectPartitions", params.MaxPartitionsContributed)
+}
+
+// dropValuesFn takes a PCollection<ID,kv.Pair{K,V}> as input, and returns a
+// PCollection<ID,K>, where K has been decoded.
+type dropValuesFn struct {
+	Codec *kv.Codec
+}
+
+func (fn *dropValuesFn) Setup() error {
+	return fn.Codec.Setup()
+}
+
+func (fn *dropValuesFn) ProcessElement(id beam.U, pair kv.Pair) (beam.U, beam.V) {
+	k, _ := fn.Codec.Decode(pair)
+	return id, k
+}
+
+// selectPartitionFn is a differentially private combineFn that decides whether
+// a partition can be released, based on its number of distinct privacy
+// identifiers.
+type selectPartitionFn struct {
+	// Privacy spec parameters (set during initial construction).
+	Epsilon                  float64
+	Delta                    float64
+	MaxPartitionsContributed int64
+	NoiseSeed                *int64 // Set by NoiseSeedForTesting, nil otherwise.
+}
+
+// newSelectPartitionFn returns a selectPartitionFn with the given budget and parameters.
+func newSelectPartitionFn(epsilon, delta float64, maxPartitionsContributed int64) *selectPartitionFn {
+	return &selectPartitionFn{
+		Epsilon:                  epsilon,
+		Delta:                    delta,
+		MaxPartitionsContributed: maxPartitionsContributed,
+	}
+}
+
+type selectPartitionAccum struct {
+	SP *dpagg.PreAggSelectPartition
+}
+
+func (fn *selectPartitionFn) CreateAccumulator() selectPartitionAccum {
+	src := seededSource(fn.NoiseSeed)
+	return selectPartitionAccum{SP: dpagg.NewPreAggSelectPartition(&dpagg.PreAggSelectPartitionOptions{
+		Epsilon:                  fn.Epsilon,
+		Delta:                    fn.Delta,
+		MaxPartitionsContributed: fn.MaxPartitionsContributed,
+		Source:                   src,
+	})}
+}
+
+// AddInput adds one privacy identifier to the partition. It ignores the
+// actual contents of value.
+func (fn *selectPartitionFn) AddInput(a selectPartitionAccum, value beam.X) selectPartitionAccum {
+	a.SP.Add()
+	return a
+}
+
+func (fn *selectPartitionFn) MergeAccumulators(a, b selectPartitionAccum) selectPartitionAccum {
+	a.SP.Merge(b.SP)
+	return a
+}
+
+func (fn *selectPartitionFn) ExtractOutput(a selectPartitionAccum) bool {
+	return a.SP.Result()
+}
+
+func (fn *selectPartitionFn) String() string {
+	return fmt.Sprintf("%#v", fn)
+}
+
+func dropUnselectedPartitionsFn(v beam.V, selected bool, emit func(beam.V)) {
+	if selected {
+		emit(v)
+	}
+}
@@ -0,0 +1,87 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+// Checks that SelectPartitions keeps the values with many privacy identifiers
+// and drops the values with few privacy identifiers.
+func TestSelectPartitionsNoNoise(t *testing.T) {
+	pairs := concatenatePairs(
+		makePairsWithFixedV(7, 0),
+		makePairsWithFixedV(52, 1),
+		makePairsWithFixedV(99, 2),
+		makePairsWithFixedV(7, 0)) // duplicated values should have no influence.
+	p, s, col := ptest.CreateList(pairs)
+	col = beam.ParDo(s, pairToKV, col)
+
+	// ε=50, δ=10⁻²⁰⁰ and l0Sensitivity=3 gives a threshold of 31: partitions
+	// with 52 or 99 privacy identifiers are always kept, and the probability of
+	// keeping a partition with 7 privacy identifiers is lower than 10⁻¹⁵⁰.
+	epsilon, delta := 50.0, 1e-200
+	pcol := MakePrivate(s, col, NewPrivacySpec(epsilon, delta))
+	got := SelectPartitions(s, pcol, SelectPartitionsParams{MaxPartitionsContributed: 3})
+	passert.Equals(s, got, 1, 2)
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestSelectPartitionsNoNoise: SelectPartitions(%v) = %v, expected [1, 2]: %v", col, got, err)
+	}
+}
+
+// Checks that SelectPartitions returns the keys of a PrivatePCollection<K,V>.
+func TestSelectPartitionsKVNoNoise(t *testing.T) {
+	triples := concatenateTriplesWithIntValue(
+		makeDummyTripleWithIntValue(7, 0),
+		makeDummyTripleWithIntValueStartingFromKey(7, 52, 1),
+		makeDummyTripleWithIntValueStartingFromKey(7+52, 99, 2))
+	p, s, col := ptest.CreateList(triples)
+	col = beam.ParDo(s, extractIDFromTripleWithIntValue, col)
+
+	// ε=50, δ=10⁻²⁰⁰ and l0Sensitivity=1 gives a threshold of 11.
+	epsilon, delta := 50.0, 1e-200
+	pcol := MakePrivate(s, col, NewPrivacySpec(epsilon, delta))
+	pcol = ParDo(s, tripleWithIntValueToKV, pcol)
+	got := SelectPartitions(s, pcol, SelectPartitionsParams{MaxPartitionsContributed: 1})
+	passert.Equals(s, got, 1, 2)
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestSelectPartitionsKVNoNoise: SelectPartitions(%v) = %v, expected [1, 2]: %v", col, got, err)
+	}
+}
+
+func TestTrySelectPartitionsReturnsError(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		delta  float64
+		params SelectPartitionsParams
+	}{
+		{"zero MaxPartitionsContributed", 1e-5, SelectPartitionsParams{}},
+		{"zero delta", 0, SelectPartitionsParams{MaxPartitionsContributed: 1}},
+	} {
+		pairs := makePairsWithFixedV(10, 0)
+		_, s, col := ptest.CreateList(pairs)
+		col = beam.ParDo(s, pairToKV, col)
+		pcol := MakePrivate(s, col, NewPrivacySpec(1, tc.delta))
+		if _, err := TrySelectPartitions(s, pcol, tc.params); err == nil {
+			t.Errorf("TrySelectPartitions: for %s got no error, want error", tc.desc)
+		}
+	}
+}
@@ -45,6 +45,13 @@ type SumParams struct {
 	// only one aggregation, both Epsilon and Delta can be left 0; in that
 	// case, the entire budget of the PrivacySpec is consumed.
 	Epsilon, Delta float64
+	// Relative weight of this aggregation when Epsilon and Delta are left 0
+	// and the PrivacySpec uses DeferredBudgetAllocation: the budget of the
+	// PrivacySpec is then split across aggregations proportionally to their
+	// weights.
+	//
+	// Defaults to 1.
+	BudgetWeight float64
 	// The maximum number of distinct values that a given privacy identifier
 	// can influence. There is an inherent trade-off when choosing this
 	// parameter: a larger MaxPartitionsContributed leads to less data loss due
@@ -65,8 +72,44 @@ type SumParams struct {
 	// large MaxValue means that less records will be clamped, but that more
 	// noise will be added.
 	//
-	// Required.
+	// Required, unless AutoBounds is set.
 	MinValue, MaxValue float64
+	// If true, MinValue and MaxValue must be left 0, and the bounds are
+	// determined from the data in a differentially private way, using part of
+	// the privacy budget of the aggregation: half of the budget that would be
+	// spent on noise is spent on finding the bounds with dpagg.ApproxBounds.
+	// This only works well for partitions with a large number of privacy
+	// identifiers; partitions for which no bounds can be found are dropped.
+	// Must be false when PublicPartitions is set.
+	AutoBounds bool
+	// How the partitions that appear in the output are selected: the
+	// strategy, and the fraction of the budget spent on it. Must be left
+	// unset when PublicPartitions is set.
+	//
+	// Optional.
+	PartitionSelection PartitionSelectionParams
+	// The partitions (i.e. keys of the PrivatePCollection) that should be
+	// present in the output, specified as a beam.PCollection<K> or a non-empty
+	// slice of K. Public partitions must not be derived from private data.
+	//
+	// When set, no privacy budget is spent on partition selection: each public
+	// partition appears in the output, with a noisy sum even if it has no
+	// data, and the keys that are not public partitions are dropped. With
+	// Laplace noise, Delta must then be 0.
+	//
+	// Optional.
+	PublicPartitions interface{}
+	// If set, each output value is an Int64WithConfidenceInterval (for integer
+	// values) or a Float64WithConfidenceInterval (for float values) instead,
+	// which contains the noisy sum and a confidence interval that contains the
+	// raw sum (i.e. after contribution bounding, but before noise is added)
+	// with probability at least ConfidenceLevel. The confidence interval is
+	// derived from the noisy sum and the parameters of the aggregation, so it
+	// doesn't consume any privacy budget. Must be strictly between 0 and 1
+	// when set.
+	//
+	// Optional.
+	ConfidenceLevel float64
 }
 
 // SumPerKey sums the values associated with each key in a
@@ -79,27 +122,36 @@ type SumParams struct {
 //
 // SumPerKey transforms a PrivatePCollection<K,V> either into a
 // PCollection<K,int64> or a PCollection<K,float64>, depending on whether its
-// input is an integer type or a float type.
+// input is an integer type or a float type. V can be any of int, int8, int16,
+// int32, int64, uint, uint8, uint16, uint32, uint64, float32 or float64: the
+// values are converted to int64 or float64 before aggregation, so they don't
+// need to be converted beforehand. If ConfidenceLevel is set, the output values
+// are Int64WithConfidenceInterval or Float64WithConfidenceInterval instead.
 func SumPerKey(s beam.Scope, pcol PrivatePCollection, params SumParams) beam.PCollection {
+	sums, err := TrySumPerKey(s, pcol, params)
+	if err != nil {
+		log.Exit(err)
+	}
+	return sums
+}
+
+// TrySumPerKey is the same as SumPerKey, but returns an error instead of
+// exiting the program if the input or the parameters are invalid, or if the
+// privacy budget cannot be consumed. All parameters are checked before the
+// budget is consumed, except those that depend on the budget (ε,δ) that the
+// aggregation gets: if ε or δ is invalid for the noise kind, or if the noise of
+// the aggregation can't be calibrated to them with the other parameters, the
+// error is returned after the budget is consumed. With
+// DeferredBudgetAllocation, these checks are done by AllocateBudget instead.
+func TrySumPerKey(s beam.Scope, pcol PrivatePCollection, params SumParams) (beam.PCollection, error) {
 	s = s.Scope("pbeam.SumPerKey")
 	// Obtain & validate type information from the underlying PCollection<K,V>.
 	idT, kvT := beam.ValidateKVType(pcol.col)
 	if kvT.Type() != reflect.TypeOf(kv.Pair{}) {
-		log.Exitf("SumPerKey must be used on a PrivatePCollection of type <K,V>, got type %v instead", kvT)
+		return beam.PCollection{}, fmt.Errorf("SumPerKey must be used on a PrivatePCollection of type <K,V>, got type %v instead", kvT)
 	}
 	if pcol.codec == nil {
-		log.Exitf("SumPerKey: no codec found for the input PrivatePCollection.")
-	}
-
-	// Get privacy parameters.
-	spec := pcol.privacySpec
-	epsilon, delta, err := spec.consumeBudget(params.Epsilon, params.Delta)
-	if err != nil {
-		log.Exitf("couldn't consume budget: %v", err)
-	}
-	err = checkSumPerKeyParams(params, epsilon, delta)
-	if err != nil {
-		log.Exit(err)
+		return beam.PCollection{}, fmt.Errorf("SumPerKey: no codec found for the input PrivatePCollection.")
 	}
 
 	var noiseKind noise.Kind
@@ -109,23 +161,92 @@ func SumPerKey(s beam.Scope, pcol PrivatePCollection, params SumParams) beam.PCo
 	} else {
 		noiseKind = params.NoiseKind.toNoiseKind()
 	}
-	maxPartitionsContributed := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
+	spec := pcol.privacySpec
+	err := checkSumPerKeyParams(params)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	maxPartitionsContributed, err := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	partitionT := pcol.codec.KType.T
+	col := pcol.col
+	usePublicPartitions := params.PublicPartitions != nil
+	if err := checkPublicPartitionsWindowing("pbeam.SumPerKey", pcol, usePublicPartitions); err != nil {
+		return beam.PCollection{}, err
+	}
+	var publicPartitions beam.PCollection
+	if usePublicPartitions {
+		publicPartitions, err = getPublicPartitions(s, params.PublicPartitions, partitionT)
+		if err != nil {
+			return beam.PCollection{}, err
+		}
+		// Drop the keys that are not public partitions before contribution
+		// bounding, so that they don't use up the contributions of a user.
+		col = dropNonPublicPartitionsKV(s, pcol, publicPartitions)
+	}
 	// First, group together the privacy ID and the partition ID, and sum the
 	// values per-user and per-partition.
 	decoded := beam.ParDo(s,
 		newPrepareSumFn(idT, pcol.codec),
-		pcol.col,
+		col,
 		beam.TypeDefinition{Var: beam.VType, T: pcol.codec.VType.T})
 	summed := stats.SumPerKey(s, decoded)
 	// Second, convert the sum to int64 or float64, and re-key.
 	_, sumT := beam.ValidateKVType(summed)
 	convertFn, err := findConvertFn(sumT)
 	if err != nil {
-		log.Exit(err)
+		return beam.PCollection{}, err
 	}
 	vKind, err := getKind(convertFn)
 	if err != nil {
-		log.Exit(err)
+		return beam.PCollection{}, err
+	}
+	// Get privacy parameters. This is done once vKind is known, since the type
+	// of the sum fn depends on it.
+	var sumFn interface{} = new(boundedSumFloat64Fn)
+	if vKind == reflect.Int64 {
+		sumFn = new(boundedSumInt64Fn)
+		// Integer bounds are checked before the budget is consumed.
+		if !params.AutoBounds {
+			if err := checks.CheckBoundsFloat64AsInt64("pbeam.SumPerKey", params.MinValue, params.MaxValue); err != nil {
+				return beam.PCollection{}, err
+			}
+		}
+	}
+	entry := BudgetLedgerEntry{
+		Scope:                    s.String(),
+		NoiseKind:                noiseKindName(noiseKind),
+		PublicPartitions:         usePublicPartitions,
+		MaxPartitionsContributed: params.MaxPartitionsContributed,
+		MinValue:                 params.MinValue,
+		MaxValue:                 params.MaxValue,
+		AutoBounds:               params.AutoBounds,
+	}
+	if !usePublicPartitions {
+		entry.PartitionSelectionEpsilonFraction, entry.PartitionSelectionDeltaFraction = partitionSelectionFractions(noiseKind, params.PartitionSelection)
+	}
+	err = spec.requestBudget(params.Epsilon, params.Delta, params.BudgetWeight, usesDelta(noiseKind, usePublicPartitions), entry, func(epsilon, delta float64) error {
+		err := checkEpsilonAndDelta("pbeam.SumPerKey", epsilon, delta, noiseKind, usePublicPartitions)
+		if err != nil {
+			return err
+		}
+		fn, err := newBoundedSumFn(epsilon, delta, params.MaxPartitionsContributed, params.MinValue, params.MaxValue, params.AutoBounds, noiseKind, vKind, params.PartitionSelection, usePublicPartitions)
+		if err != nil {
+			return err
+		}
+		setFn(sumFn, fn)
+		switch fn := sumFn.(type) {
+		case *boundedSumInt64Fn:
+			fn.NoiseSeed = spec.noiseSeed
+		case *boundedSumFloat64Fn:
+			fn.NoiseSeed = spec.noiseSeed
+		}
+		return nil
+	})
+	if err != nil {
+		return beam.PCollection{}, err
 	}
 	converted := beam.ParDo(s, convertFn, summed)
 	rekeyed := beam.ParDo(s, findRekeyFn(vKind), converted)
@@ -134,37 +255,56 @@ func SumPerKey(s beam.Scope, pcol PrivatePCollection, params SumParams) beam.PCo
 	// Fourth, now that contribution bounding is done, remove the privacy keys,
 	// decode the value, and do a DP sum with all the partial sums.
 	partialSumPairs := beam.DropKey(s, rekeyed)
-	partitionT := pcol.codec.KType.T
 	partialSumKV := beam.ParDo(s,
 		newDecodePairFn(partitionT, vKind),
 		partialSumPairs,
 		beam.TypeDefinition{Var: beam.XType, T: partitionT})
-	sums := beam.CombinePerKey(s,
-		newBoundedSumFn(epsilon, delta, maxPartitionsContributed, params.MinValue, params.MaxValue, noiseKind, vKind),
-		partialSumKV)
+	withConfidenceIntervals := params.ConfidenceLevel != 0
+	combineFn := sumFn
+	dropThresholdedPartitionsFn := findDropThresholdedPartitionsFn(vKind)
+	if withConfidenceIntervals {
+		combineFn, err = newSumWithConfidenceIntervalFn(sumFn, 1-params.ConfidenceLevel)
+		if err != nil {
+			return beam.PCollection{}, err
+		}
+		dropThresholdedPartitionsFn = findDropThresholdedPartitionsWithConfidenceIntervalFn(vKind)
+	}
+	sums := beam.CombinePerKey(s, combineFn, partialSumKV)
 	// Drop thresholded partitions.
-	sums = beam.ParDo(s, findDropThresholdedPartitionsFn(vKind), sums)
+	sums = beam.ParDo(s, dropThresholdedPartitionsFn, sums)
+	if usePublicPartitions {
+		// Add the public partitions that have no data.
+		fillFn, err := newFillPublicPartitionsSumFn(combineFn)
+		if err != nil {
+			return beam.PCollection{}, err
+		}
+		sums = fillPublicPartitions(s, sums, publicPartitions, fillFn)
+	}
 	// Clamp negative counts to zero when MinValue is non-negative.
-	if params.MinValue >= 0 {
-		sums = beam.ParDo(s, findClampNegativePartitionsFn(vKind), sums)
+	if !params.AutoBounds && params.MinValue >= 0 {
+		if withConfidenceIntervals {
+			sums = beam.ParDo(s, findClampNegativePartitionsWithConfidenceIntervalFn(vKind), sums)
+		} else {
+			sums = beam.ParDo(s, findClampNegativePartitionsFn(vKind), sums)
+		}
 	}
-	return sums
+	return sums, nil
 }
 
-func checkSumPerKeyParams(params SumParams, epsilon, delta float64) error {
-	err := checks.CheckEpsilon("pbeam.SumPerKey", epsilon)
+func checkSumPerKeyParams(params SumParams) error {
+	err := checkPartitionSelectionParams("pbeam.SumPerKey", params.PartitionSelection, params.PublicPartitions != nil)
 	if err != nil {
 		return err
 	}
-	err = checks.CheckDeltaStrict("pbeam.SumPerKey", delta)
+	err = checkBoundsOrAutoBounds("pbeam.SumPerKey", params.MinValue, params.MaxValue, params.AutoBounds, params.PublicPartitions != nil)
 	if err != nil {
 		return err
 	}
-	err = checks.CheckBoundsFloat64("pbeam.SumPerKey", params.MinValue, params.MaxValue)
+	err = checks.CheckMaxPartitionsContributed("pbeam.SumPerKey", params.MaxPartitionsContributed)
 	if err != nil {
 		return err
 	}
-	return checks.CheckMaxPartitionsContributed("pbeam.SumPerKey", params.MaxPartitionsContributed)
+	return checkConfidenceLevel("pbeam.SumPerKey", params.ConfidenceLevel)
 }
 
 // prepareSumFn takes a PCollection<ID,kv.Pair{K,V}> as input, and returns a
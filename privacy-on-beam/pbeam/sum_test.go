@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/google/differential-privacy/go/dpagg"
+	"github.com/google/differential-privacy/go/noise"
 	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
@@ -154,6 +155,88 @@ func TestSumPerKeyNegativeBoundsFloat(t *testing.T) {
 	}
 }
 
+// Checks that SumPerKey determines the bounds automatically when AutoBounds is set.
+func TestSumPerKeyAutoBoundsFloat(t *testing.T) {
+	triples := concatenateTriplesWithFloatValue(
+		makeTripleWithFloatValue(7, 0, 1.0),
+		makeTripleWithFloatValue(200, 1, 1.0))
+	result := []testFloat64Metric{
+		// Only 7 users are associated to value 0: should be thresholded.
+		{1, 200},
+	}
+	p, s, col, want := ptest.CreateList2(triples, result)
+	col = beam.ParDo(s, extractIDFromTripleWithFloatValue, col)
+
+	// ε is split by 2 for noise and for partition selection, and the noise
+	// budget is split by 2 between finding the bounds and the sum itself.
+	// The bounds are then [0.5, 1], so the l1Sensitivity of the sum is 1.
+	epsilon, delta, k, l1Sensitivity := 50.0, 1e-200, 25.0, 1.0
+	pcol := MakePrivate(s, col, NewPrivacySpec(epsilon, delta))
+	pcol = ParDo(s, tripleWithFloatValueToKV, pcol)
+	got := SumPerKey(s, pcol, SumParams{MaxPartitionsContributed: 1, AutoBounds: true, NoiseKind: LaplaceNoise{}})
+	want = beam.ParDo(s, float64MetricToKV, want)
+	if err := approxEqualsKVFloat64(s, got, want, laplaceTolerance(k, l1Sensitivity, epsilon/4)); err != nil {
+		t.Fatalf("TestSumPerKeyAutoBoundsFloat: %v", err)
+	}
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestSumPerKeyAutoBoundsFloat: SumPerKey(%v) = %v, expected %v: %v", col, got, want, err)
+	}
+}
+
+func TestCheckSumPerKeyParamsAutoBounds(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		params  SumParams
+		wantErr bool
+	}{
+		{"AutoBounds without bounds", SumParams{MaxPartitionsContributed: 1, AutoBounds: true}, false},
+		{"AutoBounds with bounds", SumParams{MaxPartitionsContributed: 1, MinValue: 0, MaxValue: 5, AutoBounds: true}, true},
+		{"no AutoBounds and invalid bounds", SumParams{MaxPartitionsContributed: 1, MinValue: 5, MaxValue: 0}, true},
+		{"AutoBounds with public partitions", SumParams{MaxPartitionsContributed: 1, AutoBounds: true, PublicPartitions: []int{0}}, true},
+	} {
+		if err := checkSumPerKeyParams(tc.params); (err != nil) != tc.wantErr {
+			t.Errorf("checkSumPerKeyParams: when %s got err %v, wantErr=%t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+// Checks that TrySumPerKey returns an error instead of exiting when the bounds
+// are valid float64 bounds but don't fit in an int64 for integer values, and
+// that it doesn't consume the privacy budget in this case.
+func TestTrySumPerKeyWithIntBoundsOverflowReturnsError(t *testing.T) {
+	triples := makeDummyTripleWithIntValue(10, 0)
+	_, s, col := ptest.CreateList(triples)
+	col = beam.ParDo(s, extractIDFromTripleWithIntValue, col)
+	pcol := MakePrivate(s, col, NewPrivacySpec(1, 1e-5))
+	pcol = ParDo(s, tripleWithIntValueToKV, pcol)
+	params := SumParams{MaxPartitionsContributed: 1, MinValue: 0, MaxValue: 1e30}
+	if _, err := TrySumPerKey(s, pcol, params); err == nil {
+		t.Errorf("TrySumPerKey: with MaxValue=1e30 for integer values got no error, want error")
+	}
+	params.MaxValue = 1
+	if _, err := TrySumPerKey(s, pcol, params); err != nil {
+		t.Errorf("TrySumPerKey: after a failure because of the bounds got error %v, want the entire budget to be available", err)
+	}
+}
+
+func TestCheckEpsilonAndDeltaPublicPartitions(t *testing.T) {
+	for _, tc := range []struct {
+		desc      string
+		noiseKind noise.Kind
+		delta     float64
+		wantErr   bool
+	}{
+		{"Laplace noise with zero delta", noise.LaplaceNoise, 0, false},
+		{"Laplace noise with positive delta", noise.LaplaceNoise, 1e-5, true},
+		{"Gaussian noise with positive delta", noise.GaussianNoise, 1e-5, false},
+		{"Gaussian noise with zero delta", noise.GaussianNoise, 0, true},
+	} {
+		if err := checkEpsilonAndDelta("pbeam.SumPerKey", 1, tc.delta, tc.noiseKind, true); (err != nil) != tc.wantErr {
+			t.Errorf("checkEpsilonAndDelta: when %s got err %v, wantErr=%t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
 // Checks that SumPerKey adds noise to its output with int values. The logic
 // mirrors TestDistinctPrivacyIDAddsNoise.
 func TestSumPerKeyAddsNoiseInt(t *testing.T) {
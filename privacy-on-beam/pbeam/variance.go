@@ -0,0 +1,343 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/dpagg"
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/privacy-on-beam/internal/kv"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*boundedVarianceFloat64Fn)(nil)))
+}
+
+// VarianceParams specifies the parameters associated with a Variance or a
+// StdDev aggregation.
+type VarianceParams struct {
+	// Noise type (which is either LaplaceNoise{} or GaussianNoise{}).
+	//
+	// Defaults to LaplaceNoise{}.
+	NoiseKind NoiseKind
+	// Differential privacy budget consumed by this aggregation. If there is
+	// only one aggregation, both Epsilon and Delta can be left 0; in that
+	// case, the entire budget of the PrivacySpec is consumed.
+	Epsilon, Delta float64
+	// Relative weight of this aggregation when Epsilon and Delta are left 0
+	// and the PrivacySpec uses DeferredBudgetAllocation: the budget of the
+	// PrivacySpec is then split across aggregations proportionally to their
+	// weights.
+	//
+	// Defaults to 1.
+	BudgetWeight float64
+	// The maximum number of distinct values that a given privacy identifier
+	// can influence. There is an inherent trade-off when choosing this
+	// parameter: a larger MaxPartitionsContributed leads to less data loss due
+	// to contribution bounding, but since the noise added in aggregations is
+	// scaled according to maxPartitionsContributed, it also means that more
+	// noise is added to each variance.
+	//
+	// Required.
+	MaxPartitionsContributed int64
+	// The maximum number of contributions from a given privacy identifier
+	// for each key. There is an inherent trade-off when choosing this
+	// parameter: a larger MaxContributionsPerPartition leads to less data loss due
+	// to contribution bounding, but since the noise added in aggregations is
+	// scaled according to maxContributionsPerPartition, it also means that more
+	// noise is added to each variance.
+	//
+	// Required.
+	MaxContributionsPerPartition int64
+	// The contributions of a given privacy identifier to a partition must be
+	// at least MinValue, and at most MaxValue; otherwise they will be clamped
+	// to these bounds. There is an inherent trade-off when choosing MinValue
+	// and MaxValue: a small MinValue and a large MaxValue means that less
+	// records will be clamped, but that more noise will be added.
+	//
+	// Required.
+	MinValue, MaxValue float64
+}
+
+// VariancePerKey obtains the variance of the values associated with each key
+// in a PrivatePCollection<K,V>, adding differentially private noise to the
+// variances and doing pre-aggregation thresholding to remove variances with a
+// low number of distinct privacy identifiers. Contributions are bounded the
+// same way as in MeanPerKey.
+//
+// Note: Do not use when your results may cause overflows for Int64 or Float64
+// values. This aggregation is not hardened for such applications yet.
+//
+// VariancePerKey transforms a PrivatePCollection<K,V> into a PCollection<K,float64>.
+func VariancePerKey(s beam.Scope, pcol PrivatePCollection, params VarianceParams) beam.PCollection {
+	variances, err := TryVariancePerKey(s, pcol, params)
+	if err != nil {
+		log.Exit(err)
+	}
+	return variances
+}
+
+// TryVariancePerKey is the same as VariancePerKey, but returns an error instead
+// of exiting the program if the input or the parameters are invalid, or if the
+// privacy budget cannot be consumed. All parameters are checked before the
+// budget is consumed, except those that depend on the budget (ε,δ) that the
+// aggregation gets: if ε or δ is invalid for the noise kind, or if the noise of
+// the aggregation can't be calibrated to them with the other parameters, the
+// error is returned after the budget is consumed. With
+// DeferredBudgetAllocation, these checks are done by AllocateBudget instead.
+func TryVariancePerKey(s beam.Scope, pcol PrivatePCollection, params VarianceParams) (beam.PCollection, error) {
+	return varianceOrStdDevPerKey(s.Scope("pbeam.VariancePerKey"), "VariancePerKey", pcol, params, false)
+}
+
+// StdDevPerKey obtains the standard deviation of the values associated with
+// each key in a PrivatePCollection<K,V>, adding differentially private noise
+// to the standard deviations and doing pre-aggregation thresholding to remove
+// standard deviations with a low number of distinct privacy identifiers.
+// Contributions are bounded the same way as in MeanPerKey.
+//
+// Note: Do not use when your results may cause overflows for Int64 or Float64
+// values. This aggregation is not hardened for such applications yet.
+//
+// StdDevPerKey transforms a PrivatePCollection<K,V> into a PCollection<K,float64>.
+func StdDevPerKey(s beam.Scope, pcol PrivatePCollection, params VarianceParams) beam.PCollection {
+	stdDevs, err := TryStdDevPerKey(s, pcol, params)
+	if err != nil {
+		log.Exit(err)
+	}
+	return stdDevs
+}
+
+// TryStdDevPerKey is the same as StdDevPerKey, but returns an error instead of
+// exiting the program if the input or the parameters are invalid, or if the
+// privacy budget cannot be consumed. All parameters are checked before the
+// budget is consumed, except those that depend on the budget (ε,δ) that the
+// aggregation gets: if ε or δ is invalid for the noise kind, or if the noise of
+// the aggregation can't be calibrated to them with the other parameters, the
+// error is returned after the budget is consumed. With
+// DeferredBudgetAllocation, these checks are done by AllocateBudget instead.
+func TryStdDevPerKey(s beam.Scope, pcol PrivatePCollection, params VarianceParams) (beam.PCollection, error) {
+	return varianceOrStdDevPerKey(s.Scope("pbeam.StdDevPerKey"), "StdDevPerKey", pcol, params, true)
+}
+
+// varianceOrStdDevPerKey implements TryVariancePerKey and TryStdDevPerKey. name is
+// used in error messages; if stdDev is true, it returns standard deviations
+// instead of variances.
+func varianceOrStdDevPerKey(s beam.Scope, name string, pcol PrivatePCollection, params VarianceParams, stdDev bool) (beam.PCollection, error) {
+	// Obtain & validate type information from the underlying PCollection<K,V>.
+	idT, kvT := beam.ValidateKVType(pcol.col)
+	if kvT.Type() != reflect.TypeOf(kv.Pair{}) {
+		return beam.PCollection{}, fmt.Errorf("%s must be used on a PrivatePCollection of type <K,V>, got type %v instead", name, kvT)
+	}
+	if pcol.codec == nil {
+		return beam.PCollection{}, fmt.Errorf("%s: no codec found for the input PrivatePCollection.", name)
+	}
+
+	var noiseKind noise.Kind
+	if params.NoiseKind == nil {
+		noiseKind = noise.LaplaceNoise
+		log.Infof("No NoiseKind specified, using Laplace Noise by default.")
+	} else {
+		noiseKind = params.NoiseKind.toNoiseKind()
+	}
+	// Get privacy parameters.
+	spec := pcol.privacySpec
+	err := checkVariancePerKeyParams("pbeam."+name, params)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	// Do contribution bounding. Result is PCollection<partition, []float64>.
+	maxContributionsPerPartition, err := getMaxContributionsPerPartition(params.MaxContributionsPerPartition)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	maxPartitionsContributed, err := getMaxPartitionsContributed(spec, params.MaxPartitionsContributed)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+	partialKV, err := boundFloat64ValuesPerPartition(s, pcol, idT, maxPartitionsContributed, maxContributionsPerPartition)
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+
+	varianceFn := new(boundedVarianceFloat64Fn)
+	entry := BudgetLedgerEntry{
+		Scope:                        s.String(),
+		NoiseKind:                    noiseKindName(noiseKind),
+		MaxPartitionsContributed:     params.MaxPartitionsContributed,
+		MaxContributionsPerPartition: params.MaxContributionsPerPartition,
+		MinValue:                     params.MinValue,
+		MaxValue:                     params.MaxValue,
+		MultipleNoises:               true,
+	}
+	entry.PartitionSelectionEpsilonFraction, entry.PartitionSelectionDeltaFraction = partitionSelectionFractions(noiseKind, PartitionSelectionParams{})
+	err = spec.requestBudget(params.Epsilon, params.Delta, params.BudgetWeight, true, entry, func(epsilon, delta float64) error {
+		err := checkEpsilonAndDelta("pbeam."+name, epsilon, delta, noiseKind, false)
+		if err != nil {
+			return err
+		}
+		fn, err := newBoundedVarianceFloat64Fn(epsilon, delta, params.MaxPartitionsContributed, params.MaxContributionsPerPartition, params.MinValue, params.MaxValue, noiseKind, stdDev)
+		if err != nil {
+			return err
+		}
+		*varianceFn = *fn
+		varianceFn.NoiseSeed = spec.noiseSeed
+		return nil
+	})
+	if err != nil {
+		return beam.PCollection{}, err
+	}
+
+	// Compute the variance or standard deviation for each partition. Result is PCollection<partition, float64>.
+	variances := beam.CombinePerKey(s, varianceFn, partialKV)
+	// Finally, drop thresholded partitions.
+	return beam.ParDo(s, dropThresholdedPartitionsFloat64Fn, variances), nil
+}
+
+func checkVariancePerKeyParams(label string, params VarianceParams) error {
+	err := checks.CheckBoundsFloat64(label, params.MinValue, params.MaxValue)
+	if err != nil {
+		return err
+	}
+	return checks.CheckMaxPartitionsContributed(label, params.MaxPartitionsContributed)
+}
+
+type boundedVarianceAccumFloat64 struct {
+	BV *dpagg.BoundedVarianceFloat64
+	SP *dpagg.PreAggSelectPartition
+}
+
+// boundedVarianceFloat64Fn is a differentially private combineFn for obtaining the variance
+// or the standard deviation of values. Do not initialize it yourself, use
+// newBoundedVarianceFloat64Fn to create a boundedVarianceFloat64Fn instance.
+type boundedVarianceFloat64Fn struct {
+	// Privacy spec parameters (set during initial construction).
+	EpsilonNoise                 float64
+	EpsilonPartitionSelection    float64
+	DeltaNoise                   float64
+	DeltaPartitionSelection      float64
+	MaxPartitionsContributed     int64
+	MaxContributionsPerPartition int64
+	Lower                        float64
+	Upper                        float64
+	NoiseKind                    noise.Kind
+	noise                        noise.Noise // Set during Setup phase according to NoiseKind.
+	NoiseSeed                    *int64      // Set by NoiseSeedForTesting, nil otherwise.
+	// Whether to output the standard deviation instead of the variance.
+	StdDev bool
+}
+
+// newBoundedVarianceFloat64Fn returns a boundedVarianceFloat64Fn with the given
+// budget and parameters, or an error if they are invalid.
+func newBoundedVarianceFloat64Fn(epsilon, delta float64, maxPartitionsContributed, maxContributionsPerPartition int64, lower, upper float64, noiseKind noise.Kind, stdDev bool) (*boundedVarianceFloat64Fn, error) {
+	fn := &boundedVarianceFloat64Fn{
+		MaxPartitionsContributed:     maxPartitionsContributed,
+		MaxContributionsPerPartition: maxContributionsPerPartition,
+		Lower:                        lower,
+		Upper:                        upper,
+		NoiseKind:                    noiseKind,
+		StdDev:                       stdDev,
+	}
+	fn.EpsilonNoise = epsilon / 2
+	fn.EpsilonPartitionSelection = epsilon / 2
+	switch noiseKind {
+	case noise.GaussianNoise:
+		fn.DeltaNoise = delta / 2
+		fn.DeltaPartitionSelection = delta / 2
+	case noise.LaplaceNoise:
+		fn.DeltaNoise = 0
+		fn.DeltaPartitionSelection = delta
+	default:
+		return nil, fmt.Errorf("newBoundedVarianceFloat64Fn: unknown noise.Kind (%v) is specified. Please specify a valid noise.", noiseKind)
+	}
+	// Check the parameters of the underlying dpagg.BoundedVarianceFloat64 now,
+	// since creating the accumulators would exit the program if they were
+	// invalid.
+	if _, err := dpagg.NewBoundedVarianceFloat64E(fn.boundedVarianceOptions(noise.ToNoise(noiseKind))); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// boundedVarianceOptions returns the options of the dpagg.BoundedVarianceFloat64
+// of an accumulator, which uses the noise n.
+func (fn *boundedVarianceFloat64Fn) boundedVarianceOptions(n noise.Noise) *dpagg.BoundedVarianceFloat64Options {
+	return &dpagg.BoundedVarianceFloat64Options{
+		Epsilon:                      fn.EpsilonNoise,
+		Delta:                        fn.DeltaNoise,
+		MaxPartitionsContributed:     fn.MaxPartitionsContributed,
+		MaxContributionsPerPartition: fn.MaxContributionsPerPartition,
+		Lower:                        fn.Lower,
+		Upper:                        fn.Upper,
+		Noise:                        n,
+	}
+}
+
+func (fn *boundedVarianceFloat64Fn) Setup() {
+	fn.noise = noise.ToNoise(fn.NoiseKind)
+}
+
+func (fn *boundedVarianceFloat64Fn) CreateAccumulator() boundedVarianceAccumFloat64 {
+	src := seededSource(fn.NoiseSeed)
+	n := noise.WithSource(fn.noise, src)
+	return boundedVarianceAccumFloat64{
+		BV: dpagg.NewBoundedVarianceFloat64(fn.boundedVarianceOptions(n)),
+		SP: dpagg.NewPreAggSelectPartition(&dpagg.PreAggSelectPartitionOptions{
+			Epsilon:                  fn.EpsilonPartitionSelection,
+			Delta:                    fn.DeltaPartitionSelection,
+			MaxPartitionsContributed: fn.MaxPartitionsContributed,
+			Source:                   src,
+		}),
+	}
+}
+
+func (fn *boundedVarianceFloat64Fn) AddInput(a boundedVarianceAccumFloat64, values []float64) boundedVarianceAccumFloat64 {
+	// We can have multiple values for each (privacy_key, partition_key) pair.
+	// We need to add each value to BoundedVariance as input but we need to add a single input
+	// for each privacy_key to SelectPartition.
+	for _, v := range values {
+		a.BV.Add(v)
+	}
+	a.SP.Add()
+	return a
+}
+
+func (fn *boundedVarianceFloat64Fn) MergeAccumulators(a, b boundedVarianceAccumFloat64) boundedVarianceAccumFloat64 {
+	a.BV.Merge(b.BV)
+	a.SP.Merge(b.SP)
+	return a
+}
+
+func (fn *boundedVarianceFloat64Fn) ExtractOutput(a boundedVarianceAccumFloat64) *float64 {
+	if a.SP.Result() {
+		result := a.BV.Result()
+		if fn.StdDev {
+			result = math.Sqrt(result)
+		}
+		return &result
+	}
+	return nil
+}
+
+func (fn *boundedVarianceFloat64Fn) String() string {
+	return fmt.Sprintf("%#v", fn)
+}
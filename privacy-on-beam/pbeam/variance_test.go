@@ -0,0 +1,198 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestNewBoundedVarianceFloat64Fn(t *testing.T) {
+	opts := []cmp.Option{
+		cmpopts.EquateApprox(0, 1e-10),
+		cmpopts.IgnoreUnexported(boundedVarianceFloat64Fn{}),
+	}
+	for _, tc := range []struct {
+		desc      string
+		noiseKind noise.Kind
+		want      interface{}
+	}{
+		{"Laplace noise kind", noise.LaplaceNoise,
+			&boundedVarianceFloat64Fn{
+				EpsilonNoise:                 0.5,
+				EpsilonPartitionSelection:    0.5,
+				DeltaNoise:                   0,
+				DeltaPartitionSelection:      1e-5,
+				MaxPartitionsContributed:     17,
+				MaxContributionsPerPartition: 5,
+				Lower:                        0,
+				Upper:                        10,
+				NoiseKind:                    noise.LaplaceNoise,
+			}},
+		{"Gaussian noise kind", noise.GaussianNoise,
+			&boundedVarianceFloat64Fn{
+				EpsilonNoise:                 0.5,
+				EpsilonPartitionSelection:    0.5,
+				DeltaNoise:                   5e-6,
+				DeltaPartitionSelection:      5e-6,
+				MaxPartitionsContributed:     17,
+				MaxContributionsPerPartition: 5,
+				Lower:                        0,
+				Upper:                        10,
+				NoiseKind:                    noise.GaussianNoise,
+			}},
+	} {
+		got, err := newBoundedVarianceFloat64Fn(1, 1e-5, 17, 5, 0, 10, tc.noiseKind, false)
+		if err != nil {
+			t.Fatalf("newBoundedVarianceFloat64Fn: for %q got error %v", tc.desc, err)
+		}
+		if diff := cmp.Diff(tc.want, got, opts...); diff != "" {
+			t.Errorf("newBoundedVarianceFloat64Fn: for %q (-want +got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func TestBoundedVarianceFloat64FnAddInputAndMergeAccumulators(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		stdDev bool
+		want   float64
+	}{
+		{"variance", false, 1.5},
+		{"standard deviation", true, math.Sqrt(1.5)},
+	} {
+		// δ=10⁻²³, ε=1e100 and l0Sensitivity=1 gives a threshold of =2.
+		// Since ε=1e100, the noise is added with probability in the order of exp(-1e100).
+		// ε is split by 2 for noise and for partition selection, so we use 2*ε to get a Laplace noise with ε.
+		fn, err := newBoundedVarianceFloat64Fn(2*1e100, 1e-23, 1, 2, 0, 5, noise.LaplaceNoise, tc.stdDev)
+		if err != nil {
+			t.Fatalf("newBoundedVarianceFloat64Fn: for %q got error %v", tc.desc, err)
+		}
+		fn.Setup()
+
+		accum1 := fn.CreateAccumulator()
+		fn.AddInput(accum1, []float64{1.0, 1.0})
+		accum2 := fn.CreateAccumulator()
+		fn.AddInput(accum2, []float64{2.0})
+		fn.AddInput(accum2, []float64{4.0})
+		fn.MergeAccumulators(accum1, accum2)
+
+		// The values are 1, 1, 2 and 4, whose mean is 2 and variance is 1.5.
+		got := fn.ExtractOutput(accum1)
+		if got == nil {
+			t.Fatalf("ExtractOutput: for %s got nil, want %f", tc.desc, tc.want)
+		}
+		if !cmp.Equal(tc.want, *got, cmpopts.EquateApprox(0, 1e-10)) {
+			t.Errorf("ExtractOutput: for %s got %f, want %f", tc.desc, *got, tc.want)
+		}
+	}
+}
+
+func TestBoundedVarianceFloat64FnExtractOutputReturnsNilForSmallPartitions(t *testing.T) {
+	for _, tc := range []struct {
+		desc              string
+		inputSize         int
+		datapointsPerUser int
+	}{
+		// It's a special case for partition selection in which the algorithm should always eliminate the partition.
+		{"Empty input", 0, 0},
+		{"Input with 1 user with 1 contribution", 1, 1},
+	} {
+		// The choice of ε=1e100, δ=10⁻²³, and l0Sensitivity=1 gives a threshold of =2.
+		// ε is split by 2 for noise and for partition selection, so we use 2*ε to get a Laplace noise with ε.
+		fn, err := newBoundedVarianceFloat64Fn(2*1e100, 1e-23, 1, 1, 0, 10, noise.LaplaceNoise, false)
+		if err != nil {
+			t.Fatalf("newBoundedVarianceFloat64Fn: got error %v", err)
+		}
+		fn.Setup()
+		accum := fn.CreateAccumulator()
+		for i := 0; i < tc.inputSize; i++ {
+			values := make([]float64, tc.datapointsPerUser)
+			for i := 0; i < tc.datapointsPerUser; i++ {
+				values[i] = 1.0
+			}
+			fn.AddInput(accum, values)
+		}
+
+		got := fn.ExtractOutput(accum)
+
+		// Should return nil output for small partitions.
+		if got != nil {
+			t.Errorf("ExtractOutput: for %s got: %f, want nil", tc.desc, *got)
+		}
+	}
+}
+
+// Checks that VariancePerKey and StdDevPerKey return a correct answer with float values.
+func TestVarianceAndStdDevPerKeyNoNoiseFloatValues(t *testing.T) {
+	// Partition 1 has 100 values equal to 1.3 and 150 values equal to 2.5: its
+	// mean is 2.02, and its variance is (100*1.3² + 150*2.5²)/250 - 2.02² = 0.3456.
+	exactVariance := 0.3456
+	for _, tc := range []struct {
+		desc   string
+		perKey func(beam.Scope, PrivatePCollection, VarianceParams) beam.PCollection
+		want   float64
+	}{
+		{"VariancePerKey", VariancePerKey, exactVariance},
+		{"StdDevPerKey", StdDevPerKey, math.Sqrt(exactVariance)},
+	} {
+		triples := concatenateTriplesWithFloatValue(
+			makeTripleWithFloatValue(7, 0, 2.0),
+			makeTripleWithFloatValueStartingFromKey(7, 100, 1, 1.3),
+			makeTripleWithFloatValueStartingFromKey(107, 150, 1, 2.5))
+		result := []testFloat64Metric{
+			{1, tc.want},
+		}
+		p, s, col, want := ptest.CreateList2(triples, result)
+		col = beam.ParDo(s, extractIDFromTripleWithFloatValue, col)
+
+		// ε=50, δ=10⁻²⁰⁰ and l0Sensitivity=1 gives a threshold of =11.
+		// The partition 0 has 7 privacy IDs and is dropped.
+		epsilon := 50.0
+		delta := 1e-200
+
+		// ε is split by 2 for noise and for partition selection, so we use 2*ε to get a Laplace noise with ε.
+		pcol := MakePrivate(s, col, NewPrivacySpec(2*epsilon, delta))
+		pcol = ParDo(s, tripleWithFloatValueToKV, pcol)
+		got := tc.perKey(s, pcol, VarianceParams{
+			MaxPartitionsContributed:     1,
+			MaxContributionsPerPartition: 1,
+			MinValue:                     1.0,
+			MaxValue:                     3.0,
+			NoiseKind:                    LaplaceNoise{},
+		})
+
+		want = beam.ParDo(s, float64MetricToKV, want)
+		// ε is split in three between the count, the sum and the sum of squares,
+		// so each of them gets Laplace noise with scale at most 1/(ε/3) = 0.06.
+		// With 250 values, the noise is below 4 with probability 1-10⁻²⁵ for each
+		// of them, which changes the variance by less than 0.1.
+		tolerance := 0.1
+		if err := approxEqualsKVFloat64(s, got, want, tolerance); err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if err := ptest.Run(p); err != nil {
+			t.Errorf("%s(%v) = %v, want %v, error %v", tc.desc, col, got, want, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"fmt"
+
+	log "github.com/golang/glog"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+)
+
+// WindowInto assigns the records of a PrivatePCollection to fixed windows,
+// according to their event timestamps. The timestamps must be set on the
+// PCollection given to MakePrivate, since pbeam.ParDo cannot change them.
+//
+// The aggregations applied to the returned PrivatePCollection are computed
+// independently for each window: contributions are bounded per window, and
+// each output PCollection contains one result per partition and per window,
+// in that window. See the "Windowed aggregations" section of the package
+// documentation for the resulting privacy guarantees.
+//
+// Only fixed windows are supported: with sliding windows, a record would
+// contribute to several windows, and session windows depend on the records of
+// each privacy unit. Only the default trigger is supported, so that each
+// aggregation outputs a single result per partition and window.
+func WindowInto(s beam.Scope, ws *window.Fn, pcol PrivatePCollection) PrivatePCollection {
+	s = s.Scope("pbeam.WindowInto")
+	if err := checkWindowFn(ws); err != nil {
+		log.Exit(err)
+	}
+	return PrivatePCollection{
+		col:         beam.WindowInto(s, ws, pcol.col),
+		codec:       pcol.codec,
+		privacySpec: pcol.privacySpec,
+		windowFn:    ws,
+	}
+}
+
+func checkWindowFn(ws *window.Fn) error {
+	if ws == nil {
+		return fmt.Errorf("pbeam.WindowInto: the window function must not be nil")
+	}
+	if ws.Kind != window.FixedWindows {
+		return fmt.Errorf("pbeam.WindowInto: only fixed windows are supported, got %v", ws)
+	}
+	if ws.Size <= 0 {
+		return fmt.Errorf("pbeam.WindowInto: the size of the windows must be positive, got %v", ws.Size)
+	}
+	return nil
+}
+
+// checkPublicPartitionsWindowing returns an error if an aggregation uses
+// public partitions on a windowed PrivatePCollection: public partitions are
+// given as a globally windowed PCollection, and the partitions without data
+// cannot be output in each window.
+func checkPublicPartitionsWindowing(label string, pcol PrivatePCollection, publicPartitions bool) error {
+	if publicPartitions && pcol.windowFn != nil {
+		return fmt.Errorf("%s: PublicPartitions are not supported on a PrivatePCollection assigned to windows by pbeam.WindowInto", label)
+	}
+	return nil
+}
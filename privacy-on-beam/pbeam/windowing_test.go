@@ -0,0 +1,149 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+func init() {
+	beam.RegisterFunction(addHourTimestampFn)
+	beam.RegisterFunction(partitionOfTripleWithIntValueFn)
+	beam.RegisterFunction(keyByHourFn)
+}
+
+// addHourTimestampFn sets the event time of a tripleWithIntValue to the
+// beginning of the hour given by its value.
+func addHourTimestampFn(t tripleWithIntValue, emit func(beam.EventTime, tripleWithIntValue)) {
+	emit(mtime.FromMilliseconds(int64(t.Value)*time.Hour.Milliseconds()), t)
+}
+
+// partitionOfTripleWithIntValueFn extracts the partition ID of a
+// tripleWithIntValue.
+func partitionOfTripleWithIntValueFn(t tripleWithIntValue) int {
+	return t.Partition
+}
+
+// keyByHourFn replaces the partition of a count, which is always 0 in the
+// tests below, by the hour of the window the count was computed in.
+func keyByHourFn(w beam.Window, _ int, count int64) (int, int64) {
+	return int(int64(w.MaxTimestamp()) / time.Hour.Milliseconds()), count
+}
+
+func TestCheckWindowFn(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		ws      *window.Fn
+		wantErr bool
+	}{
+		{"fixed windows", window.NewFixedWindows(time.Hour), false},
+		{"nil window function", nil, true},
+		{"global windows", window.NewGlobalWindows(), true},
+		{"sliding windows", window.NewSlidingWindows(time.Minute, time.Hour), true},
+		{"sessions", window.NewSessions(time.Hour), true},
+		{"empty fixed windows", window.NewFixedWindows(0), true},
+	} {
+		if err := checkWindowFn(tc.ws); (err != nil) != tc.wantErr {
+			t.Errorf("checkWindowFn: with %s got err=%v, wantErr=%t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+// Checks that ParDo keeps the windows of a PrivatePCollection.
+func TestParDoKeepsWindowFn(t *testing.T) {
+	_, s, col := ptest.CreateList(makeTripleWithIntValue(10, 0, 0))
+	col = beam.ParDo(s, addHourTimestampFn, col)
+	col = beam.ParDo(s, extractIDFromTripleWithIntValue, col)
+	pcol := MakePrivate(s, col, NewPrivacySpec(1, 1e-10))
+	ws := window.NewFixedWindows(time.Hour)
+	pcol = WindowInto(s, ws, pcol)
+	pcol = ParDo(s, partitionOfTripleWithIntValueFn, pcol)
+	if pcol.windowFn != ws {
+		t.Errorf("ParDo: got window function %v, want %v", pcol.windowFn, ws)
+	}
+}
+
+// Checks that Count on windowed input bounds contributions and outputs
+// results per window: each user contributes to partition 0 in two different
+// hours, and is counted once in each of them.
+func TestCountPerWindowNoNoise(t *testing.T) {
+	// Users 0 to 99 contribute once to partition 0 during hour 0, and twice
+	// during hour 1; users 100 to 149 only contribute during hour 1.
+	triples := concatenateTriplesWithIntValue(
+		makeTripleWithIntValue(100, 0, 0),
+		makeTripleWithIntValue(100, 0, 1),
+		makeTripleWithIntValue(100, 0, 1),
+		makeTripleWithIntValueStartingFromKey(100, 50, 0, 1),
+	)
+	result := []testInt64Metric{
+		{0, 100},
+		{1, 150},
+	}
+	p, s, col, want := ptest.CreateList2(triples, result)
+	col = beam.ParDo(s, addHourTimestampFn, col)
+	col = beam.ParDo(s, extractIDFromTripleWithIntValue, col)
+
+	// ε=50, δ=10⁻²⁰⁰ and l1Sensitivity=1 gives a threshold of ≈10.
+	// We have 2 windows with a single partition each. So, to get an overall
+	// flakiness of 10⁻²³, we need to have each partition pass with
+	// 1-10⁻²⁵ probability (k=25).
+	epsilon, delta, k, l1Sensitivity := 50.0, 1e-200, 25.0, 1.0
+	pcol := MakePrivate(s, col, NewPrivacySpec(epsilon, delta))
+	pcol = WindowInto(s, window.NewFixedWindows(time.Hour), pcol)
+	pcol = ParDo(s, partitionOfTripleWithIntValueFn, pcol)
+	counts := Count(s, pcol, CountParams{MaxValue: 1, MaxPartitionsContributed: 1, NoiseKind: LaplaceNoise{}})
+	got := beam.ParDo(s, keyByHourFn, counts)
+	got = beam.WindowInto(s, window.NewGlobalWindows(), got)
+	want = beam.ParDo(s, int64MetricToKV, want)
+	if err := approxEqualsKVInt64(s, got, want, laplaceTolerance(k, l1Sensitivity, epsilon)); err != nil {
+		t.Fatalf("TestCountPerWindowNoNoise: %v", err)
+	}
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("TestCountPerWindowNoNoise: Count(%v) = %v, expected %v: %v", col, got, want, err)
+	}
+}
+
+// Checks that aggregations on windowed input reject public partitions.
+func TestWindowedAggregationsWithPublicPartitionsReturnError(t *testing.T) {
+	_, s, col := ptest.CreateList(makeTripleWithIntValue(10, 0, 0))
+	col = beam.ParDo(s, addHourTimestampFn, col)
+	col = beam.ParDo(s, extractIDFromTripleWithIntValue, col)
+	publicPartitions := beam.CreateList(s, []int{0})
+	pcol := MakePrivate(s, col, NewPrivacySpec(1, 1e-10))
+	pcol = WindowInto(s, window.NewFixedWindows(time.Hour), pcol)
+	partitions := ParDo(s, partitionOfTripleWithIntValueFn, pcol)
+	kvs := ParDo(s, tripleWithIntValueToKV, pcol)
+
+	if _, err := TryCount(s, partitions, CountParams{Epsilon: 0.1, MaxValue: 1, MaxPartitionsContributed: 1, NoiseKind: LaplaceNoise{}, PublicPartitions: publicPartitions}); err == nil {
+		t.Errorf("TryCount: with public partitions on windowed input got no error, want error")
+	}
+	if _, err := TrySumPerKey(s, kvs, SumParams{Epsilon: 0.1, MinValue: 0, MaxValue: 1, MaxPartitionsContributed: 1, NoiseKind: LaplaceNoise{}, PublicPartitions: publicPartitions}); err == nil {
+		t.Errorf("TrySumPerKey: with public partitions on windowed input got no error, want error")
+	}
+	if _, err := TryMeanPerKey(s, kvs, MeanParams{Epsilon: 0.1, MinValue: 0, MaxValue: 1, MaxContributionsPerPartition: 1, MaxPartitionsContributed: 1, NoiseKind: LaplaceNoise{}, PublicPartitions: publicPartitions}); err == nil {
+		t.Errorf("TryMeanPerKey: with public partitions on windowed input got no error, want error")
+	}
+	if _, err := TryDistinctPrivacyID(s, partitions, DistinctPrivacyIDParams{Epsilon: 0.1, MaxPartitionsContributed: 1, NoiseKind: LaplaceNoise{}, PublicPartitions: publicPartitions}); err == nil {
+		t.Errorf("TryDistinctPrivacyID: with public partitions on windowed input got no error, want error")
+	}
+}
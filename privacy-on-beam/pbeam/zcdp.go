@@ -0,0 +1,113 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"fmt"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// ZCDPAccounting is a PrivacySpecOption that composes the budgets of
+// aggregations adding only Gaussian noise in zero-concentrated differential
+// privacy (zCDP), instead of adding their ε and δ.
+//
+// An aggregation adds only Gaussian noise if it uses GaussianNoise and public
+// partitions, without AutoBounds. Its budget (ε,δ) is converted to the ρ of
+// the corresponding Gaussian mechanism (see noise.RhoForGaussian), and the ρ
+// of all such aggregations are added. The (ε,δ) guarantee of their
+// composition is only computed from the total ρ, which is much tighter than
+// adding their ε and δ when there are many aggregations. The budgets of other
+// aggregations are added to this guarantee as usual.
+//
+// Consequently, the ε of the aggregations using the PrivacySpec can add up to
+// more than its total ε. ZCDPAccounting can't be used with
+// DeferredBudgetAllocation or PLDAccounting.
+type ZCDPAccounting struct{}
+
+func (ZCDPAccounting) updatePrivacySpec(ps *PrivacySpec) {
+	ps.zcdp = true
+}
+
+// NewPrivacySpecZCDP creates a new PrivacySpec using ZCDPAccounting, whose
+// budget is defined in zCDP: aggregations that only add Gaussian noise can
+// consume a total ρ of rho, and the PrivacySpec is (ε,δ)-differentially
+// private for the given delta. Aggregations that don't only add Gaussian noise
+// consume ε and δ from the equivalent (ε,δ) budget, which is
+// (noise.EpsilonForGaussianRho(rho, delta), delta).
+//
+// To give an aggregation a budget ρ_i, set its Epsilon to
+// noise.EpsilonForGaussianRho(ρ_i, δ_i) and its Delta to δ_i, for any δ_i in
+// (0, 1).
+func NewPrivacySpecZCDP(rho, delta float64, options ...PrivacySpecOption) *PrivacySpec {
+	options = append(options, ZCDPAccounting{})
+	return NewPrivacySpec(noise.EpsilonForGaussianRho(rho, delta), delta, options...)
+}
+
+// onlyGaussianNoise returns whether the aggregation of entry only adds
+// Gaussian noise, so that its budget can be composed in zCDP.
+func onlyGaussianNoise(entry BudgetLedgerEntry) bool {
+	return entry.NoiseKind == noiseKindName(noise.GaussianNoise) && entry.PublicPartitions && !entry.AutoBounds
+}
+
+// recordZCDPBudget records the consumption of a budget (ε,δ) in a PrivacySpec
+// using ZCDPAccounting, and fills in the ρ of entry.
+func (ps *PrivacySpec) recordZCDPBudget(epsilon, delta float64, entry *BudgetLedgerEntry) {
+	if onlyGaussianNoise(*entry) {
+		entry.Rho = noise.RhoForGaussian(epsilon, delta)
+		ps.gaussianRho += entry.Rho
+	} else {
+		ps.otherEpsilon += epsilon
+		ps.otherDelta += delta
+	}
+}
+
+// consumeZCDPBudget is the same as consumePartialBudget, for a PrivacySpec
+// using ZCDPAccounting. The remaining budget of ps is then the (ε,δ) that an
+// aggregation that doesn't only add Gaussian noise can still consume, if it
+// uses no δ for ε and no ε for δ.
+func (ps *PrivacySpec) consumeZCDPBudget(epsilon, delta float64, entry *BudgetLedgerEntry) (eps, del float64, err error) {
+	rho, otherEpsilon, otherDelta := ps.gaussianRho, ps.otherEpsilon, ps.otherDelta
+	ps.recordZCDPBudget(epsilon, delta, entry)
+	// The δ of the composition of the Gaussian aggregations in zCDP.
+	gaussianDelta := ps.totalDelta - ps.otherDelta
+	if gaussianDelta < 0 && gaussianDelta >= -ps.totalDelta/eqBudgetRelTol {
+		log.Infof("corrected rounding error for delta budget allocation (requested: %e, difference: %e)", delta, -gaussianDelta)
+		gaussianDelta = 0
+	}
+	remainingEpsilon := ps.totalEpsilon - ps.otherEpsilon
+	if ps.gaussianRho > 0 {
+		if gaussianDelta <= 0 {
+			remainingEpsilon = -1
+		} else {
+			remainingEpsilon -= noise.EpsilonForGaussianRho(ps.gaussianRho, gaussianDelta)
+		}
+	}
+	if remainingEpsilon < 0 && remainingEpsilon >= -ps.totalEpsilon/eqBudgetRelTol {
+		log.Infof("corrected rounding error for epsilon budget allocation (requested: %f, difference: %e)", epsilon, -remainingEpsilon)
+		remainingEpsilon = 0
+	}
+	if remainingEpsilon < 0 || gaussianDelta < 0 {
+		ps.gaussianRho, ps.otherEpsilon, ps.otherDelta = rho, otherEpsilon, otherDelta
+		return 0, 0, fmt.Errorf("not enough budget left for PrivacySpec with ZCDPAccounting: trying to consume epsilon=%f and delta=%e out of %+v", epsilon, delta, ps)
+	}
+	ps.epsilon = remainingEpsilon
+	ps.delta = gaussianDelta
+	ps.partiallyConsumed = true
+	return epsilon, delta, nil
+}
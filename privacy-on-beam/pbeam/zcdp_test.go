@@ -0,0 +1,89 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pbeam
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+)
+
+var gaussianPublicPartitionsEntry = BudgetLedgerEntry{
+	NoiseKind:        noiseKindName(noise.GaussianNoise),
+	PublicPartitions: true,
+}
+
+// Checks that with NewPrivacySpecZCDP, aggregations only adding Gaussian noise
+// can consume the entire ρ budget of the PrivacySpec, and no more.
+func TestNewPrivacySpecZCDP(t *testing.T) {
+	const numAggregations = 10
+	const rho, delta = 0.01, 1e-5
+	spec := NewPrivacySpecZCDP(rho, delta)
+	epsilon := noise.EpsilonForGaussianRho(rho/numAggregations, 1e-6)
+	for i := 0; i < numAggregations; i++ {
+		if _, _, err := spec.consumeBudget(epsilon, 1e-6, gaussianPublicPartitionsEntry); err != nil {
+			t.Fatalf("consumeBudget(%f, 1e-6) #%d: got error %v", epsilon, i, err)
+		}
+	}
+	ledger := spec.BudgetLedger()
+	if math.Abs(ledger.Rho-rho) > 1e-9 {
+		t.Errorf("BudgetLedger: got ρ=%f, want %f", ledger.Rho, rho)
+	}
+	// The aggregations got more ε than with naive composition.
+	if numAggregations*epsilon <= ledger.TotalEpsilon {
+		t.Errorf("consumeBudget: got ε=%f per aggregation, want more than %f", epsilon, ledger.TotalEpsilon/numAggregations)
+	}
+	if _, _, err := spec.consumeBudget(epsilon, 1e-6, gaussianPublicPartitionsEntry); err == nil {
+		t.Errorf("consumeBudget: got no error after the entire ρ budget was consumed")
+	}
+}
+
+// Checks that aggregations that don't only add Gaussian noise are composed
+// with basic composition.
+func TestZCDPAccountingOtherAggregations(t *testing.T) {
+	spec := NewPrivacySpec(1, 1e-5, ZCDPAccounting{})
+	laplace := BudgetLedgerEntry{NoiseKind: noiseKindName(noise.LaplaceNoise), PublicPartitions: true}
+	if _, _, err := spec.consumeBudget(0.5, 0, laplace); err != nil {
+		t.Fatalf("consumeBudget(0.5, 0) with Laplace noise: got error %v", err)
+	}
+	// Gaussian noise with private partitions also uses δ for partition
+	// selection, so it isn't composed in zCDP.
+	gaussianPrivatePartitions := BudgetLedgerEntry{NoiseKind: noiseKindName(noise.GaussianNoise)}
+	if _, _, err := spec.consumeBudget(0.2, 5e-6, gaussianPrivatePartitions); err != nil {
+		t.Fatalf("consumeBudget(0.2, 5e-6) with private partitions: got error %v", err)
+	}
+	ledger := spec.BudgetLedger()
+	if ledger.Rho != 0 || math.Abs(ledger.RemainingEpsilon-0.3) > 1e-9 || math.Abs(ledger.RemainingDelta-5e-6) > 1e-15 {
+		t.Errorf("BudgetLedger: got ρ=%f and remaining budget (%f, %e), want ρ=0 and (0.3, 5e-6)", ledger.Rho, ledger.RemainingEpsilon, ledger.RemainingDelta)
+	}
+	// The remaining budget is enough for a Gaussian aggregation with
+	// (0.3, 5e-6), but no other aggregation can then consume ε.
+	if _, _, err := spec.consumeBudget(0.3, 5e-6, gaussianPublicPartitionsEntry); err != nil {
+		t.Fatalf("consumeBudget(0.3, 5e-6) with only Gaussian noise: got error %v", err)
+	}
+	if _, _, err := spec.consumeBudget(0.01, 0, laplace); err == nil {
+		t.Errorf("consumeBudget(0.01, 0) with Laplace noise: got no error after the entire ε budget was consumed")
+	}
+}
+
+func TestZCDPAccountingWithDeferredBudgetAllocation(t *testing.T) {
+	spec := NewPrivacySpec(1, 1e-5, ZCDPAccounting{}, DeferredBudgetAllocation{})
+	if err := spec.AllocateBudget(); err == nil {
+		t.Errorf("AllocateBudget: got no error with ZCDPAccounting")
+	}
+}